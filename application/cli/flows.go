@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+)
+
+type FlowsCommand struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewFlowsCommand(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *FlowsCommand {
+	return &FlowsCommand{
+		logger: logger,
+		store:  store,
+	}
+}
+
+// Run aggregates VPC flow log files into the traffic observed between the assets of the last
+// fetched inventory, which replaces the traffic stored for the days the files cover
+//
+//	asset-relations flows [-format '${version} ${srcaddr} ...'] flow-logs/ more.log.gz
+func (f *FlowsCommand) Run(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("flows", flag.ContinueOnError)
+	format := flags.String("format", "", "custom format of files without a header line")
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(flags.Output(), "usage: asset-relations flows [-format '${version} ${srcaddr} ...'] path...")
+		return ExitError
+	}
+
+	inventory, found, err := f.store.GetInventory(ctx)
+	if err != nil {
+		f.logger.Error("Couldn't get inventory: " + err.Error())
+		return ExitError
+	}
+
+	if !found {
+		f.logger.Error("Nothing fetched yet, fetch the graph first")
+		return ExitError
+	}
+
+	traffic, err := aws.ObserveTraffic(inventory, flags.Args(), *format)
+	if err != nil {
+		f.logger.Error("Couldn't read flow logs: " + err.Error())
+		return ExitError
+	}
+
+	if err := f.store.StoreObservedTraffic(ctx, traffic); err != nil {
+		f.logger.Error("Couldn't store observed traffic: " + err.Error())
+		return ExitError
+	}
+
+	return ExitOk
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const loadUsage = `usage:
+  asset-relations load terraform-state terraform.tfstate
+  asset-relations load cloudformation template.yml [parameters.json]
+  asset-relations load aws-cli dumps/
+  asset-relations load aws-config [-at 2024-05-01T00:00:00Z] config/`
+
+type LoadCommand struct {
+	logger  *slog.Logger
+	builder *aws.RelationBuilder
+}
+
+func NewLoadCommand(logger *slog.Logger, builder *aws.RelationBuilder) *LoadCommand {
+	return &LoadCommand{
+		logger:  logger,
+		builder: builder,
+	}
+}
+
+// Run analyzes and stores an inventory loaded from files instead of fetched from AWS, which
+// then replaces the fetched one for the API
+func (l *LoadCommand) Run(ctx context.Context, args []string) int {
+	source, valid := l.source(args)
+	if !valid {
+		fmt.Fprintln(os.Stderr, loadUsage)
+		return ExitError
+	}
+
+	if err := l.builder.BuildFrom(ctx, source); err != nil {
+		l.logger.Error("Couldn't load " + source.Name() + ": " + err.Error())
+		return ExitError
+	}
+
+	return ExitOk
+}
+
+func (l *LoadCommand) source(args []string) (aws.InventorySource, bool) {
+	if len(args) < 2 {
+		return nil, false
+	}
+
+	switch {
+	case args[0] == "terraform-state" && len(args) == 2:
+		return aws.NewTerraformStateSource(args[1]), true
+	case args[0] == "cloudformation" && len(args) == 2:
+		return aws.NewCloudFormationSource(args[1], ""), true
+	case args[0] == "cloudformation" && len(args) == 3:
+		return aws.NewCloudFormationSource(args[1], args[2]), true
+	case args[0] == "aws-cli" && len(args) == 2:
+		return aws.NewAwsCliDumpSource(args[1], l.logger), true
+	case args[0] == "aws-config":
+		return l.awsConfigSource(args[1:])
+	default:
+		return nil, false
+	}
+}
+
+// awsConfigSource loads the latest state of the resources, or their state at the time given
+// with -at from their configuration history
+func (l *LoadCommand) awsConfigSource(args []string) (aws.InventorySource, bool) {
+	flags := flag.NewFlagSet("aws-config", flag.ContinueOnError)
+	at := flags.String("at", "", "time to load the state of resources at, in RFC 3339")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return nil, false
+	}
+
+	var atTime time.Time
+	if *at != "" {
+		var err error
+		if atTime, err = time.Parse(time.RFC3339, *at); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid -at time: "+err.Error())
+			return nil, false
+		}
+	}
+
+	return aws.NewAwsConfigSource(flags.Arg(0), atTime, l.logger), true
+}
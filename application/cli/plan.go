@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Exit codes of commands
+const (
+	ExitOk       = 0
+	ExitFindings = 1
+	ExitError    = 2
+)
+
+type PlanCommand struct {
+	logger   *slog.Logger
+	store    *neo4jstore.Neo4jDataStore
+	services aws.ServiceCatalog
+	networks aws.NetworkClassifier
+	out      io.Writer
+}
+
+func NewPlanCommand(logger *slog.Logger, store *neo4jstore.Neo4jDataStore, services aws.ServiceCatalog, networks aws.NetworkClassifier) *PlanCommand {
+	return &PlanCommand{
+		logger:   logger,
+		store:    store,
+		services: services,
+		networks: networks,
+		out:      os.Stdout,
+	}
+}
+
+// Run checks a Terraform plan, as output by terraform show -json, against the last fetched
+// inventory. It reports the findings the plan introduces and resolves, and fails when it
+// introduces findings of the -fail-on severity or above
+//
+//	asset-relations plan [-fail-on high] plan.json
+func (p *PlanCommand) Run(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	failOn := flags.String("fail-on", aws.SeverityHigh, "lowest severity of new findings failing the check")
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+
+	// Known severities are all at least info
+	if !aws.SeverityAtLeast(*failOn, aws.SeverityInfo) || flags.NArg() != 1 {
+		fmt.Fprintln(flags.Output(), "usage: asset-relations plan [-fail-on critical|high|medium|low|info] plan.json")
+		return ExitError
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		p.logger.Error("Couldn't read plan: " + err.Error())
+		return ExitError
+	}
+
+	plan, err := aws.ParseTerraformPlan(data)
+	if err != nil {
+		p.logger.Error("Couldn't parse plan: " + err.Error())
+		return ExitError
+	}
+
+	inventory, found, err := p.store.GetInventory(ctx)
+	if err != nil {
+		p.logger.Error("Couldn't get inventory: " + err.Error())
+		return ExitError
+	}
+
+	if !found {
+		p.logger.Error("Nothing fetched yet, fetch the graph first")
+		return ExitError
+	}
+
+	diff, err := aws.PlanFindings(inventory, plan, p.services, p.networks)
+	if err != nil {
+		p.logger.Error("Couldn't check plan: " + err.Error())
+		return ExitError
+	}
+
+	p.report(diff)
+
+	for _, finding := range diff.NewFindings {
+		if aws.SeverityAtLeast(finding.Severity, *failOn) {
+			return ExitFindings
+		}
+	}
+
+	return ExitOk
+}
+
+func (p *PlanCommand) report(diff aws.SimulationDiff) {
+	fmt.Fprintf(p.out, "%d new findings, %d resolved\n", len(diff.NewFindings), len(diff.ResolvedFindings))
+
+	for _, finding := range diff.NewFindings {
+		fmt.Fprintf(p.out, "+ [%s] %s\n", finding.Severity, finding.Title)
+		for _, detail := range finding.EvidenceDetails() {
+			fmt.Fprintf(p.out, "    %s\n", detail)
+		}
+	}
+
+	for _, finding := range diff.ResolvedFindings {
+		fmt.Fprintf(p.out, "- [%s] %s\n", finding.Severity, finding.Title)
+	}
+
+	for _, change := range diff.ExposureChanges {
+		fmt.Fprintf(p.out, "~ %s exposure %s -> %s\n", change.InstanceId, valueOr(change.Before, "none"), change.After)
+	}
+
+	for _, reach := range diff.NewlyReachable {
+		fmt.Fprintf(p.out, "~ %s can reach %s over %s %s\n", reach.From, reach.To, reach.Protocol, strings.Join(reach.Ports(), ", "))
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
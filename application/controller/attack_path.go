@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+const defaultAttackPaths = 3
+
+type AttackPathController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewAttackPathController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *AttackPathController {
+	return &AttackPathController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+// GetAttackPaths finds paths to the target instance, or to every critical instance when
+// target is empty or "critical"
+func (a *AttackPathController) GetAttackPaths(ctx context.Context, target, k string) JSONResponse {
+	if target == "critical" {
+		target = ""
+	}
+
+	if target != "" && !instanceIdValid(target) {
+		return jsonRes(400, []byte(`{"error": "invalid target, expected an instance id or critical"}`))
+	}
+
+	paths := defaultAttackPaths
+	if k != "" {
+		parsed, err := strconv.Atoi(k)
+		if err != nil || parsed < 1 {
+			return jsonRes(400, []byte(`{"error": "invalid k"}`))
+		}
+
+		paths = parsed
+	}
+
+	inventory, found, err := a.store.GetInventory(ctx)
+	if err != nil {
+		a.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	attackPaths, err := aws.FindAttackPaths(inventory, target, paths)
+	if err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		return jsonRes(404, msg)
+	}
+
+	data, err := json.Marshal(attackPaths)
+	if err != nil {
+		a.logger.Error("Couldn't convert attack paths to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+type DnsController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewDnsController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *DnsController {
+	return &DnsController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+func (d *DnsController) GetDanglingRecords(ctx context.Context, includeSuppressed bool) JSONResponse {
+	records, err := d.store.GetDanglingDnsRecords(ctx)
+	if err == nil {
+		records, err = applySuppressions(ctx, d.store, records, dnsRecordSubject, includeSuppressed)
+	}
+
+	if err != nil {
+		d.logger.Error("Couldn't get dangling DNS records: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		d.logger.Error("Couldn't convert DNS records to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
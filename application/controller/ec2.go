@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 )
@@ -15,18 +16,26 @@ type Ec2Controller struct {
 	logger          *slog.Logger
 	store           *neo4jstore.Neo4jDataStore
 	relationBuilder *aws.RelationBuilder
+	services        aws.ServiceCatalog
 }
 
-func NewEc2Controller(logger *slog.Logger, store *neo4jstore.Neo4jDataStore, relationBuilder *aws.RelationBuilder) *Ec2Controller {
+func NewEc2Controller(logger *slog.Logger, store *neo4jstore.Neo4jDataStore, relationBuilder *aws.RelationBuilder, services aws.ServiceCatalog) *Ec2Controller {
 	return &Ec2Controller{
 		logger:          logger,
 		store:           store,
 		relationBuilder: relationBuilder,
+		services:        services,
 	}
 }
 
-func (e *Ec2Controller) GetInstancesSSHOpen(ctx context.Context, partial bool) JSONResponse {
+func (e *Ec2Controller) GetInstancesSSHOpen(ctx context.Context, partial, includeSuppressed bool) JSONResponse {
 	instances, err := e.getInstancesSSHOpen(ctx, partial)
+	if err == nil {
+		// ssh is always in the catalog
+		ssh, _ := e.services.Lookup("ssh")
+		instances, err = applySuppressions(ctx, e.store, instances, instanceSubject(ssh), includeSuppressed)
+	}
+
 	if err != nil {
 		e.logger.Error("Couldn't get Instances open to internet: " + err.Error())
 		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
@@ -51,6 +60,71 @@ func (e *Ec2Controller) getInstancesSSHOpen(ctx context.Context, partial bool) (
 	return e.store.GetInstancesWithOpenSSH(ctx)
 }
 
+// GetInstances lists every instance, sorted by id or, with sort=risk, by risk score with the
+// factors adding to it under "riskBreakdown"
+func (e *Ec2Controller) GetInstances(ctx context.Context, sort string) JSONResponse {
+	if sort != "" && sort != "id" && sort != "risk" {
+		return jsonRes(400, []byte(`{"error": "invalid sort, expected id or risk"}`))
+	}
+
+	instances, err := e.store.GetInstances(ctx, sort == "risk")
+	if err != nil {
+		e.logger.Error("Couldn't get Instances: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(instances)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instances to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+// GetInstance returns the stored instance with the effective policy of all its security groups
+// under "effectivePolicy", and the rules other rules already cover under "redundantRules"
+func (e *Ec2Controller) GetInstance(ctx context.Context, instanceId string) JSONResponse {
+	if !instanceIdValid(instanceId) {
+		return jsonRes(400, []byte(`{"error": "invalid instance id"}`))
+	}
+
+	instance, err := e.store.GetInstance(ctx, instanceId)
+	if err != nil {
+		e.logger.Error("Couldn't get Instance: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if instance == nil {
+		return jsonRes(404, []byte(`{"error": "instance not found"}`))
+	}
+
+	inventory, _, err := e.store.GetInventory(ctx)
+	if err != nil {
+		e.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	for _, inst := range inventory.Instances {
+		if inst.Id == instanceId {
+			instance["effectivePolicy"], instance["redundantRules"] = aws.InstanceEffectivePolicy(inst)
+		}
+	}
+
+	data, err := json.Marshal(instance)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instance to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
 func (e *Ec2Controller) GetInstancesInSameVPC(ctx context.Context, instanceId string) JSONResponse {
 	if !instanceIdValid(instanceId) {
 		return jsonRes(400, []byte(`{"error": "invalid instance id"}`))
@@ -73,6 +147,127 @@ func (e *Ec2Controller) GetInstancesInSameVPC(ctx context.Context, instanceId st
 	return jsonRes(200, data)
 }
 
+var instanceExposures = []string{
+	aws.ExposureOpenToInternet,
+	aws.ExposureFrontDoor,
+	aws.ExposureFrontDoorWaf,
+	aws.ExposurePublic,
+	aws.ExposurePrivate,
+}
+
+// GetInstancesByExposure lists the instances with the exposure, suppressions match them with the
+// exposure as rule
+func (e *Ec2Controller) GetInstancesByExposure(ctx context.Context, exposure string, includeSuppressed bool) JSONResponse {
+	if !slices.Contains(instanceExposures, exposure) {
+		msg := []byte(fmt.Sprintf(`{"error": "invalid exposure, expected one of %s"}`, strings.Join(instanceExposures, ", ")))
+		return jsonRes(400, msg)
+	}
+
+	instances, err := e.store.GetInstancesByExposure(ctx, exposure)
+	if err == nil {
+		instances, err = applySuppressions(ctx, e.store, instances, instanceRuleSubject(exposure), includeSuppressed)
+	}
+
+	if err != nil {
+		e.logger.Error("Couldn't get Instances by exposure: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(instances)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instances to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (e *Ec2Controller) GetInstancesExposingService(ctx context.Context, service string, includeSuppressed bool) JSONResponse {
+	riskyService, known := e.services.Lookup(strings.ToLower(service))
+	if !known {
+		msg := []byte(fmt.Sprintf(`{"error": "unknown service, expected one of %s"}`, strings.Join(e.services.Names(), ", ")))
+		return jsonRes(400, msg)
+	}
+
+	instances, err := e.store.GetInstancesExposingService(ctx, strings.ToLower(service))
+	if err == nil {
+		instances, err = applySuppressions(ctx, e.store, instances, instanceSubject(riskyService), includeSuppressed)
+	}
+
+	if err != nil {
+		e.logger.Error("Couldn't get Instances exposing service: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(instances)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instances to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (e *Ec2Controller) GetInstancesWithRiskyAmis(ctx context.Context, includeSuppressed bool) JSONResponse {
+	instances, err := e.store.GetInstancesWithRiskyAmis(ctx)
+	if err == nil {
+		instances, err = applySuppressions(ctx, e.store, instances, riskyAmiSubject, includeSuppressed)
+	}
+
+	if err != nil {
+		e.logger.Error("Couldn't get Instances with risky AMIs: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(instances)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instances to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (e *Ec2Controller) GetReachableInstances(ctx context.Context, instanceId string) JSONResponse {
+	if !instanceIdValid(instanceId) {
+		return jsonRes(400, []byte(`{"error": "invalid instance id"}`))
+	}
+
+	return e.getReaches(ctx, e.store.GetReachableInstances, instanceId)
+}
+
+func (e *Ec2Controller) GetInstancesReachingFrom(ctx context.Context, instanceId string) JSONResponse {
+	if !instanceIdValid(instanceId) {
+		return jsonRes(400, []byte(`{"error": "invalid instance id"}`))
+	}
+
+	return e.getReaches(ctx, e.store.GetInstancesReachingFrom, instanceId)
+}
+
+func (e *Ec2Controller) getReaches(ctx context.Context, get func(context.Context, string) ([]map[string]any, error), instanceId string) JSONResponse {
+	instances, err := get(ctx, instanceId)
+	if err != nil {
+		e.logger.Error("Couldn't get reachable Instances: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(instances)
+	if err != nil {
+		e.logger.Error("Couldn't convert Instances to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
 func instanceIdValid(instanceId string) bool {
 	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/resource-ids.html
 	suffix, found := strings.CutPrefix(instanceId, "i-")
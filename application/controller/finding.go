@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+var findingStatuses = map[string]bool{"open": true, "resolved": true, "all": true}
+
+type FindingController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewFindingController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *FindingController {
+	return &FindingController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+// GetFindings returns the findings in the given status, without the suppressed ones unless
+// includeSuppressed
+func (f *FindingController) GetFindings(ctx context.Context, status string, includeSuppressed bool) JSONResponse {
+	if status == "" {
+		status = "open"
+	}
+
+	if !findingStatuses[status] {
+		return jsonRes(400, []byte(`{"error": "invalid status, use open, resolved or all"}`))
+	}
+
+	findings, err := f.store.GetFindings(ctx, status)
+	if err == nil {
+		findings, err = applySuppressions(ctx, f.store, findings, findingSubject, includeSuppressed)
+	}
+
+	if err != nil {
+		f.logger.Error("Couldn't get findings: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(findings)
+	if err != nil {
+		f.logger.Error("Couldn't convert findings to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (f *FindingController) GetSnapshotFindings(ctx context.Context, includeSuppressed bool) JSONResponse {
+	findings, err := f.store.GetSnapshotFindings(ctx)
+	if err == nil {
+		findings, err = applySuppressions(ctx, f.store, findings, findingSubject, includeSuppressed)
+	}
+
+	if err != nil {
+		f.logger.Error("Couldn't get snapshot findings: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(findings)
+	if err != nil {
+		f.logger.Error("Couldn't convert findings to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
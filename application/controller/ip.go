@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/netip"
+)
+
+type IpController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewIpController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *IpController {
+	return &IpController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+func (i *IpController) GetIpOwner(ctx context.Context, address string) JSONResponse {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return jsonRes(400, []byte(`{"error": "invalid ip address"}`))
+	}
+
+	ip, err := i.store.GetIpAddress(ctx, addr.String())
+	if err != nil {
+		i.logger.Error("Couldn't get ip address owner: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if ip == nil {
+		return jsonRes(404, []byte(`{"error": "ip address not owned by any known asset"}`))
+	}
+
+	data, err := json.Marshal(ip)
+	if err != nil {
+		i.logger.Error("Couldn't convert ip address to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
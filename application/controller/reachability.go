@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+type ReachabilityController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewReachabilityController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *ReachabilityController {
+	return &ReachabilityController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+func (r *ReachabilityController) GetReachability(ctx context.Context, from, port, protocol string) JSONResponse {
+	source, err := aws.ParseReachabilitySource(from)
+	if err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	portNumber, err := strconv.ParseInt(port, 10, 32)
+	if err != nil || portNumber < 0 || portNumber > 65535 {
+		return jsonRes(400, []byte(`{"error": "invalid port"}`))
+	}
+
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	inventory, found, err := r.store.GetInventory(ctx)
+	if err != nil {
+		r.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	verdicts, err := aws.AnalyzeReachability(inventory, source, protocol, int32(portNumber))
+	if err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "%s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	data, err := json.Marshal(verdicts)
+	if err != nil {
+		r.logger.Error("Couldn't convert reachability to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
@@ -1,10 +1,17 @@
 package controller
 
+// JSONResponse is the response of every endpoint. ContentType is only set for the ones that
+// aren't JSON, like CSV reports
 type JSONResponse struct {
-	Status  int
-	Content []byte
+	Status      int
+	Content     []byte
+	ContentType string
 }
 
 func jsonRes(status int, content []byte) JSONResponse {
-	return JSONResponse{status, content}
+	return JSONResponse{Status: status, Content: content}
+}
+
+func csvRes(status int, content []byte) JSONResponse {
+	return JSONResponse{Status: status, Content: content, ContentType: "text/csv"}
 }
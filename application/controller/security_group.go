@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+// Rules below this score, like a single port from a /24, aren't worth reporting
+const defaultPermissiveScore = 30
+
+// Traffic observed over the last month covers most periodic jobs, like monthly reports
+const defaultTrafficWindowDays = 30
+
+// Windows are limited to ten years, older flow logs are hardly ever kept
+const maxTrafficWindowDays = 3650
+
+type SecurityGroupController struct {
+	logger   *slog.Logger
+	store    *neo4jstore.Neo4jDataStore
+	networks aws.NetworkClassifier
+}
+
+func NewSecurityGroupController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore, networks aws.NetworkClassifier) *SecurityGroupController {
+	return &SecurityGroupController{
+		logger:   logger,
+		store:    store,
+		networks: networks,
+	}
+}
+
+func (s *SecurityGroupController) GetPermissiveSecurityGroups(ctx context.Context, minScore string, includeSuppressed bool) JSONResponse {
+	score := defaultPermissiveScore
+	if minScore != "" {
+		parsed, err := strconv.Atoi(minScore)
+		if err != nil || parsed < 0 || parsed > 100 {
+			return jsonRes(400, []byte(`{"error": "invalid min_score, expected a number between 0 and 100"}`))
+		}
+
+		score = parsed
+	}
+
+	inventory, found, err := s.store.GetInventory(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	groups, err := applyReportSuppressions(ctx, s.store, aws.ScorePermissiveRules(inventory, s.networks, score), func(group aws.SecurityGroupPermissiveness) aws.SuppressionSubject {
+		return aws.SuppressionSubject{Rule: aws.ReportPermissiveRule, AssetId: group.GroupId, InstanceIds: group.InstanceIds}
+	}, includeSuppressed)
+	if err != nil {
+		s.logger.Error("Couldn't apply suppressions: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(groups)
+	if err != nil {
+		s.logger.Error("Couldn't convert security groups to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (s *SecurityGroupController) GetRedundantRules(ctx context.Context, includeSuppressed bool) JSONResponse {
+	inventory, found, err := s.store.GetInventory(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	groups, err := applyReportSuppressions(ctx, s.store, aws.FindRedundantRules(inventory), func(group aws.SecurityGroupRedundancy) aws.SuppressionSubject {
+		return aws.SuppressionSubject{Rule: aws.ReportRedundantRule, AssetId: group.GroupId}
+	}, includeSuppressed)
+	if err != nil {
+		s.logger.Error("Couldn't apply suppressions: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(groups)
+	if err != nil {
+		s.logger.Error("Couldn't convert redundant rules to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+// GetLeastPrivilegeRecommendations compares the rules of every security group, or of groupId
+// only, with the traffic observed over the last windowDays
+func (s *SecurityGroupController) GetLeastPrivilegeRecommendations(ctx context.Context, windowDays, groupId string) JSONResponse {
+	days := defaultTrafficWindowDays
+	if windowDays != "" {
+		parsed, err := strconv.Atoi(windowDays)
+		if err != nil || parsed <= 0 || parsed > maxTrafficWindowDays {
+			return jsonRes(400, []byte(`{"error": "invalid window_days, expected a number from 1 to 3650"}`))
+		}
+
+		days = parsed
+	}
+
+	inventory, found, err := s.store.GetInventory(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	traffic, err := s.store.GetObservedTraffic(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get observed traffic: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if len(traffic) == 0 {
+		return jsonRes(404, []byte(`{"error": "no traffic observed yet, load flow logs first"}`))
+	}
+
+	recommendations := aws.RecommendLeastPrivilege(inventory, traffic, days, s.networks)
+	if groupId != "" {
+		recommendations = slices.DeleteFunc(recommendations, func(r aws.SecurityGroupRecommendations) bool {
+			return r.GroupId != groupId
+		})
+	}
+
+	data, err := json.Marshal(recommendations)
+	if err != nil {
+		s.logger.Error("Couldn't convert recommendations to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+// GetSecurityGroupIssues returns the cleanup report as JSON, or as CSV when format is csv.
+// Suppressed issues are left out of CSV reports, which can't flag them
+func (s *SecurityGroupController) GetSecurityGroupIssues(ctx context.Context, format string, includeSuppressed bool) JSONResponse {
+	if format != "" && format != "json" && format != "csv" {
+		return jsonRes(400, []byte(`{"error": "invalid format, expected json or csv"}`))
+	}
+
+	inventory, found, err := s.store.GetInventory(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	issues := aws.FindSecurityGroupIssues(inventory)
+	if format == "csv" {
+		if !includeSuppressed {
+			if issues, err = s.unsuppressedIssues(ctx, issues); err != nil {
+				s.logger.Error("Couldn't apply suppressions: " + err.Error())
+				msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+				return jsonRes(500, msg)
+			}
+		}
+
+		return s.issuesCsv(issues)
+	}
+
+	reported, err := applyReportSuppressions(ctx, s.store, issues, issueSubject, includeSuppressed)
+	if err != nil {
+		s.logger.Error("Couldn't apply suppressions: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(reported)
+	if err != nil {
+		s.logger.Error("Couldn't convert security group issues to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+// issueSubject matches a cleanup issue by its group, with the issue as rule
+func issueSubject(issue aws.SecurityGroupIssue) aws.SuppressionSubject {
+	return aws.SuppressionSubject{Rule: issue.Issue, AssetId: issue.GroupId}
+}
+
+func (s *SecurityGroupController) unsuppressedIssues(ctx context.Context, issues []aws.SecurityGroupIssue) ([]aws.SecurityGroupIssue, error) {
+	subjects := make([]aws.SuppressionSubject, 0, len(issues))
+	for _, issue := range issues {
+		subjects = append(subjects, issueSubject(issue))
+	}
+
+	matched, err := matchSuppressions(ctx, s.store, subjects)
+	if err != nil || matched == nil {
+		return issues, err
+	}
+
+	kept := make([]aws.SecurityGroupIssue, 0, len(issues))
+	for idx, issue := range issues {
+		if matched[idx] == nil {
+			kept = append(kept, issue)
+		}
+	}
+
+	return kept, nil
+}
+
+func (s *SecurityGroupController) issuesCsv(issues []aws.SecurityGroupIssue) JSONResponse {
+	buf := bytes.Buffer{}
+	writer := csv.NewWriter(&buf)
+
+	rows := make([][]string, 0, len(issues)+1)
+	rows = append(rows, []string{"group_id", "group_name", "vpc_id", "issue", "detail"})
+	for _, issue := range issues {
+		rows = append(rows, []string{issue.GroupId, issue.GroupName, issue.VPC, issue.Issue, issue.Detail})
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		s.logger.Error("Couldn't convert security group issues to csv: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return csvRes(200, buf.Bytes())
+}
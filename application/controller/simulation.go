@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+type SimulationController struct {
+	logger   *slog.Logger
+	store    *neo4jstore.Neo4jDataStore
+	services aws.ServiceCatalog
+	networks aws.NetworkClassifier
+}
+
+func NewSimulationController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore, services aws.ServiceCatalog, networks aws.NetworkClassifier) *SimulationController {
+	return &SimulationController{
+		logger:   logger,
+		store:    store,
+		services: services,
+		networks: networks,
+	}
+}
+
+// Simulate runs the proposed changes in the body against a copy of the last fetched inventory,
+// nothing is stored
+func (s *SimulationController) Simulate(ctx context.Context, body []byte) JSONResponse {
+	var changes []aws.SimulationChange
+	if err := json.Unmarshal(body, &changes); err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "invalid changes: %s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	if len(changes) == 0 {
+		return jsonRes(400, []byte(`{"error": "no changes to simulate"}`))
+	}
+
+	inventory, found, err := s.store.GetInventory(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get inventory: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !found {
+		return jsonRes(404, []byte(`{"error": "nothing fetched yet, fetch the graph first"}`))
+	}
+
+	diff, err := aws.Simulate(inventory, changes, s.services, s.networks)
+	if err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "invalid changes: %s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		s.logger.Error("Couldn't convert simulation to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/core/neo4jstore"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type SuppressionController struct {
+	logger *slog.Logger
+	store  *neo4jstore.Neo4jDataStore
+}
+
+func NewSuppressionController(logger *slog.Logger, store *neo4jstore.Neo4jDataStore) *SuppressionController {
+	return &SuppressionController{
+		logger: logger,
+		store:  store,
+	}
+}
+
+// CreateSuppression stores the suppression in the body. Its id and creation date are set here
+func (s *SuppressionController) CreateSuppression(ctx context.Context, body []byte) JSONResponse {
+	var suppression aws.Suppression
+	if err := json.Unmarshal(body, &suppression); err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "invalid suppression: %s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	now := time.Now().UTC()
+	if err := suppression.Validate(now); err != nil {
+		msg := []byte(fmt.Sprintf(`{"error": "invalid suppression: %s"}`, err.Error()))
+		return jsonRes(400, msg)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		s.logger.Error("Couldn't generate suppression id: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	suppression.Id = hex.EncodeToString(id)
+	suppression.CreatedAt = now
+
+	if err := s.store.StoreSuppression(ctx, suppression); err != nil {
+		s.logger.Error("Couldn't store suppression: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	data, err := json.Marshal(suppression)
+	if err != nil {
+		s.logger.Error("Couldn't convert suppression to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(201, data)
+}
+
+// suppressionView is a suppression as listed, with whether it still applies
+type suppressionView struct {
+	aws.Suppression
+	Expired bool
+}
+
+func (s *SuppressionController) GetSuppressions(ctx context.Context) JSONResponse {
+	suppressions, err := s.store.GetSuppressions(ctx)
+	if err != nil {
+		s.logger.Error("Couldn't get suppressions: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	now := time.Now()
+	views := make([]suppressionView, 0, len(suppressions))
+	for _, suppression := range suppressions {
+		views = append(views, suppressionView{Suppression: suppression, Expired: !suppression.Active(now)})
+	}
+
+	data, err := json.Marshal(views)
+	if err != nil {
+		s.logger.Error("Couldn't convert suppressions to json: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	return jsonRes(200, data)
+}
+
+func (s *SuppressionController) DeleteSuppression(ctx context.Context, id string) JSONResponse {
+	deleted, err := s.store.DeleteSuppression(ctx, id)
+	if err != nil {
+		s.logger.Error("Couldn't delete suppression: " + err.Error())
+		msg := []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		return jsonRes(500, msg)
+	}
+
+	if !deleted {
+		return jsonRes(404, []byte(`{"error": "suppression not found"}`))
+	}
+
+	return jsonRes(200, []byte(`{"status": "deleted"}`))
+}
+
+// applySuppressions drops the items an active suppression matches. With includeSuppressed they
+// are kept instead, flagged under "suppressed" with the suppression under "suppression"
+func applySuppressions(ctx context.Context, store *neo4jstore.Neo4jDataStore, items []map[string]any, subject func(map[string]any) aws.SuppressionSubject, includeSuppressed bool) ([]map[string]any, error) {
+	subjects := make([]aws.SuppressionSubject, 0, len(items))
+	for _, item := range items {
+		subjects = append(subjects, subject(item))
+	}
+
+	return suppressItems(ctx, store, items, subjects, includeSuppressed)
+}
+
+// applyReportSuppressions applies suppressions to the items of a report computed from the
+// inventory, converted to maps so they are flagged like the stored ones
+func applyReportSuppressions[T any](ctx context.Context, store *neo4jstore.Neo4jDataStore, items []T, subject func(T) aws.SuppressionSubject, includeSuppressed bool) ([]map[string]any, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]map[string]any, 0, len(items))
+	if err := json.Unmarshal(data, &converted); err != nil {
+		return nil, err
+	}
+
+	subjects := make([]aws.SuppressionSubject, 0, len(items))
+	for _, item := range items {
+		subjects = append(subjects, subject(item))
+	}
+
+	return suppressItems(ctx, store, converted, subjects, includeSuppressed)
+}
+
+func suppressItems(ctx context.Context, store *neo4jstore.Neo4jDataStore, items []map[string]any, subjects []aws.SuppressionSubject, includeSuppressed bool) ([]map[string]any, error) {
+	matched, err := matchSuppressions(ctx, store, subjects)
+	if err != nil || matched == nil {
+		return items, err
+	}
+
+	kept := make([]map[string]any, 0, len(items))
+	for idx, item := range items {
+		suppression := matched[idx]
+		if suppression != nil && !includeSuppressed {
+			continue
+		}
+
+		if includeSuppressed {
+			item["suppressed"] = suppression != nil
+		}
+
+		if suppression != nil {
+			item["suppression"] = *suppression
+		}
+
+		kept = append(kept, item)
+	}
+
+	return kept, nil
+}
+
+// matchSuppressions returns the active suppression matching every subject, nil for the ones
+// none matches, or nil when there are no suppressions at all
+func matchSuppressions(ctx context.Context, store *neo4jstore.Neo4jDataStore, subjects []aws.SuppressionSubject) ([]*aws.Suppression, error) {
+	suppressions, err := store.GetSuppressions(ctx)
+	if err != nil || len(suppressions) == 0 {
+		return nil, err
+	}
+
+	// Tags and security groups are matched on the instances of the last fetch
+	inventory, _, err := store.GetInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	index := aws.NewSuppressions(suppressions, inventory, time.Now())
+	matched := make([]*aws.Suppression, 0, len(subjects))
+	for _, subject := range subjects {
+		if suppression, suppressed := index.Match(subject); suppressed {
+			matched = append(matched, &suppression)
+			continue
+		}
+
+		matched = append(matched, nil)
+	}
+
+	return matched, nil
+}
+
+// findingSubject matches a stored finding by its type, asset and instances
+func findingSubject(finding map[string]any) aws.SuppressionSubject {
+	subject := aws.SuppressionSubject{}
+	subject.Rule, _ = finding["type"].(string)
+	subject.AssetId, _ = finding["assetId"].(string)
+
+	instances, _ := finding["instances"].([]any)
+	for _, instance := range instances {
+		if instanceId, isString := instance.(string); isString {
+			subject.InstanceIds = append(subject.InstanceIds, instanceId)
+		}
+	}
+
+	return subject
+}
+
+// instanceSubject matches an instance listed as exposing a service, with the finding it's
+// raised as: open to the internet, or to untrusted ranges
+func instanceSubject(service aws.RiskyService) func(map[string]any) aws.SuppressionSubject {
+	return func(instance map[string]any) aws.SuppressionSubject {
+		subject := instanceRuleSubject(service.UntrustedFindingType())(instance)
+
+		exposure, _ := instance["exposure"].(map[string]any)
+		if openToInternet, _ := exposure["openToInternet"].(bool); openToInternet {
+			subject.Rule = service.FindingType()
+		}
+
+		return subject
+	}
+}
+
+// instanceRuleSubject matches an instance listed by an endpoint under rule. Suppressions of
+// its Auto Scaling group match it too
+func instanceRuleSubject(rule string) func(map[string]any) aws.SuppressionSubject {
+	return func(instance map[string]any) aws.SuppressionSubject {
+		instanceId, _ := instance["id"].(string)
+		return aws.SuppressionSubject{
+			Rule:        rule,
+			AssetId:     instanceId,
+			InstanceIds: []string{instanceId},
+		}
+	}
+}
+
+// riskyAmiSubject matches an instance running a risky image by the image, as the image is what
+// a suppression accepts
+func riskyAmiSubject(instance map[string]any) aws.SuppressionSubject {
+	subject := instanceRuleSubject(aws.ReportRiskyAmi)(instance)
+
+	ami, _ := instance["ami"].(map[string]any)
+	subject.AssetId, _ = ami["id"].(string)
+	if sharedPublicly, _ := ami["sharedPublicly"].(bool); sharedPublicly {
+		subject.Rule = aws.FindingAmiSharedPublicly
+	}
+
+	return subject
+}
+
+// dnsRecordSubject matches a dangling record by its id
+func dnsRecordSubject(record map[string]any) aws.SuppressionSubject {
+	recordId, _ := record["id"].(string)
+	return aws.SuppressionSubject{Rule: aws.ReportDanglingDns, AssetId: recordId}
+}
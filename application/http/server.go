@@ -4,31 +4,69 @@ import (
 	"asset-relations/application/controller"
 	"asset-relations/support/config"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 )
 
+// Request bodies are small JSON documents
+const maxBodySize = 1 << 20
+
 type Server struct {
-	ec2Controller *controller.Ec2Controller
-	logger        *slog.Logger
-	cfg           config.HTTPConfig
+	ec2Controller           *controller.Ec2Controller
+	ipController            *controller.IpController
+	dnsController           *controller.DnsController
+	findingController       *controller.FindingController
+	reachabilityController  *controller.ReachabilityController
+	attackPathController    *controller.AttackPathController
+	securityGroupController *controller.SecurityGroupController
+	suppressionController   *controller.SuppressionController
+	simulationController    *controller.SimulationController
+	logger                  *slog.Logger
+	cfg                     config.HTTPConfig
 }
 
-func NewServer(ec2Controller *controller.Ec2Controller, logger *slog.Logger, cfg config.HTTPConfig) *Server {
+func NewServer(ec2Controller *controller.Ec2Controller, ipController *controller.IpController, dnsController *controller.DnsController, findingController *controller.FindingController, reachabilityController *controller.ReachabilityController, attackPathController *controller.AttackPathController, securityGroupController *controller.SecurityGroupController, suppressionController *controller.SuppressionController, simulationController *controller.SimulationController, logger *slog.Logger, cfg config.HTTPConfig) *Server {
 	return &Server{
-		ec2Controller: ec2Controller,
-		logger:        logger,
-		cfg:           cfg,
+		ec2Controller:           ec2Controller,
+		ipController:            ipController,
+		dnsController:           dnsController,
+		findingController:       findingController,
+		reachabilityController:  reachabilityController,
+		attackPathController:    attackPathController,
+		securityGroupController: securityGroupController,
+		suppressionController:   suppressionController,
+		simulationController:    simulationController,
+		logger:                  logger,
+		cfg:                     cfg,
 	}
 }
 
 func (s *Server) ListenAndServe() {
 	router := http.NewServeMux()
 
+	router.HandleFunc("GET /ec2-instances", s.getInstances)
+	router.HandleFunc("GET /ec2-instances/{id}", s.getInstance)
 	router.HandleFunc("GET /ec2-instances/ssh-open-to-internet", s.getInstancesOpenSSH)
-	router.HandleFunc("GET /ec2-instances/in-vpc/{instanceId}", s.getInstancesInVPC)
+	router.HandleFunc("GET /ec2-instances/{first}/{second}", s.routeInstancesSubPath)
+	router.HandleFunc("GET /ec2-instances/risky-amis", s.getInstancesWithRiskyAmis)
+	router.HandleFunc("GET /ec2-instances/exposed", s.getInstancesExposingService)
 	router.HandleFunc("POST /ec2-instances/fetch-graph", s.fetchInstancesGraph)
+	router.HandleFunc("GET /ip/{address}", s.getIpOwner)
+	router.HandleFunc("GET /dns-records/dangling", s.getDanglingDnsRecords)
+	router.HandleFunc("GET /findings", s.getFindings)
+	router.HandleFunc("GET /findings/snapshots", s.getSnapshotFindings)
+	router.HandleFunc("GET /reachability", s.getReachability)
+	router.HandleFunc("GET /attack-paths", s.getAttackPaths)
+	router.HandleFunc("GET /security-groups/permissive", s.getPermissiveSecurityGroups)
+	router.HandleFunc("GET /security-groups/cleanup", s.getSecurityGroupIssues)
+	router.HandleFunc("GET /security-groups/redundant", s.getRedundantRules)
+	router.HandleFunc("GET /security-groups/recommendations", s.getLeastPrivilegeRecommendations)
+	router.HandleFunc("POST /suppressions", s.createSuppression)
+	router.HandleFunc("GET /suppressions", s.getSuppressions)
+	router.HandleFunc("DELETE /suppressions/{id}", s.deleteSuppression)
+	router.HandleFunc("POST /simulate", s.simulate)
 
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%s", s.cfg.Port),
@@ -41,26 +79,211 @@ func (s *Server) ListenAndServe() {
 	}
 }
 
+func (s *Server) getInstances(writer http.ResponseWriter, req *http.Request) {
+	sort := strings.ToLower(req.URL.Query().Get("sort"))
+	res := s.ec2Controller.GetInstances(req.Context(), sort)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getInstance(writer http.ResponseWriter, req *http.Request) {
+	res := s.ec2Controller.GetInstance(req.Context(), req.PathValue("id"))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
 func (s *Server) getInstancesOpenSSH(writer http.ResponseWriter, req *http.Request) {
 	partial := strings.ToLower(req.URL.Query().Get("partial")) == "true"
-	res := s.ec2Controller.GetInstancesSSHOpen(req.Context(), partial)
+	res := s.ec2Controller.GetInstancesSSHOpen(req.Context(), partial, includeSuppressed(req))
 	writer.WriteHeader(res.Status)
 	s.safeWriteJson(writer, res.Content)
 }
 
-func (s *Server) getInstancesInVPC(writer http.ResponseWriter, req *http.Request) {
-	id := req.PathValue("instanceId")
+// routeInstancesSubPath dispatches /ec2-instances/{first}/{second} paths, as net/http can't
+// tell /ec2-instances/in-vpc/{instanceId} apart from /ec2-instances/{instanceId}/reachable
+func (s *Server) routeInstancesSubPath(writer http.ResponseWriter, req *http.Request) {
+	first, second := req.PathValue("first"), req.PathValue("second")
+
+	switch {
+	case first == "in-vpc":
+		s.getInstancesInVPC(writer, req, second)
+	case first == "exposure":
+		s.getInstancesByExposure(writer, req, second)
+	case second == "reachable":
+		s.getReachableInstances(writer, req, first)
+	case second == "reachable-from":
+		s.getInstancesReachingFrom(writer, req, first)
+	default:
+		http.NotFound(writer, req)
+	}
+}
+
+func (s *Server) getInstancesInVPC(writer http.ResponseWriter, req *http.Request, id string) {
 	res := s.ec2Controller.GetInstancesInSameVPC(req.Context(), id)
 	writer.WriteHeader(res.Status)
 	s.safeWriteJson(writer, res.Content)
 }
 
+func (s *Server) getInstancesByExposure(writer http.ResponseWriter, req *http.Request, exposure string) {
+	res := s.ec2Controller.GetInstancesByExposure(req.Context(), exposure, includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getReachableInstances(writer http.ResponseWriter, req *http.Request, id string) {
+	res := s.ec2Controller.GetReachableInstances(req.Context(), id)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getInstancesReachingFrom(writer http.ResponseWriter, req *http.Request, id string) {
+	res := s.ec2Controller.GetInstancesReachingFrom(req.Context(), id)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getInstancesWithRiskyAmis(writer http.ResponseWriter, req *http.Request) {
+	res := s.ec2Controller.GetInstancesWithRiskyAmis(req.Context(), includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getInstancesExposingService(writer http.ResponseWriter, req *http.Request) {
+	service := req.URL.Query().Get("service")
+	res := s.ec2Controller.GetInstancesExposingService(req.Context(), service, includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
 func (s *Server) fetchInstancesGraph(writer http.ResponseWriter, req *http.Request) {
 	res := s.ec2Controller.FetchInstancesGraph(req.Context())
 	writer.WriteHeader(res.Status)
 	s.safeWriteJson(writer, res.Content)
 }
 
+func (s *Server) getIpOwner(writer http.ResponseWriter, req *http.Request) {
+	address := req.PathValue("address")
+	res := s.ipController.GetIpOwner(req.Context(), address)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getDanglingDnsRecords(writer http.ResponseWriter, req *http.Request) {
+	res := s.dnsController.GetDanglingRecords(req.Context(), includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getFindings(writer http.ResponseWriter, req *http.Request) {
+	status := strings.ToLower(req.URL.Query().Get("status"))
+	res := s.findingController.GetFindings(req.Context(), status, includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getSnapshotFindings(writer http.ResponseWriter, req *http.Request) {
+	res := s.findingController.GetSnapshotFindings(req.Context(), includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getReachability(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	res := s.reachabilityController.GetReachability(req.Context(), query.Get("from"), query.Get("port"), strings.ToLower(query.Get("protocol")))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getAttackPaths(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	res := s.attackPathController.GetAttackPaths(req.Context(), query.Get("target"), query.Get("k"))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getPermissiveSecurityGroups(writer http.ResponseWriter, req *http.Request) {
+	res := s.securityGroupController.GetPermissiveSecurityGroups(req.Context(), req.URL.Query().Get("min_score"), includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getRedundantRules(writer http.ResponseWriter, req *http.Request) {
+	res := s.securityGroupController.GetRedundantRules(req.Context(), includeSuppressed(req))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getLeastPrivilegeRecommendations(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	res := s.securityGroupController.GetLeastPrivilegeRecommendations(req.Context(), query.Get("window_days"), query.Get("group_id"))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getSecurityGroupIssues(writer http.ResponseWriter, req *http.Request) {
+	format := strings.ToLower(req.URL.Query().Get("format"))
+	res := s.securityGroupController.GetSecurityGroupIssues(req.Context(), format, includeSuppressed(req))
+	if res.ContentType != "" {
+		writer.Header().Add("Content-Type", res.ContentType)
+		writer.Header().Add("Content-Disposition", `attachment; filename="security-group-cleanup.csv"`)
+		writer.WriteHeader(res.Status)
+		s.safeWrite(writer, res.Content)
+		return
+	}
+
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) createSuppression(writer http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		s.safeWriteJson(writer, []byte(`{"error": "can't read body"}`))
+		return
+	}
+
+	res := s.suppressionController.CreateSuppression(req.Context(), body)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) getSuppressions(writer http.ResponseWriter, req *http.Request) {
+	res := s.suppressionController.GetSuppressions(req.Context())
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) deleteSuppression(writer http.ResponseWriter, req *http.Request) {
+	res := s.suppressionController.DeleteSuppression(req.Context(), req.PathValue("id"))
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+func (s *Server) simulate(writer http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		s.safeWriteJson(writer, []byte(`{"error": "can't read body"}`))
+		return
+	}
+
+	res := s.simulationController.Simulate(req.Context(), body)
+	writer.WriteHeader(res.Status)
+	s.safeWriteJson(writer, res.Content)
+}
+
+// includeSuppressed tells findings endpoints to list suppressed results too, flagged as such
+func includeSuppressed(req *http.Request) bool {
+	return strings.ToLower(req.URL.Query().Get("include_suppressed")) == "true"
+}
+
+func (s *Server) safeWrite(writer http.ResponseWriter, content []byte) {
+	if _, err := writer.Write(content); err != nil {
+		s.logger.Error("HTTP Failure to write response")
+	}
+}
+
 func (s *Server) safeWriteJson(writer http.ResponseWriter, json []byte) {
 	if json == nil {
 		return
@@ -1,34 +1,186 @@
 package aws
 
 import (
+	"asset-relations/core/rules"
 	"context"
+	"fmt"
 	"log/slog"
 )
 
 type DataStore interface {
+	StoreInventory(ctx context.Context, inventory Inventory) error
 	StoreInstances(ctx context.Context, instances []Ec2Instance) error
 	StoreVPCRelatedInstances(ctx context.Context, instances map[string][]Ec2Instance) error
+	StoreElasticIps(ctx context.Context, addresses []ElasticIp) error
+	StoreNetworkInterfaces(ctx context.Context, interfaces []NetworkInterface) error
+	StoreNatGateways(ctx context.Context, gateways []NatGateway) error
+	StoreLoadBalancers(ctx context.Context, balancers []LoadBalancer) error
+	StoreCloudFrontDistributions(ctx context.Context, distributions []CloudFrontDistribution) error
+	StoreApiGatewayStages(ctx context.Context, stages []ApiGatewayStage) error
+	StoreIpAddresses(ctx context.Context, addresses []IpAddress) error
+	StoreDnsRecords(ctx context.Context, resolutions []DnsResolution) error
+	StoreFrontDoorRoutes(ctx context.Context, routes []FrontDoorRoute) error
+	StoreInstanceExposures(ctx context.Context, exposures []InstanceExposure) error
+	StoreInstanceReaches(ctx context.Context, reaches []InstanceReach) error
+	StoreServiceExposures(ctx context.Context, exposures []ServiceExposure) error
+	StoreRiskScores(ctx context.Context, scores []RiskScore) error
+	StoreSecurityGroups(ctx context.Context, groups []SecurityGroup, members map[string][]AssetRef) error
+	StoreLaunchTemplates(ctx context.Context, templates []LaunchTemplate) error
+	StoreAutoScalingGroups(ctx context.Context, groups []AutoScalingGroup) error
+	StoreAmis(ctx context.Context, amis []Ami) error
+	StoreEbsVolumes(ctx context.Context, volumes []EbsVolume) error
+	StoreEbsSnapshots(ctx context.Context, snapshots []EbsSnapshot, sources map[string]string) error
+	StoreFindings(ctx context.Context, findings []Finding) error
+	MatchRule(ctx context.Context, rule rules.Rule) ([]string, error)
 }
 
 type analyzer struct {
-	logger *slog.Logger
-	store  DataStore
+	logger   *slog.Logger
+	store    DataStore
+	rules    []rules.Rule
+	services ServiceCatalog
+	networks NetworkClassifier
+	weights  RiskWeights
 }
 
-func newAnalyzer(logger *slog.Logger, store DataStore) *analyzer {
+func newAnalyzer(logger *slog.Logger, store DataStore, rules []rules.Rule, services ServiceCatalog, networks NetworkClassifier, weights RiskWeights) *analyzer {
 	return &analyzer{
-		logger: logger,
-		store:  store,
+		logger:   logger,
+		store:    store,
+		rules:    rules,
+		services: services,
+		networks: networks,
+		weights:  weights,
 	}
 }
 
-func (a *analyzer) buildRelationsAndSave(ctx context.Context, ec2Instances []Ec2Instance) error {
-	err := a.store.StoreInstances(ctx, ec2Instances)
+func (a *analyzer) buildRelationsAndSave(ctx context.Context, inventory Inventory) error {
+	// The inventory itself is kept, analyses like reachability run on it on demand
+	err := a.store.StoreInventory(ctx, inventory)
 	if err != nil {
 		return err
 	}
 
-	err = a.store.StoreVPCRelatedInstances(ctx, groupInstancesByVPC(ec2Instances))
+	err = a.store.StoreInstances(ctx, inventory.Instances)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreVPCRelatedInstances(ctx, groupInstancesByVPC(inventory.Instances))
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreElasticIps(ctx, inventory.ElasticIps)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreNetworkInterfaces(ctx, inventory.NetworkInterfaces)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreNatGateways(ctx, inventory.NatGateways)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreLoadBalancers(ctx, inventory.LoadBalancers)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreCloudFrontDistributions(ctx, inventory.CloudFrontDistributions)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreApiGatewayStages(ctx, inventory.ApiGatewayStages)
+	if err != nil {
+		return err
+	}
+
+	addresses := indexIpAddresses(inventory)
+	resolver := newDnsResolver(inventory, addresses)
+	frontDoors := buildFrontDoorGraph(inventory, resolver)
+	exposures := computeInstanceExposures(inventory, frontDoors)
+	applyInstanceExposures(addresses, exposures)
+
+	err = a.store.StoreIpAddresses(ctx, addresses)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreDnsRecords(ctx, resolver.resolveAll(inventory.DnsRecords))
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreFrontDoorRoutes(ctx, frontDoors.allRoutes())
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreInstanceExposures(ctx, exposures)
+	if err != nil {
+		return err
+	}
+
+	reaches := computeInstanceReaches(inventory)
+	err = a.store.StoreInstanceReaches(ctx, reaches)
+	if err != nil {
+		return err
+	}
+
+	serviceExposures := computeServiceExposures(inventory, a.services, a.networks)
+	err = a.store.StoreServiceExposures(ctx, serviceExposures)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreRiskScores(ctx, ComputeRiskScores(inventory, exposures, reaches, serviceExposures, a.networks, a.weights))
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreLaunchTemplates(ctx, inventory.LaunchTemplates)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreAutoScalingGroups(ctx, inventory.AutoScalingGroups)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreAmis(ctx, inventory.Amis)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreEbsVolumes(ctx, inventory.EbsVolumes)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreEbsSnapshots(ctx, inventory.EbsSnapshots, snapshotSources(inventory))
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreSecurityGroups(ctx, inventory.SecurityGroups, securityGroupMembers(inventory))
+	if err != nil {
+		return err
+	}
+
+	// Rules match over the graph, so they run once every asset is stored
+	ruleFindings, err := a.evaluateRules(ctx, inventory)
+	if err != nil {
+		return err
+	}
+
+	err = a.store.StoreFindings(ctx, append(buildFindings(inventory, exposures, serviceExposures, a.services), ruleFindings...))
 	if err != nil {
 		return err
 	}
@@ -51,3 +203,53 @@ func groupInstancesByVPC(instances []Ec2Instance) map[string][]Ec2Instance {
 
 	return grouped
 }
+
+// evaluateRules raises a finding for every asset the rules match. Like other findings, the
+// instances of an Auto Scaling group are raised once on the group
+func (a *analyzer) evaluateRules(ctx context.Context, inv Inventory) ([]Finding, error) {
+	groups := instanceGroups(inv)
+	findings := make([]Finding, 0, len(a.rules))
+	for _, rule := range a.rules {
+		assetIds, err := a.store.MatchRule(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+
+		a.logger.Info(fmt.Sprintf("Rule %s matched %d assets", rule.Id, len(assetIds)))
+
+		groupNames := make([]string, 0)
+		groupInstances := make(map[string][]string)
+		for _, assetId := range assetIds {
+			var instances []string
+			if rule.Match.Asset == AssetEc2Instance {
+				if group, inGroup := groups[assetId]; inGroup {
+					if _, seen := groupInstances[group]; !seen {
+						groupNames = append(groupNames, group)
+					}
+
+					groupInstances[group] = append(groupInstances[group], assetId)
+					continue
+				}
+
+				instances = []string{assetId}
+			}
+
+			asset := AssetRef{AssetType: rule.Match.Asset, AssetId: assetId}
+			findings = append(findings, ruleFinding(rule, asset, rule.Match.Asset+" "+assetId, instances))
+		}
+
+		for _, group := range groupNames {
+			asset := AssetRef{AssetType: AssetAutoScalingGroup, AssetId: group}
+			findings = append(findings, ruleFinding(rule, asset, "ASG "+group, groupInstances[group]))
+		}
+	}
+
+	return findings, nil
+}
+
+func ruleFinding(rule rules.Rule, asset AssetRef, subject string, instances []string) Finding {
+	finding := newFinding(rule.Id, rule.Severity, asset, fmt.Sprintf("%s: %s", subject, rule.Description), instances)
+	finding.Remediation = rule.Remediation
+
+	return finding
+}
@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"asset-relations/core/rules"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// ruleStore only matches rules, on the assets listed per rule id
+type ruleStore struct {
+	DataStore
+	matches map[string][]string
+}
+
+func (r ruleStore) MatchRule(_ context.Context, rule rules.Rule) ([]string, error) {
+	return r.matches[rule.Id], nil
+}
+
+func TestEvaluateRulesGroupsInstances(t *testing.T) {
+	inv := Inventory{
+		Instances:         []Ec2Instance{{Id: "i-1"}, {Id: "i-2"}, {Id: "i-3"}},
+		AutoScalingGroups: []AutoScalingGroup{{Name: "web", InstanceIds: []string{"i-1", "i-2"}}},
+	}
+
+	store := ruleStore{matches: map[string][]string{"imdsv1": {"i-1", "i-2", "i-3"}}}
+	rule := rules.Rule{Id: "imdsv1", Severity: SeverityHigh, Description: "IMDSv1 enabled", Match: rules.Match{Asset: AssetEc2Instance}}
+	a := newAnalyzer(slog.New(slog.NewTextHandler(io.Discard, nil)), store, []rules.Rule{rule}, ServiceCatalog{}, NetworkClassifier{}, RiskWeights{})
+
+	findings, err := a.evaluateRules(context.Background(), inv)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected a finding for i-3 and one for the group, got %+v", findings)
+	}
+
+	if findings[0].Asset.AssetId != "i-3" || findings[1].Asset != (AssetRef{AssetType: AssetAutoScalingGroup, AssetId: "web"}) {
+		t.Errorf("unexpected assets %+v and %+v", findings[0].Asset, findings[1].Asset)
+	}
+
+	if !slices.Equal(findings[1].Instances, []string{"i-1", "i-2"}) {
+		t.Errorf("expected the group finding to list its instances, got %v", findings[1].Instances)
+	}
+}
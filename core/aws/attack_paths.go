@@ -0,0 +1,373 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	AttackHopExposure  = "exposure"
+	AttackHopNetwork   = "network"
+	AttackHopSharedKey = "shared-key"
+	AttackHopIamRole   = "iam-role"
+)
+
+// AssetInternet is the origin of every attack path
+const AssetInternet = "Internet"
+
+// Instances are critical when tagged critical=true or criticality=critical
+var criticalTags = map[string]string{"critical": "true", "criticality": "critical"}
+
+// Every hop costs the same, the cost of a path is its number of hops
+const attackHopCost = 1
+
+const maxAttackPaths = 10
+
+var internetRef = AssetRef{AssetType: AssetInternet, AssetId: "internet"}
+
+type AttackPathHop struct {
+	From   AssetRef
+	To     AssetRef
+	Kind   string
+	Reason string
+}
+
+type AttackPath struct {
+	Cost int
+	Hops []AttackPathHop
+}
+
+// TargetAttackPaths are the paths to the target instance. CredentialPaths are the paths to
+// the credentials of its instance profile, through any instance using the same profile
+type TargetAttackPaths struct {
+	Target          AssetRef
+	Paths           []AttackPath
+	CredentialPaths []AttackPath
+}
+
+func IsCriticalInstance(inst Ec2Instance) bool {
+	for key, value := range inst.Tags {
+		if expected, isCriticalTag := criticalTags[strings.ToLower(key)]; isCriticalTag && strings.EqualFold(value, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindAttackPaths returns up to k of the cheapest paths from the internet to the target
+// instance, or to every critical instance when target is empty. The first path of every
+// target is the shortest one.
+func FindAttackPaths(inv Inventory, target string, k int) ([]TargetAttackPaths, error) {
+	k = max(1, min(k, maxAttackPaths))
+
+	targets := make([]Ec2Instance, 0, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		if (target == "" && IsCriticalInstance(inst)) || inst.Id == target {
+			targets = append(targets, inst)
+		}
+	}
+
+	if target != "" && len(targets) == 0 {
+		return nil, fmt.Errorf("unknown instance %s", target)
+	}
+
+	resolver := newDnsResolver(inv, indexIpAddresses(inv))
+	exposures := computeInstanceExposures(inv, buildFrontDoorGraph(inv, resolver))
+	g := buildAttackGraph(inv, exposures, computeInstanceReaches(inv))
+	results := make([]TargetAttackPaths, 0, len(targets))
+	for _, inst := range targets {
+		paths := TargetAttackPaths{
+			Target: AssetRef{AssetType: AssetEc2Instance, AssetId: inst.Id},
+			Paths:  g.kShortestPaths(internetRef.AssetId, inst.Id, k),
+		}
+
+		if !ptr.IsEmpty(inst.IamInstanceProfile) {
+			paths.CredentialPaths = g.kShortestPaths(internetRef.AssetId, *inst.IamInstanceProfile, k)
+		}
+
+		results = append(results, paths)
+	}
+
+	return results, nil
+}
+
+type attackEdge struct {
+	to   string
+	cost int
+	hop  AttackPathHop
+}
+
+// attackGraph has the internet, instances and instance profiles as nodes. Between two nodes
+// only the first way to move found is kept
+type attackGraph struct {
+	edges map[string][]attackEdge
+}
+
+// buildAttackGraph takes the exposures and reaches of the instances, as the analyzer has them
+// already when scoring risks
+func buildAttackGraph(inv Inventory, exposures []InstanceExposure, instanceReaches []InstanceReach) attackGraph {
+	g := attackGraph{edges: make(map[string][]attackEdge, len(inv.Instances)+1)}
+	instances := make(map[string]Ec2Instance, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		instances[inst.Id] = inst
+	}
+
+	for _, exposure := range exposures {
+		reason, exposed := exposureReason(instances[exposure.InstanceId], exposure)
+		if exposed {
+			g.add(internetRef, instanceRef(exposure.InstanceId), AttackHopExposure, reason)
+		}
+	}
+
+	reaches := make(map[[2]string][]InstanceReach, len(inv.Instances))
+	for _, reach := range instanceReaches {
+		key := [2]string{reach.From, reach.To}
+		reaches[key] = append(reaches[key], reach)
+	}
+
+	for key, pairReaches := range reaches {
+		from, to := instances[key[0]], instances[key[1]]
+
+		ports := make([]string, 0, len(pairReaches))
+		ssh := false
+		for _, reach := range pairReaches {
+			ports = append(ports, reach.Protocol+" "+strings.Join(reach.Ports(), ","))
+			ssh = ssh || (reach.Protocol == protocolTcp && reachCoversPort(reach, SSHPort))
+		}
+
+		reason := "open ports " + strings.Join(ports, "; ")
+		if ssh && !ptr.IsEmpty(from.SSHKeyPairName) && ptr.Deref(from.SSHKeyPairName) == ptr.Deref(to.SSHKeyPairName) {
+			g.add(instanceRef(from.Id), instanceRef(to.Id), AttackHopSharedKey,
+				fmt.Sprintf("%s, both use SSH key pair %s", reason, *from.SSHKeyPairName))
+			continue
+		}
+
+		g.add(instanceRef(from.Id), instanceRef(to.Id), AttackHopNetwork, reason)
+	}
+
+	// Taking the credentials of an instance profile reaches the profile, not the other instances
+	// using it: it gives their cloud permissions, not a shell on them, so paths end there
+	for _, inst := range inv.Instances {
+		if !ptr.IsEmpty(inst.IamInstanceProfile) {
+			profile := AssetRef{AssetType: AssetInstanceProfile, AssetId: *inst.IamInstanceProfile}
+			g.add(instanceRef(inst.Id), profile, AttackHopIamRole,
+				"instance profile "+profile.AssetId+" gives its IAM role credentials")
+		}
+	}
+
+	for from := range g.edges {
+		slices.SortFunc(g.edges[from], func(a, b attackEdge) int {
+			return strings.Compare(a.to, b.to)
+		})
+	}
+
+	return g
+}
+
+func exposureReason(inst Ec2Instance, exposure InstanceExposure) (string, bool) {
+	switch exposure.Status {
+	case ExposureOpenToInternet:
+		ports := make([]string, 0, len(inst.IngressSecRules))
+		for _, rule := range inst.IngressSecRules {
+			if slices.Contains(rule.IpRanges, anyIPv4) || slices.Contains(rule.IpRanges, anyIPv6) {
+				ports = append(ports, fmt.Sprintf("%s %s", rule.IpProtocol, PortRange{From: rule.FromPort, To: rule.ToPort}))
+			}
+		}
+
+		return fmt.Sprintf("public address %s with open ports %s", ptr.Deref(inst.PublicIP), strings.Join(ports, ", ")), true
+	case ExposureFrontDoor, ExposureFrontDoorWaf:
+		return "served through " + strings.Join(exposure.FrontDoorPaths(), "; "), true
+	default:
+		return "", false
+	}
+}
+
+func reachCoversPort(reach InstanceReach, port int32) bool {
+	for _, portRange := range reach.PortRanges {
+		if portRange.From <= port && portRange.To >= port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func instanceRef(instanceId string) AssetRef {
+	return AssetRef{AssetType: AssetEc2Instance, AssetId: instanceId}
+}
+
+func (g attackGraph) add(from, to AssetRef, kind, reason string) {
+	edge := attackEdge{
+		to:   to.AssetId,
+		cost: attackHopCost,
+		hop: AttackPathHop{
+			From:   from,
+			To:     to,
+			Kind:   kind,
+			Reason: reason,
+		},
+	}
+
+	for _, existing := range g.edges[from.AssetId] {
+		if existing.to == to.AssetId {
+			return
+		}
+	}
+
+	g.edges[from.AssetId] = append(g.edges[from.AssetId], edge)
+}
+
+type attackPathCandidate struct {
+	nodes []string
+	edges []attackEdge
+	cost  int
+}
+
+func (c attackPathCandidate) toPath() AttackPath {
+	hops := make([]AttackPathHop, 0, len(c.edges))
+	for _, edge := range c.edges {
+		hops = append(hops, edge.hop)
+	}
+
+	return AttackPath{Cost: c.cost, Hops: hops}
+}
+
+// kShortestPaths is Yen's algorithm: every next path deviates from a previous one at some
+// node, with the edges previous paths took from there removed
+func (g attackGraph) kShortestPaths(from, to string, k int) []AttackPath {
+	first, found := g.shortestPath(from, to, nil, nil)
+	if !found {
+		return nil
+	}
+
+	accepted := []attackPathCandidate{first}
+	candidates := make([]attackPathCandidate, 0, k)
+
+	for len(accepted) < k {
+		previous := accepted[len(accepted)-1]
+
+		for spurIdx := 0; spurIdx < len(previous.nodes)-1; spurIdx++ {
+			spurNode := previous.nodes[spurIdx]
+			rootNodes := previous.nodes[:spurIdx+1]
+
+			removedEdges := make(map[[2]string]bool, len(accepted))
+			for _, path := range accepted {
+				if len(path.nodes) > spurIdx+1 && slices.Equal(path.nodes[:spurIdx+1], rootNodes) {
+					removedEdges[[2]string{path.nodes[spurIdx], path.nodes[spurIdx+1]}] = true
+				}
+			}
+
+			removedNodes := make(map[string]bool, spurIdx)
+			for _, node := range rootNodes[:spurIdx] {
+				removedNodes[node] = true
+			}
+
+			spur, found := g.shortestPath(spurNode, to, removedNodes, removedEdges)
+			if !found {
+				continue
+			}
+
+			candidate := attackPathCandidate{
+				nodes: append(slices.Clone(rootNodes), spur.nodes[1:]...),
+				edges: append(slices.Clone(previous.edges[:spurIdx]), spur.edges...),
+			}
+			for _, edge := range candidate.edges {
+				candidate.cost += edge.cost
+			}
+
+			if !containsCandidate(candidates, candidate) && !containsCandidate(accepted, candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+
+		slices.SortStableFunc(candidates, func(a, b attackPathCandidate) int {
+			if a.cost != b.cost {
+				return a.cost - b.cost
+			}
+
+			return len(a.nodes) - len(b.nodes)
+		})
+
+		accepted = append(accepted, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	paths := make([]AttackPath, 0, len(accepted))
+	for _, candidate := range accepted {
+		paths = append(paths, candidate.toPath())
+	}
+
+	return paths
+}
+
+func containsCandidate(candidates []attackPathCandidate, candidate attackPathCandidate) bool {
+	for _, existing := range candidates {
+		if slices.Equal(existing.nodes, candidate.nodes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shortestPath is Dijkstra's algorithm. Graphs are at most a few thousand instances, so the
+// next node is found with a linear scan instead of a heap
+func (g attackGraph) shortestPath(from, to string, removedNodes map[string]bool, removedEdges map[[2]string]bool) (attackPathCandidate, bool) {
+	costs := map[string]int{from: 0}
+	previous := make(map[string]attackEdge, len(g.edges))
+	previousNode := make(map[string]string, len(g.edges))
+	visited := make(map[string]bool, len(g.edges))
+
+	for {
+		current, currentCost, found := "", 0, false
+		for node, cost := range costs {
+			if visited[node] {
+				continue
+			}
+
+			if !found || cost < currentCost || (cost == currentCost && node < current) {
+				current, currentCost, found = node, cost, true
+			}
+		}
+
+		if !found {
+			return attackPathCandidate{}, false
+		}
+
+		if current == to {
+			break
+		}
+
+		visited[current] = true
+		for _, edge := range g.edges[current] {
+			if removedNodes[edge.to] || removedEdges[[2]string{current, edge.to}] || visited[edge.to] {
+				continue
+			}
+
+			cost, known := costs[edge.to]
+			if !known || currentCost+edge.cost < cost {
+				costs[edge.to] = currentCost + edge.cost
+				previous[edge.to] = edge
+				previousNode[edge.to] = current
+			}
+		}
+	}
+
+	path := attackPathCandidate{nodes: []string{to}, cost: costs[to]}
+	for node := to; node != from; node = previousNode[node] {
+		path.nodes = append(path.nodes, previousNode[node])
+		path.edges = append(path.edges, previous[node])
+	}
+
+	slices.Reverse(path.nodes)
+	slices.Reverse(path.edges)
+
+	return path, true
+}
@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKShortestAttackPaths(t *testing.T) {
+	g := attackGraph{edges: make(map[string][]attackEdge)}
+	profile := AssetRef{AssetType: AssetInstanceProfile, AssetId: "db"}
+	g.add(internetRef, instanceRef("i-web"), AttackHopExposure, "")
+	g.add(internetRef, instanceRef("i-bastion"), AttackHopExposure, "")
+	g.add(instanceRef("i-web"), instanceRef("i-db"), AttackHopNetwork, "")
+	g.add(instanceRef("i-bastion"), instanceRef("i-app"), AttackHopNetwork, "")
+	g.add(instanceRef("i-app"), instanceRef("i-db"), AttackHopNetwork, "")
+	g.add(instanceRef("i-bastion"), profile, AttackHopIamRole, "")
+	g.add(instanceRef("i-db"), profile, AttackHopIamRole, "")
+
+	paths := g.kShortestPaths("internet", "i-db", 5)
+	costs := make([]int, 0, len(paths))
+	for _, path := range paths {
+		costs = append(costs, path.Cost)
+	}
+
+	if expected := []int{2, 3}; !slices.Equal(costs, expected) {
+		t.Errorf("Expected path costs %v, got %v", expected, costs)
+	}
+
+	credentials := g.kShortestPaths("internet", profile.AssetId, 5)
+	if len(credentials) != 3 || credentials[0].Cost != 2 || credentials[0].Hops[1].From.AssetId != "i-bastion" {
+		t.Errorf("Expected the instance profile credentials through i-bastion first, got %+v", credentials)
+	}
+
+	if last := paths[0].Hops[len(paths[0].Hops)-1]; last.From.AssetId != "i-web" {
+		t.Errorf("Expected the shortest path to go through i-web, got %+v", paths[0].Hops)
+	}
+}
+
+func TestKShortestAttackPathsDistinct(t *testing.T) {
+	g := attackGraph{edges: make(map[string][]attackEdge)}
+	g.add(internetRef, instanceRef("i-b"), AttackHopExposure, "")
+	g.add(internetRef, instanceRef("i-c"), AttackHopExposure, "")
+	g.add(internetRef, instanceRef("i-d"), AttackHopExposure, "")
+	g.add(instanceRef("i-b"), instanceRef("i-c"), AttackHopNetwork, "")
+	g.add(instanceRef("i-c"), instanceRef("i-d"), AttackHopNetwork, "")
+	g.add(instanceRef("i-c"), instanceRef("i-target"), AttackHopNetwork, "")
+	g.add(instanceRef("i-d"), instanceRef("i-target"), AttackHopNetwork, "")
+
+	// Later spurs find paths already waiting as candidates again, and k is above the 5 paths there are
+	paths := g.kShortestPaths("internet", "i-target", maxAttackPaths)
+
+	routes := make([]string, 0, len(paths))
+	costs := make([]int, 0, len(paths))
+	for _, path := range paths {
+		nodes := make([]string, 0, len(path.Hops))
+		for _, hop := range path.Hops {
+			nodes = append(nodes, hop.To.AssetId)
+		}
+
+		routes = append(routes, strings.Join(nodes, ">"))
+		costs = append(costs, path.Cost)
+	}
+
+	if expected := []int{2, 2, 3, 3, 4}; !slices.Equal(costs, expected) {
+		t.Errorf("Expected path costs %v, got %v for %v", expected, costs, routes)
+	}
+
+	sorted := slices.Clone(routes)
+	slices.Sort(sorted)
+	if len(slices.Compact(sorted)) != len(routes) {
+		t.Errorf("Expected distinct paths, got %v", routes)
+	}
+}
@@ -0,0 +1,317 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"encoding/json"
+	"fmt"
+	astypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AwsCliDumpSource loads the inventory from a directory of AWS CLI outputs, like
+// aws ec2 describe-instances > instances.json, for accounts we have no credentials for.
+// Files are recognized by their content, whatever their name
+type AwsCliDumpSource struct {
+	dir    string
+	logger *slog.Logger
+}
+
+func NewAwsCliDumpSource(dir string, logger *slog.Logger) AwsCliDumpSource {
+	return AwsCliDumpSource{dir: dir, logger: logger}
+}
+
+func (a AwsCliDumpSource) Name() string {
+	return "AWS CLI dumps " + a.dir
+}
+
+// awsCliDump holds the outputs of the describe commands, which are the SDK outputs in JSON. The
+// attribute outputs, describe-image-attribute and describe-snapshot-attribute, hold a single
+// resource each, they are gathered by resource id
+type awsCliDump struct {
+	Account                 *string
+	Reservations            []ec2types.Reservation
+	SecurityGroups          []ec2types.SecurityGroup
+	Vpcs                    []ec2types.Vpc
+	Subnets                 []ec2types.Subnet
+	RouteTables             []ec2types.RouteTable
+	NetworkAcls             []ec2types.NetworkAcl
+	InternetGateways        []ec2types.InternetGateway
+	Addresses               []ec2types.Address
+	NetworkInterfaces       []ec2types.NetworkInterface
+	NatGateways             []ec2types.NatGateway
+	LoadBalancers           []elbtypes.LoadBalancer
+	AutoScalingGroups       []astypes.AutoScalingGroup
+	LaunchTemplates         []ec2types.LaunchTemplate
+	LaunchTemplateVersions  []ec2types.LaunchTemplateVersion
+	Images                  []ec2types.Image
+	Volumes                 []ec2types.Volume
+	Snapshots               []ec2types.Snapshot
+	ImageId                 *string
+	LaunchPermissions       []ec2types.LaunchPermission
+	SnapshotId              *string
+	CreateVolumePermissions []ec2types.CreateVolumePermission
+
+	launchPermissions       map[string][]ec2types.LaunchPermission
+	createVolumePermissions map[string][]ec2types.CreateVolumePermission
+}
+
+// awsCliDumpKeys are the top level keys of the outputs awsCliDump holds
+var awsCliDumpKeys = []string{
+	"Account", "Reservations", "SecurityGroups", "Vpcs", "Subnets", "RouteTables", "NetworkAcls",
+	"InternetGateways", "Addresses", "NetworkInterfaces", "NatGateways", "LoadBalancers",
+	"AutoScalingGroups", "LaunchTemplates", "LaunchTemplateVersions", "Images", "Volumes",
+	"Snapshots", "LaunchPermissions", "CreateVolumePermissions",
+}
+
+func (a AwsCliDumpSource) Load(_ context.Context) (Inventory, error) {
+	paths, err := filepath.Glob(filepath.Join(a.dir, "*.json"))
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	if len(paths) == 0 {
+		return Inventory{}, fmt.Errorf("no JSON files in %s", a.dir)
+	}
+
+	dump := newAwsCliDump()
+	for _, path := range paths {
+		if err := a.read(path, &dump); err != nil {
+			return Inventory{}, err
+		}
+	}
+
+	return markMissingData(dump.inventory(a.logger)), nil
+}
+
+// read adds the content of the file to the dump. Files of the same command, like paginated
+// outputs, add up
+func (a AwsCliDumpSource) read(path string, dump *awsCliDump) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("invalid AWS CLI output %s: %w", path, err)
+	}
+
+	known := false
+	for _, key := range awsCliDumpKeys {
+		_, found := keys[key]
+		known = known || found
+	}
+
+	if !known {
+		a.logger.Warn("Skipping " + path + ", it isn't the output of a describe command we know")
+		return nil
+	}
+
+	var file awsCliDump
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid AWS CLI output %s: %w", path, err)
+	}
+
+	dump.add(file)
+
+	return nil
+}
+
+func (d *awsCliDump) add(file awsCliDump) {
+	if file.Account != nil {
+		d.Account = file.Account
+	}
+
+	d.Reservations = append(d.Reservations, file.Reservations...)
+	d.SecurityGroups = append(d.SecurityGroups, file.SecurityGroups...)
+	d.Vpcs = append(d.Vpcs, file.Vpcs...)
+	d.Subnets = append(d.Subnets, file.Subnets...)
+	d.RouteTables = append(d.RouteTables, file.RouteTables...)
+	d.NetworkAcls = append(d.NetworkAcls, file.NetworkAcls...)
+	d.InternetGateways = append(d.InternetGateways, file.InternetGateways...)
+	d.Addresses = append(d.Addresses, file.Addresses...)
+	d.NetworkInterfaces = append(d.NetworkInterfaces, file.NetworkInterfaces...)
+	d.NatGateways = append(d.NatGateways, file.NatGateways...)
+	d.LoadBalancers = append(d.LoadBalancers, file.LoadBalancers...)
+	d.AutoScalingGroups = append(d.AutoScalingGroups, file.AutoScalingGroups...)
+	d.LaunchTemplates = append(d.LaunchTemplates, file.LaunchTemplates...)
+	d.LaunchTemplateVersions = append(d.LaunchTemplateVersions, file.LaunchTemplateVersions...)
+	d.Images = append(d.Images, file.Images...)
+	d.Volumes = append(d.Volumes, file.Volumes...)
+	d.Snapshots = append(d.Snapshots, file.Snapshots...)
+
+	if file.ImageId != nil {
+		d.launchPermissions[*file.ImageId] = append(d.launchPermissions[*file.ImageId], file.LaunchPermissions...)
+	}
+
+	if file.SnapshotId != nil {
+		d.createVolumePermissions[*file.SnapshotId] = append(d.createVolumePermissions[*file.SnapshotId], file.CreateVolumePermissions...)
+	}
+}
+
+func newAwsCliDump() awsCliDump {
+	return awsCliDump{
+		launchPermissions:       make(map[string][]ec2types.LaunchPermission),
+		createVolumePermissions: make(map[string][]ec2types.CreateVolumePermission),
+	}
+}
+
+// inventory converts the dump the way the fetchers convert the API responses. Load balancer
+// targets aren't part of any describe output, they are left out
+func (d awsCliDump) inventory(logger *slog.Logger) Inventory {
+	inv := Inventory{AccountId: ptr.Deref(d.Account)}
+
+	for _, group := range d.SecurityGroups {
+		inv.SecurityGroups = append(inv.SecurityGroups, convertGroup(group))
+	}
+
+	for _, reservation := range d.Reservations {
+		for _, instance := range reservation.Instances {
+			inv.Instances = append(inv.Instances, convertDumpInstance(instance, d.SecurityGroups, logger))
+		}
+	}
+
+	for _, vpc := range d.Vpcs {
+		inv.Vpcs = append(inv.Vpcs, convertVpc(vpc))
+	}
+
+	for _, subnet := range d.Subnets {
+		inv.Subnets = append(inv.Subnets, convertSubnet(subnet))
+	}
+
+	for _, table := range d.RouteTables {
+		inv.RouteTables = append(inv.RouteTables, convertRouteTable(table))
+	}
+
+	for _, acl := range d.NetworkAcls {
+		inv.NetworkAcls = append(inv.NetworkAcls, convertNetworkAcl(acl))
+	}
+
+	for _, gateway := range d.InternetGateways {
+		inv.InternetGateways = append(inv.InternetGateways, convertInternetGateway(gateway))
+	}
+
+	for _, address := range d.Addresses {
+		inv.ElasticIps = append(inv.ElasticIps, convertAddress(address))
+	}
+
+	for _, eni := range d.NetworkInterfaces {
+		inv.NetworkInterfaces = append(inv.NetworkInterfaces, convertNetworkInterface(eni))
+	}
+
+	for _, nat := range d.NatGateways {
+		inv.NatGateways = append(inv.NatGateways, convertNatGateway(nat))
+	}
+
+	for _, lb := range d.LoadBalancers {
+		inv.LoadBalancers = append(inv.LoadBalancers, convertLoadBalancer(lb))
+	}
+
+	for _, group := range d.AutoScalingGroups {
+		inv.AutoScalingGroups = append(inv.AutoScalingGroups, convertAutoScalingGroup(group))
+	}
+
+	inv.LaunchTemplates = convertLaunchTemplates(d.LaunchTemplates, d.LaunchTemplateVersions)
+
+	for _, volume := range d.Volumes {
+		inv.EbsVolumes = append(inv.EbsVolumes, convertVolume(volume))
+	}
+
+	for _, snapshot := range d.Snapshots {
+		converted := convertSnapshot(snapshot)
+		converted.CreateVolumePermissions = convertCreateVolumePermissions(d.createVolumePermissions[converted.Id])
+		inv.EbsSnapshots = append(inv.EbsSnapshots, converted)
+	}
+
+	inv.Amis = convertImages(d.Images, inv.AccountId, d.launchPermissions)
+	inv.Region = dumpRegion(inv)
+
+	logger.Info(fmt.Sprintf("Loaded %d instances and %d security groups", len(inv.Instances), len(inv.SecurityGroups)))
+
+	return inv
+}
+
+// convertDumpInstance converts the instance with the rules of its groups, like the instances
+// fetcher does with the groups it describes
+func convertDumpInstance(instance ec2types.Instance, groups []ec2types.SecurityGroup, logger *slog.Logger) Ec2Instance {
+	converted := convertInstance(instance)
+
+	attached := make([]ec2types.SecurityGroup, 0, len(converted.SecurityGroupIds))
+	for _, groupId := range converted.SecurityGroupIds {
+		found := false
+		for _, group := range groups {
+			if ptr.Deref(group.GroupId) == groupId {
+				attached = append(attached, group)
+				found = true
+			}
+		}
+
+		if !found {
+			logger.Warn("Security group " + groupId + " of instance " + converted.Id + " isn't in the dumps, its rules are left out")
+		}
+	}
+
+	converted.IngressSecRules, converted.EgressSecRules = extractSecGroup(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: attached})
+
+	return converted
+}
+
+func convertLaunchTemplates(templates []ec2types.LaunchTemplate, versions []ec2types.LaunchTemplateVersion) []LaunchTemplate {
+	converted := make([]LaunchTemplate, 0, len(templates))
+	for _, template := range templates {
+		launchTemplate := LaunchTemplate{
+			Id:             ptr.Deref(template.LaunchTemplateId),
+			Name:           ptr.Deref(template.LaunchTemplateName),
+			DefaultVersion: ptr.Deref(template.DefaultVersionNumber),
+		}
+
+		for _, version := range versions {
+			if ptr.Deref(version.LaunchTemplateId) == launchTemplate.Id && ptr.Deref(version.DefaultVersion) && version.LaunchTemplateData != nil {
+				launchTemplate = convertLaunchTemplateData(launchTemplate, *version.LaunchTemplateData)
+			}
+		}
+
+		converted = append(converted, launchTemplate)
+	}
+
+	return converted
+}
+
+// convertImages converts the described images with their launch permissions. Images only
+// known by their attribute output are ours, as only owners can get it
+func convertImages(images []ec2types.Image, accountId string, launchPermissions map[string][]ec2types.LaunchPermission) []Ami {
+	amis := make([]Ami, 0, len(images)+len(launchPermissions))
+	described := make(map[string]bool, len(images))
+	for _, image := range images {
+		ami := convertImage(image, accountId)
+		ami.LaunchPermissions = convertLaunchPermissions(launchPermissions[ami.Id])
+		described[ami.Id] = true
+		amis = append(amis, ami)
+	}
+
+	for imageId, permissions := range launchPermissions {
+		if !described[imageId] {
+			amis = append(amis, Ami{Id: imageId, Provenance: AmiOwnedByAccount, LaunchPermissions: convertLaunchPermissions(permissions)})
+		}
+	}
+
+	return amis
+}
+
+// dumpRegion tells the region from the availability zones, as the outputs don't hold it
+func dumpRegion(inv Inventory) string {
+	for _, subnet := range inv.Subnets {
+		if subnet.AvailabilityZone != "" {
+			return strings.TrimRight(subnet.AvailabilityZone, "abcdefghijklmnopqrstuvwxyz")
+		}
+	}
+
+	return ""
+}
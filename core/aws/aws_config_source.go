@@ -0,0 +1,331 @@
+package aws
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Resource types recorded by AWS Config the inventory is made of
+const (
+	configEc2Instance          = "AWS::EC2::Instance"
+	configEc2SecurityGroup     = "AWS::EC2::SecurityGroup"
+	configEc2Vpc               = "AWS::EC2::VPC"
+	configEc2Subnet            = "AWS::EC2::Subnet"
+	configEc2RouteTable        = "AWS::EC2::RouteTable"
+	configEc2NetworkAcl        = "AWS::EC2::NetworkAcl"
+	configEc2InternetGateway   = "AWS::EC2::InternetGateway"
+	configEc2Eip               = "AWS::EC2::EIP"
+	configEc2NetworkInterface  = "AWS::EC2::NetworkInterface"
+	configEc2NatGateway        = "AWS::EC2::NatGateway"
+	configEc2Volume            = "AWS::EC2::Volume"
+	configLoadBalancerV2       = "AWS::ElasticLoadBalancingV2::LoadBalancer"
+	configAutoScalingGroup     = "AWS::AutoScaling::AutoScalingGroup"
+	configStatusDeleted        = "ResourceDeleted"
+	configStatusDeletedNotSeen = "ResourceDeletedNotRecorded"
+	configStatusNotRecorded    = "ResourceNotRecorded"
+)
+
+// AwsConfigSource loads the inventory from the configuration snapshots and history files AWS
+// Config delivers to S3, copied to a local directory. Files may be gzipped, as delivered, and
+// in subdirectories, as laid out in the bucket
+type AwsConfigSource struct {
+	dir string
+	// at loads the state of resources at that time instead of their latest one
+	at     time.Time
+	logger *slog.Logger
+}
+
+func NewAwsConfigSource(dir string, at time.Time, logger *slog.Logger) AwsConfigSource {
+	return AwsConfigSource{dir: dir, at: at, logger: logger}
+}
+
+func (a AwsConfigSource) Name() string {
+	if a.at.IsZero() {
+		return "AWS Config " + a.dir
+	}
+
+	return "AWS Config " + a.dir + " at " + a.at.Format(time.RFC3339)
+}
+
+// configItems holds the items of snapshots, history files and of the outputs of
+// aws configservice get-resource-config-history and batch-get-resource-config
+type configItems struct {
+	ConfigurationItems     []configItem `json:"configurationItems"`
+	BaseConfigurationItems []configItem `json:"baseConfigurationItems"`
+}
+
+type configItem struct {
+	ResourceType string    `json:"resourceType"`
+	ResourceId   string    `json:"resourceId"`
+	AwsAccountId string    `json:"awsAccountId"`
+	AccountId    string    `json:"accountId"`
+	AwsRegion    string    `json:"awsRegion"`
+	CaptureTime  time.Time `json:"configurationItemCaptureTime"`
+	Status       string    `json:"configurationItemStatus"`
+	// Configuration is the describe output of the resource in camel case, files delivered to
+	// S3 hold it as an object and the API as a string
+	Configuration json.RawMessage `json:"configuration"`
+}
+
+func (a AwsConfigSource) Load(_ context.Context) (Inventory, error) {
+	items, err := a.readItems()
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	if len(items) == 0 {
+		return Inventory{}, fmt.Errorf("no AWS Config items in %s", a.dir)
+	}
+
+	dump := newAwsCliDump()
+	accounts := make([]string, 0, 1)
+	regions := make([]string, 0, 1)
+	skipped := make(map[string]int)
+	for _, item := range a.currentItems(items) {
+		if !item.supported() {
+			skipped[item.ResourceType]++
+			continue
+		}
+
+		if err := item.addTo(&dump); err != nil {
+			a.logger.Warn("Skipping " + item.ResourceType + " " + item.ResourceId + ": " + err.Error())
+			continue
+		}
+
+		for _, account := range []string{item.AwsAccountId, item.AccountId} {
+			if account != "" && !slices.Contains(accounts, account) {
+				accounts = append(accounts, account)
+			}
+		}
+
+		if item.AwsRegion != "" && !slices.Contains(regions, item.AwsRegion) {
+			regions = append(regions, item.AwsRegion)
+		}
+	}
+
+	for resourceType, count := range skipped {
+		a.logger.Info(fmt.Sprintf("Skipping %d %s items, the type isn't supported", count, resourceType))
+	}
+
+	if len(accounts) > 1 || len(regions) > 1 {
+		a.logger.Warn(fmt.Sprintf("Items of accounts %v and regions %v are loaded as one inventory", accounts, regions))
+	}
+
+	inv := dump.inventory(a.logger)
+	if len(accounts) > 0 {
+		inv.AccountId = accounts[0]
+	}
+
+	if len(regions) > 0 {
+		inv.Region = regions[0]
+	}
+
+	return markMissingData(inv), nil
+}
+
+func (a AwsConfigSource) readItems() ([]configItem, error) {
+	items := make([]configItem, 0)
+	err := filepath.WalkDir(a.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || !(strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".json.gz")) {
+			return nil
+		}
+
+		fileItems, err := readConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("invalid AWS Config file %s: %w", path, err)
+		}
+
+		items = append(items, fileItems...)
+
+		return nil
+	})
+
+	return items, err
+}
+
+func readConfigFile(path string) ([]configItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	var items configItems
+	if err := json.NewDecoder(reader).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	return append(items.ConfigurationItems, items.BaseConfigurationItems...), nil
+}
+
+// currentItems keeps the last item of every resource captured at the time of the source, or
+// the last one when loading the latest state. Resources deleted by then are left out. Items
+// are sorted by resource, for the inventory not to depend on the order of files
+func (a AwsConfigSource) currentItems(items []configItem) []configItem {
+	latest := make(map[string]configItem, len(items))
+	for _, item := range items {
+		if !a.at.IsZero() && item.CaptureTime.After(a.at) {
+			continue
+		}
+
+		key := item.ResourceType + "/" + item.ResourceId
+		if known, found := latest[key]; !found || !item.CaptureTime.Before(known.CaptureTime) {
+			latest[key] = item
+		}
+	}
+
+	current := make([]configItem, 0, len(latest))
+	for _, item := range latest {
+		switch item.Status {
+		case configStatusDeleted, configStatusDeletedNotSeen, configStatusNotRecorded:
+			continue
+		}
+
+		current = append(current, item)
+	}
+
+	slices.SortFunc(current, func(a, b configItem) int {
+		return strings.Compare(a.ResourceType+"/"+a.ResourceId, b.ResourceType+"/"+b.ResourceId)
+	})
+
+	return current
+}
+
+func (c configItem) supported() bool {
+	switch c.ResourceType {
+	case configEc2Instance, configEc2SecurityGroup, configEc2Vpc, configEc2Subnet, configEc2RouteTable,
+		configEc2NetworkAcl, configEc2InternetGateway, configEc2Eip, configEc2NetworkInterface,
+		configEc2NatGateway, configEc2Volume, configLoadBalancerV2, configAutoScalingGroup:
+		return true
+	default:
+		return false
+	}
+}
+
+// addTo adds the configuration of the item to the dump. Configurations are the describe
+// outputs in camel case, which decode into the SDK types like the AWS CLI outputs do
+func (c configItem) addTo(dump *awsCliDump) error {
+	configuration := []byte(c.Configuration)
+
+	var encoded string
+	if err := json.Unmarshal(c.Configuration, &encoded); err == nil {
+		configuration = []byte(encoded)
+	}
+
+	switch c.ResourceType {
+	case configEc2Instance:
+		var instance ec2types.Instance
+		if err := json.Unmarshal(configuration, &instance); err != nil {
+			return err
+		}
+
+		dump.Reservations = append(dump.Reservations, ec2types.Reservation{Instances: []ec2types.Instance{instance}})
+	case configEc2SecurityGroup:
+		var group configSecurityGroup
+		if err := json.Unmarshal(configuration, &group); err != nil {
+			return err
+		}
+
+		dump.SecurityGroups = append(dump.SecurityGroups, group.securityGroup())
+	case configEc2Vpc:
+		return appendConfiguration(configuration, &dump.Vpcs)
+	case configEc2Subnet:
+		return appendConfiguration(configuration, &dump.Subnets)
+	case configEc2RouteTable:
+		return appendConfiguration(configuration, &dump.RouteTables)
+	case configEc2NetworkAcl:
+		return appendConfiguration(configuration, &dump.NetworkAcls)
+	case configEc2InternetGateway:
+		return appendConfiguration(configuration, &dump.InternetGateways)
+	case configEc2Eip:
+		return appendConfiguration(configuration, &dump.Addresses)
+	case configEc2NetworkInterface:
+		return appendConfiguration(configuration, &dump.NetworkInterfaces)
+	case configEc2NatGateway:
+		return appendConfiguration(configuration, &dump.NatGateways)
+	case configEc2Volume:
+		return appendConfiguration(configuration, &dump.Volumes)
+	case configLoadBalancerV2:
+		return appendConfiguration(configuration, &dump.LoadBalancers)
+	case configAutoScalingGroup:
+		return appendConfiguration(configuration, &dump.AutoScalingGroups)
+	}
+
+	return nil
+}
+
+func appendConfiguration[T any](configuration []byte, to *[]T) error {
+	var resource T
+	if err := json.Unmarshal(configuration, &resource); err != nil {
+		return err
+	}
+
+	*to = append(*to, resource)
+
+	return nil
+}
+
+// configSecurityGroup is a security group as recorded by AWS Config, which lists the IPv4
+// ranges of rules twice: as plain CIDRs under ipRanges and as ranges under ipv4Ranges
+type configSecurityGroup struct {
+	ec2types.SecurityGroup
+	IpPermissions       []configIpPermission `json:"ipPermissions"`
+	IpPermissionsEgress []configIpPermission `json:"ipPermissionsEgress"`
+}
+
+type configIpPermission struct {
+	ec2types.IpPermission
+	IpRanges   []string           `json:"ipRanges"`
+	Ipv4Ranges []ec2types.IpRange `json:"ipv4Ranges"`
+}
+
+func (g configSecurityGroup) securityGroup() ec2types.SecurityGroup {
+	group := g.SecurityGroup
+	group.IpPermissions = convertConfigIpPermissions(g.IpPermissions)
+	group.IpPermissionsEgress = convertConfigIpPermissions(g.IpPermissionsEgress)
+
+	return group
+}
+
+func convertConfigIpPermissions(permissions []configIpPermission) []ec2types.IpPermission {
+	converted := make([]ec2types.IpPermission, 0, len(permissions))
+	for _, permission := range permissions {
+		ipPermission := permission.IpPermission
+		ipPermission.IpRanges = permission.Ipv4Ranges
+		if len(ipPermission.IpRanges) == 0 {
+			for _, cidr := range permission.IpRanges {
+				ipPermission.IpRanges = append(ipPermission.IpRanges, ec2types.IpRange{CidrIp: &cidr})
+			}
+		}
+
+		converted = append(converted, ipPermission)
+	}
+
+	return converted
+}
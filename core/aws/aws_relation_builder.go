@@ -1,9 +1,13 @@
 package aws
 
 import (
+	"asset-relations/core/rules"
 	"asset-relations/support/config"
+	"asset-relations/support/ptr"
 	"context"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"log/slog"
 )
 
@@ -13,11 +17,11 @@ type RelationBuilder struct {
 	analyzer *analyzer
 }
 
-func NewRelationBuilder(logger *slog.Logger, cfg config.AwsConfig, store DataStore) *RelationBuilder {
+func NewRelationBuilder(logger *slog.Logger, cfg config.AwsConfig, store DataStore, rules []rules.Rule, services ServiceCatalog, networks NetworkClassifier, weights RiskWeights) *RelationBuilder {
 	return &RelationBuilder{
 		logger:   logger,
 		cfg:      cfg,
-		analyzer: newAnalyzer(logger, store),
+		analyzer: newAnalyzer(logger, store, rules, services, networks, weights),
 	}
 }
 
@@ -30,11 +34,163 @@ func (r *RelationBuilder) Build(ctx context.Context) error {
 
 	awsCfg.Region = r.cfg.Region
 
-	ec2F := NewEc2InstanceFetcher(awsCfg, r.logger)
-	instances, err := ec2F.Fetch(ctx)
+	inventory, err := r.fetchInventory(ctx, awsCfg)
 	if err != nil {
 		return err
 	}
 
-	return r.analyzer.buildRelationsAndSave(ctx, instances)
+	return r.analyzer.buildRelationsAndSave(ctx, inventory)
+}
+
+func (r *RelationBuilder) fetchInventory(ctx context.Context, awsCfg awssdk.Config) (Inventory, error) {
+	inventory := Inventory{Region: awsCfg.Region}
+
+	identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return inventory, err
+	}
+	inventory.AccountId = ptr.Deref(identity.Account)
+
+	ec2F := NewEc2InstanceFetcher(awsCfg, r.logger)
+	if inventory.Instances, err = ec2F.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	// Without the VPC network reachability can't be confirmed, but everything else still holds
+	networkF := NewEc2VpcNetworkFetcher(awsCfg, r.logger)
+	if inventory.Vpcs, err = networkF.FetchVpcs(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataVpcs, err)
+	}
+
+	if inventory.Subnets, err = networkF.FetchSubnets(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataSubnets, err)
+	}
+
+	if inventory.RouteTables, err = networkF.FetchRouteTables(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataRouteTables, err)
+	}
+
+	if inventory.NetworkAcls, err = networkF.FetchNetworkAcls(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataNetworkAcls, err)
+	}
+
+	if inventory.InternetGateways, err = networkF.FetchInternetGateways(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataInternetGateways, err)
+	}
+
+	securityGroupsF := NewEc2SecurityGroupsFetcher(awsCfg, r.logger)
+	if inventory.SecurityGroups, err = securityGroupsF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+	inventory.Instances = r.attachSecurityGroupRules(inventory.Instances, inventory.SecurityGroups)
+
+	addressesF := NewEc2AddressesFetcher(awsCfg, r.logger)
+	if inventory.ElasticIps, err = addressesF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	interfacesF := NewEc2NetworkInterfacesFetcher(awsCfg, r.logger)
+	if inventory.NetworkInterfaces, err = interfacesF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	natF := NewEc2NatGatewaysFetcher(awsCfg, r.logger)
+	if inventory.NatGateways, err = natF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	lbF := NewLoadBalancersFetcher(awsCfg, r.logger)
+	if inventory.LoadBalancers, err = lbF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	route53F := NewRoute53RecordsFetcher(awsCfg, r.logger)
+	if inventory.DnsRecords, err = route53F.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	cloudFrontF := NewCloudFrontDistributionsFetcher(awsCfg, r.logger)
+	if inventory.CloudFrontDistributions, err = cloudFrontF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	apiGatewayF := NewApiGatewayStagesFetcher(awsCfg, r.logger)
+	if inventory.ApiGatewayStages, err = apiGatewayF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	autoScalingF := NewAutoScalingGroupsFetcher(awsCfg, r.logger)
+	if inventory.AutoScalingGroups, err = autoScalingF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	launchTemplatesF := NewEc2LaunchTemplatesFetcher(awsCfg, r.logger)
+	if inventory.LaunchTemplates, err = launchTemplatesF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	volumesF := NewEc2VolumesFetcher(awsCfg, r.logger)
+	if inventory.EbsVolumes, err = volumesF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	snapshotsF := NewEc2SnapshotsFetcher(awsCfg, r.logger)
+	if inventory.EbsSnapshots, err = snapshotsF.Fetch(ctx); err != nil {
+		return inventory, err
+	}
+
+	// IAM is global and often not granted to region scanners, risk scores go on without it
+	profilesF := NewIamInstanceProfilesFetcher(awsCfg, r.logger)
+	if inventory.InstanceProfiles, err = profilesF.Fetch(ctx, usedInstanceProfiles(inventory)); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataInstanceProfiles, err)
+	}
+
+	// Without the ranges, addresses no asset owns can't be told dangling
+	ipRangesF := NewAwsIpRangesFetcher(inventory.Region, r.logger)
+	if inventory.AwsIpRanges, err = ipRangesF.Fetch(ctx); err != nil {
+		inventory.MissingData = r.missing(inventory.MissingData, DataAwsIpRanges, err)
+	}
+
+	// Images are fetched last, as they are the ones used by instances and launch templates, on
+	// top of the ones the account owns
+	imagesF := NewEc2ImagesFetcher(awsCfg, inventory.AccountId, r.logger)
+	if inventory.Amis, err = imagesF.Fetch(ctx, usedImageIds(inventory)); err != nil {
+		return inventory, err
+	}
+
+	return inventory, nil
+}
+
+// attachSecurityGroupRules gives every instance the rules of its security groups, as the
+// dump sources do, rather than describing the groups again for each instance
+func (r *RelationBuilder) attachSecurityGroupRules(instances []Ec2Instance, groups []SecurityGroup) []Ec2Instance {
+	byId := make(map[string]SecurityGroup, len(groups))
+	for _, group := range groups {
+		byId[group.Id] = group
+	}
+
+	for idx, instance := range instances {
+		ingress := make([]Ec2SecGroupRule, 0, len(instance.SecurityGroupIds)*5)
+		egress := make([]Ec2SecGroupRule, 0, len(instance.SecurityGroupIds)*5)
+		for _, groupId := range instance.SecurityGroupIds {
+			group, ok := byId[groupId]
+			if !ok {
+				r.logger.Warn("Security group " + groupId + " of instance " + instance.Id + " wasn't fetched, its rules are left out")
+				continue
+			}
+
+			ingress = append(ingress, group.IngressRules...)
+			egress = append(egress, group.EgressRules...)
+		}
+
+		instances[idx].IngressSecRules = ingress
+		instances[idx].EgressSecRules = egress
+	}
+
+	return instances
+}
+
+func (r *RelationBuilder) missing(missingData []string, data string, err error) []string {
+	r.logger.Warn("Couldn't fetch " + data + ", going on without it: " + err.Error())
+	return append(missingData, data)
 }
@@ -0,0 +1,32 @@
+package aws
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// parsePrefix accepts CIDRs as well as single addresses, which become /32 or /128
+func parsePrefix(value string) (netip.Prefix, bool) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+
+		return prefix.Masked(), true
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// prefixContains tells whether every address of inner is in outer
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Addr().Is4() == inner.Addr().Is4() &&
+		outer.Bits() <= inner.Bits() &&
+		outer.Contains(inner.Addr())
+}
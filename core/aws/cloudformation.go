@@ -0,0 +1,722 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"slices"
+	"strings"
+)
+
+// awsNoValue removes the property it's the value of
+const awsNoValue = "AWS::NoValue"
+
+// Id prefixes of CloudFormation resources, which only have logical ids before they are created
+var cfnIdPrefixes = map[string]string{
+	"AWS::EC2::Instance":             "i-",
+	"AWS::EC2::SecurityGroup":        "sg-",
+	"AWS::EC2::VPC":                  "vpc-",
+	"AWS::EC2::Subnet":               "subnet-",
+	"AWS::EC2::InternetGateway":      "igw-",
+	"AWS::EC2::RouteTable":           "rtb-",
+	"AWS::EC2::NatGateway":           "nat-",
+	"AWS::EC2::NetworkAcl":           "acl-",
+	"AWS::EC2::TransitGateway":       "tgw-",
+	"AWS::EC2::VPNGateway":           "vgw-",
+	"AWS::EC2::VPCPeeringConnection": "pcx-",
+}
+
+// CloudFormationSource loads the inventory from a CloudFormation template, in JSON or YAML,
+// with the parameters of the stack
+type CloudFormationSource struct {
+	templatePath   string
+	parametersPath string
+}
+
+// NewCloudFormationSource takes the template and, optionally, a parameters file. Parameters
+// not in it take their default value
+func NewCloudFormationSource(templatePath, parametersPath string) CloudFormationSource {
+	return CloudFormationSource{templatePath: templatePath, parametersPath: parametersPath}
+}
+
+func (c CloudFormationSource) Name() string {
+	return "CloudFormation template " + c.templatePath
+}
+
+type cfnTemplate struct {
+	Parameters map[string]struct {
+		Type    string
+		Default any
+	}
+	Mappings   map[string]map[string]map[string]any
+	Conditions map[string]any
+	Resources  map[string]struct {
+		Type       string
+		Condition  string
+		Properties map[string]any
+	}
+}
+
+func (c CloudFormationSource) Load(_ context.Context) (Inventory, error) {
+	data, err := os.ReadFile(c.templatePath)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	template, err := parseCfnTemplate(data)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	parameters := map[string]string{}
+	if c.parametersPath != "" {
+		if data, err = os.ReadFile(c.parametersPath); err != nil {
+			return Inventory{}, err
+		}
+
+		if parameters, err = parseCfnParameters(data); err != nil {
+			return Inventory{}, err
+		}
+	}
+
+	stack, err := newCfnStack(template, parameters)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	resources := stack.resources()
+	if *stack.err != nil {
+		return Inventory{}, *stack.err
+	}
+
+	inv, err := overlayTerraform(Inventory{}, resources, nil)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	inv.AccountId, inv.Region = parameters["AWS::AccountId"], parameters["AWS::Region"]
+
+	return markMissingData(inv), nil
+}
+
+// parseCfnTemplate reads JSON and YAML templates. The short form of functions in YAML, like
+// !Ref or !GetAtt, is turned into the full one
+func parseCfnTemplate(data []byte) (cfnTemplate, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return cfnTemplate{}, fmt.Errorf("invalid CloudFormation template: %w", err)
+	}
+
+	value, err := cfnNodeValue(&root)
+	if err != nil {
+		return cfnTemplate{}, fmt.Errorf("invalid CloudFormation template: %w", err)
+	}
+
+	// Going through JSON gives numbers and maps the types JSON templates have
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return cfnTemplate{}, err
+	}
+
+	var template cfnTemplate
+	if err := json.Unmarshal(converted, &template); err != nil {
+		return cfnTemplate{}, fmt.Errorf("invalid CloudFormation template: %w", err)
+	}
+
+	if len(template.Resources) == 0 {
+		return cfnTemplate{}, fmt.Errorf("invalid CloudFormation template: no resources")
+	}
+
+	return template, nil
+}
+
+func cfnNodeValue(node *yaml.Node) (any, error) {
+	var value any
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return cfnNodeValue(node.Content[0])
+	case yaml.AliasNode:
+		return cfnNodeValue(node.Alias)
+	case yaml.MappingNode:
+		mapping := make(map[string]any, len(node.Content)/2)
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			item, err := cfnNodeValue(node.Content[idx+1])
+			if err != nil {
+				return nil, err
+			}
+
+			mapping[node.Content[idx].Value] = item
+		}
+		value = mapping
+	case yaml.SequenceNode:
+		sequence := make([]any, 0, len(node.Content))
+		for _, child := range node.Content {
+			item, err := cfnNodeValue(child)
+			if err != nil {
+				return nil, err
+			}
+
+			sequence = append(sequence, item)
+		}
+		value = sequence
+	default:
+		if err := node.Decode(&value); err != nil {
+			// Short form functions have scalars with tags the decoder doesn't know
+			value = node.Value
+		}
+	}
+
+	// Tags of YAML types start with !!, like !!str
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return value, nil
+	}
+
+	function := strings.TrimPrefix(node.Tag, "!")
+	switch function {
+	case "Ref", "Condition":
+		return map[string]any{function: value}, nil
+	case "GetAtt":
+		if attribute, isString := value.(string); isString {
+			resource, name, _ := strings.Cut(attribute, ".")
+			value = []any{resource, name}
+		}
+	}
+
+	return map[string]any{"Fn::" + function: value}, nil
+}
+
+// parseCfnParameters reads the parameters as given to the AWS CLI, a list of ParameterKey and
+// ParameterValue, or as a map of names to values, alone or under Parameters
+func parseCfnParameters(data []byte) (map[string]string, error) {
+	parameters := map[string]string{}
+
+	var list []struct {
+		ParameterKey   string
+		ParameterValue string
+	}
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, parameter := range list {
+			parameters[parameter.ParameterKey] = parameter.ParameterValue
+		}
+
+		return parameters, nil
+	}
+
+	var mapping map[string]any
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("invalid CloudFormation parameters: %w", err)
+	}
+
+	if nested, isMap := mapping["Parameters"].(map[string]any); isMap {
+		mapping = nested
+	}
+
+	for name, value := range mapping {
+		parameters[name] = fmt.Sprint(value)
+	}
+
+	return parameters, nil
+}
+
+// cfnStack resolves the functions of a template with the parameters of the stack. Functions
+// that can't be evaluated, like a condition referencing itself, record the first error in err
+type cfnStack struct {
+	template   cfnTemplate
+	parameters map[string]any
+	conditions map[string]bool
+	// evaluating are the conditions being evaluated, to catch cycles
+	evaluating map[string]bool
+	err        *error
+}
+
+func newCfnStack(template cfnTemplate, values map[string]string) (cfnStack, error) {
+	s := cfnStack{
+		template:   template,
+		parameters: map[string]any{"AWS::Partition": "aws", "AWS::URLSuffix": "amazonaws.com", "AWS::StackName": "stack"},
+		conditions: make(map[string]bool, len(template.Conditions)),
+		evaluating: make(map[string]bool),
+		err:        new(error),
+	}
+
+	for name, value := range values {
+		if strings.HasPrefix(name, "AWS::") {
+			s.parameters[name] = value
+		}
+	}
+
+	for name, parameter := range template.Parameters {
+		value, given := values[name]
+		switch {
+		case given:
+		case parameter.Default != nil:
+			value = fmt.Sprint(parameter.Default)
+		default:
+			return s, fmt.Errorf("parameter %s has no value nor default", name)
+		}
+
+		// Lists are comma delimited
+		if strings.HasPrefix(parameter.Type, "List<") || parameter.Type == "CommaDelimitedList" {
+			items := make([]any, 0, strings.Count(value, ",")+1)
+			for _, item := range strings.Split(value, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+			s.parameters[name] = items
+			continue
+		}
+
+		s.parameters[name] = value
+	}
+
+	for name := range template.Conditions {
+		s.conditions[name] = s.condition(name)
+	}
+
+	return s, *s.err
+}
+
+func (s cfnStack) condition(name string) bool {
+	if value, evaluated := s.conditions[name]; evaluated {
+		return value
+	}
+
+	if s.evaluating[name] {
+		s.fail(fmt.Errorf("condition %s references itself", name))
+		return false
+	}
+
+	s.evaluating[name] = true
+	defer delete(s.evaluating, name)
+
+	value, _ := s.resolve(s.template.Conditions[name]).(bool)
+	return value
+}
+
+// fail records the error unless one was already
+func (s cfnStack) fail(err error) {
+	if *s.err == nil {
+		*s.err = err
+	}
+}
+
+// resolve evaluates the functions of a value. What can't be known before the stack is created,
+// like the attributes of resources, gets placeholders
+func (s cfnStack) resolve(value any) any {
+	switch typed := value.(type) {
+	case []any:
+		resolved := make([]any, 0, len(typed))
+		for _, item := range typed {
+			if item = s.resolve(item); item != awsNoValue {
+				resolved = append(resolved, item)
+			}
+		}
+		return resolved
+	case map[string]any:
+		if len(typed) == 1 {
+			for name, argument := range typed {
+				if resolved, isFunction := s.function(name, argument); isFunction {
+					return resolved
+				}
+			}
+		}
+
+		resolved := make(map[string]any, len(typed))
+		for key, item := range typed {
+			if item = s.resolve(item); item != awsNoValue {
+				resolved[key] = item
+			}
+		}
+		return resolved
+	default:
+		return value
+	}
+}
+
+func (s cfnStack) function(name string, argument any) (any, bool) {
+	switch name {
+	case "Ref":
+		reference := fmt.Sprint(argument)
+		if value, isParameter := s.parameters[reference]; isParameter {
+			return value, true
+		}
+
+		if reference == awsNoValue {
+			return awsNoValue, true
+		}
+
+		return s.physicalId(reference), true
+	case "Condition":
+		return s.condition(fmt.Sprint(argument)), true
+	case "Fn::GetAtt":
+		if attribute, isString := argument.(string); isString {
+			resource, name, _ := strings.Cut(attribute, ".")
+			argument = []any{resource, name}
+		}
+
+		arguments := cfnStrings(s.resolve(argument))
+		if len(arguments) != 2 {
+			return "", true
+		}
+		return s.attribute(arguments[0], arguments[1]), true
+	case "Fn::Sub":
+		return s.sub(argument), true
+	case "Fn::Join":
+		arguments, _ := s.resolve(argument).([]any)
+		if len(arguments) != 2 {
+			return "", true
+		}
+		return strings.Join(cfnStrings(arguments[1]), fmt.Sprint(arguments[0])), true
+	case "Fn::Select":
+		arguments, _ := s.resolve(argument).([]any)
+		if len(arguments) != 2 {
+			return "", true
+		}
+		index := tfInt32(map[string]any{"index": arguments[0]}, "index")
+		if index < 0 {
+			s.fail(fmt.Errorf("Fn::Select index %d is negative", index))
+			return "", true
+		}
+		list, _ := arguments[1].([]any)
+		if int(index) >= len(list) {
+			return "", true
+		}
+		return list[index], true
+	case "Fn::Split":
+		arguments := cfnStrings(s.resolve(argument))
+		if len(arguments) != 2 {
+			return []any{}, true
+		}
+		parts := make([]any, 0)
+		for _, part := range strings.Split(arguments[1], arguments[0]) {
+			parts = append(parts, part)
+		}
+		return parts, true
+	case "Fn::FindInMap":
+		arguments := cfnStrings(s.resolve(argument))
+		if len(arguments) < 3 {
+			return "", true
+		}
+		return s.resolve(s.template.Mappings[arguments[0]][arguments[1]][arguments[2]]), true
+	case "Fn::If":
+		arguments, _ := argument.([]any)
+		if len(arguments) != 3 {
+			return "", true
+		}
+		if s.condition(fmt.Sprint(arguments[0])) {
+			return s.resolve(arguments[1]), true
+		}
+		return s.resolve(arguments[2]), true
+	case "Fn::Equals":
+		arguments, _ := s.resolve(argument).([]any)
+		return len(arguments) == 2 && fmt.Sprint(arguments[0]) == fmt.Sprint(arguments[1]), true
+	case "Fn::Not":
+		arguments, _ := s.resolve(argument).([]any)
+		return len(arguments) == 1 && arguments[0] == false, true
+	case "Fn::And", "Fn::Or":
+		arguments, _ := s.resolve(argument).([]any)
+		result := name == "Fn::And"
+		for _, item := range arguments {
+			if name == "Fn::And" {
+				result = result && item == true
+			} else {
+				result = result || item == true
+			}
+		}
+		return result, true
+	case "Fn::Base64", "Fn::GetAZs", "Fn::ImportValue", "Fn::Cidr":
+		return s.resolve(argument), true
+	default:
+		return nil, false
+	}
+}
+
+// sub replaces ${Name} with parameters and resources, and ${Resource.Attribute} with their
+// attributes
+func (s cfnStack) sub(argument any) string {
+	template, variables := "", map[string]any{}
+	switch typed := argument.(type) {
+	case string:
+		template = typed
+	case []any:
+		if len(typed) > 0 {
+			template = fmt.Sprint(typed[0])
+		}
+		if len(typed) > 1 {
+			variables, _ = s.resolve(typed[1]).(map[string]any)
+		}
+	}
+
+	var builder strings.Builder
+	for {
+		start := strings.Index(template, "${")
+		end := strings.Index(template[max(start, 0):], "}")
+		if start < 0 || end < 0 {
+			builder.WriteString(template)
+			return builder.String()
+		}
+
+		name := template[start+2 : start+end]
+		builder.WriteString(template[:start])
+		template = template[start+end+1:]
+
+		switch value, isVariable := variables[name]; {
+		case strings.HasPrefix(name, "!"):
+			builder.WriteString("${" + name[1:] + "}")
+		case isVariable:
+			builder.WriteString(fmt.Sprint(value))
+		case strings.Contains(name, "."):
+			resource, attribute, _ := strings.Cut(name, ".")
+			builder.WriteString(fmt.Sprint(s.attribute(resource, attribute)))
+		default:
+			reference, _ := s.function("Ref", name)
+			builder.WriteString(fmt.Sprint(reference))
+		}
+	}
+}
+
+// physicalId is the id of the resource once created, made of its logical id
+func (s cfnStack) physicalId(logicalId string) string {
+	return cfnIdPrefixes[s.template.Resources[logicalId].Type] + "{" + logicalId + "}"
+}
+
+func (s cfnStack) attribute(logicalId, attribute string) any {
+	resource := s.template.Resources[logicalId]
+
+	switch {
+	case attribute == "GroupId", attribute == "VpcId" && resource.Type == "AWS::EC2::VPC", attribute == "SubnetId":
+		return s.physicalId(logicalId)
+	case attribute == "CidrBlock", attribute == "PrivateIp" && resource.Properties["PrivateIpAddress"] != nil:
+		property := attribute
+		if attribute == "PrivateIp" {
+			property = "PrivateIpAddress"
+		}
+		return s.resolve(resource.Properties[property])
+	case attribute == "PublicIp":
+		return knownAfterApply
+	default:
+		return "{" + logicalId + "." + attribute + "}"
+	}
+}
+
+func cfnStrings(value any) []string {
+	list, _ := value.([]any)
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		strs = append(strs, fmt.Sprint(item))
+	}
+
+	return strs
+}
+
+// resources converts the resources of the stack to the Terraform resources they match, so
+// the same mapping applies
+func (s cfnStack) resources() []terraformResource {
+	attachments := map[string]string{}
+	for _, resource := range s.template.Resources {
+		if resource.Type != "AWS::EC2::VPCGatewayAttachment" || resource.Condition != "" && !s.condition(resource.Condition) {
+			continue
+		}
+
+		properties, _ := s.resolve(resource.Properties).(map[string]any)
+		if properties == nil {
+			continue
+		}
+		attachments[fmt.Sprint(properties["InternetGatewayId"])] = fmt.Sprint(properties["VpcId"])
+	}
+
+	logicalIds := make([]string, 0, len(s.template.Resources))
+	for logicalId := range s.template.Resources {
+		logicalIds = append(logicalIds, logicalId)
+	}
+	slices.Sort(logicalIds)
+
+	resources := make([]terraformResource, 0, len(logicalIds))
+	for _, logicalId := range logicalIds {
+		resource := s.template.Resources[logicalId]
+		if resource.Condition != "" && !s.condition(resource.Condition) {
+			continue
+		}
+
+		properties, _ := s.resolve(resource.Properties).(map[string]any)
+		if properties == nil {
+			properties = map[string]any{}
+		}
+
+		resType, values := cfnResourceValues(resource.Type, properties)
+		if resType == "" {
+			continue
+		}
+
+		values["id"] = s.physicalId(logicalId)
+		if resType == tfInternetGateway {
+			values["vpc_id"] = attachments[s.physicalId(logicalId)]
+		}
+
+		resources = append(resources, terraformResource{Address: logicalId, Type: resType, Values: values})
+	}
+
+	return resources
+}
+
+// cfnResourceValues maps the properties of a CloudFormation resource to the attributes of the
+// matching Terraform resource
+func cfnResourceValues(resType string, p map[string]any) (string, map[string]any) {
+	switch resType {
+	case "AWS::EC2::VPC":
+		return tfVpc, map[string]any{"cidr_block": p["CidrBlock"]}
+	case "AWS::EC2::Subnet":
+		return tfSubnet, map[string]any{
+			"vpc_id":                  p["VpcId"],
+			"cidr_block":              p["CidrBlock"],
+			"availability_zone":       p["AvailabilityZone"],
+			"map_public_ip_on_launch": p["MapPublicIpOnLaunch"],
+		}
+	case "AWS::EC2::InternetGateway":
+		return tfInternetGateway, map[string]any{}
+	case "AWS::EC2::RouteTable":
+		return tfRouteTable, map[string]any{"vpc_id": p["VpcId"]}
+	case "AWS::EC2::Route":
+		return tfRoute, map[string]any{
+			"route_table_id":              p["RouteTableId"],
+			"destination_cidr_block":      p["DestinationCidrBlock"],
+			"destination_ipv6_cidr_block": p["DestinationIpv6CidrBlock"],
+			"destination_prefix_list_id":  p["DestinationPrefixListId"],
+			"gateway_id":                  p["GatewayId"],
+			"nat_gateway_id":              p["NatGatewayId"],
+			"transit_gateway_id":          p["TransitGatewayId"],
+			"vpc_peering_connection_id":   p["VpcPeeringConnectionId"],
+			"network_interface_id":        p["NetworkInterfaceId"],
+			"vpc_endpoint_id":             p["VpcEndpointId"],
+			"egress_only_gateway_id":      p["EgressOnlyInternetGatewayId"],
+		}
+	case "AWS::EC2::SubnetRouteTableAssociation":
+		return tfRouteTableAssociation, map[string]any{"subnet_id": p["SubnetId"], "route_table_id": p["RouteTableId"]}
+	case "AWS::EC2::SecurityGroup":
+		ingress := make([]any, 0)
+		for _, rule := range cfnList(p["SecurityGroupIngress"]) {
+			ingress = append(ingress, cfnRuleBlock(rule, "SourceSecurityGroupId", "SourcePrefixListId"))
+		}
+
+		// Without egress rules the group allows all outbound traffic, as in AWS
+		egress := []any{map[string]any{"protocol": allProtocols, "cidr_blocks": []any{anyIPv4}}}
+		if p["SecurityGroupEgress"] != nil {
+			egress = make([]any, 0)
+			for _, rule := range cfnList(p["SecurityGroupEgress"]) {
+				egress = append(egress, cfnRuleBlock(rule, "DestinationSecurityGroupId", "DestinationPrefixListId"))
+			}
+		}
+
+		return tfSecurityGroup, map[string]any{
+			"name":        p["GroupName"],
+			"description": p["GroupDescription"],
+			"vpc_id":      p["VpcId"],
+			"ingress":     ingress,
+			"egress":      egress,
+			"tags":        cfnTags(p["Tags"]),
+		}
+	case "AWS::EC2::SecurityGroupIngress", "AWS::EC2::SecurityGroupEgress":
+		ruleType, source, prefixList := "ingress", "SourceSecurityGroupId", "SourcePrefixListId"
+		if resType == "AWS::EC2::SecurityGroupEgress" {
+			ruleType, source, prefixList = "egress", "DestinationSecurityGroupId", "DestinationPrefixListId"
+		}
+
+		values := cfnRuleBlock(p, source, prefixList)
+		values["type"] = ruleType
+		values["security_group_id"] = p["GroupId"]
+		values["source_security_group_id"] = p[source]
+		return tfSecurityGroupRule, values
+	case "AWS::EC2::Instance":
+		values := map[string]any{
+			"ami":                    p["ImageId"],
+			"subnet_id":              p["SubnetId"],
+			"vpc_security_group_ids": p["SecurityGroupIds"],
+			"key_name":               p["KeyName"],
+			"iam_instance_profile":   p["IamInstanceProfile"],
+			"private_ip":             p["PrivateIpAddress"],
+			"tags":                   cfnTags(p["Tags"]),
+		}
+
+		// The primary interface holds the network settings when interfaces are given
+		for _, eni := range cfnList(p["NetworkInterfaces"]) {
+			if tfInt32(eni, "DeviceIndex") != 0 {
+				continue
+			}
+
+			values["subnet_id"] = eni["SubnetId"]
+			values["vpc_security_group_ids"] = eni["GroupSet"]
+			values["private_ip"] = eni["PrivateIpAddress"]
+			if eni["AssociatePublicIpAddress"] != nil {
+				values["associate_public_ip_address"] = tfBool(eni, "AssociatePublicIpAddress")
+			}
+		}
+		return tfInstance, values
+	case "AWS::EC2::EIP":
+		return tfEip, map[string]any{"instance": p["InstanceId"]}
+	case "AWS::EC2::EIPAssociation":
+		return tfEipAssociation, map[string]any{"instance_id": p["InstanceId"]}
+	case "AWS::EC2::NetworkAcl":
+		return tfNetworkAcl, map[string]any{"vpc_id": p["VpcId"]}
+	case "AWS::EC2::NetworkAclEntry":
+		portRange, _ := p["PortRange"].(map[string]any)
+		return tfNetworkAclRule, map[string]any{
+			"network_acl_id":  p["NetworkAclId"],
+			"rule_number":     p["RuleNumber"],
+			"egress":          p["Egress"],
+			"protocol":        p["Protocol"],
+			"rule_action":     p["RuleAction"],
+			"cidr_block":      p["CidrBlock"],
+			"ipv6_cidr_block": p["Ipv6CidrBlock"],
+			"from_port":       portRange["From"],
+			"to_port":         portRange["To"],
+		}
+	case "AWS::EC2::SubnetNetworkAclAssociation":
+		return tfNetworkAclAssociation, map[string]any{"subnet_id": p["SubnetId"], "network_acl_id": p["NetworkAclId"]}
+	default:
+		return "", nil
+	}
+}
+
+// cfnRuleBlock converts a security group rule to the ingress and egress blocks of Terraform
+func cfnRuleBlock(rule map[string]any, source, prefixList string) map[string]any {
+	block := map[string]any{
+		"protocol":         rule["IpProtocol"],
+		"from_port":        rule["FromPort"],
+		"to_port":          rule["ToPort"],
+		"cidr_blocks":      cfnSingle(rule["CidrIp"]),
+		"ipv6_cidr_blocks": cfnSingle(rule["CidrIpv6"]),
+		"security_groups":  cfnSingle(rule[source]),
+		"prefix_list_ids":  cfnSingle(rule[prefixList]),
+	}
+
+	// All protocols have no ports, CloudFormation takes -1 for them
+	if fmt.Sprint(rule["IpProtocol"]) == allProtocols {
+		block["from_port"], block["to_port"] = 0.0, 0.0
+	}
+
+	return block
+}
+
+func cfnSingle(value any) []any {
+	if value == nil {
+		return []any{}
+	}
+
+	return []any{value}
+}
+
+func cfnList(value any) []map[string]any {
+	return tfBlocks(map[string]any{"list": value}, "list")
+}
+
+func cfnTags(value any) map[string]any {
+	tags := map[string]any{}
+	for _, tag := range cfnList(value) {
+		tags[tfString(tag, "Key")] = tfString(tag, "Value")
+	}
+
+	return tags
+}
@@ -0,0 +1,211 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"net/netip"
+	"strings"
+)
+
+var resolvableRecordTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true}
+
+const dnsMaxChainLength = 5
+
+// resolveAll links A, AAAA, CNAME and alias records to the assets they point to.
+// Values that land in AWS namespaces we fully fetch (our region's instances and load
+// balancers, CloudFront) but match no asset are dangling, as well as addresses of the EC2
+// ranges of our region no asset owns. Other addresses no asset owns are unknown, they may
+// belong to another region or account of ours, or to anyone outside AWS.
+func (r dnsResolver) resolveAll(records []DnsRecord) []DnsResolution {
+	resolutions := make([]DnsResolution, 0, len(records))
+	for _, record := range records {
+		if !resolvableRecordTypes[record.Type] {
+			continue
+		}
+
+		resolutions = append(resolutions, r.resolve(record))
+	}
+
+	return resolutions
+}
+
+type dnsResolver struct {
+	region    string
+	ranges    []netip.Prefix
+	addresses map[string]AssetRef
+	hostnames map[string]AssetRef
+	records   map[string]DnsRecord
+}
+
+func newDnsResolver(inv Inventory, addresses []IpAddress) dnsResolver {
+	r := dnsResolver{
+		region:    inv.Region,
+		addresses: make(map[string]AssetRef, len(addresses)),
+		hostnames: make(map[string]AssetRef, len(inv.Instances)+len(inv.LoadBalancers)+len(inv.DnsRecords)),
+		records:   make(map[string]DnsRecord, len(inv.DnsRecords)),
+	}
+
+	for _, ipRange := range inv.AwsIpRanges {
+		if prefix, valid := parsePrefix(ipRange); valid {
+			r.ranges = append(r.ranges, prefix)
+		}
+	}
+
+	for _, addr := range addresses {
+		r.addresses[addr.Address] = AssetRef{AssetType: addr.OwnerType, AssetId: addr.OwnerId}
+	}
+
+	for _, inst := range inv.Instances {
+		target := AssetRef{AssetType: AssetEc2Instance, AssetId: inst.Id}
+		r.addHostname(inst.PrivateDNS, target)
+		r.addHostname(ptr.Deref(inst.PublicDNS), target)
+	}
+
+	for _, lb := range inv.LoadBalancers {
+		r.addHostname(lb.DNSName, AssetRef{AssetType: AssetLoadBalancer, AssetId: lb.Arn})
+	}
+
+	for _, distribution := range inv.CloudFrontDistributions {
+		r.addHostname(distribution.DomainName, AssetRef{AssetType: AssetCloudFrontDistribution, AssetId: distribution.Id})
+	}
+
+	// Records pointing to other records of ours are resolved through the graph
+	for _, record := range inv.DnsRecords {
+		r.addHostname(record.Name, AssetRef{AssetType: AssetDnsRecord, AssetId: record.Id()})
+		r.records[record.Id()] = record
+	}
+
+	return r
+}
+
+func (r dnsResolver) addHostname(hostname string, target AssetRef) {
+	if hostname == "" {
+		return
+	}
+
+	hostname = normalizeHostname(hostname)
+	if _, exists := r.hostnames[hostname]; !exists {
+		r.hostnames[hostname] = target
+	}
+}
+
+func (r dnsResolver) resolve(record DnsRecord) DnsResolution {
+	resolution := DnsResolution{Record: record}
+	unknown := false
+
+	values := record.Values
+	if record.AliasTarget != nil {
+		values = []string{*record.AliasTarget}
+	}
+
+	for _, value := range values {
+		target, status := r.resolveValue(value)
+		switch status {
+		case DnsResolved:
+			resolution.Targets = append(resolution.Targets, target)
+		case DnsDangling:
+			resolution.DanglingValues = append(resolution.DanglingValues, value)
+		case DnsUnknown:
+			unknown = true
+		}
+	}
+
+	switch {
+	case len(resolution.DanglingValues) > 0:
+		resolution.Status = DnsDangling
+	case len(resolution.Targets) > 0:
+		resolution.Status = DnsResolved
+	case unknown:
+		resolution.Status = DnsUnknown
+	default:
+		resolution.Status = DnsExternal
+	}
+
+	return resolution
+}
+
+func (r dnsResolver) resolveValue(value string) (AssetRef, string) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		if target, owned := r.addresses[addr.String()]; owned {
+			return target, DnsResolved
+		}
+
+		if r.inAwsRanges(addr) {
+			return AssetRef{}, DnsDangling
+		}
+
+		return AssetRef{}, DnsUnknown
+	}
+
+	hostname := normalizeHostname(value)
+	if target, owned := r.hostnames[hostname]; owned {
+		return target, DnsResolved
+	}
+
+	if r.isInventoriedHostname(hostname) {
+		return AssetRef{}, DnsDangling
+	}
+
+	if strings.HasSuffix(hostname, ".amazonaws.com") || strings.HasSuffix(hostname, ".aws") {
+		return AssetRef{}, DnsUnknown
+	}
+
+	return AssetRef{}, DnsExternal
+}
+
+// resolveAsset finds the asset behind a hostname or address, following our own records
+// until they point to something that is not a record
+func (r dnsResolver) resolveAsset(value string) (AssetRef, bool) {
+	for range dnsMaxChainLength {
+		target, status := r.resolveValue(value)
+		if status != DnsResolved {
+			return AssetRef{}, false
+		}
+
+		if target.AssetType != AssetDnsRecord {
+			return target, true
+		}
+
+		record := r.records[target.AssetId]
+		switch {
+		case record.AliasTarget != nil:
+			value = *record.AliasTarget
+		case len(record.Values) > 0:
+			value = record.Values[0]
+		default:
+			return AssetRef{}, false
+		}
+	}
+
+	return AssetRef{}, false
+}
+
+// inAwsRanges tells whether the address comes from the EC2 ranges of our region
+func (r dnsResolver) inAwsRanges(addr netip.Addr) bool {
+	for _, prefix := range r.ranges {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isInventoriedHostname tells whether the hostname belongs to a namespace where we know
+// every asset, so a hostname we don't know can't belong to us anymore
+func (r dnsResolver) isInventoriedHostname(hostname string) bool {
+	if strings.HasSuffix(hostname, ".cloudfront.net") {
+		return true
+	}
+
+	// EC2 and load balancers from us-east-1 have their own hostnames
+	if r.region == "us-east-1" &&
+		(strings.HasSuffix(hostname, ".compute-1.amazonaws.com") || strings.HasSuffix(hostname, ".ec2.internal")) {
+		return true
+	}
+
+	inRegion := strings.Contains(hostname, "."+r.region+".")
+	isEc2 := strings.HasSuffix(hostname, ".compute.amazonaws.com") || strings.HasSuffix(hostname, ".compute.internal")
+	isElb := strings.HasSuffix(hostname, ".elb.amazonaws.com") || strings.Contains(hostname, ".elb."+r.region+".amazonaws.com")
+
+	return inRegion && (isEc2 || isElb)
+}
@@ -0,0 +1,26 @@
+package aws
+
+import "testing"
+
+func TestResolveDanglingAddresses(t *testing.T) {
+	inv := Inventory{Region: "eu-west-1", AwsIpRanges: []string{"3.248.0.0/13", "2a05:d018::/36"}}
+	resolver := newDnsResolver(inv, []IpAddress{{Address: "3.250.0.10", OwnerType: AssetElasticIp, OwnerId: "eipalloc-1"}})
+
+	cases := map[string]string{
+		"3.250.0.10":       DnsResolved,
+		"3.250.0.11":       DnsDangling,
+		"2a05:d018::1":     DnsDangling,
+		"198.51.100.7":     DnsUnknown,
+		"www.example.com.": DnsExternal,
+	}
+
+	for value, expected := range cases {
+		if _, status := resolver.resolveValue(value); status != expected {
+			t.Errorf("%s: expected %s, got %s", value, expected, status)
+		}
+	}
+
+	if _, status := newDnsResolver(Inventory{Region: "eu-west-1"}, nil).resolveValue("3.250.0.11"); status != DnsUnknown {
+		t.Errorf("expected addresses to be unknown without the AWS ranges, got %s", status)
+	}
+}
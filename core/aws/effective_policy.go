@@ -0,0 +1,340 @@
+package aws
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// PolicyEntry is a single (protocol, port range, peer) tuple allowed by security groups. The
+// peer is a CIDR, a security group id or a prefix list id
+type PolicyEntry struct {
+	Protocol string
+	Ports    PortRange
+	Peer     string
+}
+
+func (p PolicyEntry) String() string {
+	return fmt.Sprintf("%s %s %s", p.Protocol, p.Ports, p.Peer)
+}
+
+// EffectivePolicy is what all security groups of an instance allow together
+type EffectivePolicy struct {
+	Ingress []PolicyEntry
+	Egress  []PolicyEntry
+}
+
+// RedundantRule is a rule that can be removed without changing what's allowed, as other rules
+// already allow all of it
+type RedundantRule struct {
+	GroupId        string
+	Direction      string
+	Protocol       string
+	Ports          string
+	IpRanges       []string
+	SourceGroupIds []string
+	PrefixListIds  []string
+	CoveredBy      []string
+}
+
+type SecurityGroupRedundancy struct {
+	GroupId        string
+	RedundantRules []RedundantRule
+}
+
+// policyAtom is one peer of one rule. A rule with several ranges, groups or prefix lists has
+// several atoms
+type policyAtom struct {
+	rule     int
+	protocol string
+	ports    PortRange
+	peer     string
+}
+
+func ruleAtoms(ruleIdx int, rule Ec2SecGroupRule) []policyAtom {
+	atoms := make([]policyAtom, 0, len(rule.IpRanges)+len(rule.SourceGroupIds)+len(rule.PrefixListIds))
+	for _, peer := range slices.Concat(rule.IpRanges, rule.SourceGroupIds, rule.PrefixListIds) {
+		atoms = append(atoms, policyAtom{
+			rule:     ruleIdx,
+			protocol: normalizeProtocol(rule.IpProtocol),
+			ports:    rulePorts(rule),
+			peer:     peer,
+		})
+	}
+
+	return atoms
+}
+
+// peerContains tells whether every address of inner is allowed by outer. Security groups and
+// prefix lists only contain themselves, as their addresses aren't resolved
+func peerContains(outer, inner string) bool {
+	if outer == inner {
+		return true
+	}
+
+	outerPrefix, outerValid := parsePrefix(outer)
+	innerPrefix, innerValid := parsePrefix(inner)
+
+	return outerValid && innerValid && prefixContains(outerPrefix, innerPrefix)
+}
+
+// size is how much the atom allows, to remove narrow atoms before the broad ones covering them
+func (a policyAtom) size() float64 {
+	ports := a.ports
+	if a.protocol == allProtocols {
+		ports = allPorts
+	}
+
+	addresses := 1.0
+	if prefix, valid := parsePrefix(a.peer); valid {
+		addresses = math.Pow(2, float64(prefix.Addr().BitLen()-prefix.Bits()))
+	}
+
+	return float64(ports.To-ports.From+1) * addresses
+}
+
+// coveredBy tells whether the atom is fully allowed by the union of the atoms of other rules
+// that weren't removed, and which rules cover it
+func (a policyAtom) coveredBy(atoms []policyAtom, removed map[int]bool) ([]int, bool) {
+	ranges := make([]PortRange, 0, len(atoms))
+	coveringRules := make([]int, 0, len(atoms))
+
+	for _, other := range atoms {
+		if other.rule == a.rule || removed[other.rule] {
+			continue
+		}
+
+		if other.protocol != allProtocols && other.protocol != a.protocol {
+			continue
+		}
+
+		if !peerContains(other.peer, a.peer) {
+			continue
+		}
+
+		ports := other.ports
+		if other.protocol == allProtocols {
+			ports = allPorts
+		}
+
+		if len(intersectPortRanges([]PortRange{ports}, []PortRange{a.ports})) == 0 {
+			continue
+		}
+
+		ranges = append(ranges, ports)
+		if !slices.Contains(coveringRules, other.rule) {
+			coveringRules = append(coveringRules, other.rule)
+		}
+	}
+
+	for _, portRange := range mergePortRanges(ranges) {
+		if portRange.From <= a.ports.From && portRange.To >= a.ports.To {
+			return coveringRules, true
+		}
+	}
+
+	return nil, false
+}
+
+// removeCovered removes, from the narrowest to the broadest, every group of atoms the remaining
+// ones cover. Going one by one matters: 22 and 23 are both covered by 22-23, which is covered by
+// them together, but only the first two can go. It returns the removed groups with the groups
+// covering them
+func removeCovered(atoms []policyAtom, groups int) map[int][]int {
+	sizes := make([]float64, groups)
+	for _, atom := range atoms {
+		sizes[atom.rule] = max(sizes[atom.rule], atom.size())
+	}
+
+	order := make([]int, 0, groups)
+	for idx := range groups {
+		order = append(order, idx)
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(sizes[a], sizes[b])
+	})
+
+	removed := make(map[int]bool, groups)
+	coveredBy := make(map[int][]int, groups)
+	for _, group := range order {
+		covering := make([]int, 0, groups)
+		covered := false
+		for _, atom := range atoms {
+			if atom.rule != group {
+				continue
+			}
+
+			atomCovering, isCovered := atom.coveredBy(atoms, removed)
+			if covered = isCovered; !covered {
+				break
+			}
+
+			for _, coveringGroup := range atomCovering {
+				if !slices.Contains(covering, coveringGroup) {
+					covering = append(covering, coveringGroup)
+				}
+			}
+		}
+
+		if covered {
+			removed[group] = true
+			slices.Sort(covering)
+			coveredBy[group] = covering
+		}
+	}
+
+	return coveredBy
+}
+
+// redundantRules returns the rules whose every peer is allowed by other rules of the list
+func redundantRules(rules []Ec2SecGroupRule, direction string) []RedundantRule {
+	atoms := make([]policyAtom, 0, len(rules))
+	for idx, rule := range rules {
+		atoms = append(atoms, ruleAtoms(idx, rule)...)
+	}
+
+	removed := removeCovered(atoms, len(rules))
+	redundant := make([]RedundantRule, 0, len(removed))
+	for idx, rule := range rules {
+		coveringRules, isRedundant := removed[idx]
+		if !isRedundant {
+			continue
+		}
+
+		coveredBy := make([]string, 0, len(coveringRules))
+		for _, coveringIdx := range coveringRules {
+			coveredBy = append(coveredBy, describeRule(rules[coveringIdx]))
+		}
+
+		redundant = append(redundant, RedundantRule{
+			GroupId:        rule.GroupId,
+			Direction:      direction,
+			Protocol:       rule.IpProtocol,
+			Ports:          rulePorts(rule).String(),
+			IpRanges:       rule.IpRanges,
+			SourceGroupIds: rule.SourceGroupIds,
+			PrefixListIds:  rule.PrefixListIds,
+			CoveredBy:      coveredBy,
+		})
+	}
+
+	return redundant
+}
+
+func describeRule(rule Ec2SecGroupRule) string {
+	return fmt.Sprintf("%s %s %s %s", rule.GroupId, rule.IpProtocol, rulePorts(rule),
+		strings.Join(slices.Concat(rule.IpRanges, rule.SourceGroupIds, rule.PrefixListIds), ","))
+}
+
+// effectiveEntries removes every atom the others already allow, then merges the ports of the
+// remaining ones by protocol and peer. Atoms of the same rule can cover each other too, e.g.
+// 10.0.0.0/8 and 10.1.0.0/16 in a single rule, so every atom is a group of its own
+func effectiveEntries(rules []Ec2SecGroupRule) []PolicyEntry {
+	atoms := make([]policyAtom, 0, len(rules))
+	for idx, rule := range rules {
+		atoms = append(atoms, ruleAtoms(idx, rule)...)
+	}
+
+	for idx := range atoms {
+		atoms[idx].rule = idx
+	}
+
+	type entryKey struct {
+		protocol string
+		peer     string
+	}
+
+	removed := removeCovered(atoms, len(atoms))
+	kept := make(map[entryKey][]PortRange, len(atoms))
+	keys := make([]entryKey, 0, len(atoms))
+	for idx, atom := range atoms {
+		if _, isRemoved := removed[idx]; isRemoved {
+			continue
+		}
+
+		key := entryKey{protocol: atom.protocol, peer: atom.peer}
+		if _, exists := kept[key]; !exists {
+			keys = append(keys, key)
+		}
+
+		ports := atom.ports
+		if atom.protocol == allProtocols {
+			ports = allPorts
+		}
+
+		kept[key] = append(kept[key], ports)
+	}
+
+	entries := make([]PolicyEntry, 0, len(keys))
+	for _, key := range keys {
+		for _, ports := range mergePortRanges(kept[key]) {
+			entries = append(entries, PolicyEntry{Protocol: protocolName(key.protocol), Ports: ports, Peer: key.peer})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b PolicyEntry) int {
+		if a.Protocol != b.Protocol {
+			return strings.Compare(a.Protocol, b.Protocol)
+		}
+
+		if a.Peer != b.Peer {
+			return strings.Compare(a.Peer, b.Peer)
+		}
+
+		return int(a.Ports.From - b.Ports.From)
+	})
+
+	return entries
+}
+
+// protocolName turns protocol numbers back into the names security groups are shown with
+func protocolName(protocol string) string {
+	for name, number := range protocolNumbers {
+		if number == protocol && name != "all" {
+			return name
+		}
+	}
+
+	if protocol == allProtocols {
+		return "all"
+	}
+
+	return protocol
+}
+
+// InstanceEffectivePolicy merges the rules of all security groups of the instance into the
+// minimal set of entries, and lists the rules other rules already cover, often in another group
+func InstanceEffectivePolicy(inst Ec2Instance) (EffectivePolicy, []RedundantRule) {
+	policy := EffectivePolicy{
+		Ingress: effectiveEntries(inst.IngressSecRules),
+		Egress:  effectiveEntries(inst.EgressSecRules),
+	}
+
+	redundant := slices.Concat(
+		redundantRules(inst.IngressSecRules, DirectionIngress),
+		redundantRules(inst.EgressSecRules, DirectionEgress),
+	)
+
+	return policy, redundant
+}
+
+// FindRedundantRules lists, for every security group of the region, the rules other rules of
+// the same group already cover
+func FindRedundantRules(inv Inventory) []SecurityGroupRedundancy {
+	groups := make([]SecurityGroupRedundancy, 0, len(inv.SecurityGroups))
+	for _, group := range securityGroupsOf(inv) {
+		redundant := slices.Concat(
+			redundantRules(group.Ingress, DirectionIngress),
+			redundantRules(group.Egress, DirectionEgress),
+		)
+
+		if len(redundant) > 0 {
+			groups = append(groups, SecurityGroupRedundancy{GroupId: group.Id, RedundantRules: redundant})
+		}
+	}
+
+	return groups
+}
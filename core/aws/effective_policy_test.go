@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInstanceEffectivePolicy(t *testing.T) {
+	inst := Ec2Instance{Id: "i-1", IngressSecRules: []Ec2SecGroupRule{
+		{GroupId: "sg-a", FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"10.0.0.0/8"}},
+		{GroupId: "sg-b", FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"10.1.0.0/16"}},
+		{GroupId: "sg-b", FromPort: 80, ToPort: 80, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+		{GroupId: "sg-c", FromPort: 81, ToPort: 90, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+		{GroupId: "sg-c", FromPort: 80, ToPort: 80, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+	}}
+
+	policy, redundant := InstanceEffectivePolicy(inst)
+
+	expected := []string{"tcp 80-90 0.0.0.0/0", "tcp 22 10.0.0.0/8"}
+	entries := make([]string, 0, len(policy.Ingress))
+	for _, entry := range policy.Ingress {
+		entries = append(entries, entry.String())
+	}
+
+	if !slices.Equal(entries, expected) {
+		t.Errorf("expected effective policy %v, got %v", expected, entries)
+	}
+
+	// The duplicated rule on port 80 is only redundant once
+	if len(redundant) != 2 || redundant[0].GroupId != "sg-b" || redundant[0].Ports != "22" || redundant[1].Ports != "80" {
+		t.Errorf("unexpected redundant rules %+v", redundant)
+	}
+}
+
+func TestPrefixListsAreNeverCovered(t *testing.T) {
+	inst := Ec2Instance{Id: "i-1", IngressSecRules: []Ec2SecGroupRule{
+		{GroupId: "sg-a", FromPort: 443, ToPort: 443, IpProtocol: "tcp", IpRanges: []string{"10.1.0.0/16"}, PrefixListIds: []string{"pl-partners"}},
+		{GroupId: "sg-b", FromPort: 443, ToPort: 443, IpProtocol: "tcp", IpRanges: []string{"10.0.0.0/8"}},
+	}}
+
+	policy, redundant := InstanceEffectivePolicy(inst)
+	if len(redundant) != 0 {
+		t.Errorf("expected the rule allowing pl-partners to be kept, got %+v", redundant)
+	}
+
+	entries := make([]string, 0, len(policy.Ingress))
+	for _, entry := range policy.Ingress {
+		entries = append(entries, entry.String())
+	}
+
+	if expected := []string{"tcp 443 10.0.0.0/8", "tcp 443 pl-partners"}; !slices.Equal(entries, expected) {
+		t.Errorf("expected effective policy %v, got %v", expected, entries)
+	}
+}
@@ -0,0 +1,121 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"time"
+)
+
+// AMI provenance, from the account point of view
+const (
+	AmiOwnedByAccount = "own"
+	AmiAmazon         = "amazon"
+	AmiMarketplace    = "aws-marketplace"
+	AmiThirdParty     = "third-party"
+	// AmiUnavailable images are used by instances but can't be described anymore, they were
+	// deregistered or are no longer shared with the account
+	AmiUnavailable = "unavailable"
+)
+
+const amiLaunchPermissionAll = "all"
+
+type Ami struct {
+	Id                string
+	Name              string
+	OwnerId           string
+	OwnerAlias        *string
+	Provenance        string
+	Public            bool
+	State             string
+	CreationDate      *string
+	DeprecationTime   *string
+	LaunchPermissions []AmiLaunchPermission
+}
+
+// AmiLaunchPermission is who an image is shared with, only known for images of the account
+type AmiLaunchPermission struct {
+	UserId                *string
+	Group                 *string
+	OrganizationArn       *string
+	OrganizationalUnitArn *string
+}
+
+func (a Ami) IsDeprecated(now time.Time) bool {
+	if ptr.IsEmpty(a.DeprecationTime) {
+		return false
+	}
+
+	deprecation, err := time.Parse(time.RFC3339, *a.DeprecationTime)
+	if err != nil {
+		return false
+	}
+
+	return !deprecation.After(now)
+}
+
+// IsSharedPublicly tells whether anyone can launch one of our images
+func (a Ami) IsSharedPublicly() bool {
+	if a.Provenance != AmiOwnedByAccount {
+		return false
+	}
+
+	if a.Public {
+		return true
+	}
+
+	for _, permission := range a.LaunchPermissions {
+		if ptr.Deref(permission.Group) == amiLaunchPermissionAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SharedWithAccounts lists the accounts our image is explicitly shared with
+func (a Ami) SharedWithAccounts() []string {
+	accounts := make([]string, 0, len(a.LaunchPermissions))
+	for _, permission := range a.LaunchPermissions {
+		if !ptr.IsEmpty(permission.UserId) {
+			accounts = append(accounts, *permission.UserId)
+		}
+	}
+
+	return accounts
+}
+
+func amiProvenance(accountId, ownerId string, ownerAlias *string) string {
+	switch {
+	case ownerId == accountId:
+		return AmiOwnedByAccount
+	case ptr.Deref(ownerAlias) == AmiAmazon:
+		return AmiAmazon
+	case ptr.Deref(ownerAlias) == AmiMarketplace:
+		return AmiMarketplace
+	default:
+		return AmiThirdParty
+	}
+}
+
+// usedImageIds returns every image launched by instances or launch templates, without repetition
+func usedImageIds(inv Inventory) []string {
+	seen := make(map[string]bool, len(inv.Instances))
+	imageIds := make([]string, 0, len(inv.Instances))
+	add := func(imageId string) {
+		if imageId == "" || seen[imageId] {
+			return
+		}
+
+		seen[imageId] = true
+		imageIds = append(imageIds, imageId)
+	}
+
+	for _, inst := range inv.Instances {
+		add(inst.ImageId)
+	}
+
+	for _, template := range inv.LaunchTemplates {
+		add(ptr.Deref(template.ImageId))
+	}
+
+	return imageIds
+}
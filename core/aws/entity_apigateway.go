@@ -0,0 +1,27 @@
+package aws
+
+const (
+	ApiGatewayRest      = "REST"
+	ApiGatewayHttp      = "HTTP"
+	ApiGatewayWebsocket = "WEBSOCKET"
+)
+
+// ApiGatewayStage is the deployed, callable unit of an API. Integrations are the ones of the
+// API, regardless of the deployment the stage points to
+type ApiGatewayStage struct {
+	ApiId        string
+	ApiName      string
+	ApiType      string
+	StageName    string
+	Private      bool
+	WebACLArn    *string
+	Integrations []FrontDoorOrigin
+}
+
+func (a *ApiGatewayStage) Id() string {
+	return a.ApiId + "/" + a.StageName
+}
+
+func (a *ApiGatewayStage) HasWAF() bool {
+	return a.WebACLArn != nil && *a.WebACLArn != ""
+}
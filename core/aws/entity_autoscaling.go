@@ -0,0 +1,54 @@
+package aws
+
+const autoScalingGroupTag = "aws:autoscaling:groupName"
+
+type AutoScalingGroup struct {
+	Name                    string
+	Arn                     string
+	InstanceIds             []string
+	LaunchTemplateId        *string
+	LaunchTemplateVersion   *string
+	LaunchConfigurationName *string
+	TargetGroupArns         []string
+	SubnetIds               []string
+	MinSize                 int32
+	MaxSize                 int32
+	DesiredCapacity         int32
+}
+
+// LaunchTemplate holds the data of the default version, the one groups usually launch
+type LaunchTemplate struct {
+	Id                 string
+	Name               string
+	DefaultVersion     int64
+	ImageId            *string
+	InstanceType       string
+	KeyName            *string
+	SecurityGroupIds   []string
+	IamInstanceProfile *string
+	AssociatePublicIp  bool
+}
+
+// instanceGroups maps instances to the name of their Auto Scaling group. Instances that
+// are still being launched might not be listed by the group yet, so the tag AWS sets on
+// them is used as fallback
+func instanceGroups(inv Inventory) map[string]string {
+	groups := make(map[string]string, len(inv.Instances))
+	for _, group := range inv.AutoScalingGroups {
+		for _, instanceId := range group.InstanceIds {
+			groups[instanceId] = group.Name
+		}
+	}
+
+	for _, inst := range inv.Instances {
+		if _, exists := groups[inst.Id]; exists {
+			continue
+		}
+
+		if group, tagged := inst.Tags[autoScalingGroupTag]; tagged {
+			groups[inst.Id] = group
+		}
+	}
+
+	return groups
+}
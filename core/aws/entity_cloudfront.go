@@ -0,0 +1,15 @@
+package aws
+
+type CloudFrontDistribution struct {
+	Id         string
+	Arn        string
+	DomainName string
+	Aliases    []string
+	Enabled    bool
+	WebACLId   *string
+	Origins    []FrontDoorOrigin
+}
+
+func (c *CloudFrontDistribution) HasWAF() bool {
+	return c.WebACLId != nil && *c.WebACLId != ""
+}
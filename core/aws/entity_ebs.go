@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"regexp"
+)
+
+const snapshotPermissionAll = "all"
+
+type EbsVolume struct {
+	Id          string
+	Encrypted   bool
+	KmsKeyId    *string
+	Size        int32
+	State       string
+	SnapshotId  *string
+	InstanceIds []string
+}
+
+type EbsSnapshot struct {
+	Id                      string
+	VolumeId                *string
+	Description             string
+	Encrypted               bool
+	KmsKeyId                *string
+	Size                    int32
+	StartTime               *string
+	CreateVolumePermissions []SnapshotPermission
+}
+
+// SnapshotPermission is who can create volumes out of a snapshot
+type SnapshotPermission struct {
+	UserId *string
+	Group  *string
+}
+
+// IsPublic tells whether any AWS account can restore the snapshot
+func (s EbsSnapshot) IsPublic() bool {
+	for _, permission := range s.CreateVolumePermissions {
+		if ptr.Deref(permission.Group) == snapshotPermissionAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s EbsSnapshot) SharedWithAccounts() []string {
+	accounts := make([]string, 0, len(s.CreateVolumePermissions))
+	for _, permission := range s.CreateVolumePermissions {
+		if !ptr.IsEmpty(permission.UserId) {
+			accounts = append(accounts, *permission.UserId)
+		}
+	}
+
+	return accounts
+}
+
+// AMI snapshots are described as "Created by CreateImage(i-0123) for ami-0123"
+var createImageInstanceRegex = regexp.MustCompile(`CreateImage\((i-[0-9a-f]+)\)`)
+
+// snapshotSources maps snapshots to the instance their data comes from. The volume might
+// have been deleted or detached since, so snapshots taken to create AMIs fall back on the
+// instance named in their description
+func snapshotSources(inv Inventory) map[string]string {
+	volumeInstances := make(map[string]string, len(inv.EbsVolumes))
+	for _, volume := range inv.EbsVolumes {
+		if len(volume.InstanceIds) > 0 {
+			volumeInstances[volume.Id] = volume.InstanceIds[0]
+		}
+	}
+
+	sources := make(map[string]string, len(inv.EbsSnapshots))
+	for _, snapshot := range inv.EbsSnapshots {
+		if instanceId, attached := volumeInstances[ptr.Deref(snapshot.VolumeId)]; attached {
+			sources[snapshot.Id] = instanceId
+			continue
+		}
+
+		if match := createImageInstanceRegex.FindStringSubmatch(snapshot.Description); match != nil {
+			sources[snapshot.Id] = match[1]
+		}
+	}
+
+	return sources
+}
@@ -2,40 +2,89 @@ package aws
 
 import (
 	"asset-relations/support/ptr"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"slices"
 	"strings"
 )
 
 type Ec2Instance struct {
-	Id               string
-	PrivateIP        string
-	PublicIP         *string
-	PublicDNS        *string
-	VPC              string
-	SecurityGroupIds []string
-	EgressSecRules   []Ec2SecGroupRule
-	IngressSecRules  []Ec2SecGroupRule
-	PrivateDNS       string
-	SSHKeyPairName   *string
+	Id                 string
+	PrivateIP          string
+	PublicIP           *string
+	PublicDNS          *string
+	VPC                string
+	SubnetId           string
+	SecurityGroupIds   []string
+	EgressSecRules     []Ec2SecGroupRule
+	IngressSecRules    []Ec2SecGroupRule
+	PrivateDNS         string
+	SSHKeyPairName     *string
+	ImageId            string
+	IamInstanceProfile *string
+	// MetadataHttpTokens is required when only IMDSv2 is allowed, optional when IMDSv1 is too
+	MetadataHttpTokens string
+	Tags               map[string]string
 }
 
+// SSH and RDP keep their own instance properties, other services are in the catalog
 const (
-	sshPort int32 = 22
-	rdpPort int32 = 3389
+	SSHPort int32 = 22
+	RDPPort int32 = 3389
 )
 
+const (
+	anyIPv4 = "0.0.0.0/0"
+	anyIPv6 = "::/0"
+)
+
+// allProtocols is the protocol of rules allowing all traffic, which don't have ports
+const allProtocols = "-1"
+
 type Ec2SecGroupRule struct {
+	// GroupId is the security group the rule belongs to
+	GroupId    string
 	FromPort   int32
 	ToPort     int32
 	IpProtocol string
 	IpRanges   []string
+	// SourceGroupIds are the security groups allowed by the rule, as destination on egress rules
+	SourceGroupIds []string
+	// PrefixListIds are managed prefix lists allowed by the rule, their ranges are not resolved
+	PrefixListIds []string
+}
+
+func (r Ec2SecGroupRule) coversPort(port int32) bool {
+	return r.IpProtocol == allProtocols || (r.FromPort <= port && r.ToPort >= port)
+}
+
+// UsesIMDSv1 tells whether the instance metadata service answers requests without a session
+// token, which SSRF vulnerabilities can use to steal the role credentials
+func (e *Ec2Instance) UsesIMDSv1() bool {
+	return e.MetadataHttpTokens == string(ec2types.HttpTokensStateOptional)
 }
 
 func (e *Ec2Instance) IsOpenToInternet() bool {
-	return ptr.IsEmpty(e.PublicIP)
+	return !ptr.IsEmpty(e.PublicIP)
+}
+
+// ExposureStatus is a coarse view on how reachable the instance is. It only considers the
+// public address and whether any ingress rule allows the whole internet
+func (e *Ec2Instance) ExposureStatus() string {
+	if !e.IsOpenToInternet() {
+		return ExposurePrivate
+	}
+
+	for _, rule := range e.IngressSecRules {
+		if slices.Contains(rule.IpRanges, anyIPv4) || slices.Contains(rule.IpRanges, anyIPv6) {
+			return ExposureOpenToInternet
+		}
+	}
+
+	return ExposurePublic
 }
 
-func (e *Ec2Instance) HasSSHPortOpen() bool {
-	_, isOpen := e.findIngressRules(sshPort)
+func (e *Ec2Instance) HasPortOpen(port int32) bool {
+	_, isOpen := e.findIngressRules(port)
 	return isOpen
 }
 
@@ -67,32 +116,32 @@ func extractPorts(rules []Ec2SecGroupRule) []int32 {
 	return ports
 }
 
-func (e *Ec2Instance) GetSSHOpenToIpRanges() *string {
-	rule, isOpen := e.findIngressRules(sshPort)
-	if !isOpen {
+// GetPortOpenToIpRanges joins the ranges of every rule allowing the port, as several
+// security groups often open the same port
+func (e *Ec2Instance) GetPortOpenToIpRanges(port int32) *string {
+	if !e.HasPortOpen(port) {
 		return nil
 	}
 
-	return ptr.Ref(strings.Join(rule.IpRanges, ","))
-}
-
-func (e *Ec2Instance) HasRDPPortOpen() bool {
-	_, isOpen := e.findIngressRules(rdpPort)
-	return isOpen
-}
+	ipRanges := make([]string, 0, len(e.IngressSecRules))
+	for _, rule := range e.IngressSecRules {
+		if !rule.coversPort(port) {
+			continue
+		}
 
-func (e *Ec2Instance) GetRDPOpenToIpRanges() *string {
-	rule, isOpen := e.findIngressRules(rdpPort)
-	if !isOpen {
-		return nil
+		for _, ipRange := range rule.IpRanges {
+			if !slices.Contains(ipRanges, ipRange) {
+				ipRanges = append(ipRanges, ipRange)
+			}
+		}
 	}
 
-	return ptr.Ref(strings.Join(rule.IpRanges, ","))
+	return ptr.Ref(strings.Join(ipRanges, ","))
 }
 
 func (e *Ec2Instance) findIngressRules(port int32) (Ec2SecGroupRule, bool) {
 	for _, rule := range e.IngressSecRules {
-		if rule.FromPort <= port && rule.ToPort >= port {
+		if rule.coversPort(port) {
 			return rule, true
 		}
 	}
@@ -0,0 +1,44 @@
+package aws
+
+type ElasticIp struct {
+	AllocationId       string
+	PublicIP           string
+	PrivateIP          *string
+	AssociationId      *string
+	InstanceId         *string
+	NetworkInterfaceId *string
+}
+
+func (e *ElasticIp) IsAssociated() bool {
+	return e.AssociationId != nil
+}
+
+type NetworkInterface struct {
+	Id               string
+	Type             string
+	Description      string
+	VPC              string
+	SubnetId         string
+	InstanceId       *string
+	SecurityGroupIds []string
+	Addresses        []NetworkInterfaceAddress
+}
+
+type NetworkInterfaceAddress struct {
+	PrivateIP string
+	PublicIP  *string
+}
+
+type NatGateway struct {
+	Id        string
+	VPC       string
+	SubnetId  string
+	Addresses []NatGatewayAddress
+}
+
+type NatGatewayAddress struct {
+	NetworkInterfaceId string
+	AllocationId       *string
+	PrivateIP          *string
+	PublicIP           *string
+}
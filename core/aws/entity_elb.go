@@ -0,0 +1,56 @@
+package aws
+
+import "strings"
+
+const loadBalancerInternetFacing = "internet-facing"
+
+// Only application load balancers can be associated with a WAF web ACL
+const loadBalancerApplication = "application"
+
+type LoadBalancer struct {
+	Arn              string
+	Name             string
+	Type             string
+	Scheme           string
+	DNSName          string
+	VPC              string
+	SecurityGroupIds []string
+	Addresses        []LoadBalancerAddress
+	Targets          []LoadBalancerTarget
+	WebACLArn        *string
+}
+
+// LoadBalancerAddress is only filled for network load balancers, which are the only ones
+// with static addresses per availability zone
+type LoadBalancerAddress struct {
+	AllocationId *string
+	PrivateIP    *string
+	PublicIP     *string
+}
+
+// LoadBalancerTarget is a registered target of any of the load balancer's target groups.
+// Id is an instance id, an ip address, a Lambda ARN or another load balancer ARN depending on Type
+type LoadBalancerTarget struct {
+	Id   string
+	Type string
+	Port int32
+}
+
+func (l *LoadBalancer) IsInternetFacing() bool {
+	return l.Scheme == loadBalancerInternetFacing
+}
+
+func (l *LoadBalancer) HasWAF() bool {
+	return l.WebACLArn != nil && *l.WebACLArn != ""
+}
+
+// ResourceId is the part of the ARN that AWS uses to describe the network interfaces it
+// creates on behalf of the load balancer, e.g. "app/my-alb/50dc6c495c0c9188"
+func (l *LoadBalancer) ResourceId() string {
+	_, id, found := strings.Cut(l.Arn, ":loadbalancer/")
+	if !found {
+		return l.Name
+	}
+
+	return id
+}
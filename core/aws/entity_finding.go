@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// severities go from the most severe, which is how findings are sorted, services validated
+// and rules checked
+var severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Severities returns the known severities from the most severe
+func Severities() []string {
+	return slices.Clone(severities)
+}
+
+// SeverityRank is 0 for the most severe and grows as severities get lower. Unknown severities
+// come after every known one
+func SeverityRank(severity string) int {
+	if rank := slices.Index(severities, severity); rank >= 0 {
+		return rank
+	}
+
+	return len(severities)
+}
+
+const (
+	FindingAmiSharedPublicly = "ami-shared-publicly"
+	// Snapshot findings are only raised for snapshots of internet exposed instances
+	FindingSnapshotPublic             = "snapshot-public"
+	FindingSnapshotSharedCrossAccount = "snapshot-shared-cross-account"
+)
+
+// ReservedFindingTypes lists the types of the built-in findings, which rules can't take as
+// their id without overwriting them. Report names are in too, as suppressions match on both
+func ReservedFindingTypes(catalog ServiceCatalog) []string {
+	reserved := []string{
+		FindingAmiSharedPublicly, FindingSnapshotPublic, FindingSnapshotSharedCrossAccount,
+		ReportDanglingDns, ReportRiskyAmi, ReportPermissiveRule, ReportRedundantRule,
+	}
+
+	for _, service := range catalog {
+		reserved = append(reserved, service.FindingType(), service.UntrustedFindingType())
+	}
+
+	return reserved
+}
+
+// Finding is a security issue on an asset. Its id only depends on the type and the asset,
+// so the same issue keeps its identity across fetches. Instances lists the instances the
+// issue was observed on, which for Auto Scaling groups change as instances rotate
+type Finding struct {
+	Id        string
+	Type      string
+	Severity  string
+	Asset     AssetRef
+	Title     string
+	Instances []string
+	// Remediation is only known for findings raised by rules
+	Remediation string
+	// Evidence is what made the asset exposed, e.g. the route, network ACL entry, security
+	// group rule and public address letting traffic in
+	Evidence   []ReachabilityHop
+	Confidence string
+}
+
+func (f Finding) EvidenceDetails() []string {
+	return evidenceDetails(f.Evidence)
+}
+
+func newFinding(findingType, severity string, asset AssetRef, title string, instances []string) Finding {
+	hash := sha256.Sum256([]byte(findingType + "|" + asset.AssetType + "|" + asset.AssetId))
+
+	return Finding{
+		Id:         hex.EncodeToString(hash[:8]),
+		Type:       findingType,
+		Severity:   severity,
+		Asset:      asset,
+		Title:      title,
+		Instances:  instances,
+		Confidence: ConfidenceConfirmed,
+	}
+}
+
+// SeverityAtLeast tells whether the severity is the threshold or above. Unknown severities
+// are below every threshold
+func SeverityAtLeast(severity, threshold string) bool {
+	return slices.Contains(severities, severity) && SeverityRank(severity) <= SeverityRank(threshold)
+}
@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+)
+
+// Actions of flow log records
+const (
+	FlowAccept = "ACCEPT"
+	FlowReject = "REJECT"
+)
+
+// Flows are counted per UTC day they started, so traffic can be summed over any window
+const trafficBucket = 24 * time.Hour
+
+// ObservedTraffic aggregates the flows seen from a client to a server port on a day. Endpoints
+// are the assets owning the addresses, or the Cidr they fall in when they're outside the inventory
+type ObservedTraffic struct {
+	From AssetRef
+	To   AssetRef
+	// Protocol is the IANA number, like in security group rules once normalized
+	Protocol string
+	Port     int32
+	Action   string
+	// Day is the start of the UTC day the flows started on
+	Day       time.Time
+	Bytes     int64
+	Packets   int64
+	Flows     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+func (o ObservedTraffic) sortKey() string {
+	return fmt.Sprintf("%s/%s>%s/%s:%s/%05d/%s@%s", o.From.AssetType, o.From.AssetId, o.To.AssetType, o.To.AssetId, o.Protocol, o.Port, o.Action, o.Day.Format(time.DateOnly))
+}
+
+// merge adds up the flows of other, seen by the same interface
+func (o ObservedTraffic) merge(other ObservedTraffic) ObservedTraffic {
+	o.Bytes += other.Bytes
+	o.Packets += other.Packets
+	o.Flows += other.Flows
+	o.FirstSeen, o.LastSeen = widenWindow(o.FirstSeen, o.LastSeen, other.FirstSeen, other.LastSeen)
+
+	return o
+}
+
+// combine keeps the largest counts of other, seen by another interface. Flows between two
+// logged interfaces are recorded by both, adding them up would count them twice
+func (o ObservedTraffic) combine(other ObservedTraffic) ObservedTraffic {
+	o.Bytes = max(o.Bytes, other.Bytes)
+	o.Packets = max(o.Packets, other.Packets)
+	o.Flows = max(o.Flows, other.Flows)
+	o.FirstSeen, o.LastSeen = widenWindow(o.FirstSeen, o.LastSeen, other.FirstSeen, other.LastSeen)
+
+	return o
+}
+
+func widenWindow(first, last, otherFirst, otherLast time.Time) (time.Time, time.Time) {
+	if first.IsZero() || (!otherFirst.IsZero() && otherFirst.Before(first)) {
+		first = otherFirst
+	}
+
+	if otherLast.After(last) {
+		last = otherLast
+	}
+
+	return first, last
+}
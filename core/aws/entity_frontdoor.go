@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Origin types of front doors, i.e. CloudFront distributions and API Gateway stages
+const (
+	OriginLoadBalancer = "load-balancer"
+	OriginEc2          = "ec2"
+	OriginS3           = "s3"
+	OriginLambda       = "lambda"
+	OriginVpcLink      = "vpc-link"
+	OriginApiGateway   = "api-gateway"
+	OriginHttp         = "http"
+)
+
+// FrontDoorOrigin is where a front door sends traffic to. Target is the hostname for
+// HTTP based origins and the ARN for Lambda functions and load balancers behind VPC links
+type FrontDoorOrigin struct {
+	Type   string
+	Target string
+}
+
+// classifyOriginHostname guesses the origin type out of AWS hostnames conventions, anything
+// else is a plain HTTP origin, which might still be one of our assets through DNS
+func classifyOriginHostname(hostname string) string {
+	hostname = normalizeHostname(hostname)
+
+	switch {
+	case strings.Contains(hostname, ".elb.") && strings.HasSuffix(hostname, ".amazonaws.com"):
+		return OriginLoadBalancer
+	case strings.Contains(hostname, ".execute-api."):
+		return OriginApiGateway
+	case strings.Contains(hostname, ".lambda-url."):
+		return OriginLambda
+	case strings.HasSuffix(hostname, ".s3.amazonaws.com") || strings.Contains(hostname, ".s3.") ||
+		strings.Contains(hostname, ".s3-website"):
+		return OriginS3
+	case strings.HasSuffix(hostname, ".compute.amazonaws.com") || strings.HasSuffix(hostname, ".compute-1.amazonaws.com"):
+		return OriginEc2
+	default:
+		return OriginHttp
+	}
+}
+
+// hostnameFromUri accepts both plain hostnames and URLs as used by API Gateway integrations
+func hostnameFromUri(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Hostname() == "" {
+		return uri
+	}
+
+	return parsed.Hostname()
+}
@@ -0,0 +1,154 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// How much an instance can do in the account with the credentials of its instance profile
+const (
+	PrivilegeAdmin = "admin"
+	// PrivilegeHigh roles can escalate to admin, or have full access to some services
+	PrivilegeHigh     = "high"
+	PrivilegeStandard = "standard"
+	// PrivilegeUnknown roles couldn't be fetched
+	PrivilegeUnknown = "unknown"
+)
+
+var privileges = []string{PrivilegeStandard, PrivilegeHigh, PrivilegeAdmin}
+
+// Actions that let a role give itself, or a role it creates, more permissions
+var escalationActions = []string{
+	"iam:passrole",
+	"iam:createaccesskey",
+	"iam:createpolicyversion",
+	"iam:attachrolepolicy",
+	"iam:attachuserpolicy",
+	"iam:putrolepolicy",
+	"iam:putuserpolicy",
+	"iam:updateassumerolepolicy",
+	"sts:assumerole",
+}
+
+// InstanceProfile is the IAM instance profile of instances, with the privilege of the most
+// privileged policy of its roles
+type InstanceProfile struct {
+	Arn       string
+	Roles     []string
+	Policies  []string
+	Privilege string
+}
+
+// managedPolicyPrivilege classifies AWS managed policies by name, their documents are known
+func managedPolicyPrivilege(name string) string {
+	switch {
+	case name == "AdministratorAccess":
+		return PrivilegeAdmin
+	case name == "PowerUserAccess", name == "IAMFullAccess", strings.HasSuffix(name, "FullAccess"):
+		return PrivilegeHigh
+	default:
+		return PrivilegeStandard
+	}
+}
+
+type policyDocument struct {
+	Statement policyList[policyStatement]
+}
+
+type policyStatement struct {
+	Effect    string
+	Action    policyList[string]
+	NotAction policyList[string]
+	Resource  policyList[string]
+}
+
+// policyList is a policy element that is either a single value or a list of them
+type policyList[T any] []T
+
+func (l *policyList[T]) UnmarshalJSON(data []byte) error {
+	var single T
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = []T{single}
+		return nil
+	}
+
+	var list []T
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// policyPrivilege classifies a policy document, as returned URL-encoded by IAM. Documents that
+// can't be read are standard, as nothing tells otherwise
+func policyPrivilege(document string) string {
+	if decoded, err := url.QueryUnescape(document); err == nil {
+		document = decoded
+	}
+
+	var policy policyDocument
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return PrivilegeStandard
+	}
+
+	privilege := PrivilegeStandard
+	for _, statement := range policy.Statement {
+		if !strings.EqualFold(statement.Effect, "Allow") {
+			continue
+		}
+
+		anyResource := slices.Contains(statement.Resource, "*")
+
+		// Allowing everything but a few actions is as broad as it gets
+		if len(statement.NotAction) > 0 && anyResource {
+			privilege = highestPrivilege(privilege, PrivilegeHigh)
+		}
+
+		for _, action := range statement.Action {
+			action = strings.ToLower(action)
+			switch {
+			case (action == "*" || action == "*:*") && anyResource:
+				return PrivilegeAdmin
+			case action == "iam:*", slices.Contains(escalationActions, action) && anyResource:
+				privilege = highestPrivilege(privilege, PrivilegeHigh)
+			case strings.HasSuffix(action, ":*") && anyResource:
+				privilege = highestPrivilege(privilege, PrivilegeHigh)
+			}
+		}
+	}
+
+	return privilege
+}
+
+func highestPrivilege(a, b string) string {
+	if slices.Index(privileges, a) > slices.Index(privileges, b) {
+		return a
+	}
+
+	return b
+}
+
+// instanceProfileName is the last part of the path of the ARN, which IAM looks profiles up by
+func instanceProfileName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// usedInstanceProfiles returns the instance profiles of instances, each once
+func usedInstanceProfiles(inv Inventory) []string {
+	seen := make(map[string]bool, len(inv.Instances))
+	arns := make([]string, 0, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		arn := ptr.Deref(inst.IamInstanceProfile)
+		if arn != "" && !seen[arn] {
+			seen[arn] = true
+			arns = append(arns, arn)
+		}
+	}
+
+	return arns
+}
@@ -0,0 +1,23 @@
+package aws
+
+const (
+	ExposureUnassociated   = "unassociated"
+	ExposurePrivate        = "private"
+	ExposurePublic         = "public"
+	ExposureOpenToInternet = "open-to-internet"
+)
+
+// IpAddress tells who owns an address in the account. Every address belongs to exactly one
+// asset, the most specific one we know of (e.g. the instance instead of its network interface)
+type IpAddress struct {
+	Address            string
+	Public             bool
+	OwnerType          string
+	OwnerId            string
+	NetworkInterfaceId *string
+	AllocationId       *string
+	Account            string
+	Region             string
+	VPC                string
+	Exposure           string
+}
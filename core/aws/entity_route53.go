@@ -0,0 +1,51 @@
+package aws
+
+import "strings"
+
+const (
+	DnsResolved = "resolved"
+	// DnsDangling records point to addresses of our region's EC2 ranges or AWS hostnames no
+	// asset of ours owns, which makes them candidates for subdomain takeover
+	DnsDangling = "dangling"
+	// DnsExternal records point to hostnames outside AWS, which we can't verify
+	DnsExternal = "external"
+	// DnsUnknown records point to AWS services we don't fetch (e.g. S3 websites), or to
+	// addresses outside of our region's EC2 ranges no asset of ours owns
+	DnsUnknown = "unknown"
+)
+
+type DnsRecord struct {
+	ZoneId        string
+	ZoneName      string
+	PrivateZone   bool
+	Name          string
+	Type          string
+	SetIdentifier *string
+	Values        []string
+	AliasTarget   *string
+}
+
+// Id is unique across zones, routing policies share name and type but not the set identifier
+func (d *DnsRecord) Id() string {
+	id := d.ZoneId + "/" + d.Name + "/" + d.Type
+	if d.SetIdentifier != nil {
+		id += "/" + *d.SetIdentifier
+	}
+
+	return id
+}
+
+// DnsResolution links a record to the assets its values point to
+type DnsResolution struct {
+	Record         DnsRecord
+	Status         string
+	Targets        []AssetRef
+	DanglingValues []string
+}
+
+// normalizeHostname makes hostnames comparable, Route 53 returns them fully qualified
+// and aliases to load balancers might carry the dualstack prefix
+func normalizeHostname(hostname string) string {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	return strings.TrimPrefix(hostname, "dualstack.")
+}
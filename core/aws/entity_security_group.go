@@ -0,0 +1,23 @@
+package aws
+
+// Every VPC has a security group named default, which can't be deleted
+const defaultSecurityGroupName = "default"
+
+type SecurityGroup struct {
+	Id           string
+	Name         string
+	Description  string
+	VPC          string
+	OwnerId      string
+	IngressRules []Ec2SecGroupRule
+	EgressRules  []Ec2SecGroupRule
+	Tags         map[string]string
+}
+
+func (g SecurityGroup) IsDefault() bool {
+	return g.Name == defaultSecurityGroupName
+}
+
+func (g SecurityGroup) HasRules() bool {
+	return len(g.IngressRules) > 0 || len(g.EgressRules) > 0
+}
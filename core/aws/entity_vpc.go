@@ -0,0 +1,64 @@
+package aws
+
+const (
+	routeTargetLocal    = "local"
+	routeStateBlackhole = "blackhole"
+)
+
+// Vpc holds the ranges of the VPC, the local route of its route tables covers them
+type Vpc struct {
+	Id         string
+	CidrBlocks []string
+	Default    bool
+}
+
+type Subnet struct {
+	Id                  string
+	VPC                 string
+	CidrBlock           string
+	AvailabilityZone    string
+	MapPublicIpOnLaunch bool
+}
+
+// RouteTable applies to the subnets explicitly associated to it, or to every subnet of the
+// VPC without association if it's the main one
+type RouteTable struct {
+	Id        string
+	VPC       string
+	Main      bool
+	SubnetIds []string
+	Routes    []Route
+}
+
+// Route sends traffic to Destination, a CIDR or a prefix list id, through Target, e.g.
+// "local", an internet gateway, a NAT gateway or a peering connection
+type Route struct {
+	Destination string
+	Target      string
+	State       string
+}
+
+type NetworkAcl struct {
+	Id        string
+	VPC       string
+	Default   bool
+	SubnetIds []string
+	Entries   []NetworkAclEntry
+}
+
+// NetworkAclEntry is evaluated in RuleNumber order, the first entry matching the traffic
+// allows or denies it
+type NetworkAclEntry struct {
+	RuleNumber int32
+	Egress     bool
+	Protocol   string
+	FromPort   int32
+	ToPort     int32
+	Cidr       string
+	Allow      bool
+}
+
+type InternetGateway struct {
+	Id   string
+	VPCs []string
+}
@@ -0,0 +1,224 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// How sure a finding or an exposure is, given the data it was computed from
+const (
+	// ConfidenceConfirmed means every piece of evidence was checked and allows it
+	ConfidenceConfirmed = "confirmed"
+	// ConfidenceLikely means it holds for part of the sources, or some hops aren't checked
+	ConfidenceLikely = "likely"
+	// ConfidenceUnknown means some data was missing, e.g. a network ACL that couldn't be
+	// fetched or a prefix list that wasn't resolved
+	ConfidenceUnknown = "unknown"
+)
+
+// HopFrontDoor is the evidence of a load balancer, CloudFront distribution or API Gateway
+// stage serving the instance
+const HopFrontDoor = "front-door"
+
+// confidences goes from the most to the least sure
+var confidences = []string{ConfidenceConfirmed, ConfidenceLikely, ConfidenceUnknown}
+
+func (h ReachabilityHop) String() string {
+	return fmt.Sprintf("%s %s %s: %s", h.Component, h.ResourceId, h.Status, h.Detail)
+}
+
+// verdictConfidence is how sure we are that the traffic gets through. Unreachable verdicts
+// are only reached on data that denies it, so they are as sure as reachable ones
+func verdictConfidence(status string) string {
+	switch status {
+	case ReachabilityReachable, ReachabilityUnreachable:
+		return ConfidenceConfirmed
+	case ReachabilityPartial:
+		return ConfidenceLikely
+	default:
+		return ConfidenceUnknown
+	}
+}
+
+func lowestConfidence(a, b string) string {
+	if slices.Index(confidences, a) > slices.Index(confidences, b) {
+		return a
+	}
+
+	return b
+}
+
+// reachabilityRanking goes from the most to the least reachable verdict
+var reachabilityRanking = []string{ReachabilityReachable, ReachabilityPartial, ReachabilityUnknown, ReachabilityUnreachable}
+
+// explainPeer runs the reachability engine from an outside peer to the instance, and keeps the
+// verdict closest to reachable over the given ports, so evidence shows the way in if there is one
+func (n networkIndex) explainPeer(inst Ec2Instance, peer netip.Prefix, protocol string, ports []int32) ([]ReachabilityHop, string) {
+	var best []ReachabilityHop
+	bestStatus := ReachabilityUnreachable
+	for _, port := range ports {
+		hops := n.externalHops(peer, inst, protocol, port)
+		status := verdictStatus(hops)
+		if best == nil || slices.Index(reachabilityRanking, status) < slices.Index(reachabilityRanking, bestStatus) {
+			best, bestStatus = hops, status
+		}
+	}
+
+	return best, bestStatus
+}
+
+// explainServiceExposure checks a service from the internet, or from the first untrusted range
+// when it's only open to those, on every port of the service
+func (n networkIndex) explainServiceExposure(exposure ServiceExposure, untrusted []string) ([]ReachabilityHop, string) {
+	inst, exists := n.instances[exposure.InstanceId]
+	if !exists || len(exposure.Ports) == 0 {
+		return nil, ReachabilityUnknown
+	}
+
+	peer := netip.MustParsePrefix(anyIPv4)
+	if exposure.OpenToInternet && !slices.Contains(exposure.IpRanges, anyIPv4) {
+		peer = netip.MustParsePrefix(anyIPv6)
+	}
+
+	if !exposure.OpenToInternet {
+		for _, ipRange := range untrusted {
+			if prefix, valid := parsePrefix(ipRange); valid {
+				peer = prefix
+				break
+			}
+		}
+	}
+
+	return n.explainPeer(inst, peer, exposure.Protocol, exposure.Ports)
+}
+
+// explainInstanceExposure gives the evidence of the exposure status. Instances open to the
+// internet are checked from end to end, and lose that status if the network denies it on every
+// port their security groups open to the internet
+func (n networkIndex) explainInstanceExposure(inst Ec2Instance, exposure *InstanceExposure) {
+	exposure.Confidence = ConfidenceConfirmed
+
+	switch exposure.Status {
+	case ExposurePrivate:
+		exposure.Evidence = []ReachabilityHop{{
+			Component:  HopPublicAddress,
+			ResourceId: inst.Id,
+			Status:     HopDeny,
+			Detail:     "instance has no public address",
+		}}
+		return
+	case ExposurePublic:
+		groupHop := ReachabilityHop{
+			Component:  HopSecurityGroup,
+			ResourceId: strings.Join(inst.SecurityGroupIds, ","),
+			Status:     HopDeny,
+			Detail:     "no ingress rule allows the internet",
+		}
+
+		for _, rule := range inst.IngressSecRules {
+			if len(rule.PrefixListIds) > 0 {
+				exposure.Confidence = ConfidenceUnknown
+				groupHop.Status = HopUnknown
+				groupHop.Detail = fmt.Sprintf("no ingress rule allows the internet, prefix lists %s are not resolved", strings.Join(rule.PrefixListIds, ","))
+			}
+		}
+
+		exposure.Evidence = []ReachabilityHop{{
+			Component:  HopPublicAddress,
+			ResourceId: inst.Id,
+			Status:     HopAllow,
+			Detail:     "instance has public address " + ptr.Deref(inst.PublicIP),
+		}, groupHop}
+		return
+	}
+
+	// Rules open to the internet, grouped by protocol and address family
+	type openRule struct {
+		protocol string
+		peer     string
+	}
+
+	keys := make([]openRule, 0, len(inst.IngressSecRules))
+	ports := make(map[openRule][]int32, len(inst.IngressSecRules))
+	for _, rule := range inst.IngressSecRules {
+		protocol := protocolName(normalizeProtocol(rule.IpProtocol))
+		if protocol == "all" {
+			protocol = protocolTcp
+		}
+
+		for _, ipRange := range rule.IpRanges {
+			if ipRange != anyIPv4 && ipRange != anyIPv6 {
+				continue
+			}
+
+			key := openRule{protocol: protocol, peer: ipRange}
+			if _, exists := ports[key]; !exists {
+				keys = append(keys, key)
+			}
+
+			for _, port := range n.inboundPorts(inst, rule) {
+				if !slices.Contains(ports[key], port) {
+					ports[key] = append(ports[key], port)
+				}
+			}
+		}
+	}
+
+	bestStatus := ReachabilityUnreachable
+	for _, key := range keys {
+		hops, status := n.explainPeer(inst, netip.MustParsePrefix(key.peer), key.protocol, ports[key])
+		if exposure.Evidence == nil || slices.Index(reachabilityRanking, status) < slices.Index(reachabilityRanking, bestStatus) {
+			exposure.Evidence, bestStatus = hops, status
+		}
+	}
+
+	exposure.Confidence = verdictConfidence(bestStatus)
+	if bestStatus == ReachabilityUnreachable {
+		exposure.Status = ExposurePublic
+	}
+}
+
+// inboundPorts stands for all the ports of the rule, one port for every range the network ACL
+// of the instance subnet decides alike, so the ports denied by the network ACL are never the
+// only ones checked
+func (n networkIndex) inboundPorts(inst Ec2Instance, rule Ec2SecGroupRule) []int32 {
+	segments := []PortRange{rulePorts(rule)}
+	if acl, exists := n.networkAcl(inst.SubnetId); exists {
+		segments = acl.portSegments(false, segments[0])
+	}
+
+	ports := make([]int32, 0, len(segments))
+	for _, segment := range segments {
+		ports = append(ports, segment.From)
+	}
+
+	return ports
+}
+
+// frontDoorEvidence turns the paths of front doors into evidence. They come from the
+// configuration of the front doors, so they are confirmed
+func frontDoorEvidence(paths []FrontDoorPath) []ReachabilityHop {
+	evidence := make([]ReachabilityHop, 0, len(paths))
+	for _, path := range paths {
+		evidence = append(evidence, ReachabilityHop{
+			Component:  HopFrontDoor,
+			ResourceId: path.Hops[0].AssetId,
+			Status:     HopAllow,
+			Detail:     path.String(),
+		})
+	}
+
+	return evidence
+}
+
+func evidenceDetails(evidence []ReachabilityHop) []string {
+	details := make([]string, 0, len(evidence))
+	for _, hop := range evidence {
+		details = append(details, hop.String())
+	}
+
+	return details
+}
@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"slices"
+	"testing"
+)
+
+func TestExplainFindings(t *testing.T) {
+	inv := reachabilityInventory()
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+
+	inv.Instances = append(inv.Instances, Ec2Instance{Id: "i-https", VPC: "vpc-1", SubnetId: "subnet-https", PrivateIP: "10.0.5.10", PublicIP: ptr.Ref("3.3.5.1"), IngressSecRules: []Ec2SecGroupRule{
+		{FromPort: 0, ToPort: 65535, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+	}})
+	inv.NetworkAcls = append(inv.NetworkAcls, NetworkAcl{Id: "acl-https", VPC: "vpc-1", SubnetIds: []string{"subnet-https"}, Entries: []NetworkAclEntry{
+		{RuleNumber: 100, Protocol: "6", FromPort: 443, ToPort: 443, Cidr: anyIPv4, Allow: true},
+		{RuleNumber: 100, Egress: true, Protocol: allProtocols, Cidr: anyIPv4, Allow: true},
+	}})
+
+	exposures := computeInstanceExposures(inv, frontDoorGraph{})
+	for _, exposure := range exposures {
+		if exposure.InstanceId == "i-denied" && (exposure.Status != ExposurePublic || exposure.Confidence != ConfidenceConfirmed) {
+			t.Errorf("expected the network ACL to take i-denied off the internet, got %+v", exposure)
+		}
+
+		if exposure.InstanceId == "i-https" && (exposure.Status == ExposurePublic || exposure.Confidence != ConfidenceConfirmed) {
+			t.Errorf("expected i-https open to the internet on 443, got %+v", exposure)
+		}
+	}
+
+	expected := map[string]string{
+		"i-public":  ConfidenceConfirmed,
+		"i-office":  ConfidenceConfirmed,
+		"i-unknown": ConfidenceUnknown,
+	}
+
+	findings := buildFindings(inv, exposures, computeServiceExposures(inv, catalog, classifier), catalog)
+	if len(findings) != len(expected) {
+		t.Fatalf("expected findings for %v, got %+v", expected, findings)
+	}
+
+	for _, finding := range findings {
+		if finding.Confidence != expected[finding.Asset.AssetId] {
+			t.Errorf("%s: expected %s confidence, got %s", finding.Asset.AssetId, expected[finding.Asset.AssetId], finding.Confidence)
+		}
+
+		components := make([]string, 0, len(finding.Evidence))
+		for _, hop := range finding.Evidence {
+			components = append(components, hop.Component)
+		}
+
+		if !slices.Contains(components, HopSecurityGroup) || !slices.Contains(components, HopNetworkAcl) {
+			t.Errorf("%s: expected security group and network ACL evidence, got %v", finding.Asset.AssetId, finding.EvidenceDetails())
+		}
+	}
+}
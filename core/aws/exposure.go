@@ -0,0 +1,258 @@
+package aws
+
+import "strings"
+
+const (
+	// ExposureFrontDoor instances are served to the internet by a load balancer, CloudFront
+	// distribution or API Gateway stage, and at least one of them has no WAF
+	ExposureFrontDoor = "front-door"
+	// ExposureFrontDoorWaf instances are served to the internet only through front doors with a WAF
+	ExposureFrontDoorWaf = "front-door-waf"
+)
+
+const frontDoorMaxDepth = 6
+
+// InstanceExposure is how the instance is reachable from the internet, with the evidence of it
+// and how sure that is
+type InstanceExposure struct {
+	InstanceId string
+	Status     string
+	FrontDoors []FrontDoorPath
+	Confidence string
+	Evidence   []ReachabilityHop
+}
+
+// FrontDoorPath is how internet traffic gets to an instance, from the internet facing asset
+// to the last hop before the instance
+type FrontDoorPath struct {
+	Hops []AssetRef
+	WAF  bool
+}
+
+func (f FrontDoorPath) String() string {
+	hops := make([]string, 0, len(f.Hops))
+	for _, hop := range f.Hops {
+		hops = append(hops, hop.AssetType+":"+hop.AssetId)
+	}
+
+	path := strings.Join(hops, " -> ")
+	if f.WAF {
+		path += " (WAF)"
+	}
+
+	return path
+}
+
+// FrontDoorRoute is a single hop of traffic between two assets, e.g. a CloudFront distribution
+// and its origin, or a load balancer and its target
+type FrontDoorRoute struct {
+	From AssetRef
+	To   AssetRef
+}
+
+type frontDoorGraph struct {
+	entries []AssetRef
+	routes  map[AssetRef][]AssetRef
+	waf     map[AssetRef]bool
+}
+
+func buildFrontDoorGraph(inv Inventory, resolver dnsResolver) frontDoorGraph {
+	g := frontDoorGraph{
+		entries: make([]AssetRef, 0, len(inv.LoadBalancers)+len(inv.CloudFrontDistributions)+len(inv.ApiGatewayStages)),
+		routes:  make(map[AssetRef][]AssetRef, len(inv.LoadBalancers)),
+		waf:     make(map[AssetRef]bool, len(inv.CloudFrontDistributions)),
+	}
+
+	loadBalancers := make(map[string]bool, len(inv.LoadBalancers))
+	for _, lb := range inv.LoadBalancers {
+		loadBalancers[lb.Arn] = true
+	}
+
+	stagesByApi := make(map[string][]AssetRef, len(inv.ApiGatewayStages))
+	for _, stage := range inv.ApiGatewayStages {
+		ref := AssetRef{AssetType: AssetApiGatewayStage, AssetId: stage.Id()}
+		stagesByApi[stage.ApiId] = append(stagesByApi[stage.ApiId], ref)
+	}
+
+	resolveOrigin := func(origin FrontDoorOrigin) []AssetRef {
+		switch origin.Type {
+		case OriginVpcLink:
+			if loadBalancers[origin.Target] {
+				return []AssetRef{{AssetType: AssetLoadBalancer, AssetId: origin.Target}}
+			}
+		case OriginApiGateway:
+			apiId, _, _ := strings.Cut(normalizeHostname(origin.Target), ".")
+			return stagesByApi[apiId]
+		case OriginLoadBalancer, OriginEc2, OriginHttp:
+			if ref, found := resolver.resolveAsset(origin.Target); found {
+				return []AssetRef{ref}
+			}
+		}
+
+		// Lambda functions and S3 buckets are not fetched, they are not part of the graph
+		return nil
+	}
+
+	for _, lb := range inv.LoadBalancers {
+		ref := AssetRef{AssetType: AssetLoadBalancer, AssetId: lb.Arn}
+		g.waf[ref] = lb.HasWAF()
+		if lb.IsInternetFacing() {
+			g.entries = append(g.entries, ref)
+		}
+
+		for _, target := range lb.Targets {
+			if to, found := resolveLoadBalancerTarget(target, resolver); found {
+				g.routes[ref] = append(g.routes[ref], to)
+			}
+		}
+	}
+
+	for _, distribution := range inv.CloudFrontDistributions {
+		ref := AssetRef{AssetType: AssetCloudFrontDistribution, AssetId: distribution.Id}
+		g.waf[ref] = distribution.HasWAF()
+		if distribution.Enabled {
+			g.entries = append(g.entries, ref)
+		}
+
+		for _, origin := range distribution.Origins {
+			g.routes[ref] = append(g.routes[ref], resolveOrigin(origin)...)
+		}
+	}
+
+	for _, stage := range inv.ApiGatewayStages {
+		ref := AssetRef{AssetType: AssetApiGatewayStage, AssetId: stage.Id()}
+		g.waf[ref] = stage.HasWAF()
+		if !stage.Private {
+			g.entries = append(g.entries, ref)
+		}
+
+		for _, integration := range stage.Integrations {
+			g.routes[ref] = append(g.routes[ref], resolveOrigin(integration)...)
+		}
+	}
+
+	return g
+}
+
+func resolveLoadBalancerTarget(target LoadBalancerTarget, resolver dnsResolver) (AssetRef, bool) {
+	switch target.Type {
+	case "instance":
+		return AssetRef{AssetType: AssetEc2Instance, AssetId: target.Id}, true
+	case "alb":
+		return AssetRef{AssetType: AssetLoadBalancer, AssetId: target.Id}, true
+	case "ip":
+		ref, found := resolver.addresses[target.Id]
+		return ref, found
+	default:
+		return AssetRef{}, false
+	}
+}
+
+func (g frontDoorGraph) allRoutes() []FrontDoorRoute {
+	routes := make([]FrontDoorRoute, 0, len(g.routes)*2)
+	for from, destinations := range g.routes {
+		for _, to := range destinations {
+			routes = append(routes, FrontDoorRoute{From: from, To: to})
+		}
+	}
+
+	return routes
+}
+
+// pathsToInstances walks from every internet facing entry down to the instances it serves
+func (g frontDoorGraph) pathsToInstances() map[string][]FrontDoorPath {
+	paths := make(map[string][]FrontDoorPath, len(g.routes))
+
+	var walk func(current AssetRef, hops []AssetRef, waf bool)
+	walk = func(current AssetRef, hops []AssetRef, waf bool) {
+		if current.AssetType == AssetEc2Instance {
+			paths[current.AssetId] = append(paths[current.AssetId], FrontDoorPath{Hops: hops, WAF: waf})
+			return
+		}
+
+		if len(hops) >= frontDoorMaxDepth {
+			return
+		}
+
+		for _, hop := range hops {
+			if hop == current {
+				return
+			}
+		}
+
+		hops = append(hops[:len(hops):len(hops)], current)
+		waf = waf || g.waf[current]
+		for _, next := range g.routes[current] {
+			walk(next, hops, waf)
+		}
+	}
+
+	for _, entry := range g.entries {
+		walk(entry, nil, false)
+	}
+
+	return paths
+}
+
+// computeInstanceExposures classifies every instance from the most to the least exposed:
+// open to the internet on its own public address, through front doors without WAF, only
+// through front doors with WAF, public address with restricted security groups or private.
+// Every status comes with its evidence, from the reachability engine for instances open to the
+// internet, or the front door paths.
+func computeInstanceExposures(inv Inventory, g frontDoorGraph) []InstanceExposure {
+	paths := g.pathsToInstances()
+	network := newNetworkIndex(inv)
+	exposures := make([]InstanceExposure, 0, len(inv.Instances))
+
+	for _, inst := range inv.Instances {
+		exposure := InstanceExposure{
+			InstanceId: inst.Id,
+			Status:     inst.ExposureStatus(),
+			FrontDoors: paths[inst.Id],
+		}
+		network.explainInstanceExposure(inst, &exposure)
+
+		if exposure.Status != ExposureOpenToInternet && len(exposure.FrontDoors) > 0 {
+			exposure.Confidence = ConfidenceConfirmed
+			exposure.Evidence = frontDoorEvidence(exposure.FrontDoors)
+			exposure.Status = ExposureFrontDoorWaf
+			for _, path := range exposure.FrontDoors {
+				if !path.WAF {
+					exposure.Status = ExposureFrontDoor
+				}
+			}
+		}
+
+		exposures = append(exposures, exposure)
+	}
+
+	return exposures
+}
+
+// applyInstanceExposures replaces the status of addresses owned by instances with the one
+// that considers front doors
+func applyInstanceExposures(addresses []IpAddress, exposures []InstanceExposure) {
+	statuses := make(map[string]string, len(exposures))
+	for _, exposure := range exposures {
+		statuses[exposure.InstanceId] = exposure.Status
+	}
+
+	for idx, addr := range addresses {
+		if status, exists := statuses[addr.OwnerId]; exists && addr.OwnerType == AssetEc2Instance {
+			addresses[idx].Exposure = status
+		}
+	}
+}
+
+func (i InstanceExposure) FrontDoorPaths() []string {
+	paths := make([]string, 0, len(i.FrontDoors))
+	for _, path := range i.FrontDoors {
+		paths = append(paths, path.String())
+	}
+
+	return paths
+}
+
+func (i InstanceExposure) EvidenceDetails() []string {
+	return evidenceDetails(i.Evidence)
+}
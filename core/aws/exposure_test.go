@@ -0,0 +1,23 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"testing"
+)
+
+func TestLoadBalancerWafExposure(t *testing.T) {
+	inv := Inventory{
+		Instances: []Ec2Instance{{Id: "i-protected"}, {Id: "i-unprotected"}},
+		LoadBalancers: []LoadBalancer{
+			{Arn: "alb-waf", Type: loadBalancerApplication, Scheme: loadBalancerInternetFacing, WebACLArn: ptr.Ref("acl-1"),
+				Targets: []LoadBalancerTarget{{Id: "i-protected", Type: "instance", Port: 443}}},
+			{Arn: "alb-open", Type: loadBalancerApplication, Scheme: loadBalancerInternetFacing,
+				Targets: []LoadBalancerTarget{{Id: "i-unprotected", Type: "instance", Port: 443}}},
+		},
+	}
+
+	exposures := computeInstanceExposures(inv, buildFrontDoorGraph(inv, newDnsResolver(inv, nil)))
+	if exposures[0].Status != ExposureFrontDoorWaf || exposures[1].Status != ExposureFrontDoor {
+		t.Errorf("expected only the instance behind the load balancer without WAF to be a front door exposure, got %+v", exposures)
+	}
+}
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"asset-relations/support/parallel"
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+)
+
+var ec2ImagesPerRequest = 100
+var ec2ImageAttributesMaxGoroutines = 20
+
+type Ec2ImagesFetcher struct {
+	client    *ec2.Client
+	accountId string
+	logger    *slog.Logger
+}
+
+func NewEc2ImagesFetcher(awsCfg awssdk.Config, accountId string, logger *slog.Logger) Ec2ImagesFetcher {
+	return Ec2ImagesFetcher{
+		client:    ec2.NewFromConfig(awsCfg),
+		accountId: accountId,
+		logger:    logger,
+	}
+}
+
+// Fetch describes the given images and every image the account owns, used or not, as our
+// images shared publicly leak whatever they hold even when no instance runs them. Images
+// that can't be described anymore are returned as unavailable, so instances running them are
+// still linked to something
+func (e *Ec2ImagesFetcher) Fetch(ctx context.Context, imageIds []string) ([]Ami, error) {
+	e.logger.Info("Fetching AMIs")
+
+	amis := make([]Ami, 0, len(imageIds))
+	found := make(map[string]bool, len(imageIds))
+	add := func(image ec2types.Image) {
+		ami := convertImage(image, e.accountId)
+		if !found[ami.Id] {
+			found[ami.Id] = true
+			amis = append(amis, ami)
+		}
+	}
+
+	owned := ec2.DescribeImagesInput{
+		Owners:            []string{"self"},
+		IncludeDeprecated: ptr.Ref(true),
+		MaxResults:        &ec2MaxResultsPerPage,
+	}
+	if err := e.describe(ctx, owned, add); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(imageIds); start += ec2ImagesPerRequest {
+		end := min(start+ec2ImagesPerRequest, len(imageIds))
+
+		// Filtering instead of passing ImageIds, which fails the whole request when a single
+		// image is not visible anymore
+		params := ec2.DescribeImagesInput{
+			Filters:           []ec2types.Filter{{Name: ptr.Ref("image-id"), Values: imageIds[start:end]}},
+			IncludeDeprecated: ptr.Ref(true),
+			MaxResults:        &ec2MaxResultsPerPage,
+		}
+		if err := e.describe(ctx, params, add); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, imageId := range imageIds {
+		if !found[imageId] {
+			amis = append(amis, Ami{Id: imageId, Provenance: AmiUnavailable})
+		}
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d AMIs", len(amis)))
+
+	return parallel.Map(ctx, amis, e.enrichLaunchPermissions, ec2ImageAttributesMaxGoroutines)
+}
+
+// describe goes through every page of images matching params
+func (e *Ec2ImagesFetcher) describe(ctx context.Context, params ec2.DescribeImagesInput, add func(ec2types.Image)) error {
+	for {
+		res, err := e.client.DescribeImages(ctx, &params)
+		if err != nil {
+			return err
+		}
+
+		for _, image := range res.Images {
+			add(image)
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			return nil
+		}
+
+		params.NextToken = res.NextToken
+	}
+}
+
+// enrichLaunchPermissions fetches who our images are shared with, AWS only tells it to the owner
+func (e *Ec2ImagesFetcher) enrichLaunchPermissions(ctx context.Context, ami Ami) (Ami, error) {
+	if ami.Provenance != AmiOwnedByAccount {
+		return ami, nil
+	}
+
+	res, err := e.client.DescribeImageAttribute(ctx, &ec2.DescribeImageAttributeInput{
+		ImageId:   ptr.Ref(ami.Id),
+		Attribute: ec2types.ImageAttributeNameLaunchPermission,
+	})
+	if err != nil {
+		return ami, err
+	}
+
+	ami.LaunchPermissions = convertLaunchPermissions(res.LaunchPermissions)
+
+	return ami, nil
+}
+
+func convertLaunchPermissions(permissions []ec2types.LaunchPermission) []AmiLaunchPermission {
+	converted := make([]AmiLaunchPermission, 0, len(permissions))
+	for _, permission := range permissions {
+		launchPermission := AmiLaunchPermission{
+			UserId:                permission.UserId,
+			OrganizationArn:       permission.OrganizationArn,
+			OrganizationalUnitArn: permission.OrganizationalUnitArn,
+		}
+
+		if permission.Group != "" {
+			launchPermission.Group = ptr.Ref(string(permission.Group))
+		}
+
+		converted = append(converted, launchPermission)
+	}
+
+	return converted
+}
+
+func convertImage(image ec2types.Image, accountId string) Ami {
+	ownerId := ptr.Deref(image.OwnerId)
+
+	return Ami{
+		Id:              ptr.Deref(image.ImageId),
+		Name:            ptr.Deref(image.Name),
+		OwnerId:         ownerId,
+		OwnerAlias:      image.ImageOwnerAlias,
+		Provenance:      amiProvenance(accountId, ownerId, image.ImageOwnerAlias),
+		Public:          ptr.Deref(image.Public),
+		State:           string(image.State),
+		CreationDate:    image.CreationDate,
+		DeprecationTime: image.DeprecationTime,
+	}
+}
@@ -0,0 +1,365 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	apigwtypes "github.com/aws/aws-sdk-go-v2/service/apigateway/types"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewayv2"
+	apigwv2types "github.com/aws/aws-sdk-go-v2/service/apigatewayv2/types"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+var apiGatewayMaxResultsPerPage = int32(500)
+
+const lambdaFunctionsPath = ":lambda:path/2015-03-31/functions/"
+
+// ApiGatewayStagesFetcher fetches both REST APIs (API Gateway v1) and HTTP/WebSocket APIs
+// (API Gateway v2), as both are front doors to the same kind of backends
+type ApiGatewayStagesFetcher struct {
+	client   *apigateway.Client
+	clientV2 *apigatewayv2.Client
+	logger   *slog.Logger
+}
+
+func NewApiGatewayStagesFetcher(awsCfg awssdk.Config, logger *slog.Logger) ApiGatewayStagesFetcher {
+	return ApiGatewayStagesFetcher{
+		client:   apigateway.NewFromConfig(awsCfg),
+		clientV2: apigatewayv2.NewFromConfig(awsCfg),
+		logger:   logger,
+	}
+}
+
+func (a *ApiGatewayStagesFetcher) Fetch(ctx context.Context) ([]ApiGatewayStage, error) {
+	restStages, err := a.fetchRestStages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpStages, err := a.fetchHttpStages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := append(restStages, httpStages...)
+	a.logger.Info(fmt.Sprintf("Fetched %d API Gateway stages", len(stages)))
+
+	return stages, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchRestStages(ctx context.Context) ([]ApiGatewayStage, error) {
+	a.logger.Info("Fetching API Gateway REST APIs")
+
+	vpcLinks, err := a.fetchVpcLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := apigateway.GetRestApisInput{Limit: &apiGatewayMaxResultsPerPage}
+	stages := make([]ApiGatewayStage, 0, 10)
+
+	for {
+		res, err := a.client.GetRestApis(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, api := range res.Items {
+			apiStages, err := a.fetchRestApiStages(ctx, api, vpcLinks)
+			if err != nil {
+				return nil, err
+			}
+
+			stages = append(stages, apiStages...)
+		}
+
+		if ptr.IsEmpty(res.Position) {
+			break
+		}
+
+		params.Position = res.Position
+	}
+
+	return stages, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchRestApiStages(ctx context.Context, api apigwtypes.RestApi, vpcLinks map[string][]string) ([]ApiGatewayStage, error) {
+	integrations, err := a.fetchRestIntegrations(ctx, api.Id, vpcLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := a.client.GetStages(ctx, &apigateway.GetStagesInput{RestApiId: api.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	private := api.EndpointConfiguration != nil &&
+		slices.Contains(api.EndpointConfiguration.Types, apigwtypes.EndpointTypePrivate)
+
+	stages := make([]ApiGatewayStage, 0, len(res.Item))
+	for _, stage := range res.Item {
+		stages = append(stages, ApiGatewayStage{
+			ApiId:        ptr.Deref(api.Id),
+			ApiName:      ptr.Deref(api.Name),
+			ApiType:      ApiGatewayRest,
+			StageName:    ptr.Deref(stage.StageName),
+			Private:      private,
+			WebACLArn:    stage.WebAclArn,
+			Integrations: integrations,
+		})
+	}
+
+	return stages, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchRestIntegrations(ctx context.Context, apiId *string, vpcLinks map[string][]string) ([]FrontDoorOrigin, error) {
+	params := apigateway.GetResourcesInput{
+		RestApiId: apiId,
+		Embed:     []string{"methods"},
+		Limit:     &apiGatewayMaxResultsPerPage,
+	}
+	integrations := make([]FrontDoorOrigin, 0, 10)
+
+	for {
+		res, err := a.client.GetResources(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range res.Items {
+			for _, method := range resource.ResourceMethods {
+				if method.MethodIntegration == nil {
+					continue
+				}
+
+				integrations = append(integrations, convertRestIntegration(*method.MethodIntegration, vpcLinks)...)
+			}
+		}
+
+		if ptr.IsEmpty(res.Position) {
+			break
+		}
+
+		params.Position = res.Position
+	}
+
+	return uniqueOrigins(integrations), nil
+}
+
+func convertRestIntegration(integration apigwtypes.Integration, vpcLinks map[string][]string) []FrontDoorOrigin {
+	uri := ptr.Deref(integration.Uri)
+
+	if integration.ConnectionType == apigwtypes.ConnectionTypeVpcLink {
+		targets := vpcLinks[ptr.Deref(integration.ConnectionId)]
+		origins := make([]FrontDoorOrigin, 0, len(targets))
+		for _, target := range targets {
+			origins = append(origins, FrontDoorOrigin{Type: OriginVpcLink, Target: target})
+		}
+
+		return origins
+	}
+
+	switch integration.Type {
+	case apigwtypes.IntegrationTypeHttp, apigwtypes.IntegrationTypeHttpProxy:
+		hostname := hostnameFromUri(uri)
+		return []FrontDoorOrigin{{Type: classifyOriginHostname(hostname), Target: hostname}}
+	case apigwtypes.IntegrationTypeAws, apigwtypes.IntegrationTypeAwsProxy:
+		if function, isLambda := lambdaFromIntegrationUri(uri); isLambda {
+			return []FrontDoorOrigin{{Type: OriginLambda, Target: function}}
+		}
+	}
+
+	return nil
+}
+
+// lambdaFromIntegrationUri extracts the function ARN out of
+// arn:aws:apigateway:{region}:lambda:path/2015-03-31/functions/{function arn}/invocations
+func lambdaFromIntegrationUri(uri string) (string, bool) {
+	_, function, found := strings.Cut(uri, lambdaFunctionsPath)
+	if !found {
+		return "", false
+	}
+
+	return strings.TrimSuffix(function, "/invocations"), true
+}
+
+// fetchVpcLinks returns the target network load balancers of every VPC link by its id
+func (a *ApiGatewayStagesFetcher) fetchVpcLinks(ctx context.Context) (map[string][]string, error) {
+	params := apigateway.GetVpcLinksInput{Limit: &apiGatewayMaxResultsPerPage}
+	vpcLinks := make(map[string][]string, 10)
+
+	for {
+		res, err := a.client.GetVpcLinks(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range res.Items {
+			vpcLinks[ptr.Deref(link.Id)] = link.TargetArns
+		}
+
+		if ptr.IsEmpty(res.Position) {
+			break
+		}
+
+		params.Position = res.Position
+	}
+
+	return vpcLinks, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchHttpStages(ctx context.Context) ([]ApiGatewayStage, error) {
+	a.logger.Info("Fetching API Gateway HTTP and WebSocket APIs")
+
+	params := apigatewayv2.GetApisInput{}
+	stages := make([]ApiGatewayStage, 0, 10)
+
+	for {
+		res, err := a.clientV2.GetApis(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, api := range res.Items {
+			apiStages, err := a.fetchHttpApiStages(ctx, api)
+			if err != nil {
+				return nil, err
+			}
+
+			stages = append(stages, apiStages...)
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	return stages, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchHttpApiStages(ctx context.Context, api apigwv2types.Api) ([]ApiGatewayStage, error) {
+	integrations, err := a.fetchHttpIntegrations(ctx, api.ApiId)
+	if err != nil {
+		return nil, err
+	}
+
+	params := apigatewayv2.GetStagesInput{ApiId: api.ApiId}
+	stages := make([]ApiGatewayStage, 0, 5)
+
+	for {
+		res, err := a.clientV2.GetStages(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		// HTTP APIs can't be associated with a WAF web ACL
+		for _, stage := range res.Items {
+			stages = append(stages, ApiGatewayStage{
+				ApiId:        ptr.Deref(api.ApiId),
+				ApiName:      ptr.Deref(api.Name),
+				ApiType:      string(api.ProtocolType),
+				StageName:    ptr.Deref(stage.StageName),
+				Integrations: integrations,
+			})
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	return stages, nil
+}
+
+func (a *ApiGatewayStagesFetcher) fetchHttpIntegrations(ctx context.Context, apiId *string) ([]FrontDoorOrigin, error) {
+	params := apigatewayv2.GetIntegrationsInput{ApiId: apiId}
+	integrations := make([]FrontDoorOrigin, 0, 10)
+
+	for {
+		res, err := a.clientV2.GetIntegrations(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, integration := range res.Items {
+			if origin, ok := convertHttpIntegration(integration); ok {
+				integrations = append(integrations, origin)
+			}
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	return uniqueOrigins(integrations), nil
+}
+
+func convertHttpIntegration(integration apigwv2types.Integration) (FrontDoorOrigin, bool) {
+	uri := ptr.Deref(integration.IntegrationUri)
+
+	// Private integrations point to a load balancer listener or a Cloud Map service
+	if integration.ConnectionType == apigwv2types.ConnectionTypeVpcLink {
+		return FrontDoorOrigin{Type: OriginVpcLink, Target: loadBalancerFromListenerArn(uri)}, true
+	}
+
+	switch integration.IntegrationType {
+	case apigwv2types.IntegrationTypeHttp, apigwv2types.IntegrationTypeHttpProxy:
+		hostname := hostnameFromUri(uri)
+		return FrontDoorOrigin{Type: classifyOriginHostname(hostname), Target: hostname}, true
+	case apigwv2types.IntegrationTypeAwsProxy:
+		if strings.Contains(uri, ":lambda:") {
+			function, isWrapped := lambdaFromIntegrationUri(uri)
+			if !isWrapped {
+				function = uri
+			}
+
+			return FrontDoorOrigin{Type: OriginLambda, Target: function}, true
+		}
+	}
+
+	return FrontDoorOrigin{}, false
+}
+
+// loadBalancerFromListenerArn turns
+// arn:aws:elasticloadbalancing:{region}:{account}:listener/app/{name}/{id}/{listener id} into
+// arn:aws:elasticloadbalancing:{region}:{account}:loadbalancer/app/{name}/{id}
+func loadBalancerFromListenerArn(arn string) string {
+	prefix, listener, found := strings.Cut(arn, ":listener/")
+	if !found {
+		return arn
+	}
+
+	lastSlash := strings.LastIndex(listener, "/")
+	if lastSlash < 0 {
+		return arn
+	}
+
+	return prefix + ":loadbalancer/" + listener[:lastSlash]
+}
+
+func uniqueOrigins(origins []FrontDoorOrigin) []FrontDoorOrigin {
+	seen := make(map[FrontDoorOrigin]bool, len(origins))
+	unique := make([]FrontDoorOrigin, 0, len(origins))
+	for _, origin := range origins {
+		if seen[origin] {
+			continue
+		}
+
+		seen[origin] = true
+		unique = append(unique, origin)
+	}
+
+	return unique
+}
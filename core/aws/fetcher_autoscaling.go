@@ -0,0 +1,185 @@
+package aws
+
+import (
+	"asset-relations/support/parallel"
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	astypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+	"strings"
+)
+
+var autoScalingMaxResultsPerPage = int32(100)
+var launchTemplateVersionsMaxGoroutines = 20
+
+const launchTemplateDefaultVersion = "$Default"
+
+type AutoScalingGroupsFetcher struct {
+	client *autoscaling.Client
+	logger *slog.Logger
+}
+
+func NewAutoScalingGroupsFetcher(awsCfg awssdk.Config, logger *slog.Logger) AutoScalingGroupsFetcher {
+	return AutoScalingGroupsFetcher{
+		client: autoscaling.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (a *AutoScalingGroupsFetcher) Fetch(ctx context.Context) ([]AutoScalingGroup, error) {
+	a.logger.Info("Fetching Auto Scaling groups")
+
+	params := autoscaling.DescribeAutoScalingGroupsInput{MaxRecords: &autoScalingMaxResultsPerPage}
+	groups := make([]AutoScalingGroup, 0, autoScalingMaxResultsPerPage)
+
+	for {
+		res, err := a.client.DescribeAutoScalingGroups(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, group := range res.AutoScalingGroups {
+			groups = append(groups, convertAutoScalingGroup(group))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	a.logger.Info(fmt.Sprintf("Fetched %d Auto Scaling groups", len(groups)))
+
+	return groups, nil
+}
+
+func convertAutoScalingGroup(group astypes.AutoScalingGroup) AutoScalingGroup {
+	instanceIds := make([]string, 0, len(group.Instances))
+	for _, instance := range group.Instances {
+		instanceIds = append(instanceIds, ptr.Deref(instance.InstanceId))
+	}
+
+	// Groups with mixed instances policies have the template inside the policy
+	template := group.LaunchTemplate
+	if template == nil && group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+		template = group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+
+	var templateId, templateVersion *string
+	if template != nil {
+		templateId = template.LaunchTemplateId
+		templateVersion = template.Version
+	}
+
+	var subnetIds []string
+	if !ptr.IsEmpty(group.VPCZoneIdentifier) {
+		subnetIds = strings.Split(*group.VPCZoneIdentifier, ",")
+	}
+
+	return AutoScalingGroup{
+		Name:                    ptr.Deref(group.AutoScalingGroupName),
+		Arn:                     ptr.Deref(group.AutoScalingGroupARN),
+		InstanceIds:             instanceIds,
+		LaunchTemplateId:        templateId,
+		LaunchTemplateVersion:   templateVersion,
+		LaunchConfigurationName: group.LaunchConfigurationName,
+		TargetGroupArns:         group.TargetGroupARNs,
+		SubnetIds:               subnetIds,
+		MinSize:                 ptr.Deref(group.MinSize),
+		MaxSize:                 ptr.Deref(group.MaxSize),
+		DesiredCapacity:         ptr.Deref(group.DesiredCapacity),
+	}
+}
+
+type Ec2LaunchTemplatesFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2LaunchTemplatesFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2LaunchTemplatesFetcher {
+	return Ec2LaunchTemplatesFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2LaunchTemplatesFetcher) Fetch(ctx context.Context) ([]LaunchTemplate, error) {
+	e.logger.Info("Fetching launch templates")
+
+	params := ec2.DescribeLaunchTemplatesInput{MaxResults: &ec2MaxResultsPerPage}
+	templates := make([]LaunchTemplate, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeLaunchTemplates(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, template := range res.LaunchTemplates {
+			templates = append(templates, LaunchTemplate{
+				Id:             ptr.Deref(template.LaunchTemplateId),
+				Name:           ptr.Deref(template.LaunchTemplateName),
+				DefaultVersion: ptr.Deref(template.DefaultVersionNumber),
+			})
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	templates, err := parallel.Map(ctx, templates, e.enrichDefaultVersion, launchTemplateVersionsMaxGoroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d launch templates", len(templates)))
+
+	return templates, nil
+}
+
+func (e *Ec2LaunchTemplatesFetcher) enrichDefaultVersion(ctx context.Context, template LaunchTemplate) (LaunchTemplate, error) {
+	res, err := e.client.DescribeLaunchTemplateVersions(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: &template.Id,
+		Versions:         []string{launchTemplateDefaultVersion},
+	})
+	if err != nil {
+		return template, err
+	}
+
+	if len(res.LaunchTemplateVersions) == 0 || res.LaunchTemplateVersions[0].LaunchTemplateData == nil {
+		return template, nil
+	}
+
+	return convertLaunchTemplateData(template, *res.LaunchTemplateVersions[0].LaunchTemplateData), nil
+}
+
+func convertLaunchTemplateData(template LaunchTemplate, data ec2types.ResponseLaunchTemplateData) LaunchTemplate {
+	template.ImageId = data.ImageId
+	template.InstanceType = string(data.InstanceType)
+	template.KeyName = data.KeyName
+	template.SecurityGroupIds = data.SecurityGroupIds
+
+	if data.IamInstanceProfile != nil {
+		template.IamInstanceProfile = data.IamInstanceProfile.Arn
+		if template.IamInstanceProfile == nil {
+			template.IamInstanceProfile = data.IamInstanceProfile.Name
+		}
+	}
+
+	// Security groups and public addresses might be set on the network interfaces instead
+	for _, eni := range data.NetworkInterfaces {
+		template.SecurityGroupIds = append(template.SecurityGroupIds, eni.Groups...)
+		template.AssociatePublicIp = template.AssociatePublicIp || ptr.Deref(eni.AssociatePublicIpAddress)
+	}
+
+	return template
+}
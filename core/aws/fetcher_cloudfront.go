@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"log/slog"
+)
+
+type CloudFrontDistributionsFetcher struct {
+	client *cloudfront.Client
+	logger *slog.Logger
+}
+
+func NewCloudFrontDistributionsFetcher(awsCfg awssdk.Config, logger *slog.Logger) CloudFrontDistributionsFetcher {
+	return CloudFrontDistributionsFetcher{
+		client: cloudfront.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (c *CloudFrontDistributionsFetcher) Fetch(ctx context.Context) ([]CloudFrontDistribution, error) {
+	c.logger.Info("Fetching CloudFront distributions")
+
+	params := cloudfront.ListDistributionsInput{}
+	distributions := make([]CloudFrontDistribution, 0, 10)
+
+	for {
+		res, err := c.client.ListDistributions(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.DistributionList == nil {
+			break
+		}
+
+		for _, distribution := range res.DistributionList.Items {
+			distributions = append(distributions, convertDistribution(distribution))
+		}
+
+		if !ptr.Deref(res.DistributionList.IsTruncated) {
+			break
+		}
+
+		params.Marker = res.DistributionList.NextMarker
+	}
+
+	c.logger.Info(fmt.Sprintf("Fetched %d CloudFront distributions", len(distributions)))
+
+	return distributions, nil
+}
+
+func convertDistribution(distribution cftypes.DistributionSummary) CloudFrontDistribution {
+	var aliases []string
+	if distribution.Aliases != nil {
+		aliases = distribution.Aliases.Items
+	}
+
+	var origins []FrontDoorOrigin
+	if distribution.Origins != nil {
+		origins = make([]FrontDoorOrigin, 0, len(distribution.Origins.Items))
+		for _, origin := range distribution.Origins.Items {
+			origins = append(origins, convertOrigin(origin))
+		}
+	}
+
+	return CloudFrontDistribution{
+		Id:         ptr.Deref(distribution.Id),
+		Arn:        ptr.Deref(distribution.ARN),
+		DomainName: ptr.Deref(distribution.DomainName),
+		Aliases:    aliases,
+		Enabled:    ptr.Deref(distribution.Enabled),
+		WebACLId:   distribution.WebACLId,
+		Origins:    origins,
+	}
+}
+
+func convertOrigin(origin cftypes.Origin) FrontDoorOrigin {
+	hostname := ptr.Deref(origin.DomainName)
+	originType := classifyOriginHostname(hostname)
+	if origin.S3OriginConfig != nil {
+		originType = OriginS3
+	}
+
+	return FrontDoorOrigin{
+		Type:   originType,
+		Target: hostname,
+	}
+}
@@ -0,0 +1,165 @@
+package aws
+
+import (
+	"asset-relations/support/parallel"
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+	"time"
+)
+
+var ec2SnapshotAttributesMaxGoroutines = 20
+
+const ownedBySelf = "self"
+
+type Ec2VolumesFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2VolumesFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2VolumesFetcher {
+	return Ec2VolumesFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2VolumesFetcher) Fetch(ctx context.Context) ([]EbsVolume, error) {
+	e.logger.Info("Fetching EBS volumes")
+
+	params := ec2.DescribeVolumesInput{MaxResults: &ec2MaxResultsPerPage}
+	volumes := make([]EbsVolume, 0, ec2MaxResultsPerPage*10)
+
+	for {
+		res, err := e.client.DescribeVolumes(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, volume := range res.Volumes {
+			volumes = append(volumes, convertVolume(volume))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d EBS volumes", len(volumes)))
+
+	return volumes, nil
+}
+
+func convertVolume(volume ec2types.Volume) EbsVolume {
+	instanceIds := make([]string, 0, len(volume.Attachments))
+	for _, attachment := range volume.Attachments {
+		if !ptr.IsEmpty(attachment.InstanceId) {
+			instanceIds = append(instanceIds, *attachment.InstanceId)
+		}
+	}
+
+	return EbsVolume{
+		Id:          ptr.Deref(volume.VolumeId),
+		Encrypted:   ptr.Deref(volume.Encrypted),
+		KmsKeyId:    volume.KmsKeyId,
+		Size:        ptr.Deref(volume.Size),
+		State:       string(volume.State),
+		SnapshotId:  volume.SnapshotId,
+		InstanceIds: instanceIds,
+	}
+}
+
+type Ec2SnapshotsFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2SnapshotsFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2SnapshotsFetcher {
+	return Ec2SnapshotsFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+// Fetch only returns snapshots owned by the account, public snapshots of others are many
+func (e *Ec2SnapshotsFetcher) Fetch(ctx context.Context) ([]EbsSnapshot, error) {
+	e.logger.Info("Fetching EBS snapshots")
+
+	params := ec2.DescribeSnapshotsInput{
+		OwnerIds:   []string{ownedBySelf},
+		MaxResults: &ec2MaxResultsPerPage,
+	}
+	snapshots := make([]EbsSnapshot, 0, ec2MaxResultsPerPage*10)
+
+	for {
+		res, err := e.client.DescribeSnapshots(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, snapshot := range res.Snapshots {
+			snapshots = append(snapshots, convertSnapshot(snapshot))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d EBS snapshots", len(snapshots)))
+
+	return parallel.Map(ctx, snapshots, e.enrichCreateVolumePermissions, ec2SnapshotAttributesMaxGoroutines)
+}
+
+func (e *Ec2SnapshotsFetcher) enrichCreateVolumePermissions(ctx context.Context, snapshot EbsSnapshot) (EbsSnapshot, error) {
+	res, err := e.client.DescribeSnapshotAttribute(ctx, &ec2.DescribeSnapshotAttributeInput{
+		SnapshotId: ptr.Ref(snapshot.Id),
+		Attribute:  ec2types.SnapshotAttributeNameCreateVolumePermission,
+	})
+	if err != nil {
+		return snapshot, err
+	}
+
+	snapshot.CreateVolumePermissions = convertCreateVolumePermissions(res.CreateVolumePermissions)
+
+	return snapshot, nil
+}
+
+func convertCreateVolumePermissions(permissions []ec2types.CreateVolumePermission) []SnapshotPermission {
+	converted := make([]SnapshotPermission, 0, len(permissions))
+	for _, permission := range permissions {
+		snapshotPermission := SnapshotPermission{UserId: permission.UserId}
+		if permission.Group != "" {
+			snapshotPermission.Group = ptr.Ref(string(permission.Group))
+		}
+
+		converted = append(converted, snapshotPermission)
+	}
+
+	return converted
+}
+
+func convertSnapshot(snapshot ec2types.Snapshot) EbsSnapshot {
+	var startTime *string
+	if snapshot.StartTime != nil {
+		startTime = ptr.Ref(snapshot.StartTime.Format(time.RFC3339))
+	}
+
+	return EbsSnapshot{
+		Id:          ptr.Deref(snapshot.SnapshotId),
+		VolumeId:    snapshot.VolumeId,
+		Description: ptr.Deref(snapshot.Description),
+		Encrypted:   ptr.Deref(snapshot.Encrypted),
+		KmsKeyId:    snapshot.KmsKeyId,
+		Size:        ptr.Deref(snapshot.VolumeSize),
+		StartTime:   startTime,
+	}
+}
@@ -1,7 +1,6 @@
 package aws
 
 import (
-	"asset-relations/support/parallel"
 	"asset-relations/support/ptr"
 	"context"
 	"fmt"
@@ -12,7 +11,6 @@ import (
 )
 
 var ec2MaxResultsPerPage = int32(100)
-var ec2SecurityGroupRulesMaxGoroutines = 50
 
 type Ec2InstancesFetcher struct {
 	client *ec2.Client
@@ -26,22 +24,9 @@ func NewEc2InstanceFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2Instanc
 	}
 }
 
+// Fetch returns the instances without their security group rules, the relation builder takes them
+// from the security groups of the region
 func (e *Ec2InstancesFetcher) Fetch(ctx context.Context) ([]Ec2Instance, error) {
-	instances, err := e.fetchInstances(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	instances, err = parallel.Map(ctx, instances, e.enrichSecurityGroup, ec2SecurityGroupRulesMaxGoroutines)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return instances, nil
-}
-
-func (e *Ec2InstancesFetcher) fetchInstances(ctx context.Context) ([]Ec2Instance, error) {
 	e.logger.Info("Fetching EC2 instances")
 
 	params := ec2.DescribeInstancesInput{MaxResults: &ec2MaxResultsPerPage}
@@ -88,48 +73,45 @@ func convertInstance(instance ec2types.Instance) Ec2Instance {
 	}
 
 	return Ec2Instance{
-		Id:               ptr.Deref(instance.InstanceId),
-		PrivateIP:        ptr.Deref(instance.PrivateIpAddress),
-		PrivateDNS:       ptr.Deref(instance.PrivateDnsName),
-		PublicIP:         instance.PublicIpAddress,
-		PublicDNS:        instance.PublicDnsName,
-		VPC:              ptr.Deref(instance.VpcId),
-		SSHKeyPairName:   instance.KeyName,
-		SecurityGroupIds: secGroupIds,
+		Id:                 ptr.Deref(instance.InstanceId),
+		PrivateIP:          ptr.Deref(instance.PrivateIpAddress),
+		PrivateDNS:         ptr.Deref(instance.PrivateDnsName),
+		PublicIP:           instance.PublicIpAddress,
+		PublicDNS:          instance.PublicDnsName,
+		VPC:                ptr.Deref(instance.VpcId),
+		SubnetId:           ptr.Deref(instance.SubnetId),
+		SSHKeyPairName:     instance.KeyName,
+		ImageId:            ptr.Deref(instance.ImageId),
+		IamInstanceProfile: iamInstanceProfileArn(instance.IamInstanceProfile),
+		MetadataHttpTokens: metadataHttpTokens(instance.MetadataOptions),
+		SecurityGroupIds:   secGroupIds,
+		Tags:               convertTags(instance.Tags),
 	}
 }
 
-func (e *Ec2InstancesFetcher) enrichSecurityGroup(ctx context.Context, instance Ec2Instance) (Ec2Instance, error) {
-	e.logger.Info("Fetching Security Group Rules for EC2 instance "+instance.Id, slog.Any("rule-ids", instance.SecurityGroupIds))
-
-	ingressRules := make([]Ec2SecGroupRule, 0, len(instance.SecurityGroupIds)*5)
-	egressRules := make([]Ec2SecGroupRule, 0, len(instance.SecurityGroupIds)*5)
-
-	params := ec2.DescribeSecurityGroupsInput{
-		GroupIds: instance.SecurityGroupIds,
+func iamInstanceProfileArn(profile *ec2types.IamInstanceProfile) *string {
+	if profile == nil {
+		return nil
 	}
 
-	for {
-		res, err := e.client.DescribeSecurityGroups(ctx, &params)
-		if err != nil {
-			return instance, err
-		}
+	return profile.Arn
+}
 
-		ingress, egress := extractSecGroup(res)
-		ingressRules = append(ingressRules, ingress...)
-		egressRules = append(egressRules, egress...)
+func metadataHttpTokens(options *ec2types.InstanceMetadataOptionsResponse) string {
+	if options == nil {
+		return ""
+	}
 
-		if ptr.IsEmpty(res.NextToken) {
-			break
-		}
+	return string(options.HttpTokens)
+}
 
-		params.NextToken = res.NextToken
+func convertTags(tags []ec2types.Tag) map[string]string {
+	converted := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		converted[ptr.Deref(tag.Key)] = ptr.Deref(tag.Value)
 	}
 
-	instance.IngressSecRules = ingressRules
-	instance.EgressSecRules = egressRules
-
-	return instance, nil
+	return converted
 }
 
 func extractSecGroup(res *ec2.DescribeSecurityGroupsOutput) ([]Ec2SecGroupRule, []Ec2SecGroupRule) {
@@ -140,14 +122,9 @@ func extractSecGroup(res *ec2.DescribeSecurityGroupsOutput) ([]Ec2SecGroupRule,
 	ingress := make([]Ec2SecGroupRule, 0, ec2MaxResultsPerPage)
 	egress := make([]Ec2SecGroupRule, 0, ec2MaxResultsPerPage)
 	for _, group := range res.SecurityGroups {
-		for _, ipPermission := range append(group.IpPermissions) {
-			ingress = append(ingress, convertSecurityGroup(ipPermission))
-		}
-
-		for _, ipPermission := range append(group.IpPermissionsEgress) {
-			egress = append(egress, convertSecurityGroup(ipPermission))
-		}
-
+		converted := convertGroup(group)
+		ingress = append(ingress, converted.IngressRules...)
+		egress = append(egress, converted.EgressRules...)
 	}
 
 	return ingress, egress
@@ -155,13 +132,24 @@ func extractSecGroup(res *ec2.DescribeSecurityGroupsOutput) ([]Ec2SecGroupRule,
 
 func convertSecurityGroup(ipPermission ec2types.IpPermission) Ec2SecGroupRule {
 	return Ec2SecGroupRule{
-		FromPort:   ptr.Deref(ipPermission.FromPort),
-		ToPort:     ptr.Deref(ipPermission.ToPort),
-		IpProtocol: ptr.Deref(ipPermission.IpProtocol),
-		IpRanges:   extractIpRanges(ipPermission.IpRanges),
+		FromPort:       ptr.Deref(ipPermission.FromPort),
+		ToPort:         ptr.Deref(ipPermission.ToPort),
+		IpProtocol:     ptr.Deref(ipPermission.IpProtocol),
+		IpRanges:       append(extractIpRanges(ipPermission.IpRanges), extractIpv6Ranges(ipPermission.Ipv6Ranges)...),
+		SourceGroupIds: extractGroupIds(ipPermission.UserIdGroupPairs),
+		PrefixListIds:  extractPrefixListIds(ipPermission.PrefixListIds),
 	}
 }
 
+func extractGroupIds(pairs []ec2types.UserIdGroupPair) []string {
+	groupIds := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		groupIds = append(groupIds, ptr.Deref(pair.GroupId))
+	}
+
+	return groupIds
+}
+
 func extractIpRanges(ranges []ec2types.IpRange) []string {
 	cidrs := make([]string, 0, len(ranges))
 	for _, ipRange := range ranges {
@@ -170,3 +158,21 @@ func extractIpRanges(ranges []ec2types.IpRange) []string {
 
 	return cidrs
 }
+
+func extractIpv6Ranges(ranges []ec2types.Ipv6Range) []string {
+	cidrs := make([]string, 0, len(ranges))
+	for _, ipRange := range ranges {
+		cidrs = append(cidrs, ptr.Deref(ipRange.CidrIpv6))
+	}
+
+	return cidrs
+}
+
+func extractPrefixListIds(prefixLists []ec2types.PrefixListId) []string {
+	ids := make([]string, 0, len(prefixLists))
+	for _, prefixList := range prefixLists {
+		ids = append(ids, ptr.Deref(prefixList.PrefixListId))
+	}
+
+	return ids
+}
@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+)
+
+type Ec2AddressesFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2AddressesFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2AddressesFetcher {
+	return Ec2AddressesFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2AddressesFetcher) Fetch(ctx context.Context) ([]ElasticIp, error) {
+	e.logger.Info("Fetching Elastic IPs")
+
+	// DescribeAddresses is not paginated
+	res, err := e.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]ElasticIp, 0, len(res.Addresses))
+	for _, address := range res.Addresses {
+		addresses = append(addresses, convertAddress(address))
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d Elastic IPs", len(addresses)))
+
+	return addresses, nil
+}
+
+func convertAddress(address ec2types.Address) ElasticIp {
+	return ElasticIp{
+		AllocationId:       ptr.Deref(address.AllocationId),
+		PublicIP:           ptr.Deref(address.PublicIp),
+		PrivateIP:          address.PrivateIpAddress,
+		AssociationId:      address.AssociationId,
+		InstanceId:         address.InstanceId,
+		NetworkInterfaceId: address.NetworkInterfaceId,
+	}
+}
+
+type Ec2NetworkInterfacesFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2NetworkInterfacesFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2NetworkInterfacesFetcher {
+	return Ec2NetworkInterfacesFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2NetworkInterfacesFetcher) Fetch(ctx context.Context) ([]NetworkInterface, error) {
+	e.logger.Info("Fetching network interfaces")
+
+	params := ec2.DescribeNetworkInterfacesInput{MaxResults: &ec2MaxResultsPerPage}
+	interfaces := make([]NetworkInterface, 0, ec2MaxResultsPerPage*10)
+
+	for {
+		res, err := e.client.DescribeNetworkInterfaces(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, eni := range res.NetworkInterfaces {
+			interfaces = append(interfaces, convertNetworkInterface(eni))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d network interfaces", len(interfaces)))
+
+	return interfaces, nil
+}
+
+func convertNetworkInterface(eni ec2types.NetworkInterface) NetworkInterface {
+	secGroupIds := make([]string, 0, len(eni.Groups))
+	for _, group := range eni.Groups {
+		secGroupIds = append(secGroupIds, ptr.Deref(group.GroupId))
+	}
+
+	addresses := make([]NetworkInterfaceAddress, 0, len(eni.PrivateIpAddresses))
+	for _, addr := range eni.PrivateIpAddresses {
+		var publicIP *string
+		if addr.Association != nil {
+			publicIP = addr.Association.PublicIp
+		}
+
+		addresses = append(addresses, NetworkInterfaceAddress{
+			PrivateIP: ptr.Deref(addr.PrivateIpAddress),
+			PublicIP:  publicIP,
+		})
+	}
+
+	var instanceId *string
+	if eni.Attachment != nil {
+		instanceId = eni.Attachment.InstanceId
+	}
+
+	return NetworkInterface{
+		Id:               ptr.Deref(eni.NetworkInterfaceId),
+		Type:             string(eni.InterfaceType),
+		Description:      ptr.Deref(eni.Description),
+		VPC:              ptr.Deref(eni.VpcId),
+		SubnetId:         ptr.Deref(eni.SubnetId),
+		InstanceId:       instanceId,
+		SecurityGroupIds: secGroupIds,
+		Addresses:        addresses,
+	}
+}
+
+type Ec2NatGatewaysFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2NatGatewaysFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2NatGatewaysFetcher {
+	return Ec2NatGatewaysFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2NatGatewaysFetcher) Fetch(ctx context.Context) ([]NatGateway, error) {
+	e.logger.Info("Fetching NAT gateways")
+
+	params := ec2.DescribeNatGatewaysInput{MaxResults: &ec2MaxResultsPerPage}
+	gateways := make([]NatGateway, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeNatGateways(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, nat := range res.NatGateways {
+			if nat.State == ec2types.NatGatewayStateDeleted {
+				continue
+			}
+
+			gateways = append(gateways, convertNatGateway(nat))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d NAT gateways", len(gateways)))
+
+	return gateways, nil
+}
+
+func convertNatGateway(nat ec2types.NatGateway) NatGateway {
+	addresses := make([]NatGatewayAddress, 0, len(nat.NatGatewayAddresses))
+	for _, addr := range nat.NatGatewayAddresses {
+		addresses = append(addresses, NatGatewayAddress{
+			NetworkInterfaceId: ptr.Deref(addr.NetworkInterfaceId),
+			AllocationId:       addr.AllocationId,
+			PrivateIP:          addr.PrivateIp,
+			PublicIP:           addr.PublicIp,
+		})
+	}
+
+	return NatGateway{
+		Id:        ptr.Deref(nat.NatGatewayId),
+		VPC:       ptr.Deref(nat.VpcId),
+		SubnetId:  ptr.Deref(nat.SubnetId),
+		Addresses: addresses,
+	}
+}
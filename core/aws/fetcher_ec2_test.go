@@ -0,0 +1,35 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"slices"
+	"testing"
+)
+
+func TestExtractSecGroup(t *testing.T) {
+	res := &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []ec2types.SecurityGroup{{
+		GroupId: ptr.Ref("sg-1"),
+		IpPermissions: []ec2types.IpPermission{{
+			FromPort:   ptr.Ref(int32(22)),
+			ToPort:     ptr.Ref(int32(22)),
+			IpProtocol: ptr.Ref("tcp"),
+			IpRanges:   []ec2types.IpRange{{CidrIp: ptr.Ref("10.0.0.0/8")}},
+			Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: ptr.Ref(anyIPv6)}},
+		}},
+		IpPermissionsEgress: []ec2types.IpPermission{{
+			IpProtocol: ptr.Ref(allProtocols),
+			Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: ptr.Ref(anyIPv6)}},
+		}},
+	}}}
+
+	ingress, egress := extractSecGroup(res)
+	if len(ingress) != 1 || !slices.Equal(ingress[0].IpRanges, []string{"10.0.0.0/8", anyIPv6}) || ingress[0].GroupId != "sg-1" {
+		t.Errorf("expected IPv4 and IPv6 ranges of the ingress rule, got %+v", ingress)
+	}
+
+	if len(egress) != 1 || !slices.Equal(egress[0].IpRanges, []string{anyIPv6}) {
+		t.Errorf("expected the IPv6 range of the egress rule, got %+v", egress)
+	}
+}
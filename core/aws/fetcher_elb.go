@@ -0,0 +1,176 @@
+package aws
+
+import (
+	"asset-relations/support/parallel"
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	elb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/wafv2"
+	"log/slog"
+)
+
+var elbMaxResultsPerPage = int32(400)
+var elbTargetHealthMaxGoroutines = 20
+
+type LoadBalancersFetcher struct {
+	client    *elb.Client
+	wafClient *wafv2.Client
+	logger    *slog.Logger
+}
+
+func NewLoadBalancersFetcher(awsCfg awssdk.Config, logger *slog.Logger) LoadBalancersFetcher {
+	return LoadBalancersFetcher{
+		client:    elb.NewFromConfig(awsCfg),
+		wafClient: wafv2.NewFromConfig(awsCfg),
+		logger:    logger,
+	}
+}
+
+func (l *LoadBalancersFetcher) Fetch(ctx context.Context) ([]LoadBalancer, error) {
+	l.logger.Info("Fetching load balancers")
+
+	params := elb.DescribeLoadBalancersInput{PageSize: &elbMaxResultsPerPage}
+	balancers := make([]LoadBalancer, 0, elbMaxResultsPerPage)
+
+	for {
+		res, err := l.client.DescribeLoadBalancers(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, lb := range res.LoadBalancers {
+			balancers = append(balancers, convertLoadBalancer(lb))
+		}
+
+		if ptr.IsEmpty(res.NextMarker) {
+			break
+		}
+
+		params.Marker = res.NextMarker
+	}
+
+	l.logger.Info(fmt.Sprintf("Fetched %d load balancers", len(balancers)))
+
+	targets, err := l.fetchTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, lb := range balancers {
+		balancers[idx].Targets = targets[lb.Arn]
+	}
+
+	// Without WAF permissions load balancers are taken as unprotected, which only overstates
+	// the exposure of the instances behind them
+	withWaf, err := parallel.Map(ctx, balancers, l.enrichWebACL, elbTargetHealthMaxGoroutines)
+	if err != nil {
+		l.logger.Warn("Couldn't fetch WAF web ACLs of load balancers, going on without them: " + err.Error())
+		return balancers, nil
+	}
+
+	return withWaf, nil
+}
+
+// enrichWebACL fetches the WAF web ACL associated with application load balancers
+func (l *LoadBalancersFetcher) enrichWebACL(ctx context.Context, lb LoadBalancer) (LoadBalancer, error) {
+	if lb.Type != loadBalancerApplication {
+		return lb, nil
+	}
+
+	res, err := l.wafClient.GetWebACLForResource(ctx, &wafv2.GetWebACLForResourceInput{ResourceArn: ptr.Ref(lb.Arn)})
+	if err != nil {
+		return lb, err
+	}
+
+	if res.WebACL != nil {
+		lb.WebACLArn = res.WebACL.ARN
+	}
+
+	return lb, nil
+}
+
+// fetchTargets returns the registered targets of all target groups, grouped by load balancer
+func (l *LoadBalancersFetcher) fetchTargets(ctx context.Context) (map[string][]LoadBalancerTarget, error) {
+	l.logger.Info("Fetching load balancers target groups")
+
+	params := elb.DescribeTargetGroupsInput{PageSize: &elbMaxResultsPerPage}
+	groups := make([]elbtypes.TargetGroup, 0, elbMaxResultsPerPage)
+
+	for {
+		res, err := l.client.DescribeTargetGroups(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, res.TargetGroups...)
+
+		if ptr.IsEmpty(res.NextMarker) {
+			break
+		}
+
+		params.Marker = res.NextMarker
+	}
+
+	groupTargets, err := parallel.Map(ctx, groups, l.fetchGroupTargets, elbTargetHealthMaxGoroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string][]LoadBalancerTarget, len(groups))
+	for idx, group := range groups {
+		for _, lbArn := range group.LoadBalancerArns {
+			targets[lbArn] = append(targets[lbArn], groupTargets[idx]...)
+		}
+	}
+
+	return targets, nil
+}
+
+func (l *LoadBalancersFetcher) fetchGroupTargets(ctx context.Context, group elbtypes.TargetGroup) ([]LoadBalancerTarget, error) {
+	res, err := l.client.DescribeTargetHealth(ctx, &elb.DescribeTargetHealthInput{TargetGroupArn: group.TargetGroupArn})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]LoadBalancerTarget, 0, len(res.TargetHealthDescriptions))
+	for _, description := range res.TargetHealthDescriptions {
+		if description.Target == nil {
+			continue
+		}
+
+		targets = append(targets, LoadBalancerTarget{
+			Id:   ptr.Deref(description.Target.Id),
+			Type: string(group.TargetType),
+			Port: ptr.Deref(description.Target.Port),
+		})
+	}
+
+	return targets, nil
+}
+
+func convertLoadBalancer(lb elbtypes.LoadBalancer) LoadBalancer {
+	addresses := make([]LoadBalancerAddress, 0, len(lb.AvailabilityZones))
+	for _, az := range lb.AvailabilityZones {
+		for _, addr := range az.LoadBalancerAddresses {
+			addresses = append(addresses, LoadBalancerAddress{
+				AllocationId: addr.AllocationId,
+				PrivateIP:    addr.PrivateIPv4Address,
+				PublicIP:     addr.IpAddress,
+			})
+		}
+	}
+
+	return LoadBalancer{
+		Arn:              ptr.Deref(lb.LoadBalancerArn),
+		Name:             ptr.Deref(lb.LoadBalancerName),
+		Type:             string(lb.Type),
+		Scheme:           string(lb.Scheme),
+		DNSName:          ptr.Deref(lb.DNSName),
+		VPC:              ptr.Deref(lb.VpcId),
+		SecurityGroupIds: lb.SecurityGroups,
+		Addresses:        addresses,
+	}
+}
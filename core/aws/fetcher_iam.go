@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"asset-relations/support/parallel"
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"log/slog"
+	"strings"
+)
+
+var iamInstanceProfilesMaxGoroutines = 10
+
+// AWS managed policies have well known documents, they are classified by name
+const awsManagedPolicyPrefix = "arn:aws:iam::aws:policy/"
+
+type IamInstanceProfilesFetcher struct {
+	client *iam.Client
+	logger *slog.Logger
+}
+
+func NewIamInstanceProfilesFetcher(awsCfg awssdk.Config, logger *slog.Logger) IamInstanceProfilesFetcher {
+	return IamInstanceProfilesFetcher{
+		client: iam.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+// Fetch gets the roles of the given instance profiles and classifies their privilege from the
+// managed and inline policies attached to them
+func (i *IamInstanceProfilesFetcher) Fetch(ctx context.Context, arns []string) ([]InstanceProfile, error) {
+	i.logger.Info("Fetching IAM instance profiles")
+
+	profiles := make([]InstanceProfile, 0, len(arns))
+	for _, arn := range arns {
+		profiles = append(profiles, InstanceProfile{Arn: arn, Privilege: PrivilegeStandard})
+	}
+
+	profiles, err := parallel.Map(ctx, profiles, i.enrichRoles, iamInstanceProfilesMaxGoroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info(fmt.Sprintf("Fetched %d instance profiles", len(profiles)))
+
+	return profiles, nil
+}
+
+func (i *IamInstanceProfilesFetcher) enrichRoles(ctx context.Context, profile InstanceProfile) (InstanceProfile, error) {
+	res, err := i.client.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: ptr.Ref(instanceProfileName(profile.Arn)),
+	})
+	if err != nil {
+		return profile, err
+	}
+
+	for _, role := range res.InstanceProfile.Roles {
+		roleName := ptr.Deref(role.RoleName)
+		profile.Roles = append(profile.Roles, ptr.Deref(role.Arn))
+
+		if err := i.classifyAttachedPolicies(ctx, roleName, &profile); err != nil {
+			return profile, err
+		}
+
+		if err := i.classifyInlinePolicies(ctx, roleName, &profile); err != nil {
+			return profile, err
+		}
+	}
+
+	return profile, nil
+}
+
+func (i *IamInstanceProfilesFetcher) classifyAttachedPolicies(ctx context.Context, roleName string, profile *InstanceProfile) error {
+	params := iam.ListAttachedRolePoliciesInput{RoleName: &roleName}
+
+	for {
+		res, err := i.client.ListAttachedRolePolicies(ctx, &params)
+		if err != nil {
+			return err
+		}
+
+		for _, policy := range res.AttachedPolicies {
+			arn := ptr.Deref(policy.PolicyArn)
+			profile.Policies = append(profile.Policies, arn)
+
+			privilege := managedPolicyPrivilege(ptr.Deref(policy.PolicyName))
+			if !strings.HasPrefix(arn, awsManagedPolicyPrefix) {
+				if privilege, err = i.customerPolicyPrivilege(ctx, arn); err != nil {
+					return err
+				}
+			}
+
+			profile.Privilege = highestPrivilege(profile.Privilege, privilege)
+		}
+
+		if !res.IsTruncated {
+			return nil
+		}
+
+		params.Marker = res.Marker
+	}
+}
+
+func (i *IamInstanceProfilesFetcher) customerPolicyPrivilege(ctx context.Context, arn string) (string, error) {
+	policy, err := i.client.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: &arn})
+	if err != nil {
+		return "", err
+	}
+
+	version, err := i.client.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: &arn,
+		VersionId: policy.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return policyPrivilege(ptr.Deref(version.PolicyVersion.Document)), nil
+}
+
+func (i *IamInstanceProfilesFetcher) classifyInlinePolicies(ctx context.Context, roleName string, profile *InstanceProfile) error {
+	params := iam.ListRolePoliciesInput{RoleName: &roleName}
+
+	for {
+		res, err := i.client.ListRolePolicies(ctx, &params)
+		if err != nil {
+			return err
+		}
+
+		for _, policyName := range res.PolicyNames {
+			policy, err := i.client.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: &roleName, PolicyName: &policyName})
+			if err != nil {
+				return err
+			}
+
+			profile.Policies = append(profile.Policies, roleName+"/"+policyName)
+			profile.Privilege = highestPrivilege(profile.Privilege, policyPrivilege(ptr.Deref(policy.PolicyDocument)))
+		}
+
+		if !res.IsTruncated {
+			return nil
+		}
+
+		params.Marker = res.Marker
+	}
+}
@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// awsIpRangesUrl publishes the public ranges of every AWS service and region
+var awsIpRangesUrl = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+
+// Public addresses of instances and Elastic IPs are allocated from the EC2 ranges
+const ec2IpRangesService = "EC2"
+
+type awsIpRanges struct {
+	Prefixes []struct {
+		IpPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	Ipv6Prefixes []struct {
+		Ipv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+type AwsIpRangesFetcher struct {
+	client *http.Client
+	region string
+	logger *slog.Logger
+}
+
+func NewAwsIpRangesFetcher(region string, logger *slog.Logger) AwsIpRangesFetcher {
+	return AwsIpRangesFetcher{
+		client: &http.Client{Timeout: 30 * time.Second},
+		region: region,
+		logger: logger,
+	}
+}
+
+// Fetch returns the EC2 ranges of the region, where the public addresses of the account come from
+func (a *AwsIpRangesFetcher) Fetch(ctx context.Context) ([]string, error) {
+	a.logger.Info("Fetching AWS IP ranges")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, awsIpRangesUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d for %s", res.StatusCode, awsIpRangesUrl)
+	}
+
+	var ranges awsIpRanges
+	if err := json.NewDecoder(res.Body).Decode(&ranges); err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]string, 0)
+	for _, prefix := range ranges.Prefixes {
+		if prefix.Service == ec2IpRangesService && prefix.Region == a.region {
+			prefixes = append(prefixes, prefix.IpPrefix)
+		}
+	}
+
+	for _, prefix := range ranges.Ipv6Prefixes {
+		if prefix.Service == ec2IpRangesService && prefix.Region == a.region {
+			prefixes = append(prefixes, prefix.Ipv6Prefix)
+		}
+	}
+
+	a.logger.Info(fmt.Sprintf("Fetched %d AWS IP ranges", len(prefixes)))
+
+	return prefixes, nil
+}
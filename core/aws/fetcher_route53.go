@@ -0,0 +1,122 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"log/slog"
+	"strings"
+)
+
+type Route53RecordsFetcher struct {
+	client *route53.Client
+	logger *slog.Logger
+}
+
+func NewRoute53RecordsFetcher(awsCfg awssdk.Config, logger *slog.Logger) Route53RecordsFetcher {
+	return Route53RecordsFetcher{
+		client: route53.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (r *Route53RecordsFetcher) Fetch(ctx context.Context) ([]DnsRecord, error) {
+	zones, err := r.fetchHostedZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]DnsRecord, 0, len(zones)*20)
+	for _, zone := range zones {
+		zoneRecords, err := r.fetchRecords(ctx, zone)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, zoneRecords...)
+	}
+
+	r.logger.Info(fmt.Sprintf("Fetched %d DNS records", len(records)))
+
+	return records, nil
+}
+
+func (r *Route53RecordsFetcher) fetchHostedZones(ctx context.Context) ([]route53types.HostedZone, error) {
+	r.logger.Info("Fetching Route 53 hosted zones")
+
+	params := route53.ListHostedZonesInput{}
+	zones := make([]route53types.HostedZone, 0, 10)
+
+	for {
+		res, err := r.client.ListHostedZones(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		zones = append(zones, res.HostedZones...)
+
+		if !res.IsTruncated {
+			break
+		}
+
+		params.Marker = res.NextMarker
+	}
+
+	return zones, nil
+}
+
+func (r *Route53RecordsFetcher) fetchRecords(ctx context.Context, zone route53types.HostedZone) ([]DnsRecord, error) {
+	r.logger.Info("Fetching DNS records of hosted zone " + ptr.Deref(zone.Id))
+
+	params := route53.ListResourceRecordSetsInput{HostedZoneId: zone.Id}
+	records := make([]DnsRecord, 0, ptr.Deref(zone.ResourceRecordSetCount))
+
+	for {
+		res, err := r.client.ListResourceRecordSets(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, recordSet := range res.ResourceRecordSets {
+			records = append(records, convertRecordSet(zone, recordSet))
+		}
+
+		if !res.IsTruncated {
+			break
+		}
+
+		params.StartRecordName = res.NextRecordName
+		params.StartRecordType = res.NextRecordType
+		params.StartRecordIdentifier = res.NextRecordIdentifier
+	}
+
+	return records, nil
+}
+
+func convertRecordSet(zone route53types.HostedZone, recordSet route53types.ResourceRecordSet) DnsRecord {
+	values := make([]string, 0, len(recordSet.ResourceRecords))
+	for _, record := range recordSet.ResourceRecords {
+		values = append(values, ptr.Deref(record.Value))
+	}
+
+	var aliasTarget *string
+	if recordSet.AliasTarget != nil {
+		aliasTarget = recordSet.AliasTarget.DNSName
+	}
+
+	privateZone := zone.Config != nil && zone.Config.PrivateZone
+
+	return DnsRecord{
+		ZoneId:        strings.TrimPrefix(ptr.Deref(zone.Id), "/hostedzone/"),
+		ZoneName:      ptr.Deref(zone.Name),
+		PrivateZone:   privateZone,
+		Name:          ptr.Deref(recordSet.Name),
+		Type:          string(recordSet.Type),
+		SetIdentifier: recordSet.SetIdentifier,
+		Values:        values,
+		AliasTarget:   aliasTarget,
+	}
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+)
+
+// Ec2SecurityGroupsFetcher fetches every security group of the region, attached or not
+type Ec2SecurityGroupsFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2SecurityGroupsFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2SecurityGroupsFetcher {
+	return Ec2SecurityGroupsFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2SecurityGroupsFetcher) Fetch(ctx context.Context) ([]SecurityGroup, error) {
+	e.logger.Info("Fetching security groups")
+
+	params := ec2.DescribeSecurityGroupsInput{MaxResults: &ec2MaxResultsPerPage}
+	groups := make([]SecurityGroup, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeSecurityGroups(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, group := range res.SecurityGroups {
+			groups = append(groups, convertGroup(group))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d security groups", len(groups)))
+
+	return groups, nil
+}
+
+func convertGroup(group ec2types.SecurityGroup) SecurityGroup {
+	converted := SecurityGroup{
+		Id:           ptr.Deref(group.GroupId),
+		Name:         ptr.Deref(group.GroupName),
+		Description:  ptr.Deref(group.Description),
+		VPC:          ptr.Deref(group.VpcId),
+		OwnerId:      ptr.Deref(group.OwnerId),
+		IngressRules: make([]Ec2SecGroupRule, 0, len(group.IpPermissions)),
+		EgressRules:  make([]Ec2SecGroupRule, 0, len(group.IpPermissionsEgress)),
+		Tags:         convertTags(group.Tags),
+	}
+
+	for _, ipPermission := range group.IpPermissions {
+		rule := convertSecurityGroup(ipPermission)
+		rule.GroupId = converted.Id
+		converted.IngressRules = append(converted.IngressRules, rule)
+	}
+
+	for _, ipPermission := range group.IpPermissionsEgress {
+		rule := convertSecurityGroup(ipPermission)
+		rule.GroupId = converted.Id
+		converted.EgressRules = append(converted.EgressRules, rule)
+	}
+
+	return converted
+}
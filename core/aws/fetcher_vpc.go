@@ -0,0 +1,295 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"context"
+	"fmt"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"log/slog"
+)
+
+// Ec2VpcNetworkFetcher fetches what decides how traffic flows inside VPCs: VPCs, subnets,
+// route tables, network ACLs and internet gateways
+type Ec2VpcNetworkFetcher struct {
+	client *ec2.Client
+	logger *slog.Logger
+}
+
+func NewEc2VpcNetworkFetcher(awsCfg awssdk.Config, logger *slog.Logger) Ec2VpcNetworkFetcher {
+	return Ec2VpcNetworkFetcher{
+		client: ec2.NewFromConfig(awsCfg),
+		logger: logger,
+	}
+}
+
+func (e *Ec2VpcNetworkFetcher) FetchVpcs(ctx context.Context) ([]Vpc, error) {
+	e.logger.Info("Fetching VPCs")
+
+	params := ec2.DescribeVpcsInput{MaxResults: &ec2MaxResultsPerPage}
+	vpcs := make([]Vpc, 0, 10)
+
+	for {
+		res, err := e.client.DescribeVpcs(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, vpc := range res.Vpcs {
+			vpcs = append(vpcs, convertVpc(vpc))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d VPCs", len(vpcs)))
+
+	return vpcs, nil
+}
+
+func convertVpc(vpc ec2types.Vpc) Vpc {
+	converted := Vpc{
+		Id:      ptr.Deref(vpc.VpcId),
+		Default: ptr.Deref(vpc.IsDefault),
+	}
+
+	for _, association := range vpc.CidrBlockAssociationSet {
+		converted.CidrBlocks = append(converted.CidrBlocks, ptr.Deref(association.CidrBlock))
+	}
+
+	for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+		converted.CidrBlocks = append(converted.CidrBlocks, ptr.Deref(association.Ipv6CidrBlock))
+	}
+
+	if len(converted.CidrBlocks) == 0 && !ptr.IsEmpty(vpc.CidrBlock) {
+		converted.CidrBlocks = []string{*vpc.CidrBlock}
+	}
+
+	return converted
+}
+
+func (e *Ec2VpcNetworkFetcher) FetchSubnets(ctx context.Context) ([]Subnet, error) {
+	e.logger.Info("Fetching subnets")
+
+	params := ec2.DescribeSubnetsInput{MaxResults: &ec2MaxResultsPerPage}
+	subnets := make([]Subnet, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeSubnets(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subnet := range res.Subnets {
+			subnets = append(subnets, convertSubnet(subnet))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d subnets", len(subnets)))
+
+	return subnets, nil
+}
+
+func convertSubnet(subnet ec2types.Subnet) Subnet {
+	return Subnet{
+		Id:                  ptr.Deref(subnet.SubnetId),
+		VPC:                 ptr.Deref(subnet.VpcId),
+		CidrBlock:           ptr.Deref(subnet.CidrBlock),
+		AvailabilityZone:    ptr.Deref(subnet.AvailabilityZone),
+		MapPublicIpOnLaunch: ptr.Deref(subnet.MapPublicIpOnLaunch),
+	}
+}
+
+func (e *Ec2VpcNetworkFetcher) FetchRouteTables(ctx context.Context) ([]RouteTable, error) {
+	e.logger.Info("Fetching route tables")
+
+	params := ec2.DescribeRouteTablesInput{MaxResults: &ec2MaxResultsPerPage}
+	tables := make([]RouteTable, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeRouteTables(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, table := range res.RouteTables {
+			tables = append(tables, convertRouteTable(table))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d route tables", len(tables)))
+
+	return tables, nil
+}
+
+func convertRouteTable(table ec2types.RouteTable) RouteTable {
+	converted := RouteTable{
+		Id:        ptr.Deref(table.RouteTableId),
+		VPC:       ptr.Deref(table.VpcId),
+		SubnetIds: make([]string, 0, len(table.Associations)),
+		Routes:    make([]Route, 0, len(table.Routes)),
+	}
+
+	for _, association := range table.Associations {
+		converted.Main = converted.Main || ptr.Deref(association.Main)
+		if !ptr.IsEmpty(association.SubnetId) {
+			converted.SubnetIds = append(converted.SubnetIds, *association.SubnetId)
+		}
+	}
+
+	for _, route := range table.Routes {
+		destination := ptr.Deref(route.DestinationCidrBlock)
+		if destination == "" {
+			destination = ptr.Deref(route.DestinationIpv6CidrBlock)
+		}
+		if destination == "" {
+			destination = ptr.Deref(route.DestinationPrefixListId)
+		}
+
+		converted.Routes = append(converted.Routes, Route{
+			Destination: destination,
+			Target:      routeTarget(route),
+			State:       string(route.State),
+		})
+	}
+
+	return converted
+}
+
+// routeTarget returns the id of whatever the route sends traffic to, only one is ever set
+func routeTarget(route ec2types.Route) string {
+	targets := []*string{
+		route.GatewayId,
+		route.NatGatewayId,
+		route.TransitGatewayId,
+		route.VpcPeeringConnectionId,
+		route.NetworkInterfaceId,
+		route.InstanceId,
+		route.EgressOnlyInternetGatewayId,
+		route.LocalGatewayId,
+		route.CarrierGatewayId,
+	}
+
+	for _, target := range targets {
+		if !ptr.IsEmpty(target) {
+			return *target
+		}
+	}
+
+	return ""
+}
+
+func (e *Ec2VpcNetworkFetcher) FetchNetworkAcls(ctx context.Context) ([]NetworkAcl, error) {
+	e.logger.Info("Fetching network ACLs")
+
+	params := ec2.DescribeNetworkAclsInput{MaxResults: &ec2MaxResultsPerPage}
+	acls := make([]NetworkAcl, 0, ec2MaxResultsPerPage)
+
+	for {
+		res, err := e.client.DescribeNetworkAcls(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, acl := range res.NetworkAcls {
+			acls = append(acls, convertNetworkAcl(acl))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d network ACLs", len(acls)))
+
+	return acls, nil
+}
+
+func convertNetworkAcl(acl ec2types.NetworkAcl) NetworkAcl {
+	converted := NetworkAcl{
+		Id:        ptr.Deref(acl.NetworkAclId),
+		VPC:       ptr.Deref(acl.VpcId),
+		Default:   ptr.Deref(acl.IsDefault),
+		SubnetIds: make([]string, 0, len(acl.Associations)),
+		Entries:   make([]NetworkAclEntry, 0, len(acl.Entries)),
+	}
+
+	for _, association := range acl.Associations {
+		converted.SubnetIds = append(converted.SubnetIds, ptr.Deref(association.SubnetId))
+	}
+
+	for _, entry := range acl.Entries {
+		cidr := ptr.Deref(entry.CidrBlock)
+		if cidr == "" {
+			cidr = ptr.Deref(entry.Ipv6CidrBlock)
+		}
+
+		converted.Entries = append(converted.Entries, NetworkAclEntry{
+			RuleNumber: ptr.Deref(entry.RuleNumber),
+			Egress:     ptr.Deref(entry.Egress),
+			Protocol:   ptr.Deref(entry.Protocol),
+			FromPort:   ptr.Deref(ptr.Deref(entry.PortRange).From),
+			ToPort:     ptr.Deref(ptr.Deref(entry.PortRange).To),
+			Cidr:       cidr,
+			Allow:      entry.RuleAction == ec2types.RuleActionAllow,
+		})
+	}
+
+	return converted
+}
+
+func (e *Ec2VpcNetworkFetcher) FetchInternetGateways(ctx context.Context) ([]InternetGateway, error) {
+	e.logger.Info("Fetching internet gateways")
+
+	params := ec2.DescribeInternetGatewaysInput{MaxResults: &ec2MaxResultsPerPage}
+	gateways := make([]InternetGateway, 0, 10)
+
+	for {
+		res, err := e.client.DescribeInternetGateways(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, gateway := range res.InternetGateways {
+			gateways = append(gateways, convertInternetGateway(gateway))
+		}
+
+		if ptr.IsEmpty(res.NextToken) {
+			break
+		}
+
+		params.NextToken = res.NextToken
+	}
+
+	e.logger.Info(fmt.Sprintf("Fetched %d internet gateways", len(gateways)))
+
+	return gateways, nil
+}
+
+func convertInternetGateway(gateway ec2types.InternetGateway) InternetGateway {
+	vpcs := make([]string, 0, len(gateway.Attachments))
+	for _, attachment := range gateway.Attachments {
+		vpcs = append(vpcs, ptr.Deref(attachment.VpcId))
+	}
+
+	return InternetGateway{Id: ptr.Deref(gateway.InternetGatewayId), VPCs: vpcs}
+}
@@ -0,0 +1,172 @@
+package aws
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// buildFindings raises the risky services instances expose to the internet or to untrusted
+// public ranges. The reachability engine checks every exposure from the source to the instance:
+// its hops are the evidence, and services it proves unreachable aren't raised. Instances of an
+// Auto Scaling group are replaced all the time, so their findings are raised on the group
+// instead, which keeps the finding identity while instances rotate
+func buildFindings(inv Inventory, exposures []InstanceExposure, serviceExposures []ServiceExposure, catalog ServiceCatalog) []Finding {
+	type groupFinding struct {
+		service  string
+		group    string
+		internet bool
+	}
+
+	groups := instanceGroups(inv)
+	network := newNetworkIndex(inv)
+	groupInstances := make(map[groupFinding][]string, len(inv.AutoScalingGroups))
+	groupRanges := make(map[groupFinding][]string, len(inv.AutoScalingGroups))
+	groupEvidence := make(map[groupFinding]Finding, len(inv.AutoScalingGroups))
+	findings := make([]Finding, 0, len(inv.Instances))
+
+	for _, exposure := range serviceExposures {
+		untrusted := exposure.UntrustedRanges()
+		_, known := catalog.Lookup(exposure.Service)
+		if !known || !exposure.PublicAddress || (!exposure.OpenToInternet && len(untrusted) == 0) {
+			continue
+		}
+
+		evidence, status := network.explainServiceExposure(exposure, untrusted)
+		if status == ReachabilityUnreachable {
+			continue
+		}
+
+		if group, inGroup := groups[exposure.InstanceId]; inGroup {
+			key := groupFinding{service: exposure.Service, group: group, internet: exposure.OpenToInternet}
+			groupInstances[key] = append(groupInstances[key], exposure.InstanceId)
+			groupEvidence[key] = withEvidence(groupEvidence[key], evidence, verdictConfidence(status))
+			for _, ipRange := range untrusted {
+				if !slices.Contains(groupRanges[key], ipRange) {
+					groupRanges[key] = append(groupRanges[key], ipRange)
+				}
+			}
+			continue
+		}
+
+		asset := AssetRef{AssetType: AssetEc2Instance, AssetId: exposure.InstanceId}
+		finding := serviceFinding(catalog, exposure.Service, exposure.OpenToInternet, untrusted, asset, "Instance "+exposure.InstanceId, []string{exposure.InstanceId})
+		finding.Evidence, finding.Confidence = evidence, verdictConfidence(status)
+		findings = append(findings, finding)
+	}
+
+	for key, instanceIds := range groupInstances {
+		slices.Sort(instanceIds)
+		slices.Sort(groupRanges[key])
+
+		asset := AssetRef{AssetType: AssetAutoScalingGroup, AssetId: key.group}
+		finding := serviceFinding(catalog, key.service, key.internet, groupRanges[key], asset, "ASG "+key.group, instanceIds)
+		finding.Evidence, finding.Confidence = groupEvidence[key].Evidence, groupEvidence[key].Confidence
+		findings = append(findings, finding)
+	}
+
+	findings = append(findings, buildAmiFindings(inv)...)
+	findings = append(findings, buildSnapshotFindings(inv, exposures)...)
+
+	slices.SortFunc(findings, func(a, b Finding) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return findings
+}
+
+// serviceFinding raises a service open to the whole internet with the severity of the catalog.
+// A service only open to untrusted ranges is one level less severe, and lists the ranges
+func serviceFinding(catalog ServiceCatalog, name string, internet bool, untrusted []string, asset AssetRef, subject string, instances []string) Finding {
+	service, _ := catalog.Lookup(name)
+	if internet {
+		title := fmt.Sprintf("%s exposes %s to the internet", subject, service.Name)
+		return newFinding(service.FindingType(), service.Severity, asset, title, instances)
+	}
+
+	title := fmt.Sprintf("%s exposes %s to untrusted ranges %s", subject, service.Name, strings.Join(untrusted, ", "))
+	return newFinding(service.UntrustedFindingType(), lowerSeverity(service.Severity), asset, title, instances)
+}
+
+// withEvidence keeps the evidence of the first instance of a group, and the lowest confidence
+// of all of them
+func withEvidence(group Finding, evidence []ReachabilityHop, confidence string) Finding {
+	if group.Evidence == nil {
+		return Finding{Evidence: evidence, Confidence: confidence}
+	}
+
+	group.Confidence = lowestConfidence(group.Confidence, confidence)
+	return group
+}
+
+func lowerSeverity(severity string) string {
+	rank := SeverityRank(severity)
+	if rank >= len(severities)-1 {
+		return severity
+	}
+
+	return severities[rank+1]
+}
+
+// buildAmiFindings raises our own images that anyone can launch, listing the instances
+// running them
+func buildAmiFindings(inv Inventory) []Finding {
+	instancesByImage := make(map[string][]string, len(inv.Amis))
+	for _, inst := range inv.Instances {
+		instancesByImage[inst.ImageId] = append(instancesByImage[inst.ImageId], inst.Id)
+	}
+
+	findings := make([]Finding, 0, len(inv.Amis))
+	for _, ami := range inv.Amis {
+		if !ami.IsSharedPublicly() {
+			continue
+		}
+
+		asset := AssetRef{AssetType: AssetAmi, AssetId: ami.Id}
+		title := fmt.Sprintf("AMI %s is shared publicly", ami.Id)
+		findings = append(findings, newFinding(FindingAmiSharedPublicly, SeverityHigh, asset, title, instancesByImage[ami.Id]))
+	}
+
+	return findings
+}
+
+// buildSnapshotFindings raises snapshots anyone or other accounts can restore, when they hold
+// the disk of an instance reachable from the internet
+func buildSnapshotFindings(inv Inventory, exposures []InstanceExposure) []Finding {
+	exposed := make(map[string]InstanceExposure, len(exposures))
+	for _, exposure := range exposures {
+		if exposure.Status != ExposurePrivate {
+			exposed[exposure.InstanceId] = exposure
+		}
+	}
+
+	sources := snapshotSources(inv)
+	findings := make([]Finding, 0, len(inv.EbsSnapshots))
+
+	for _, snapshot := range inv.EbsSnapshots {
+		instanceId, hasSource := sources[snapshot.Id]
+		exposure, isExposed := exposed[instanceId]
+		if !hasSource || !isExposed {
+			continue
+		}
+
+		asset := AssetRef{AssetType: AssetEbsSnapshot, AssetId: snapshot.Id}
+		var finding Finding
+		switch {
+		case snapshot.IsPublic():
+			title := fmt.Sprintf("Snapshot %s of internet exposed instance %s is public", snapshot.Id, instanceId)
+			finding = newFinding(FindingSnapshotPublic, SeverityCritical, asset, title, []string{instanceId})
+		case len(snapshot.SharedWithAccounts()) > 0:
+			title := fmt.Sprintf("Snapshot %s of internet exposed instance %s is shared with other accounts", snapshot.Id, instanceId)
+			finding = newFinding(FindingSnapshotSharedCrossAccount, SeverityMedium, asset, title, []string{instanceId})
+		default:
+			continue
+		}
+
+		// The snapshot is a concern because of how exposed its instance is
+		finding.Evidence, finding.Confidence = exposure.Evidence, exposure.Confidence
+		findings = append(findings, finding)
+	}
+
+	return findings
+}
@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"slices"
+	"testing"
+)
+
+func TestBuildAmiFindings(t *testing.T) {
+	const account = "123456789012"
+	catalog, _ := NewServiceCatalog(nil)
+
+	cases := []struct {
+		name     string
+		ownerId  string
+		alias    *string
+		ami      Ami
+		expected bool
+	}{
+		{name: "own public", ownerId: account, ami: Ami{Public: true}, expected: true},
+		{name: "own launchable by all", ownerId: account, ami: Ami{LaunchPermissions: []AmiLaunchPermission{{Group: ptr.Ref(amiLaunchPermissionAll)}}}, expected: true},
+		{name: "own cross-account", ownerId: account, ami: Ami{LaunchPermissions: []AmiLaunchPermission{{UserId: ptr.Ref("210987654321")}}}},
+		{name: "own private", ownerId: account},
+		{name: "amazon public", ownerId: "137112412989", alias: ptr.Ref(AmiAmazon), ami: Ami{Public: true}},
+		{name: "marketplace public", ownerId: "679593333241", alias: ptr.Ref(AmiMarketplace), ami: Ami{Public: true}},
+		{name: "third-party public", ownerId: "210987654321", ami: Ami{Public: true}},
+	}
+
+	for _, c := range cases {
+		ami := c.ami
+		ami.Id, ami.OwnerId, ami.OwnerAlias = "ami-1", c.ownerId, c.alias
+		ami.Provenance = amiProvenance(account, c.ownerId, c.alias)
+
+		inv := Inventory{
+			AccountId: account,
+			Instances: []Ec2Instance{{Id: "i-1", ImageId: "ami-1"}, {Id: "i-2", ImageId: "ami-2"}},
+			Amis:      []Ami{ami},
+		}
+
+		findings := buildFindings(inv, nil, nil, catalog)
+		if !c.expected {
+			if len(findings) != 0 {
+				t.Errorf("%s: expected no finding, got %+v", c.name, findings)
+			}
+			continue
+		}
+
+		if len(findings) != 1 || findings[0].Type != FindingAmiSharedPublicly || findings[0].Severity != SeverityHigh ||
+			findings[0].Asset.AssetId != "ami-1" || !slices.Equal(findings[0].Instances, []string{"i-1"}) {
+			t.Errorf("%s: expected ami-1 shared publicly and run by i-1, got %+v", c.name, findings)
+		}
+	}
+}
+
+func TestBuildSnapshotFindings(t *testing.T) {
+	catalog, _ := NewServiceCatalog(nil)
+	public := []SnapshotPermission{{Group: ptr.Ref(snapshotPermissionAll)}}
+	crossAccount := []SnapshotPermission{{UserId: ptr.Ref("210987654321")}}
+
+	cases := []struct {
+		name     string
+		snapshot EbsSnapshot
+		status   string
+		expected string
+		severity string
+	}{
+		{name: "public", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-1"), CreateVolumePermissions: public}, status: ExposureOpenToInternet, expected: FindingSnapshotPublic, severity: SeverityCritical},
+		{name: "cross-account", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-1"), CreateVolumePermissions: crossAccount}, status: ExposureFrontDoor, expected: FindingSnapshotSharedCrossAccount, severity: SeverityMedium},
+		{name: "public and cross-account", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-1"), CreateVolumePermissions: append(crossAccount, public...)}, status: ExposureOpenToInternet, expected: FindingSnapshotPublic, severity: SeverityCritical},
+		{name: "image of a detached volume", snapshot: EbsSnapshot{Description: "Created by CreateImage(i-0abc) for ami-1", CreateVolumePermissions: public}, status: ExposureOpenToInternet, expected: FindingSnapshotPublic, severity: SeverityCritical},
+		{name: "private", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-1")}, status: ExposureOpenToInternet},
+		{name: "public of a private instance", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-1"), CreateVolumePermissions: public}, status: ExposurePrivate},
+		{name: "public of an unknown volume", snapshot: EbsSnapshot{VolumeId: ptr.Ref("vol-2"), CreateVolumePermissions: public}, status: ExposureOpenToInternet},
+	}
+
+	for _, c := range cases {
+		snapshot := c.snapshot
+		snapshot.Id = "snap-1"
+
+		inv := Inventory{
+			Instances:    []Ec2Instance{{Id: "i-0abc"}},
+			EbsVolumes:   []EbsVolume{{Id: "vol-1", InstanceIds: []string{"i-0abc"}}},
+			EbsSnapshots: []EbsSnapshot{snapshot},
+		}
+		exposures := []InstanceExposure{{InstanceId: "i-0abc", Status: c.status, Confidence: ConfidenceConfirmed}}
+
+		findings := buildFindings(inv, exposures, nil, catalog)
+		if c.expected == "" {
+			if len(findings) != 0 {
+				t.Errorf("%s: expected no finding, got %+v", c.name, findings)
+			}
+			continue
+		}
+
+		if len(findings) != 1 || findings[0].Type != c.expected || findings[0].Severity != c.severity ||
+			findings[0].Asset.AssetId != "snap-1" || !slices.Equal(findings[0].Instances, []string{"i-0abc"}) {
+			t.Errorf("%s: expected a %s %s finding on snap-1, got %+v", c.name, c.severity, c.expected, findings)
+		}
+	}
+}
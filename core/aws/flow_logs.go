@@ -0,0 +1,407 @@
+package aws
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of flow log records, as named in custom formats
+const (
+	flowFieldInterfaceId = "interface-id"
+	flowFieldSrcAddr     = "srcaddr"
+	flowFieldDstAddr     = "dstaddr"
+	flowFieldPktSrcAddr  = "pkt-srcaddr"
+	flowFieldPktDstAddr  = "pkt-dstaddr"
+	flowFieldSrcPort     = "srcport"
+	flowFieldDstPort     = "dstport"
+	flowFieldProtocol    = "protocol"
+	flowFieldPackets     = "packets"
+	flowFieldBytes       = "bytes"
+	flowFieldStart       = "start"
+	flowFieldEnd         = "end"
+	flowFieldAction      = "action"
+	flowFieldLogStatus   = "log-status"
+	flowFieldTcpFlags    = "tcp-flags"
+)
+
+// defaultFlowLogFields is the default format, version 2
+var defaultFlowLogFields = []string{
+	"version", "account-id", flowFieldInterfaceId, flowFieldSrcAddr, flowFieldDstAddr, flowFieldSrcPort,
+	flowFieldDstPort, flowFieldProtocol, flowFieldPackets, flowFieldBytes, flowFieldStart, flowFieldEnd,
+	flowFieldAction, flowFieldLogStatus,
+}
+
+const (
+	flowMissingValue = "-"
+	flowStatusOk     = "OK"
+	// Addresses outside the inventory are aggregated into networks of these sizes
+	flowCidrBitsV4 = 24
+	flowCidrBitsV6 = 64
+	// TCP flags of flow logs, SYN-ACK tells the record comes from the server
+	tcpFlagSyn    = 0x02
+	tcpFlagSynAck = 0x12
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type flowRecord struct {
+	interfaceId string
+	srcAddr     netip.Addr
+	dstAddr     netip.Addr
+	srcPort     int32
+	dstPort     int32
+	protocol    string
+	packets     int64
+	bytes       int64
+	start       time.Time
+	end         time.Time
+	action      string
+	tcpFlags    *int
+}
+
+// ObserveTraffic aggregates the records of flow log files, plain or gzipped, into the traffic
+// observed between the assets of the inventory and the networks outside of it. Paths may be
+// files or directories. The format, like "${version} ${srcaddr} ...", is only needed for
+// custom formats without a header line, files delivered to S3 have one
+func ObserveTraffic(inv Inventory, paths []string, format string) ([]ObservedTraffic, error) {
+	var fields []string
+	if format != "" {
+		fields = parseFlowLogFormat(format)
+		if err := validateFlowLogFields(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	aggregator := newTrafficAggregator(inv)
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+
+			if err := readFlowLog(file, fields, aggregator.add); err != nil {
+				return fmt.Errorf("invalid flow log %s: %w", file, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return aggregator.traffic(), nil
+}
+
+func parseFlowLogFormat(format string) []string {
+	fields := strings.Fields(format)
+	for idx, field := range fields {
+		fields[idx] = strings.TrimSuffix(strings.TrimPrefix(field, "${"), "}")
+	}
+
+	return fields
+}
+
+func validateFlowLogFields(fields []string) error {
+	for _, required := range []string{flowFieldSrcAddr, flowFieldDstAddr, flowFieldProtocol} {
+		if !slices.Contains(fields, required) {
+			return fmt.Errorf("the format must include %s", required)
+		}
+	}
+
+	return nil
+}
+
+// isFlowLogHeader tells whether the line names the fields instead of holding a record
+func isFlowLogHeader(values []string) bool {
+	return slices.Contains(values, flowFieldSrcAddr) && slices.Contains(values, flowFieldDstAddr)
+}
+
+func readFlowLog(path string, fields []string, add func(flowRecord)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && slices.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		values := strings.Fields(scanner.Text())
+		if len(values) == 0 {
+			continue
+		}
+
+		if isFlowLogHeader(values) {
+			if fields == nil {
+				fields = values
+				if err := validateFlowLogFields(fields); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if fields == nil {
+			fields = defaultFlowLogFields
+		}
+
+		if len(values) != len(fields) {
+			return fmt.Errorf("line %d has %d fields, the format has %d", line, len(values), len(fields))
+		}
+
+		record, valid, err := parseFlowRecord(fields, values)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if valid {
+			add(record)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseFlowRecord reads the values of a record, which isn't valid when nothing was logged.
+// Packet level addresses are preferred, as they are the actual endpoints of traffic going
+// through NAT gateways or load balancers
+func parseFlowRecord(fields, values []string) (flowRecord, bool, error) {
+	value := make(map[string]string, len(fields))
+	for idx, field := range fields {
+		if values[idx] != flowMissingValue {
+			value[field] = values[idx]
+		}
+	}
+
+	if status, found := value[flowFieldLogStatus]; found && status != flowStatusOk {
+		return flowRecord{}, false, nil
+	}
+
+	srcAddr, dstAddr := value[flowFieldSrcAddr], value[flowFieldDstAddr]
+	if pktSrcAddr, found := value[flowFieldPktSrcAddr]; found {
+		srcAddr = pktSrcAddr
+	}
+
+	if pktDstAddr, found := value[flowFieldPktDstAddr]; found {
+		dstAddr = pktDstAddr
+	}
+
+	if srcAddr == "" || dstAddr == "" || value[flowFieldProtocol] == "" {
+		return flowRecord{}, false, nil
+	}
+
+	var err error
+	record := flowRecord{
+		interfaceId: value[flowFieldInterfaceId],
+		protocol:    value[flowFieldProtocol],
+		action:      strings.ToUpper(value[flowFieldAction]),
+	}
+
+	if record.action == "" {
+		record.action = FlowAccept
+	}
+
+	if record.srcAddr, err = netip.ParseAddr(srcAddr); err != nil {
+		return record, false, err
+	}
+
+	if record.dstAddr, err = netip.ParseAddr(dstAddr); err != nil {
+		return record, false, err
+	}
+
+	var srcPort, dstPort, start, end int64
+	for field, to := range map[string]*int64{
+		flowFieldPackets: &record.packets,
+		flowFieldBytes:   &record.bytes,
+		flowFieldSrcPort: &srcPort,
+		flowFieldDstPort: &dstPort,
+		flowFieldStart:   &start,
+		flowFieldEnd:     &end,
+	} {
+		if raw, found := value[field]; found {
+			if *to, err = strconv.ParseInt(raw, 10, 64); err != nil {
+				return record, false, fmt.Errorf("invalid %s: %w", field, err)
+			}
+		}
+	}
+
+	record.srcPort, record.dstPort = int32(srcPort), int32(dstPort)
+	if start > 0 {
+		record.start = time.Unix(start, 0).UTC()
+	}
+
+	if end > 0 {
+		record.end = time.Unix(end, 0).UTC()
+	}
+
+	if raw, found := value[flowFieldTcpFlags]; found {
+		flags, err := strconv.Atoi(raw)
+		if err != nil {
+			return record, false, fmt.Errorf("invalid %s: %w", flowFieldTcpFlags, err)
+		}
+
+		record.tcpFlags = &flags
+	}
+
+	return record, true, nil
+}
+
+// fromServer tells whether the record is the response of a server to its client. The TCP
+// flags tell it when they are logged, otherwise the lower port is taken as the server's
+func (r flowRecord) fromServer() bool {
+	if r.protocol == protocolNumbers[protocolTcp] && r.tcpFlags != nil {
+		if *r.tcpFlags&tcpFlagSynAck == tcpFlagSynAck {
+			return true
+		}
+
+		if *r.tcpFlags&tcpFlagSyn != 0 {
+			return false
+		}
+	}
+
+	return r.srcPort != 0 && r.srcPort < r.dstPort
+}
+
+func (r flowRecord) hasPorts() bool {
+	return r.protocol == protocolNumbers[protocolTcp] || r.protocol == protocolNumbers[protocolUdp]
+}
+
+type trafficKey struct {
+	from     AssetRef
+	to       AssetRef
+	protocol string
+	port     int32
+	action   string
+	day      time.Time
+}
+
+// trafficAggregator adds up records per client, server, port, action and day, apart for every
+// interface that logged them
+type trafficAggregator struct {
+	addresses map[netip.Addr]IpAddress
+	observed  map[trafficKey]map[string]ObservedTraffic
+}
+
+func newTrafficAggregator(inv Inventory) *trafficAggregator {
+	addresses := indexIpAddresses(inv)
+	a := &trafficAggregator{
+		addresses: make(map[netip.Addr]IpAddress, len(addresses)),
+		observed:  make(map[trafficKey]map[string]ObservedTraffic),
+	}
+
+	for _, address := range addresses {
+		if addr, err := netip.ParseAddr(address.Address); err == nil {
+			a.addresses[addr] = address
+		}
+	}
+
+	return a
+}
+
+func (a *trafficAggregator) add(record flowRecord) {
+	client, server, port := record.srcAddr, record.dstAddr, record.dstPort
+	if record.fromServer() {
+		client, server, port = record.dstAddr, record.srcAddr, record.srcPort
+	}
+
+	if !record.hasPorts() {
+		port = 0
+	}
+
+	key := trafficKey{
+		from:     a.endpoint(client),
+		to:       a.endpoint(server),
+		protocol: record.protocol,
+		port:     port,
+		action:   record.action,
+		day:      record.start.Truncate(trafficBucket),
+	}
+
+	traffic := ObservedTraffic{
+		From:      key.from,
+		To:        key.to,
+		Protocol:  key.protocol,
+		Port:      key.port,
+		Action:    key.action,
+		Day:       key.day,
+		Bytes:     record.bytes,
+		Packets:   record.packets,
+		Flows:     1,
+		FirstSeen: record.start,
+		LastSeen:  record.end,
+	}
+
+	byInterface, found := a.observed[key]
+	if !found {
+		byInterface = make(map[string]ObservedTraffic, 1)
+		a.observed[key] = byInterface
+	}
+
+	if known, found := byInterface[record.interfaceId]; found {
+		traffic = known.merge(traffic)
+	}
+
+	byInterface[record.interfaceId] = traffic
+}
+
+// endpoint is the asset owning the address, or the network it falls in outside the inventory
+func (a *trafficAggregator) endpoint(addr netip.Addr) AssetRef {
+	if owner, found := a.addresses[addr]; found {
+		return AssetRef{AssetType: owner.OwnerType, AssetId: owner.OwnerId}
+	}
+
+	bits := flowCidrBitsV4
+	if addr.Is6() {
+		bits = flowCidrBitsV6
+	}
+
+	return AssetRef{AssetType: AssetCidr, AssetId: netip.PrefixFrom(addr, bits).Masked().String()}
+}
+
+func (a *trafficAggregator) traffic() []ObservedTraffic {
+	traffic := make([]ObservedTraffic, 0, len(a.observed))
+	for _, byInterface := range a.observed {
+		interfaceIds := make([]string, 0, len(byInterface))
+		for interfaceId := range byInterface {
+			interfaceIds = append(interfaceIds, interfaceId)
+		}
+
+		slices.Sort(interfaceIds)
+
+		combined := byInterface[interfaceIds[0]]
+		for _, interfaceId := range interfaceIds[1:] {
+			combined = combined.combine(byInterface[interfaceId])
+		}
+
+		traffic = append(traffic, combined)
+	}
+
+	slices.SortFunc(traffic, func(a, b ObservedTraffic) int {
+		return strings.Compare(a.sortKey(), b.sortKey())
+	})
+
+	return traffic
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const defaultFlowLog = `2 123456789012 eni-1 198.51.100.7 10.0.0.10 50000 22 6 10 840 1700000000 1700000060 ACCEPT OK
+2 123456789012 eni-1 10.0.0.10 198.51.100.7 22 50000 6 8 1200 1700000000 1700000060 ACCEPT OK
+2 123456789012 eni-1 198.51.100.9 10.0.0.10 50010 22 6 5 400 1700000600 1700000660 ACCEPT OK
+2 123456789012 eni-1 203.0.113.5 10.0.0.10 40000 3389 6 1 40 1700000000 1700000060 REJECT OK
+2 123456789012 eni-1 - - - - - - - 1700000000 1700000060 - NODATA
+`
+
+const customFlowLog = `version interface-id srcaddr dstaddr srcport dstport protocol packets bytes start end action log-status tcp-flags
+2 eni-1 10.0.0.10 10.0.0.20 40000 443 6 4 400 1700000000 1700000060 ACCEPT OK 2
+2 eni-2 10.0.0.20 10.0.0.10 443 40000 6 4 2000 1700000000 1700000060 ACCEPT OK 18
+2 eni-2 10.0.0.10 10.0.0.20 40000 443 6 4 400 1700000000 1700000060 ACCEPT OK 2
+`
+
+func TestObserveTraffic(t *testing.T) {
+	inv := Inventory{
+		Instances: []Ec2Instance{{Id: "i-1", PrivateIP: "10.0.0.10"}, {Id: "i-2", PrivateIP: "10.0.0.20"}},
+		NetworkInterfaces: []NetworkInterface{
+			{Id: "eni-1", InstanceId: ptr.Ref("i-1"), Addresses: []NetworkInterfaceAddress{{PrivateIP: "10.0.0.10"}}},
+			{Id: "eni-2", InstanceId: ptr.Ref("i-2"), Addresses: []NetworkInterfaceAddress{{PrivateIP: "10.0.0.20"}}},
+		},
+	}
+
+	dir := t.TempDir()
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write([]byte(defaultFlowLog)); err != nil || gzipWriter.Close() != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "default.log.gz"), compressed.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "custom.log"), []byte(customFlowLog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	traffic, err := ObserveTraffic(inv, []string{dir}, "")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(traffic) != 3 {
+		t.Fatalf("expected SSH, RDP and HTTPS traffic, got %+v", traffic)
+	}
+
+	ssh, rdp, https := traffic[0], traffic[1], traffic[2]
+	expectedSsh := ObservedTraffic{
+		From:      AssetRef{AssetType: AssetCidr, AssetId: "198.51.100.0/24"},
+		To:        AssetRef{AssetType: AssetEc2Instance, AssetId: "i-1"},
+		Protocol:  "6",
+		Port:      22,
+		Action:    FlowAccept,
+		Day:       time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC),
+		Bytes:     2440,
+		Packets:   23,
+		Flows:     3,
+		FirstSeen: time.Unix(1700000000, 0).UTC(),
+		LastSeen:  time.Unix(1700000660, 0).UTC(),
+	}
+	if ssh != expectedSsh {
+		t.Errorf("expected both clients and the responses to add up, got %+v", ssh)
+	}
+
+	if rdp.From.AssetId != "203.0.113.0/24" || rdp.Port != 3389 || rdp.Action != FlowReject {
+		t.Errorf("expected rejected RDP, got %+v", rdp)
+	}
+
+	if https.From.AssetId != "i-1" || https.To.AssetId != "i-2" || https.Port != 443 || https.Bytes != 2400 || https.Flows != 2 {
+		t.Errorf("expected HTTPS from i-1 to i-2 counted once, got %+v", https)
+	}
+}
@@ -0,0 +1,69 @@
+package aws
+
+// Asset types, used to reference assets of different kinds, e.g. who owns an ip address
+const (
+	AssetEc2Instance            = "Ec2Instance"
+	AssetNetworkInterface       = "NetworkInterface"
+	AssetNatGateway             = "NatGateway"
+	AssetLoadBalancer           = "LoadBalancer"
+	AssetElasticIp              = "ElasticIp"
+	AssetCloudFrontDistribution = "CloudFrontDistribution"
+	AssetDnsRecord              = "DnsRecord"
+	AssetApiGatewayStage        = "ApiGatewayStage"
+	AssetAutoScalingGroup       = "AutoScalingGroup"
+	AssetLaunchTemplate         = "LaunchTemplate"
+	AssetAmi                    = "Ami"
+	AssetEbsVolume              = "EbsVolume"
+	AssetEbsSnapshot            = "EbsSnapshot"
+	AssetSecurityGroup          = "SecurityGroup"
+	AssetCidr                   = "Cidr"
+	AssetInstanceProfile        = "InstanceProfile"
+)
+
+// Data the analysis can go on without, when fetching it fails
+const (
+	DataVpcs             = "vpcs"
+	DataSubnets          = "subnets"
+	DataRouteTables      = "route-tables"
+	DataNetworkAcls      = "network-acls"
+	DataInternetGateways = "internet-gateways"
+	DataInstanceProfiles = "instance-profiles"
+	DataAwsIpRanges      = "aws-ip-ranges"
+)
+
+// AssetRef points to an asset of any type
+type AssetRef struct {
+	AssetType string
+	AssetId   string
+}
+
+// Inventory holds every asset fetched from a single account and region
+type Inventory struct {
+	AccountId               string
+	Region                  string
+	Instances               []Ec2Instance
+	ElasticIps              []ElasticIp
+	NetworkInterfaces       []NetworkInterface
+	NatGateways             []NatGateway
+	LoadBalancers           []LoadBalancer
+	DnsRecords              []DnsRecord
+	CloudFrontDistributions []CloudFrontDistribution
+	ApiGatewayStages        []ApiGatewayStage
+	AutoScalingGroups       []AutoScalingGroup
+	LaunchTemplates         []LaunchTemplate
+	Amis                    []Ami
+	EbsVolumes              []EbsVolume
+	EbsSnapshots            []EbsSnapshot
+	Vpcs                    []Vpc
+	Subnets                 []Subnet
+	RouteTables             []RouteTable
+	NetworkAcls             []NetworkAcl
+	InternetGateways        []InternetGateway
+	SecurityGroups          []SecurityGroup
+	InstanceProfiles        []InstanceProfile
+	// AwsIpRanges are the EC2 ranges of the region, addresses in them no asset owns were
+	// released by us or belong to other AWS customers
+	AwsIpRanges []string
+	// MissingData lists what couldn't be fetched, analyses go on without it at a lower confidence
+	MissingData []string
+}
@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"slices"
+	"strings"
+)
+
+const elbInterfaceDescriptionPrefix = "ELB "
+
+type ipOwner struct {
+	ownerType string
+	ownerId   string
+	vpc       string
+	exposure  string
+}
+
+// indexIpAddresses maps every known private and public address of the inventory to its owner.
+// Network interfaces are the source of truth, as instances, NAT gateways and load balancers
+// all get their addresses through them. Elastic IPs that are not in use by any interface
+// are still indexed, so we can tell they are allocated but unassociated.
+func indexIpAddresses(inv Inventory) []IpAddress {
+	index := make(map[string]IpAddress, len(inv.NetworkInterfaces)*2)
+	add := func(address string, public bool, eniId *string, owner ipOwner) {
+		if address == "" {
+			return
+		}
+
+		if _, exists := index[address]; exists {
+			return
+		}
+
+		index[address] = IpAddress{
+			Address:            address,
+			Public:             public,
+			OwnerType:          owner.ownerType,
+			OwnerId:            owner.ownerId,
+			NetworkInterfaceId: eniId,
+			Account:            inv.AccountId,
+			Region:             inv.Region,
+			VPC:                owner.vpc,
+			Exposure:           owner.exposure,
+		}
+	}
+
+	owners := newIpOwnerResolver(inv)
+
+	for _, eni := range inv.NetworkInterfaces {
+		owner := owners.resolve(eni)
+		for _, addr := range eni.Addresses {
+			add(addr.PrivateIP, false, ptr.Ref(eni.Id), owner)
+			add(ptr.Deref(addr.PublicIP), true, ptr.Ref(eni.Id), owner)
+		}
+	}
+
+	// Interfaces might be missing from the inventory, so fall back on the assets themselves
+	for _, inst := range inv.Instances {
+		owner := owners.instanceOwner(inst)
+		add(inst.PrivateIP, false, nil, owner)
+		add(ptr.Deref(inst.PublicIP), true, nil, owner)
+	}
+
+	for _, nat := range inv.NatGateways {
+		owner := natOwner(nat)
+		for _, addr := range nat.Addresses {
+			add(ptr.Deref(addr.PrivateIP), false, ptr.Ref(addr.NetworkInterfaceId), owner)
+			add(ptr.Deref(addr.PublicIP), true, ptr.Ref(addr.NetworkInterfaceId), owner)
+		}
+	}
+
+	for _, lb := range inv.LoadBalancers {
+		owner := loadBalancerOwner(lb)
+		for _, addr := range lb.Addresses {
+			add(ptr.Deref(addr.PrivateIP), false, nil, owner)
+			add(ptr.Deref(addr.PublicIP), true, nil, owner)
+		}
+	}
+
+	for _, eip := range inv.ElasticIps {
+		if _, exists := index[eip.PublicIP]; !exists {
+			exposure := ExposurePublic
+			if !eip.IsAssociated() {
+				exposure = ExposureUnassociated
+			}
+
+			add(eip.PublicIP, true, eip.NetworkInterfaceId, ipOwner{
+				ownerType: AssetElasticIp,
+				ownerId:   eip.AllocationId,
+				exposure:  exposure,
+			})
+		}
+
+		indexed := index[eip.PublicIP]
+		indexed.AllocationId = ptr.Ref(eip.AllocationId)
+		index[eip.PublicIP] = indexed
+	}
+
+	addresses := make([]IpAddress, 0, len(index))
+	for _, addr := range index {
+		addresses = append(addresses, addr)
+	}
+
+	slices.SortFunc(addresses, func(a, b IpAddress) int {
+		return strings.Compare(a.Address, b.Address)
+	})
+
+	return addresses
+}
+
+type ipOwnerResolver struct {
+	instances     map[string]Ec2Instance
+	natGateways   map[string]NatGateway
+	loadBalancers map[string]LoadBalancer
+}
+
+func newIpOwnerResolver(inv Inventory) ipOwnerResolver {
+	r := ipOwnerResolver{
+		instances:     make(map[string]Ec2Instance, len(inv.Instances)),
+		natGateways:   make(map[string]NatGateway, len(inv.NatGateways)),
+		loadBalancers: make(map[string]LoadBalancer, len(inv.LoadBalancers)),
+	}
+
+	for _, inst := range inv.Instances {
+		r.instances[inst.Id] = inst
+	}
+
+	for _, nat := range inv.NatGateways {
+		for _, addr := range nat.Addresses {
+			r.natGateways[addr.NetworkInterfaceId] = nat
+		}
+	}
+
+	for _, lb := range inv.LoadBalancers {
+		r.loadBalancers[elbInterfaceDescriptionPrefix+lb.ResourceId()] = lb
+	}
+
+	return r
+}
+
+func (r ipOwnerResolver) resolve(eni NetworkInterface) ipOwner {
+	if eni.InstanceId != nil {
+		if inst, exists := r.instances[*eni.InstanceId]; exists {
+			return r.instanceOwner(inst)
+		}
+	}
+
+	if nat, exists := r.natGateways[eni.Id]; exists {
+		return natOwner(nat)
+	}
+
+	if lb, exists := r.loadBalancers[eni.Description]; exists {
+		return loadBalancerOwner(lb)
+	}
+
+	exposure := ExposurePrivate
+	for _, addr := range eni.Addresses {
+		if !ptr.IsEmpty(addr.PublicIP) {
+			exposure = ExposurePublic
+		}
+	}
+
+	return ipOwner{
+		ownerType: AssetNetworkInterface,
+		ownerId:   eni.Id,
+		vpc:       eni.VPC,
+		exposure:  exposure,
+	}
+}
+
+func (r ipOwnerResolver) instanceOwner(inst Ec2Instance) ipOwner {
+	return ipOwner{
+		ownerType: AssetEc2Instance,
+		ownerId:   inst.Id,
+		vpc:       inst.VPC,
+		exposure:  inst.ExposureStatus(),
+	}
+}
+
+func natOwner(nat NatGateway) ipOwner {
+	// NAT gateway addresses are public, but only carry connections opened from inside the VPC,
+	// so they are never open to the internet
+	return ipOwner{
+		ownerType: AssetNatGateway,
+		ownerId:   nat.Id,
+		vpc:       nat.VPC,
+		exposure:  ExposurePublic,
+	}
+}
+
+func loadBalancerOwner(lb LoadBalancer) ipOwner {
+	exposure := ExposurePrivate
+	if lb.IsInternetFacing() {
+		exposure = ExposurePublic
+	}
+
+	return ipOwner{
+		ownerType: AssetLoadBalancer,
+		ownerId:   lb.Arn,
+		vpc:       lb.VPC,
+		exposure:  exposure,
+	}
+}
@@ -0,0 +1,183 @@
+package aws
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Lateral movement is computed for the protocols that have ports
+var lateralMovementProtocols = []string{protocolTcp, protocolUdp}
+
+// InstanceReach is what an instance can open connections to on another instance. Status is
+// ReachabilityUnknown when data to confirm it is missing, e.g. a network ACL of their subnets,
+// and ReachabilityPartial when network ACLs only let part of the return traffic through
+type InstanceReach struct {
+	From       string
+	To         string
+	Protocol   string
+	PortRanges []PortRange
+	Status     string
+}
+
+func (i InstanceReach) Ports() []string {
+	ports := make([]string, 0, len(i.PortRanges))
+	for _, portRange := range i.PortRanges {
+		ports = append(ports, portRange.String())
+	}
+
+	return ports
+}
+
+// computeInstanceReaches finds every pair of instances where the first can connect to the
+// second through their private addresses, and on which ports. Ports are what both the
+// source egress rules and the destination ingress rules allow, narrowed down by the
+// network ACLs traffic crosses when the instances are in different subnets.
+func computeInstanceReaches(inv Inventory) []InstanceReach {
+	network := newNetworkIndex(inv)
+	reaches := make([]InstanceReach, 0, len(inv.Instances))
+
+	for _, src := range inv.Instances {
+		for _, dst := range inv.Instances {
+			if src.Id == dst.Id {
+				continue
+			}
+
+			for _, protocol := range lateralMovementProtocols {
+				if reach, canReach := network.reach(src, dst, protocol); canReach {
+					reaches = append(reaches, reach)
+				}
+			}
+		}
+	}
+
+	return reaches
+}
+
+func (n networkIndex) reach(src, dst Ec2Instance, protocol string) (InstanceReach, bool) {
+	srcPrefix, srcValid := parsePrefix(src.PrivateIP)
+	dstPrefix, dstValid := parsePrefix(dst.PrivateIP)
+	if !srcValid || !dstValid {
+		return InstanceReach{}, false
+	}
+
+	reach := InstanceReach{From: src.Id, To: dst.Id, Protocol: protocol, Status: ReachabilityReachable}
+
+	// Routing and return traffic don't depend on the port, return traffic goes to the
+	// ephemeral port the client picked
+	forward, _ := n.routeHop(src, dstPrefix)
+	back, _ := n.routeHop(dst, srcPrefix)
+	hops := []ReachabilityHop{forward, back}
+
+	ports := intersectPortRanges(
+		securityGroupPorts(src.EgressSecRules, protocol, dstPrefix, dst.SecurityGroupIds),
+		securityGroupPorts(dst.IngressSecRules, protocol, srcPrefix, src.SecurityGroupIds),
+	)
+
+	if src.SubnetId != dst.SubnetId {
+		hops = append(hops,
+			n.networkAclHop(dst, true, protocol, ephemeralPorts, srcPrefix),
+			n.networkAclHop(src, false, protocol, ephemeralPorts, dstPrefix),
+		)
+
+		srcAclPorts, srcAclKnown := n.networkAclPorts(src, true, protocol, dstPrefix)
+		dstAclPorts, dstAclKnown := n.networkAclPorts(dst, false, protocol, srcPrefix)
+
+		// Unknown network ACLs don't narrow ports down, the reach is only not confirmed
+		if srcAclKnown {
+			ports = intersectPortRanges(ports, srcAclPorts)
+		}
+
+		if dstAclKnown {
+			ports = intersectPortRanges(ports, dstAclPorts)
+		}
+
+		if !srcAclKnown || !dstAclKnown {
+			reach.Status = ReachabilityUnknown
+		}
+	}
+
+	switch verdictStatus(hops) {
+	case ReachabilityUnreachable:
+		return InstanceReach{}, false
+	case ReachabilityUnknown:
+		reach.Status = ReachabilityUnknown
+	case ReachabilityPartial:
+		if reach.Status == ReachabilityReachable {
+			reach.Status = ReachabilityPartial
+		}
+	}
+
+	reach.PortRanges = ports
+
+	return reach, len(ports) > 0
+}
+
+// securityGroupPorts returns the ports the rules allow for the peer, by address or by group
+func securityGroupPorts(rules []Ec2SecGroupRule, protocol string, peer netip.Prefix, peerGroups []string) []PortRange {
+	ranges := make([]PortRange, 0, len(rules))
+	for _, rule := range rules {
+		if !protocolMatches(rule.IpProtocol, protocol) || !ruleMatchesPeer(rule, peer, peerGroups) {
+			continue
+		}
+
+		if rule.IpProtocol == allProtocols {
+			ranges = append(ranges, allPorts)
+			continue
+		}
+
+		ranges = append(ranges, PortRange{From: rule.FromPort, To: rule.ToPort})
+	}
+
+	return mergePortRanges(ranges)
+}
+
+func ruleMatchesPeer(rule Ec2SecGroupRule, peer netip.Prefix, peerGroups []string) bool {
+	for _, groupId := range rule.SourceGroupIds {
+		if slices.Contains(peerGroups, groupId) {
+			return true
+		}
+	}
+
+	for _, ipRange := range rule.IpRanges {
+		if cidr, valid := parsePrefix(ipRange); valid && prefixContains(cidr, peer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkAclPorts walks the entries in order over the whole port space, each entry deciding
+// the ports no previous entry decided. False when the subnet network ACL is not known
+func (n networkIndex) networkAclPorts(inst Ec2Instance, egress bool, protocol string, peer netip.Prefix) ([]PortRange, bool) {
+	acl, exists := n.networkAcl(inst.SubnetId)
+	if !exists {
+		return nil, false
+	}
+
+	undecided := []PortRange{allPorts}
+	allowed := make([]PortRange, 0, len(acl.Entries))
+
+	for _, entry := range acl.sortedEntries(egress) {
+		if !protocolMatches(entry.Protocol, protocol) {
+			continue
+		}
+
+		if cidr, valid := parsePrefix(entry.Cidr); !valid || !prefixContains(cidr, peer) {
+			continue
+		}
+
+		entryRange := PortRange{From: entry.FromPort, To: entry.ToPort}
+		if normalizeProtocol(entry.Protocol) == allProtocols {
+			entryRange = allPorts
+		}
+
+		if entry.Allow {
+			allowed = append(allowed, intersectPortRanges(undecided, []PortRange{entryRange})...)
+		}
+
+		undecided = subtractPortRange(undecided, entryRange)
+	}
+
+	return mergePortRanges(allowed), true
+}
@@ -0,0 +1,368 @@
+package aws
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Recommendations for security group rules
+const (
+	RecommendKeep   = "keep"
+	RecommendNarrow = "narrow"
+	RecommendRemove = "remove"
+)
+
+// Suggestions with more ranges than these wouldn't be tighter in practice, like a public
+// website seen from thousands of networks, the rule is kept as it is instead
+const (
+	maxSuggestedIpRanges   = 20
+	maxSuggestedPortRanges = 10
+)
+
+// RuleRecommendation tells whether a rule is needed for the traffic observed, and the rules
+// that would be enough to allow it when it's wider than needed
+type RuleRecommendation struct {
+	Direction      string
+	Protocol       string
+	Ports          string
+	IpRanges       []string
+	SourceGroupIds []string
+	Recommendation string
+	MatchedFlows   int64
+	MatchedBytes   int64
+	LastMatched    *time.Time
+	// Suggested replace the rule when it's narrowed
+	Suggested []Ec2SecGroupRule
+}
+
+// SecurityGroupRecommendations are the recommendations for the rules of a group, with the
+// exposure of the group before and after applying them. Exposure is the sum of the
+// permissiveness scores of the rules
+type SecurityGroupRecommendations struct {
+	GroupId           string
+	WindowStart       time.Time
+	WindowEnd         time.Time
+	Rules             []RuleRecommendation
+	ExposureBefore    int
+	ExposureAfter     int
+	ExposureReduction int
+}
+
+// RecommendLeastPrivilege compares the rules of every security group with the accepted
+// traffic observed over the window: the day of the last observed flow and the windowDays - 1
+// days before it. Only the daily counts of those days are added up. Groups none of whose
+// assets appear in the traffic are left out, their flows might not be logged at all. Rules
+// with prefix lists are kept, as their ranges aren't resolved
+func RecommendLeastPrivilege(inv Inventory, traffic []ObservedTraffic, windowDays int, networks NetworkClassifier) []SecurityGroupRecommendations {
+	var windowEnd time.Time
+	for _, observed := range traffic {
+		if observed.LastSeen.After(windowEnd) {
+			windowEnd = observed.LastSeen
+		}
+	}
+
+	windowStart := windowEnd.Truncate(trafficBucket).AddDate(0, 0, 1-windowDays)
+	accepted := make([]ObservedTraffic, 0, len(traffic))
+	observedAssets := make(map[AssetRef]bool, len(traffic))
+	for _, observed := range traffic {
+		if observed.Action == FlowAccept && !observed.Day.Before(windowStart) {
+			accepted = append(accepted, observed)
+			observedAssets[observed.From] = true
+			observedAssets[observed.To] = true
+		}
+	}
+
+	peers := newTrafficPeers(inv)
+	members := securityGroupMembers(inv)
+	recommendations := make([]SecurityGroupRecommendations, 0, len(inv.SecurityGroups))
+	for _, group := range securityGroupsOf(inv) {
+		groupMembers := make(map[AssetRef]bool, len(members[group.Id]))
+		covered := false
+		for _, member := range members[group.Id] {
+			groupMembers[member] = true
+			covered = covered || observedAssets[member]
+		}
+
+		if !covered {
+			continue
+		}
+
+		recommended := SecurityGroupRecommendations{GroupId: group.Id, WindowStart: windowStart, WindowEnd: windowEnd}
+		for _, direction := range []string{DirectionIngress, DirectionEgress} {
+			rules := group.Ingress
+			if direction == DirectionEgress {
+				rules = group.Egress
+			}
+
+			for _, rule := range rules {
+				recommendation, before, after := recommendRule(rule, direction, groupMembers, accepted, peers, networks)
+				recommended.Rules = append(recommended.Rules, recommendation)
+				recommended.ExposureBefore += before
+				recommended.ExposureAfter += after
+			}
+		}
+
+		if recommended.ExposureBefore > 0 {
+			recommended.ExposureReduction = 100 * (recommended.ExposureBefore - recommended.ExposureAfter) / recommended.ExposureBefore
+		}
+
+		recommendations = append(recommendations, recommended)
+	}
+
+	slices.SortStableFunc(recommendations, func(a, b SecurityGroupRecommendations) int {
+		if a.ExposureReduction != b.ExposureReduction {
+			return b.ExposureReduction - a.ExposureReduction
+		}
+
+		return strings.Compare(a.GroupId, b.GroupId)
+	})
+
+	return recommendations
+}
+
+// recommendRule matches the traffic of the members against the rule, and returns the
+// recommendation with the permissiveness score of the rule before and after it
+func recommendRule(rule Ec2SecGroupRule, direction string, members map[AssetRef]bool, traffic []ObservedTraffic, peers trafficPeers, networks NetworkClassifier) (RuleRecommendation, int, int) {
+	recommendation := RuleRecommendation{
+		Direction:      direction,
+		Protocol:       rule.IpProtocol,
+		Ports:          rulePorts(rule).String(),
+		IpRanges:       rule.IpRanges,
+		SourceGroupIds: rule.SourceGroupIds,
+		Recommendation: RecommendKeep,
+	}
+
+	score := scoreRule(rule, direction, networks).Score
+	if len(rule.PrefixListIds) > 0 {
+		return recommendation, score, score
+	}
+
+	matches := make([]ruleMatch, 0)
+	for _, observed := range traffic {
+		member, peer := observed.To, observed.From
+		if direction == DirectionEgress {
+			member, peer = observed.From, observed.To
+		}
+
+		if !members[member] || !protocolMatches(rule.IpProtocol, observed.Protocol) || !rulePortsMatch(rule, observed) {
+			continue
+		}
+
+		match := peers.match(rule, peer)
+		if len(match.ipRanges) == 0 && len(match.groupIds) == 0 {
+			continue
+		}
+
+		match.traffic = observed
+		matches = append(matches, match)
+		recommendation.MatchedFlows += observed.Flows
+		recommendation.MatchedBytes += observed.Bytes
+		if recommendation.LastMatched == nil || observed.LastSeen.After(*recommendation.LastMatched) {
+			recommendation.LastMatched = &observed.LastSeen
+		}
+	}
+
+	if len(matches) == 0 {
+		recommendation.Recommendation = RecommendRemove
+		return recommendation, score, 0
+	}
+
+	suggested := suggestRules(rule, matches)
+	if len(suggested) == 1 && sameRule(rule, suggested[0]) {
+		return recommendation, score, score
+	}
+
+	suggestedScore := 0
+	for _, suggestion := range suggested {
+		suggestedScore = max(suggestedScore, scoreRule(suggestion, direction, networks).Score)
+	}
+
+	recommendation.Recommendation = RecommendNarrow
+	recommendation.Suggested = suggested
+
+	return recommendation, score, min(score, suggestedScore)
+}
+
+// rulePortsMatch tells whether the rule allows the port of the traffic. Only TCP and UDP
+// traffic has ports
+func rulePortsMatch(rule Ec2SecGroupRule, observed ObservedTraffic) bool {
+	protocol := normalizeProtocol(observed.Protocol)
+	if protocol != protocolNumbers[protocolTcp] && protocol != protocolNumbers[protocolUdp] {
+		return true
+	}
+
+	ports := rulePorts(rule)
+	return ports.From <= observed.Port && ports.To >= observed.Port
+}
+
+// ruleMatch is traffic allowed by a rule, with the part of the rule's ranges and groups the
+// peer falls in
+type ruleMatch struct {
+	traffic  ObservedTraffic
+	ipRanges []string
+	groupIds []string
+}
+
+// trafficPeers looks up the addresses and security groups of the assets traffic goes from or to
+type trafficPeers struct {
+	addresses map[AssetRef][]netip.Prefix
+	groups    map[AssetRef][]string
+}
+
+func newTrafficPeers(inv Inventory) trafficPeers {
+	peers := trafficPeers{
+		addresses: make(map[AssetRef][]netip.Prefix),
+		groups:    make(map[AssetRef][]string),
+	}
+
+	for _, address := range indexIpAddresses(inv) {
+		if prefix, valid := parsePrefix(address.Address); valid {
+			owner := AssetRef{AssetType: address.OwnerType, AssetId: address.OwnerId}
+			peers.addresses[owner] = append(peers.addresses[owner], prefix)
+		}
+	}
+
+	for groupId, members := range securityGroupMembers(inv) {
+		for _, member := range members {
+			peers.groups[member] = append(peers.groups[member], groupId)
+		}
+	}
+
+	return peers
+}
+
+// match returns the ranges of the rule narrowed down to the peer, and the groups of the rule
+// the peer is a member of
+func (p trafficPeers) match(rule Ec2SecGroupRule, peer AssetRef) ruleMatch {
+	var match ruleMatch
+	peerPrefixes := p.addresses[peer]
+	if peer.AssetType == AssetCidr {
+		if prefix, valid := parsePrefix(peer.AssetId); valid {
+			peerPrefixes = []netip.Prefix{prefix}
+		}
+	}
+
+	for _, ipRange := range rule.IpRanges {
+		rulePrefix, valid := parsePrefix(ipRange)
+		if !valid {
+			continue
+		}
+
+		for _, peerPrefix := range peerPrefixes {
+			switch {
+			case prefixContains(rulePrefix, peerPrefix):
+				match.ipRanges = append(match.ipRanges, peerPrefix.String())
+			case prefixContains(peerPrefix, rulePrefix):
+				match.ipRanges = append(match.ipRanges, rulePrefix.String())
+			}
+		}
+	}
+
+	for _, groupId := range rule.SourceGroupIds {
+		if slices.Contains(p.groups[peer], groupId) {
+			match.groupIds = append(match.groupIds, groupId)
+		}
+	}
+
+	return match
+}
+
+// suggestRules builds the rules allowing just the matched traffic: one per protocol and range
+// of ports seen, from the networks and groups seen on them. Ranges or ports too scattered to
+// list are left as the rule has them
+func suggestRules(rule Ec2SecGroupRule, matches []ruleMatch) []Ec2SecGroupRule {
+	byProtocol := make(map[string][]ruleMatch)
+	protocols := make([]string, 0, 1)
+	for _, match := range matches {
+		protocol := normalizeProtocol(match.traffic.Protocol)
+		if _, found := byProtocol[protocol]; !found {
+			protocols = append(protocols, protocol)
+		}
+
+		byProtocol[protocol] = append(byProtocol[protocol], match)
+	}
+
+	slices.Sort(protocols)
+
+	suggested := make([]Ec2SecGroupRule, 0, len(protocols))
+	for _, protocol := range protocols {
+		protocolMatches := byProtocol[protocol]
+		for _, ports := range suggestedPorts(rule, protocol, protocolMatches) {
+			suggestion := Ec2SecGroupRule{
+				GroupId:    rule.GroupId,
+				FromPort:   ports.From,
+				ToPort:     ports.To,
+				IpProtocol: protocolName(protocol),
+			}
+
+			for _, match := range protocolMatches {
+				if ports.From > match.traffic.Port || ports.To < match.traffic.Port {
+					continue
+				}
+
+				suggestion.IpRanges = appendMissing(suggestion.IpRanges, match.ipRanges...)
+				suggestion.SourceGroupIds = appendMissing(suggestion.SourceGroupIds, match.groupIds...)
+			}
+
+			if len(suggestion.IpRanges) > maxSuggestedIpRanges {
+				suggestion.IpRanges = rule.IpRanges
+			}
+
+			slices.Sort(suggestion.IpRanges)
+			slices.Sort(suggestion.SourceGroupIds)
+			suggested = append(suggested, suggestion)
+		}
+	}
+
+	return suggested
+}
+
+// suggestedPorts are the ports seen, merged into ranges. Protocols without ports keep the
+// types and codes of the rule, or all of them
+func suggestedPorts(rule Ec2SecGroupRule, protocol string, matches []ruleMatch) []PortRange {
+	if protocol != protocolNumbers[protocolTcp] && protocol != protocolNumbers[protocolUdp] {
+		if normalizeProtocol(rule.IpProtocol) == protocol {
+			return []PortRange{{From: rule.FromPort, To: rule.ToPort}}
+		}
+
+		return []PortRange{{From: -1, To: -1}}
+	}
+
+	ports := make([]PortRange, 0, len(matches))
+	for _, match := range matches {
+		ports = append(ports, PortRange{From: match.traffic.Port, To: match.traffic.Port})
+	}
+
+	merged := mergePortRanges(ports)
+	if len(merged) > maxSuggestedPortRanges {
+		return []PortRange{{From: merged[0].From, To: merged[len(merged)-1].To}}
+	}
+
+	return merged
+}
+
+func sameRule(rule, other Ec2SecGroupRule) bool {
+	return normalizeProtocol(rule.IpProtocol) == normalizeProtocol(other.IpProtocol) &&
+		rulePorts(rule) == rulePorts(other) &&
+		slices.Equal(sortedCopy(rule.IpRanges), sortedCopy(other.IpRanges)) &&
+		slices.Equal(sortedCopy(rule.SourceGroupIds), sortedCopy(other.SourceGroupIds))
+}
+
+func sortedCopy(values []string) []string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	return sorted
+}
+
+func appendMissing(values []string, added ...string) []string {
+	for _, value := range added {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
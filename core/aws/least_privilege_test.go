@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecommendLeastPrivilege(t *testing.T) {
+	inv := Inventory{Instances: []Ec2Instance{
+		{Id: "i-web", PrivateIP: "10.0.0.10", SecurityGroupIds: []string{"sg-web"}, IngressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-web", FromPort: 0, ToPort: 65535, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+			{GroupId: "sg-web", FromPort: 3389, ToPort: 3389, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+		}, EgressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-web", IpProtocol: allProtocols, IpRanges: []string{anyIPv4}},
+		}},
+		{Id: "i-app", PrivateIP: "10.0.0.20", SecurityGroupIds: []string{"sg-app"}, IngressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-app", FromPort: 8080, ToPort: 8080, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web"}},
+		}},
+		{Id: "i-unlogged", PrivateIP: "10.0.1.10", SecurityGroupIds: []string{"sg-unlogged"}, IngressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-unlogged", FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{anyIPv4}},
+		}},
+	}}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	today, earlier := now.Truncate(trafficBucket), now.AddDate(0, -2, 0).Truncate(trafficBucket)
+	web := AssetRef{AssetType: AssetEc2Instance, AssetId: "i-web"}
+	app := AssetRef{AssetType: AssetEc2Instance, AssetId: "i-app"}
+	clients := AssetRef{AssetType: AssetCidr, AssetId: "198.51.100.0/24"}
+	resolvers := AssetRef{AssetType: AssetCidr, AssetId: "203.0.113.0/24"}
+	traffic := []ObservedTraffic{
+		{From: clients, To: web, Protocol: "6", Port: 443, Action: FlowAccept, Flows: 10, Day: today, LastSeen: now},
+		{From: clients, To: web, Protocol: "6", Port: 443, Action: FlowAccept, Flows: 50, Day: earlier, LastSeen: earlier},
+		{From: clients, To: web, Protocol: "6", Port: 22, Action: FlowAccept, Flows: 2, Day: today, LastSeen: now},
+		{From: clients, To: web, Protocol: "6", Port: 3389, Action: FlowAccept, Flows: 1, Day: earlier, LastSeen: earlier},
+		{From: clients, To: web, Protocol: "6", Port: 3389, Action: FlowReject, Flows: 5, Day: today, LastSeen: now},
+		{From: web, To: app, Protocol: "6", Port: 8080, Action: FlowAccept, Flows: 4, Day: today, LastSeen: now},
+		{From: web, To: resolvers, Protocol: "17", Port: 53, Action: FlowAccept, Flows: 3, Day: today, LastSeen: now},
+	}
+
+	recommendations := RecommendLeastPrivilege(inv, traffic, 30, NetworkClassifier{})
+	if len(recommendations) != 2 {
+		t.Fatalf("expected sg-web and sg-app only, got %+v", recommendations)
+	}
+
+	webGroup, appGroup := recommendations[0], recommendations[1]
+	if webGroup.GroupId != "sg-web" || appGroup.GroupId != "sg-app" {
+		t.Fatalf("expected sg-web with the largest reduction first, got %s and %s", webGroup.GroupId, appGroup.GroupId)
+	}
+
+	if len(webGroup.Rules) != 3 {
+		t.Fatalf("expected every rule of sg-web, got %+v", webGroup.Rules)
+	}
+
+	ports, rdp, egress := webGroup.Rules[0], webGroup.Rules[1], webGroup.Rules[2]
+	if ports.Recommendation != RecommendNarrow || ports.MatchedFlows != 12 || len(ports.Suggested) != 2 {
+		t.Fatalf("expected the port range narrowed to SSH and HTTPS seen within the window, got %+v", ports)
+	}
+
+	if ports.Suggested[0].FromPort != 22 || ports.Suggested[1].FromPort != 443 || ports.Suggested[1].IpRanges[0] != "198.51.100.0/24" {
+		t.Errorf("unexpected suggestions %+v", ports.Suggested)
+	}
+
+	if rdp.Recommendation != RecommendRemove || rdp.MatchedFlows != 0 {
+		t.Errorf("expected RDP unused over the window, got %+v", rdp)
+	}
+
+	if egress.Recommendation != RecommendNarrow || len(egress.Suggested) != 2 || egress.Suggested[0].IpProtocol != "udp" {
+		t.Errorf("expected egress narrowed to the app and resolvers, got %+v", egress)
+	}
+
+	if webGroup.ExposureAfter >= webGroup.ExposureBefore || webGroup.ExposureReduction <= 0 {
+		t.Errorf("expected a lower exposure, got %+v", webGroup)
+	}
+
+	if appGroup.Rules[0].Recommendation != RecommendKeep || appGroup.Rules[0].MatchedFlows != 4 {
+		t.Errorf("expected the group reference kept, got %+v", appGroup.Rules[0])
+	}
+}
@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	protocolTcp  = "tcp"
+	protocolUdp  = "udp"
+	protocolIcmp = "icmp"
+)
+
+// Security groups and network ACLs accept protocols by name or number
+var protocolNumbers = map[string]string{
+	protocolTcp:  "6",
+	protocolUdp:  "17",
+	protocolIcmp: "1",
+	"all":        allProtocols,
+}
+
+func normalizeProtocol(protocol string) string {
+	if number, isName := protocolNumbers[strings.ToLower(protocol)]; isName {
+		return number
+	}
+
+	return protocol
+}
+
+func protocolMatches(ruleProtocol, protocol string) bool {
+	ruleProtocol = normalizeProtocol(ruleProtocol)
+	return ruleProtocol == allProtocols || ruleProtocol == normalizeProtocol(protocol)
+}
+
+// networkIndex looks up what applies to the traffic of a subnet
+type networkIndex struct {
+	subnets          map[string]Subnet
+	instances        map[string]Ec2Instance
+	subnetRoutes     map[string]RouteTable
+	mainRoutes       map[string]RouteTable
+	subnetAcls       map[string]NetworkAcl
+	internetGateways map[string]InternetGateway
+	// missing is the data that couldn't be fetched, so its absence proves nothing
+	missing map[string]bool
+}
+
+func newNetworkIndex(inv Inventory) networkIndex {
+	n := networkIndex{
+		subnets:          make(map[string]Subnet, len(inv.Subnets)),
+		instances:        make(map[string]Ec2Instance, len(inv.Instances)),
+		subnetRoutes:     make(map[string]RouteTable, len(inv.Subnets)),
+		mainRoutes:       make(map[string]RouteTable, len(inv.RouteTables)),
+		subnetAcls:       make(map[string]NetworkAcl, len(inv.Subnets)),
+		internetGateways: make(map[string]InternetGateway, len(inv.InternetGateways)),
+		missing:          make(map[string]bool, len(inv.MissingData)),
+	}
+
+	for _, data := range inv.MissingData {
+		n.missing[data] = true
+	}
+
+	for _, subnet := range inv.Subnets {
+		n.subnets[subnet.Id] = subnet
+	}
+
+	for _, inst := range inv.Instances {
+		n.instances[inst.Id] = inst
+	}
+
+	for _, table := range inv.RouteTables {
+		if table.Main {
+			n.mainRoutes[table.VPC] = table
+		}
+
+		for _, subnetId := range table.SubnetIds {
+			n.subnetRoutes[subnetId] = table
+		}
+	}
+
+	// Every subnet is associated to exactly one network ACL, the default one unless told otherwise
+	for _, acl := range inv.NetworkAcls {
+		for _, subnetId := range acl.SubnetIds {
+			n.subnetAcls[subnetId] = acl
+		}
+	}
+
+	for _, gateway := range inv.InternetGateways {
+		n.internetGateways[gateway.Id] = gateway
+	}
+
+	return n
+}
+
+func (n networkIndex) routeTable(subnetId, vpc string) (RouteTable, bool) {
+	if table, associated := n.subnetRoutes[subnetId]; associated {
+		return table, true
+	}
+
+	table, exists := n.mainRoutes[vpc]
+	return table, exists
+}
+
+func (n networkIndex) networkAcl(subnetId string) (NetworkAcl, bool) {
+	acl, exists := n.subnetAcls[subnetId]
+	return acl, exists
+}
+
+// sortedEntries returns the entries of one direction in evaluation order
+func (a NetworkAcl) sortedEntries(egress bool) []NetworkAclEntry {
+	entries := make([]NetworkAclEntry, 0, len(a.Entries))
+	for _, entry := range a.Entries {
+		if entry.Egress == egress {
+			entries = append(entries, entry)
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b NetworkAclEntry) int {
+		return int(a.RuleNumber - b.RuleNumber)
+	})
+
+	return entries
+}
+
+// portSegments splits the ports where entries start or end, so that every entry covers either
+// all or none of the ports of a segment
+func (a NetworkAcl) portSegments(egress bool, ports PortRange) []PortRange {
+	bounds := []int32{ports.From}
+	for _, entry := range a.sortedEntries(egress) {
+		for _, bound := range []int32{entry.FromPort, entry.ToPort + 1} {
+			if bound > ports.From && bound <= ports.To && !slices.Contains(bounds, bound) {
+				bounds = append(bounds, bound)
+			}
+		}
+	}
+
+	slices.Sort(bounds)
+
+	segments := make([]PortRange, 0, len(bounds))
+	for idx, from := range bounds {
+		to := ports.To
+		if idx+1 < len(bounds) {
+			to = bounds[idx+1] - 1
+		}
+
+		segments = append(segments, PortRange{From: from, To: to})
+	}
+
+	return segments
+}
+
+func (e NetworkAclEntry) covers(protocol string, port int32) bool {
+	if !protocolMatches(e.Protocol, protocol) {
+		return false
+	}
+
+	return normalizeProtocol(e.Protocol) == allProtocols || (e.FromPort <= port && e.ToPort >= port)
+}
+
+func (r Ec2SecGroupRule) allows(protocol string, port int32) bool {
+	return protocolMatches(r.IpProtocol, protocol) && r.coversPort(port)
+}
@@ -0,0 +1,227 @@
+package aws
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+)
+
+// Ranges of our own networks are less of a concern than public ones of the same size
+const trustedRangeDiscount = 0.25
+
+// Egress rules only let instances open connections, which attackers need a foothold to use,
+// so the default egress rule weighs half of all ingress from the internet
+const egressDiscount = 0.5
+
+// PermissiveRule is a security group rule with its permissiveness, from 0 for a single port
+// from a single address to 100 for every protocol from the whole internet
+type PermissiveRule struct {
+	Direction      string
+	Protocol       string
+	Ports          string
+	IpRanges       []string
+	SourceGroupIds []string
+	Score          int
+	Reasons        []string
+}
+
+type SecurityGroupPermissiveness struct {
+	GroupId     string
+	Score       int
+	InstanceIds []string
+	Rules       []PermissiveRule
+}
+
+// ScorePermissiveRules scores every rule of the security groups of the region, and
+// returns the groups with a rule scoring at least minScore, the most permissive first. Only
+// the rules reaching minScore are listed
+func ScorePermissiveRules(inv Inventory, networks NetworkClassifier, minScore int) []SecurityGroupPermissiveness {
+	groups := make([]SecurityGroupPermissiveness, 0, len(inv.Instances))
+	for _, group := range securityGroupsOf(inv) {
+		scored := SecurityGroupPermissiveness{GroupId: group.Id, InstanceIds: group.InstanceIds}
+		for direction, rules := range map[string][]Ec2SecGroupRule{DirectionIngress: group.Ingress, DirectionEgress: group.Egress} {
+			for _, rule := range rules {
+				permissive := scoreRule(rule, direction, networks)
+				if permissive.Score >= minScore {
+					scored.Rules = append(scored.Rules, permissive)
+					scored.Score = max(scored.Score, permissive.Score)
+				}
+			}
+		}
+
+		if len(scored.Rules) == 0 {
+			continue
+		}
+
+		slices.SortStableFunc(scored.Rules, func(a, b PermissiveRule) int {
+			if a.Score != b.Score {
+				return b.Score - a.Score
+			}
+
+			return strings.Compare(a.Direction, b.Direction)
+		})
+		groups = append(groups, scored)
+	}
+
+	slices.SortFunc(groups, func(a, b SecurityGroupPermissiveness) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+
+		return strings.Compare(a.GroupId, b.GroupId)
+	})
+
+	return groups
+}
+
+// instanceSecurityGroup is a security group as seen from the instances it's attached to
+type instanceSecurityGroup struct {
+	Id          string
+	Ingress     []Ec2SecGroupRule
+	Egress      []Ec2SecGroupRule
+	InstanceIds []string
+}
+
+// securityGroupsOf returns the security groups of the region with the instances using them.
+// Inventories without groups, like offline ones, get them rebuilt from the rules of the
+// instances. Every instance gets all the rules of its groups, so the rules are taken from the
+// first instance using it
+func securityGroupsOf(inv Inventory) []instanceSecurityGroup {
+	byId := make(map[string]*instanceSecurityGroup, len(inv.SecurityGroups)+len(inv.Instances))
+	ids := make([]string, 0, len(inv.SecurityGroups)+len(inv.Instances))
+
+	for _, group := range inv.SecurityGroups {
+		byId[group.Id] = &instanceSecurityGroup{Id: group.Id, Ingress: group.IngressRules, Egress: group.EgressRules}
+		ids = append(ids, group.Id)
+	}
+
+	for _, inst := range inv.Instances {
+		for _, groupId := range inst.SecurityGroupIds {
+			group, known := byId[groupId]
+			if known {
+				group.InstanceIds = append(group.InstanceIds, inst.Id)
+				continue
+			}
+
+			group = &instanceSecurityGroup{Id: groupId, InstanceIds: []string{inst.Id}}
+			for _, rule := range inst.IngressSecRules {
+				if rule.GroupId == groupId {
+					group.Ingress = append(group.Ingress, rule)
+				}
+			}
+
+			for _, rule := range inst.EgressSecRules {
+				if rule.GroupId == groupId {
+					group.Egress = append(group.Egress, rule)
+				}
+			}
+
+			byId[groupId] = group
+			ids = append(ids, groupId)
+		}
+	}
+
+	slices.Sort(ids)
+	groups := make([]instanceSecurityGroup, 0, len(ids))
+	for _, id := range ids {
+		groups = append(groups, *byId[id])
+	}
+
+	return groups
+}
+
+// scoreRule weighs how much of the address space the rule opens, on how many ports and with
+// which protocol. The address space counts the most, a rule only allowing other security
+// groups doesn't open anything to addresses. Egress rules weigh less than ingress ones
+func scoreRule(rule Ec2SecGroupRule, direction string, networks NetworkClassifier) PermissiveRule {
+	permissive := PermissiveRule{
+		Direction:      direction,
+		Protocol:       rule.IpProtocol,
+		Ports:          rulePorts(rule).String(),
+		IpRanges:       rule.IpRanges,
+		SourceGroupIds: rule.SourceGroupIds,
+	}
+
+	addressScore := 0.0
+	for _, ipRange := range rule.IpRanges {
+		score, reason := addressSpaceScore(ipRange, direction, networks)
+		if score > addressScore {
+			addressScore = score
+		}
+
+		if reason != "" {
+			permissive.Reasons = append(permissive.Reasons, reason)
+		}
+	}
+
+	ports := rulePorts(rule)
+	portCount := float64(ports.To - ports.From + 1)
+	portScore := math.Log2(portCount) / 16
+	if normalizeProtocol(rule.IpProtocol) == protocolNumbers[protocolIcmp] {
+		portScore = 0
+	} else if portCount > 1024 {
+		permissive.Reasons = append(permissive.Reasons, fmt.Sprintf("%s ports open", ports))
+	}
+
+	protocolScore := 0.5
+	switch normalizeProtocol(rule.IpProtocol) {
+	case allProtocols:
+		protocolScore = 1
+		permissive.Reasons = append(permissive.Reasons, "all protocols")
+	case protocolNumbers[protocolIcmp]:
+		protocolScore = 0.2
+	}
+
+	if direction == DirectionEgress {
+		addressScore *= egressDiscount
+	}
+
+	permissive.Score = int(math.Round(100 * addressScore * (0.2 + 0.5*portScore + 0.3*protocolScore)))
+
+	return permissive
+}
+
+// rulePorts returns the ports of the rule. Rules for all protocols, and ICMP rules for all
+// types, have -1 or no ports
+func rulePorts(rule Ec2SecGroupRule) PortRange {
+	if rule.IpProtocol == allProtocols || rule.FromPort < 0 || rule.ToPort < 0 {
+		return allPorts
+	}
+
+	return PortRange{From: max(0, rule.FromPort), To: min(maxPort, max(rule.ToPort, rule.FromPort))}
+}
+
+// addressSpaceScore is the share of the address space of the range, on a logarithmic scale,
+// so a /8 is 0.75 and a /32 is 0
+func addressSpaceScore(ipRange, direction string, networks NetworkClassifier) (float64, string) {
+	prefix, valid := parsePrefix(ipRange)
+	if !valid {
+		return 0, ""
+	}
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	score := float64(hostBits) / float64(prefix.Addr().BitLen())
+
+	switch source := networks.Classify(ipRange); source.Class {
+	case ClassInternet:
+		if direction == DirectionEgress {
+			return score, "egress to the internet"
+		}
+
+		return score, "open to the internet"
+	case ClassTrusted, ClassInternal:
+		return score * trustedRangeDiscount, ""
+	default:
+		if prefix.Addr().Is4() && prefix.Bits() <= 16 {
+			return score, fmt.Sprintf("wide %s range %s", source.Class, ipRange)
+		}
+
+		return score, ""
+	}
+}
@@ -0,0 +1,29 @@
+package aws
+
+import "testing"
+
+func TestScorePermissiveRules(t *testing.T) {
+	inv := Inventory{Instances: []Ec2Instance{
+		{Id: "i-1", SecurityGroupIds: []string{"sg-wide", "sg-narrow"}, IngressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-wide", FromPort: 0, ToPort: 65535, IpProtocol: "tcp", IpRanges: []string{"52.0.0.0/8"}},
+			{GroupId: "sg-narrow", FromPort: 443, ToPort: 443, IpProtocol: "tcp", IpRanges: []string{"52.1.2.3/32"}},
+		}, EgressSecRules: []Ec2SecGroupRule{
+			{GroupId: "sg-wide", IpProtocol: allProtocols, IpRanges: []string{anyIPv4}},
+		}},
+		{Id: "i-2", SecurityGroupIds: []string{"sg-wide"}},
+	}}
+
+	groups := ScorePermissiveRules(inv, NetworkClassifier{}, 30)
+	if len(groups) != 1 || groups[0].GroupId != "sg-wide" {
+		t.Fatalf("expected only sg-wide, got %+v", groups)
+	}
+
+	wide := groups[0]
+	if wide.Score != 64 || len(wide.Rules) != 2 || wide.Rules[0].Direction != DirectionIngress || wide.Rules[1].Score != 50 {
+		t.Errorf("expected the default egress rule below the wide ingress one, got %+v", wide)
+	}
+
+	if len(wide.InstanceIds) != 2 {
+		t.Errorf("expected both instances using sg-wide, got %v", wide.InstanceIds)
+	}
+}
@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"fmt"
+	"slices"
+)
+
+const maxPort int32 = 65535
+
+type PortRange struct {
+	From int32
+	To   int32
+}
+
+var allPorts = PortRange{From: 0, To: maxPort}
+
+func (p PortRange) String() string {
+	if p.From == p.To {
+		return fmt.Sprintf("%d", p.From)
+	}
+
+	return fmt.Sprintf("%d-%d", p.From, p.To)
+}
+
+// mergePortRanges sorts the ranges and joins the ones that overlap or are contiguous
+func mergePortRanges(ranges []PortRange) []PortRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b PortRange) int {
+		return int(a.From - b.From)
+	})
+
+	merged := []PortRange{sorted[0]}
+	for _, current := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if current.From <= last.To+1 {
+			last.To = max(last.To, current.To)
+			continue
+		}
+
+		merged = append(merged, current)
+	}
+
+	return merged
+}
+
+// intersectPortRanges returns the ports present in both lists of merged ranges
+func intersectPortRanges(a, b []PortRange) []PortRange {
+	intersection := make([]PortRange, 0, len(a))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		from, to := max(a[i].From, b[j].From), min(a[i].To, b[j].To)
+		if from <= to {
+			intersection = append(intersection, PortRange{From: from, To: to})
+		}
+
+		if a[i].To < b[j].To {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return intersection
+}
+
+// subtractPortRange removes the ports of r from the merged ranges
+func subtractPortRange(ranges []PortRange, r PortRange) []PortRange {
+	remaining := make([]PortRange, 0, len(ranges)+1)
+	for _, current := range ranges {
+		if r.To < current.From || r.From > current.To {
+			remaining = append(remaining, current)
+			continue
+		}
+
+		if current.From < r.From {
+			remaining = append(remaining, PortRange{From: current.From, To: r.From - 1})
+		}
+
+		if current.To > r.To {
+			remaining = append(remaining, PortRange{From: r.To + 1, To: current.To})
+		}
+	}
+
+	return remaining
+}
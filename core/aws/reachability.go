@@ -0,0 +1,484 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+const (
+	ReachabilityReachable = "reachable"
+	// ReachabilityPartial means only part of the source addresses, or of the ports clients
+	// pick for return traffic, get through
+	ReachabilityPartial     = "partial"
+	ReachabilityUnreachable = "unreachable"
+	// ReachabilityUnknown means some data needed to tell is missing, e.g. a network ACL
+	ReachabilityUnknown = "unknown"
+)
+
+const (
+	HopAllow   = "allow"
+	HopPartial = "partial"
+	HopDeny    = "deny"
+	HopUnknown = "unknown"
+)
+
+const (
+	HopPublicAddress   = "public-address"
+	HopRouteTable      = "route-table"
+	HopInternetGateway = "internet-gateway"
+	HopNetworkAcl      = "network-acl"
+	HopSecurityGroup   = "security-group"
+)
+
+const (
+	SourceInternet = "internet"
+	SourceCidr     = "cidr"
+	SourceInstance = "instance"
+)
+
+// Clients pick the port of the return traffic from their ephemeral range: 32768-60999 on
+// Linux, 49152-65535 on Windows, 1024-65535 for NAT gateways and load balancers. Network ACLs
+// are checked against all of them
+var ephemeralPorts = PortRange{From: 1024, To: maxPort}
+
+// ReachabilitySource is where traffic comes from. Only IPv4 is supported
+type ReachabilitySource struct {
+	Type       string
+	Prefix     netip.Prefix
+	InstanceId string
+}
+
+// ParseReachabilitySource accepts "internet", a CIDR, an address or an instance id
+func ParseReachabilitySource(from string) (ReachabilitySource, error) {
+	if from == SourceInternet {
+		return ReachabilitySource{Type: SourceInternet, Prefix: netip.MustParsePrefix(anyIPv4)}, nil
+	}
+
+	if strings.HasPrefix(from, "i-") {
+		return ReachabilitySource{Type: SourceInstance, InstanceId: from}, nil
+	}
+
+	prefix, valid := parsePrefix(from)
+	if !valid || !prefix.Addr().Is4() {
+		return ReachabilitySource{}, fmt.Errorf("invalid source %q, expected internet, an IPv4 CIDR or an instance id", from)
+	}
+
+	return ReachabilitySource{Type: SourceCidr, Prefix: prefix}, nil
+}
+
+func (s ReachabilitySource) String() string {
+	switch s.Type {
+	case SourceInternet:
+		return SourceInternet
+	case SourceInstance:
+		return s.InstanceId
+	default:
+		return s.Prefix.String()
+	}
+}
+
+// ReachabilityHop is a piece of evidence, one of the things traffic goes through on its way
+type ReachabilityHop struct {
+	Component  string
+	ResourceId string
+	Status     string
+	Detail     string
+}
+
+type ReachabilityVerdict struct {
+	InstanceId string
+	Source     string
+	Protocol   string
+	Port       int32
+	Status     string
+	Confidence string
+	Hops       []ReachabilityHop
+}
+
+// AnalyzeReachability tells, for every instance, whether the source can open a connection to
+// the port. Route tables, internet gateways, network ACLs in both directions and security
+// groups are all checked, and all of them are reported as evidence, even after one denied
+// the traffic.
+func AnalyzeReachability(inv Inventory, source ReachabilitySource, protocol string, port int32) ([]ReachabilityVerdict, error) {
+	network := newNetworkIndex(inv)
+
+	var srcInstance *Ec2Instance
+	if source.Type == SourceInstance {
+		inst, exists := network.instances[source.InstanceId]
+		if !exists {
+			return nil, fmt.Errorf("unknown instance %s", source.InstanceId)
+		}
+
+		srcInstance = &inst
+	}
+
+	verdicts := make([]ReachabilityVerdict, 0, len(inv.Instances))
+	for _, dst := range inv.Instances {
+		if srcInstance != nil && srcInstance.Id == dst.Id {
+			continue
+		}
+
+		var hops []ReachabilityHop
+		if srcInstance != nil {
+			hops = network.instanceHops(*srcInstance, dst, protocol, port)
+		} else {
+			hops = network.externalHops(source.Prefix, dst, protocol, port)
+		}
+
+		verdicts = append(verdicts, ReachabilityVerdict{
+			InstanceId: dst.Id,
+			Source:     source.String(),
+			Protocol:   protocol,
+			Port:       port,
+			Status:     verdictStatus(hops),
+			Confidence: verdictConfidence(verdictStatus(hops)),
+			Hops:       hops,
+		})
+	}
+
+	return verdicts, nil
+}
+
+func verdictStatus(hops []ReachabilityHop) string {
+	statuses := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		statuses = append(statuses, hop.Status)
+	}
+
+	switch {
+	case slices.Contains(statuses, HopDeny):
+		return ReachabilityUnreachable
+	case slices.Contains(statuses, HopUnknown):
+		return ReachabilityUnknown
+	case slices.Contains(statuses, HopPartial):
+		return ReachabilityPartial
+	default:
+		return ReachabilityReachable
+	}
+}
+
+// externalHops checks traffic coming from outside the instances we know, either from the
+// internet through an internet gateway or from networks routed to the VPC, e.g. over VPN
+func (n networkIndex) externalHops(src netip.Prefix, dst Ec2Instance, protocol string, port int32) []ReachabilityHop {
+	hops := make([]ReachabilityHop, 0, 5)
+
+	// The route back to the source tells how the source gets in, routing is symmetric
+	routeHop, target := n.routeHop(dst, src)
+	hops = append(hops, routeHop)
+
+	if strings.HasPrefix(target, "igw-") {
+		hops = append(hops, n.internetGatewayHop(target, dst.VPC))
+
+		if ptr.IsEmpty(dst.PublicIP) {
+			hops = append(hops, ReachabilityHop{
+				Component:  HopPublicAddress,
+				ResourceId: dst.Id,
+				Status:     HopDeny,
+				Detail:     "instance has no public address",
+			})
+		} else {
+			hops = append(hops, ReachabilityHop{
+				Component:  HopPublicAddress,
+				ResourceId: dst.Id,
+				Status:     HopAllow,
+				Detail:     "instance has public address " + *dst.PublicIP,
+			})
+		}
+	}
+
+	hops = append(hops, n.networkAclHop(dst, false, protocol, PortRange{From: port, To: port}, src))
+	hops = append(hops, n.networkAclHop(dst, true, protocol, ephemeralPorts, src))
+	hops = append(hops, securityGroupHop(dst, false, protocol, port, src, nil))
+
+	return hops
+}
+
+// instanceHops checks traffic between two instances through their private addresses.
+// Network ACLs only apply when traffic leaves or enters a subnet
+func (n networkIndex) instanceHops(src, dst Ec2Instance, protocol string, port int32) []ReachabilityHop {
+	hops := make([]ReachabilityHop, 0, 8)
+
+	srcPrefix, srcValid := parsePrefix(src.PrivateIP)
+	dstPrefix, dstValid := parsePrefix(dst.PrivateIP)
+	if !srcValid || !dstValid {
+		return []ReachabilityHop{{
+			Component:  HopRouteTable,
+			ResourceId: dst.Id,
+			Status:     HopUnknown,
+			Detail:     "missing private address",
+		}}
+	}
+
+	forward, _ := n.routeHop(src, dstPrefix)
+	back, _ := n.routeHop(dst, srcPrefix)
+	hops = append(hops, forward, back)
+
+	if src.SubnetId != dst.SubnetId {
+		hops = append(hops,
+			n.networkAclHop(src, true, protocol, PortRange{From: port, To: port}, dstPrefix),
+			n.networkAclHop(dst, false, protocol, PortRange{From: port, To: port}, srcPrefix),
+			n.networkAclHop(dst, true, protocol, ephemeralPorts, srcPrefix),
+			n.networkAclHop(src, false, protocol, ephemeralPorts, dstPrefix),
+		)
+	}
+
+	hops = append(hops,
+		securityGroupHop(src, true, protocol, port, dstPrefix, dst.SecurityGroupIds),
+		securityGroupHop(dst, false, protocol, port, srcPrefix, src.SecurityGroupIds),
+	)
+
+	return hops
+}
+
+// routeHop finds the most specific route of the instance subnet towards the peer, and
+// returns the target of that route
+func (n networkIndex) routeHop(inst Ec2Instance, peer netip.Prefix) (ReachabilityHop, string) {
+	hop := ReachabilityHop{Component: HopRouteTable, ResourceId: inst.SubnetId}
+
+	table, exists := n.routeTable(inst.SubnetId, inst.VPC)
+	if !exists {
+		hop.Status = HopUnknown
+		hop.Detail = fmt.Sprintf("no route table known for subnet %s of %s", inst.SubnetId, inst.Id)
+		if n.missing[DataRouteTables] {
+			hop.Detail = "route tables couldn't be fetched"
+		}
+
+		return hop, ""
+	}
+
+	hop.ResourceId = table.Id
+
+	var best *Route
+	bestBits := -1
+	prefixListRoutes := false
+	for idx, route := range table.Routes {
+		destination, isCidr := parsePrefix(route.Destination)
+		if !isCidr {
+			prefixListRoutes = true
+			continue
+		}
+
+		if prefixContains(destination, peer) && destination.Bits() > bestBits {
+			best = &table.Routes[idx]
+			bestBits = destination.Bits()
+		}
+	}
+
+	switch {
+	case best == nil && prefixListRoutes:
+		hop.Status = HopUnknown
+		hop.Detail = fmt.Sprintf("no route to %s, prefix list routes are not resolved", peer)
+		return hop, ""
+	case best == nil:
+		hop.Status = HopDeny
+		hop.Detail = fmt.Sprintf("no route to %s", peer)
+		return hop, ""
+	case best.State == routeStateBlackhole:
+		hop.Status = HopDeny
+		hop.Detail = fmt.Sprintf("route %s -> %s is a blackhole", best.Destination, best.Target)
+		return hop, best.Target
+	}
+
+	hop.Detail = fmt.Sprintf("route %s -> %s", best.Destination, best.Target)
+
+	switch {
+	case best.Target == routeTargetLocal, strings.HasPrefix(best.Target, "igw-"):
+		hop.Status = HopAllow
+	case strings.HasPrefix(best.Target, "pcx-"), strings.HasPrefix(best.Target, "tgw-"), strings.HasPrefix(best.Target, "vgw-"):
+		hop.Status = HopAllow
+		hop.Detail += ", the other side of the connection is not checked"
+	case strings.HasPrefix(best.Target, "nat-"):
+		hop.Status = HopDeny
+		hop.Detail += ", NAT gateways don't accept inbound connections"
+	default:
+		// Network appliances, e.g. firewalls behind an interface, decide on their own
+		hop.Status = HopUnknown
+		hop.Detail += ", traffic goes through an appliance"
+	}
+
+	return hop, best.Target
+}
+
+func (n networkIndex) internetGatewayHop(gatewayId, vpc string) ReachabilityHop {
+	hop := ReachabilityHop{Component: HopInternetGateway, ResourceId: gatewayId}
+
+	gateway, exists := n.internetGateways[gatewayId]
+	switch {
+	case !exists:
+		hop.Status = HopUnknown
+		hop.Detail = "internet gateway not found"
+		if n.missing[DataInternetGateways] {
+			hop.Detail = "internet gateways couldn't be fetched"
+		}
+	case !slices.Contains(gateway.VPCs, vpc):
+		hop.Status = HopDeny
+		hop.Detail = "internet gateway is not attached to " + vpc
+	default:
+		hop.Status = HopAllow
+		hop.Detail = "internet gateway is attached to " + vpc
+	}
+
+	return hop
+}
+
+// networkAclHop evaluates the network ACL of the instance subnet for traffic to or from the
+// peer on the ports. Network ACLs are stateless, so return traffic is checked separately by
+// callers
+func (n networkIndex) networkAclHop(inst Ec2Instance, egress bool, protocol string, ports PortRange, peer netip.Prefix) ReachabilityHop {
+	direction, preposition := "inbound", "from"
+	if egress {
+		direction, preposition = "outbound", "to"
+	}
+
+	hop := ReachabilityHop{Component: HopNetworkAcl, ResourceId: inst.SubnetId}
+
+	acl, exists := n.networkAcl(inst.SubnetId)
+	if !exists {
+		hop.Status = HopUnknown
+		hop.Detail = fmt.Sprintf("no network ACL known for subnet %s of %s", inst.SubnetId, inst.Id)
+		if n.missing[DataNetworkAcls] {
+			hop.Detail = "network ACLs couldn't be fetched"
+		}
+
+		return hop
+	}
+
+	hop.ResourceId = acl.Id
+	hop.Status, hop.Detail = evaluateNetworkAclPorts(acl, egress, protocol, ports, peer)
+	hop.Detail = fmt.Sprintf("%s %s %s %s %s: %s", direction, protocol, ports, preposition, peer, hop.Detail)
+
+	return hop
+}
+
+// evaluateNetworkAclPorts evaluates every segment of ports entries decide alike. The ports
+// are allowed or denied when all segments are, and partial otherwise
+func evaluateNetworkAclPorts(acl NetworkAcl, egress bool, protocol string, ports PortRange, peer netip.Prefix) (string, string) {
+	var status, detail string
+	segments := acl.portSegments(egress, ports)
+	allowed := make([]PortRange, 0, len(segments))
+	for _, segment := range segments {
+		segmentStatus, segmentDetail := evaluateNetworkAcl(acl, egress, protocol, segment.From, peer)
+		if segmentStatus != HopDeny {
+			allowed = append(allowed, segment)
+		}
+
+		switch {
+		case status == "":
+			status, detail = segmentStatus, segmentDetail
+		case status != segmentStatus:
+			status = HopPartial
+		}
+	}
+
+	switch {
+	case status != HopPartial || len(segments) == 1:
+		return status, detail
+	case len(allowed) < len(segments):
+		merged := mergePortRanges(allowed)
+		allowedPorts := make([]string, 0, len(merged))
+		for _, portRange := range merged {
+			allowedPorts = append(allowedPorts, portRange.String())
+		}
+
+		return HopPartial, "only ports " + strings.Join(allowedPorts, ",") + " are allowed"
+	default:
+		return HopPartial, "only part of the addresses are allowed on some ports"
+	}
+}
+
+// evaluateNetworkAcl goes through the entries in order. Entries that only cover part of
+// the peer addresses make the result partial, as some addresses are decided before others
+func evaluateNetworkAcl(acl NetworkAcl, egress bool, protocol string, port int32, peer netip.Prefix) (string, string) {
+	partlyDenied, partlyAllowed := false, false
+
+	for _, entry := range acl.sortedEntries(egress) {
+		if !entry.covers(protocol, port) {
+			continue
+		}
+
+		cidr, valid := parsePrefix(entry.Cidr)
+		if !valid || cidr.Addr().Is4() != peer.Addr().Is4() {
+			continue
+		}
+
+		switch {
+		case prefixContains(cidr, peer) && entry.Allow && partlyDenied:
+			return HopPartial, fmt.Sprintf("rule %d allows %s, earlier rules deny part of it", entry.RuleNumber, cidr)
+		case prefixContains(cidr, peer) && entry.Allow:
+			return HopAllow, fmt.Sprintf("rule %d allows %s", entry.RuleNumber, cidr)
+		case prefixContains(cidr, peer) && partlyAllowed:
+			return HopPartial, fmt.Sprintf("rule %d denies %s, earlier rules allow part of it", entry.RuleNumber, cidr)
+		case prefixContains(cidr, peer):
+			return HopDeny, fmt.Sprintf("rule %d denies %s", entry.RuleNumber, cidr)
+		case cidr.Overlaps(peer) && entry.Allow:
+			partlyAllowed = true
+		case cidr.Overlaps(peer):
+			partlyDenied = true
+		}
+	}
+
+	if partlyAllowed {
+		return HopPartial, "only part of the addresses are allowed"
+	}
+
+	return HopDeny, "no rule allows it"
+}
+
+// securityGroupHop checks the rules of the instance security groups. Security groups are
+// stateful, so return traffic is always allowed. Peer groups are the security groups of the
+// peer instance, if any, which rules can reference
+func securityGroupHop(inst Ec2Instance, egress bool, protocol string, port int32, peer netip.Prefix, peerGroups []string) ReachabilityHop {
+	rules, direction, preposition := inst.IngressSecRules, "ingress", "from"
+	if egress {
+		rules, direction, preposition = inst.EgressSecRules, "egress", "to"
+	}
+
+	hop := ReachabilityHop{
+		Component:  HopSecurityGroup,
+		ResourceId: strings.Join(inst.SecurityGroupIds, ","),
+		Status:     HopDeny,
+		Detail:     fmt.Sprintf("no %s rule allows %s %d %s %s", direction, protocol, port, preposition, peer),
+	}
+
+	for _, rule := range rules {
+		if !rule.allows(protocol, port) {
+			continue
+		}
+
+		if len(rule.PrefixListIds) > 0 && hop.Status == HopDeny {
+			hop.Status = HopUnknown
+			hop.Detail = fmt.Sprintf("%s rule %s %d-%d allows prefix lists %s, which are not resolved", direction, rule.IpProtocol, rule.FromPort, rule.ToPort, strings.Join(rule.PrefixListIds, ","))
+		}
+
+		for _, groupId := range rule.SourceGroupIds {
+			if slices.Contains(peerGroups, groupId) {
+				hop.Status = HopAllow
+				hop.Detail = fmt.Sprintf("%s rule %s %d-%d allows security group %s", direction, rule.IpProtocol, rule.FromPort, rule.ToPort, groupId)
+				return hop
+			}
+		}
+
+		for _, ipRange := range rule.IpRanges {
+			cidr, valid := parsePrefix(ipRange)
+			if !valid {
+				continue
+			}
+
+			if prefixContains(cidr, peer) {
+				hop.Status = HopAllow
+				hop.Detail = fmt.Sprintf("%s rule %s %d-%d allows %s", direction, rule.IpProtocol, rule.FromPort, rule.ToPort, ipRange)
+				return hop
+			}
+
+			if cidr.Overlaps(peer) {
+				hop.Status = HopPartial
+				hop.Detail = fmt.Sprintf("%s rule %s %d-%d only allows %s", direction, rule.IpProtocol, rule.FromPort, rule.ToPort, ipRange)
+			}
+		}
+	}
+
+	return hop
+}
@@ -0,0 +1,196 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func reachabilityInventory() Inventory {
+	allowAll := []NetworkAclEntry{
+		{RuleNumber: 100, Protocol: allProtocols, Cidr: anyIPv4, Allow: true},
+		{RuleNumber: 100, Egress: true, Protocol: allProtocols, Cidr: anyIPv4, Allow: true},
+		{RuleNumber: 32767, Protocol: allProtocols, Cidr: anyIPv4},
+		{RuleNumber: 32767, Egress: true, Protocol: allProtocols, Cidr: anyIPv4},
+	}
+
+	sshFromAnywhere := []Ec2SecGroupRule{{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{anyIPv4}}}
+
+	return Inventory{
+		Instances: []Ec2Instance{
+			{Id: "i-public", VPC: "vpc-1", SubnetId: "subnet-public", PrivateIP: "10.0.0.10", PublicIP: ptr.Ref("3.3.3.3"), IngressSecRules: sshFromAnywhere},
+			{Id: "i-private", VPC: "vpc-1", SubnetId: "subnet-private", PrivateIP: "10.0.1.10", IngressSecRules: sshFromAnywhere},
+			{Id: "i-office", VPC: "vpc-1", SubnetId: "subnet-public", PrivateIP: "10.0.0.11", PublicIP: ptr.Ref("3.3.3.4"), IngressSecRules: []Ec2SecGroupRule{
+				{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"203.0.113.0/24"}},
+			}},
+			{Id: "i-denied", VPC: "vpc-1", SubnetId: "subnet-denied", PrivateIP: "10.0.2.10", PublicIP: ptr.Ref("3.3.3.5"), IngressSecRules: sshFromAnywhere},
+			{Id: "i-unknown", VPC: "vpc-1", SubnetId: "subnet-unknown", PrivateIP: "10.0.3.10", PublicIP: ptr.Ref("3.3.3.6"), IngressSecRules: sshFromAnywhere},
+		},
+		RouteTables: []RouteTable{
+			{Id: "rtb-public", VPC: "vpc-1", Main: true, Routes: []Route{
+				{Destination: "10.0.0.0/16", Target: routeTargetLocal},
+				{Destination: anyIPv4, Target: "igw-1"},
+			}},
+			{Id: "rtb-private", VPC: "vpc-1", SubnetIds: []string{"subnet-private"}, Routes: []Route{
+				{Destination: "10.0.0.0/16", Target: routeTargetLocal},
+				{Destination: anyIPv4, Target: "nat-1"},
+			}},
+		},
+		NetworkAcls: []NetworkAcl{
+			{Id: "acl-default", VPC: "vpc-1", SubnetIds: []string{"subnet-public", "subnet-private"}, Entries: allowAll},
+			{Id: "acl-deny-ssh", VPC: "vpc-1", SubnetIds: []string{"subnet-denied"}, Entries: append([]NetworkAclEntry{
+				{RuleNumber: 10, Protocol: "6", FromPort: 22, ToPort: 22, Cidr: anyIPv4},
+			}, allowAll...)},
+		},
+		InternetGateways: []InternetGateway{{Id: "igw-1", VPCs: []string{"vpc-1"}}},
+	}
+}
+
+func TestAnalyzeReachabilityFromInternet(t *testing.T) {
+	expected := map[string]string{
+		"i-public":  ReachabilityReachable,
+		"i-private": ReachabilityUnreachable,
+		"i-office":  ReachabilityPartial,
+		"i-denied":  ReachabilityUnreachable,
+		"i-unknown": ReachabilityUnknown,
+	}
+
+	source, err := ParseReachabilitySource("internet")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	verdicts, err := AnalyzeReachability(reachabilityInventory(), source, "tcp", 22)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	for _, verdict := range verdicts {
+		if verdict.Status != expected[verdict.InstanceId] {
+			t.Errorf("%s: expected %s, got %s\nHops: %+v", verdict.InstanceId, expected[verdict.InstanceId], verdict.Status, verdict.Hops)
+		}
+	}
+}
+
+func TestReturnTrafficEphemeralPorts(t *testing.T) {
+	returnPorts := func(from, to int32) []NetworkAclEntry {
+		return []NetworkAclEntry{
+			{RuleNumber: 100, Protocol: allProtocols, Cidr: anyIPv4, Allow: true},
+			{RuleNumber: 100, Egress: true, Protocol: "6", FromPort: from, ToPort: to, Cidr: anyIPv4, Allow: true},
+		}
+	}
+
+	inv := reachabilityInventory()
+	expected := map[string]string{
+		"i-public": ReachabilityReachable,
+		"i-linux":  ReachabilityPartial,
+		"i-low":    ReachabilityPartial,
+		"i-http":   ReachabilityUnreachable,
+	}
+
+	for _, subnet := range []struct {
+		instanceId string
+		address    string
+		ports      PortRange
+	}{
+		{"i-linux", "10.0.4.10", PortRange{From: 32768, To: 49151}},
+		{"i-low", "10.0.4.11", PortRange{From: 1024, To: 49151}},
+		{"i-http", "10.0.4.12", PortRange{From: 80, To: 80}},
+	} {
+		subnetId := "subnet-" + subnet.instanceId
+		inv.Instances = append(inv.Instances, Ec2Instance{Id: subnet.instanceId, VPC: "vpc-1", SubnetId: subnetId, PrivateIP: subnet.address, PublicIP: ptr.Ref("3.3.4.1"), IngressSecRules: inv.Instances[0].IngressSecRules})
+		inv.NetworkAcls = append(inv.NetworkAcls, NetworkAcl{Id: "acl-" + subnet.instanceId, VPC: "vpc-1", SubnetIds: []string{subnetId}, Entries: returnPorts(subnet.ports.From, subnet.ports.To)})
+	}
+
+	verdicts, err := AnalyzeReachability(inv, ReachabilitySource{Type: SourceInternet, Prefix: netip.MustParsePrefix(anyIPv4)}, "tcp", 22)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	for _, verdict := range verdicts {
+		status, checked := expected[verdict.InstanceId]
+		if checked && verdict.Status != status {
+			t.Errorf("%s: expected %s, got %s\nHops: %+v", verdict.InstanceId, status, verdict.Status, verdict.Hops)
+		}
+	}
+}
+
+func TestAnalyzeReachabilityFromInstance(t *testing.T) {
+	expected := map[string]string{
+		"i-private": ReachabilityReachable,
+		"i-office":  ReachabilityUnreachable,
+		"i-denied":  ReachabilityUnreachable,
+	}
+
+	source, err := ParseReachabilitySource("i-public")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	inv := reachabilityInventory()
+	inv.Instances[0].EgressSecRules = []Ec2SecGroupRule{{IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}}
+
+	verdicts, err := AnalyzeReachability(inv, source, "tcp", 22)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	for _, verdict := range verdicts {
+		status, checked := expected[verdict.InstanceId]
+		if checked && verdict.Status != status {
+			t.Errorf("%s: expected %s, got %s\nHops: %+v", verdict.InstanceId, status, verdict.Status, verdict.Hops)
+		}
+	}
+}
+
+func TestComputeInstanceReaches(t *testing.T) {
+	inv := reachabilityInventory()
+	inv.Instances[0].SecurityGroupIds = []string{"sg-web"}
+	inv.Instances[0].EgressSecRules = []Ec2SecGroupRule{{IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}}
+	inv.Instances[1].IngressSecRules = []Ec2SecGroupRule{
+		{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"10.0.0.0/16"}},
+		{FromPort: 8000, ToPort: 8100, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web"}},
+	}
+	inv.NetworkAcls[0].Entries = append([]NetworkAclEntry{
+		{RuleNumber: 10, Protocol: "6", FromPort: 8050, ToPort: 8060, Cidr: "10.0.0.0/24"},
+	}, inv.NetworkAcls[0].Entries...)
+
+	var found *InstanceReach
+	for _, reach := range computeInstanceReaches(inv) {
+		if reach.From == "i-public" && reach.To == "i-private" && reach.Protocol == protocolTcp {
+			found = &reach
+		}
+	}
+
+	if found == nil {
+		t.Fatal("Expected i-public to reach i-private")
+	}
+
+	expected := []string{"22", "8000-8049", "8061-8100"}
+	if !slices.Equal(found.Ports(), expected) || found.Status != ReachabilityReachable {
+		t.Errorf("Unexpected reach %+v, expected ports %v", *found, expected)
+	}
+}
+
+func TestComputeInstanceReachesPartialReturn(t *testing.T) {
+	inv := reachabilityInventory()
+	inv.Instances[0].EgressSecRules = []Ec2SecGroupRule{{IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}}
+	inv.NetworkAcls[0].SubnetIds = []string{"subnet-public"}
+	inv.NetworkAcls = append(inv.NetworkAcls, NetworkAcl{Id: "acl-private", VPC: "vpc-1", SubnetIds: []string{"subnet-private"}, Entries: []NetworkAclEntry{
+		{RuleNumber: 100, Protocol: allProtocols, Cidr: anyIPv4, Allow: true},
+		{RuleNumber: 100, Egress: true, Protocol: "6", FromPort: 1024, ToPort: 49151, Cidr: anyIPv4, Allow: true},
+	}})
+
+	for _, reach := range computeInstanceReaches(inv) {
+		if reach.From == "i-public" && reach.To == "i-private" && reach.Protocol == protocolTcp {
+			if reach.Status != ReachabilityPartial || !slices.Equal(reach.Ports(), []string{"22"}) {
+				t.Errorf("Expected a partial reach on 22, got %+v", reach)
+			}
+
+			return
+		}
+	}
+
+	t.Error("Expected i-public to reach i-private, return traffic is allowed on part of the ephemeral ports")
+}
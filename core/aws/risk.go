@@ -0,0 +1,348 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Risk factors, each one is worth from 0 to 1 before its weight
+const (
+	RiskExposure      = "exposure"
+	RiskServices      = "services"
+	RiskIamPrivilege  = "iam-privilege"
+	RiskImdsV1        = "imdsv1"
+	RiskPublicAmi     = "public-ami"
+	RiskSharedSshKey  = "shared-ssh-key"
+	RiskCriticalReach = "critical-reach"
+)
+
+var riskFactors = []string{RiskExposure, RiskServices, RiskIamPrivilege, RiskImdsV1, RiskPublicAmi, RiskSharedSshKey, RiskCriticalReach}
+
+// How exposed an instance is counts the most, the rest tells how bad a compromise would be
+var defaultRiskWeights = RiskWeights{
+	RiskExposure:      30,
+	RiskServices:      20,
+	RiskIamPrivilege:  15,
+	RiskImdsV1:        10,
+	RiskPublicAmi:     5,
+	RiskSharedSshKey:  5,
+	RiskCriticalReach: 15,
+}
+
+var severityRisk = map[string]float64{
+	SeverityCritical: 1,
+	SeverityHigh:     0.7,
+	SeverityMedium:   0.4,
+	SeverityLow:      0.2,
+}
+
+var privilegeRisk = map[string]float64{
+	PrivilegeAdmin:    1,
+	PrivilegeHigh:     0.7,
+	PrivilegeUnknown:  0.5,
+	PrivilegeStandard: 0.2,
+}
+
+// RiskWeights is how much every factor counts in the score, relative to the others
+type RiskWeights map[string]float64
+
+// NewRiskWeights overrides the default weights with the configured ones
+func NewRiskWeights(cfg map[string]float64) (RiskWeights, error) {
+	weights := make(RiskWeights, len(defaultRiskWeights))
+	for factor, weight := range defaultRiskWeights {
+		weights[factor] = weight
+	}
+
+	for factor, weight := range cfg {
+		if !slices.Contains(riskFactors, factor) {
+			return nil, fmt.Errorf("unknown risk factor %q, expected one of %s", factor, strings.Join(riskFactors, ", "))
+		}
+
+		if weight < 0 {
+			return nil, fmt.Errorf("risk factor %s has a negative weight", factor)
+		}
+
+		weights[factor] = weight
+	}
+
+	if weights.total() == 0 {
+		return nil, fmt.Errorf("every risk factor has a weight of 0")
+	}
+
+	return weights, nil
+}
+
+func (w RiskWeights) total() float64 {
+	total := 0.0
+	for _, weight := range w {
+		total += weight
+	}
+
+	return total
+}
+
+// RiskFactor is one part of the score. Points are what it adds to the score out of 100
+type RiskFactor struct {
+	Name   string
+	Value  float64
+	Weight float64
+	Points float64
+	Reason string
+}
+
+type RiskScore struct {
+	InstanceId string
+	Score      int
+	Factors    []RiskFactor
+}
+
+// Breakdown lists the factors adding to the score, the biggest first
+func (r RiskScore) Breakdown() []string {
+	breakdown := make([]string, 0, len(r.Factors))
+	for _, factor := range r.Factors {
+		if factor.Points > 0 {
+			breakdown = append(breakdown, fmt.Sprintf("%s %.1f: %s", factor.Name, factor.Points, factor.Reason))
+		}
+	}
+
+	return breakdown
+}
+
+// ComputeRiskScores gives every instance a score from 0 to 100 to decide what to fix first. It's
+// the weighted average of how widely the instance is exposed and what an attacker gets from it
+func ComputeRiskScores(inv Inventory, exposures []InstanceExposure, reaches []InstanceReach, serviceExposures []ServiceExposure, networks NetworkClassifier, weights RiskWeights) []RiskScore {
+	statuses := make(map[string]string, len(exposures))
+	for _, exposure := range exposures {
+		statuses[exposure.InstanceId] = exposure.Status
+	}
+
+	services := make(map[string][]ServiceExposure, len(inv.Instances))
+	for _, exposure := range serviceExposures {
+		services[exposure.InstanceId] = append(services[exposure.InstanceId], exposure)
+	}
+
+	profiles := make(map[string]InstanceProfile, len(inv.InstanceProfiles))
+	for _, profile := range inv.InstanceProfiles {
+		profiles[profile.Arn] = profile
+	}
+
+	amis := make(map[string]Ami, len(inv.Amis))
+	for _, ami := range inv.Amis {
+		amis[ami.Id] = ami
+	}
+
+	keyPairs := make(map[string]int, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		if !ptr.IsEmpty(inst.SSHKeyPairName) {
+			keyPairs[*inst.SSHKeyPairName]++
+		}
+	}
+
+	criticalHops := criticalReachHops(inv, exposures, reaches)
+	total := weights.total()
+
+	scores := make([]RiskScore, 0, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		factors := []RiskFactor{
+			exposureRisk(inst, statuses[inst.Id], networks),
+			servicesRisk(services[inst.Id]),
+			iamRisk(inst, profiles, slices.Contains(inv.MissingData, DataInstanceProfiles)),
+			imdsRisk(inst),
+			amiRisk(inst, amis),
+			sharedKeyRisk(inst, keyPairs),
+			criticalReachRisk(criticalHops[inst.Id]),
+		}
+
+		score := RiskScore{InstanceId: inst.Id, Factors: factors}
+		points := 0.0
+		for idx := range factors {
+			factors[idx].Weight = weights[factors[idx].Name]
+			factors[idx].Points = math.Round(1000*factors[idx].Value*factors[idx].Weight/total) / 10
+			points += factors[idx].Value * factors[idx].Weight
+		}
+
+		score.Score = int(math.Round(100 * points / total))
+		slices.SortStableFunc(score.Factors, func(a, b RiskFactor) int {
+			return cmp.Compare(b.Points, a.Points)
+		})
+		scores = append(scores, score)
+	}
+
+	slices.SortFunc(scores, func(a, b RiskScore) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+
+		return strings.Compare(a.InstanceId, b.InstanceId)
+	})
+
+	return scores
+}
+
+// exposureRisk is the breadth of the sources reaching the instance: the internet, public
+// ranges we don't trust, partners or only our own networks
+func exposureRisk(inst Ec2Instance, status string, networks NetworkClassifier) RiskFactor {
+	factor := RiskFactor{Name: RiskExposure}
+
+	switch status {
+	case ExposureOpenToInternet:
+		factor.Value, factor.Reason = 1, "open to the internet"
+		return factor
+	case ExposureFrontDoor:
+		factor.Value, factor.Reason = 1, "served to the internet by front doors without WAF"
+		return factor
+	case ExposureFrontDoorWaf:
+		factor.Value, factor.Reason = 0.7, "served to the internet by front doors with WAF"
+		return factor
+	}
+
+	for _, rule := range inst.IngressSecRules {
+		if len(rule.SourceGroupIds) > 0 && factor.Value < 0.1 {
+			factor.Value, factor.Reason = 0.1, "reachable from internal networks"
+		}
+
+		for _, source := range networks.ClassifyAll(rule.IpRanges) {
+			switch {
+			case source.Class == ClassBroadUntrusted && inst.IsOpenToInternet() && factor.Value < 0.6:
+				factor.Value, factor.Reason = 0.6, "reachable from untrusted public range "+source.Range
+			case source.Class == ClassKnownPartner && factor.Value < 0.4:
+				factor.Value, factor.Reason = 0.4, "reachable from partner network "+source.Network
+			case (source.Class == ClassTrusted || source.Class == ClassInternal) && factor.Value < 0.1:
+				factor.Value, factor.Reason = 0.1, "reachable from internal networks"
+			}
+		}
+	}
+
+	return factor
+}
+
+// servicesRisk adds up the severity of the risky services reachable from beyond our networks
+func servicesRisk(exposures []ServiceExposure) RiskFactor {
+	factor := RiskFactor{Name: RiskServices}
+
+	names := make([]string, 0, len(exposures))
+	for _, exposure := range exposures {
+		classes := exposure.SourceClasses()
+		beyondInternal := exposure.OpenToInternet || slices.Contains(classes, ClassKnownPartner) ||
+			(exposure.PublicAddress && slices.Contains(classes, ClassBroadUntrusted))
+		if !beyondInternal {
+			continue
+		}
+
+		factor.Value += severityRisk[exposure.Severity]
+		names = append(names, exposure.Service)
+	}
+
+	factor.Value = min(1, factor.Value)
+	if len(names) > 0 {
+		factor.Reason = "exposes " + strings.Join(names, ", ")
+	}
+
+	return factor
+}
+
+func iamRisk(inst Ec2Instance, profiles map[string]InstanceProfile, profilesMissing bool) RiskFactor {
+	factor := RiskFactor{Name: RiskIamPrivilege}
+	if ptr.IsEmpty(inst.IamInstanceProfile) {
+		return factor
+	}
+
+	privilege := PrivilegeUnknown
+	if profile, known := profiles[*inst.IamInstanceProfile]; known && !profilesMissing {
+		privilege = profile.Privilege
+	}
+
+	factor.Value = privilegeRisk[privilege]
+	factor.Reason = fmt.Sprintf("instance profile %s has %s privilege", instanceProfileName(*inst.IamInstanceProfile), privilege)
+
+	return factor
+}
+
+func imdsRisk(inst Ec2Instance) RiskFactor {
+	factor := RiskFactor{Name: RiskImdsV1}
+	if inst.UsesIMDSv1() {
+		factor.Value, factor.Reason = 1, "IMDSv1 is enabled"
+	}
+
+	return factor
+}
+
+func amiRisk(inst Ec2Instance, amis map[string]Ami) RiskFactor {
+	factor := RiskFactor{Name: RiskPublicAmi}
+	if ami, known := amis[inst.ImageId]; known && ami.Public {
+		factor.Value, factor.Reason = 1, fmt.Sprintf("runs public AMI %s", ami.Id)
+	}
+
+	return factor
+}
+
+func sharedKeyRisk(inst Ec2Instance, keyPairs map[string]int) RiskFactor {
+	factor := RiskFactor{Name: RiskSharedSshKey}
+	if others := keyPairs[ptr.Deref(inst.SSHKeyPairName)] - 1; !ptr.IsEmpty(inst.SSHKeyPairName) && others > 0 {
+		factor.Value, factor.Reason = 1, fmt.Sprintf("SSH key pair %s is shared with %d other instances", *inst.SSHKeyPairName, others)
+	}
+
+	return factor
+}
+
+// criticalReachRisk is higher the fewer hops it takes to get to a critical instance
+func criticalReachRisk(hops int) RiskFactor {
+	factor := RiskFactor{Name: RiskCriticalReach}
+
+	switch {
+	case hops == 1:
+		factor.Value = 1
+	case hops == 2:
+		factor.Value = 0.6
+	case hops > 2:
+		factor.Value = 0.3
+	default:
+		return factor
+	}
+
+	factor.Reason = fmt.Sprintf("reaches a critical instance in %d hops", hops)
+	return factor
+}
+
+// criticalReachHops is, for every instance, the least number of attack graph hops to a critical
+// instance other than itself. Instances not reaching any aren't listed
+func criticalReachHops(inv Inventory, exposures []InstanceExposure, reaches []InstanceReach) map[string]int {
+	critical := make(map[string]bool, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		critical[inst.Id] = IsCriticalInstance(inst)
+	}
+
+	g := buildAttackGraph(inv, exposures, reaches)
+	hops := make(map[string]int, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		visited := map[string]bool{inst.Id: true}
+		frontier := []string{inst.Id}
+
+		for depth := 1; len(frontier) > 0 && hops[inst.Id] == 0; depth++ {
+			next := make([]string, 0, len(frontier))
+			for _, node := range frontier {
+				for _, edge := range g.edges[node] {
+					// Instance profiles give cloud permissions, not a way to the instances using them
+					if visited[edge.to] || edge.hop.Kind == AttackHopIamRole {
+						continue
+					}
+
+					if critical[edge.to] {
+						hops[inst.Id] = depth
+					}
+
+					visited[edge.to] = true
+					next = append(next, edge.to)
+				}
+			}
+
+			frontier = next
+		}
+	}
+
+	return hops
+}
@@ -0,0 +1,67 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"testing"
+)
+
+func TestPolicyPrivilege(t *testing.T) {
+	cases := map[string]string{
+		`{"Statement": {"Effect": "Allow", "Action": "*", "Resource": "*"}}`:                                           PrivilegeAdmin,
+		`{"Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "iam:PassRole"], "Resource": "*"}]}`:            PrivilegeHigh,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/*"}]}`:                  PrivilegeStandard,
+		`{"Statement": [{"Effect": "Deny", "Action": "*", "Resource": "*"}]}`:                                          PrivilegeStandard,
+		`%7B%22Statement%22%3A%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22*%22%2C%22Resource%22%3A%22*%22%7D%7D`: PrivilegeAdmin,
+	}
+
+	for document, expected := range cases {
+		if got := policyPrivilege(document); got != expected {
+			t.Errorf("%s: expected %s, got %s", document, expected, got)
+		}
+	}
+}
+
+func TestComputeRiskScores(t *testing.T) {
+	weights, err := NewRiskWeights(map[string]float64{RiskPublicAmi: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRiskWeights(map[string]float64{"unknown": 1}); err == nil {
+		t.Error("expected unknown factors to be rejected")
+	}
+
+	inv := Inventory{
+		Instances: []Ec2Instance{
+			{Id: "i-web", PublicIP: ptr.Ref("3.3.3.3"), MetadataHttpTokens: "optional", IamInstanceProfile: ptr.Ref("arn:aws:iam::1:instance-profile/web"),
+				IngressSecRules: []Ec2SecGroupRule{{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{anyIPv4}}}},
+			{Id: "i-db", MetadataHttpTokens: "required"},
+		},
+		InstanceProfiles: []InstanceProfile{{Arn: "arn:aws:iam::1:instance-profile/web", Privilege: PrivilegeAdmin}},
+	}
+
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+	exposures := computeInstanceExposures(inv, frontDoorGraph{})
+	scores := ComputeRiskScores(inv, exposures, computeInstanceReaches(inv), computeServiceExposures(inv, catalog, classifier), classifier, weights)
+
+	// exposure 30, ssh is high so services 0.7 * 20, admin 15 and IMDSv1 10 out of 95
+	if scores[0].InstanceId != "i-web" || scores[0].Score != 73 {
+		t.Errorf("expected i-web first with 73, got %+v", scores[0])
+	}
+
+	if scores[1].InstanceId != "i-db" || scores[1].Score != 0 || len(scores[1].Breakdown()) != 0 {
+		t.Errorf("expected i-db without risk, got %+v", scores[1])
+	}
+}
+
+func TestCriticalReachHopsIgnoreSharedProfiles(t *testing.T) {
+	inv := Inventory{Instances: []Ec2Instance{
+		{Id: "i-web", IamInstanceProfile: ptr.Ref("shared")},
+		{Id: "i-db", IamInstanceProfile: ptr.Ref("shared"), Tags: map[string]string{"critical": "true"}},
+	}}
+
+	if hops := criticalReachHops(inv, computeInstanceExposures(inv, frontDoorGraph{}), computeInstanceReaches(inv)); len(hops) != 0 {
+		t.Errorf("expected no instance reaching i-db through its instance profile, got %v", hops)
+	}
+}
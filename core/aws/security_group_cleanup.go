@@ -0,0 +1,142 @@
+package aws
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	IssueUnusedSecurityGroup  = "unused"
+	IssueStaleReference       = "stale-reference"
+	IssueDefaultGroupHasRules = "default-with-rules"
+)
+
+// SecurityGroupIssue is a row of the cleanup report
+type SecurityGroupIssue struct {
+	GroupId   string
+	GroupName string
+	VPC       string
+	Issue     string
+	Detail    string
+}
+
+// FindSecurityGroupIssues reports groups nothing uses, rules referencing groups that don't
+// exist in the region anymore and default groups that still have rules. Groups are used by
+// instances, load balancers, launch templates and network interfaces, which cover databases,
+// functions and every other service running in the VPC
+func FindSecurityGroupIssues(inv Inventory) []SecurityGroupIssue {
+	used := usedSecurityGroups(inv)
+	known := make(map[string]bool, len(inv.SecurityGroups))
+	for _, group := range inv.SecurityGroups {
+		known[group.Id] = true
+	}
+
+	referencedBy := make(map[string][]string, len(inv.SecurityGroups))
+	issues := make([]SecurityGroupIssue, 0, len(inv.SecurityGroups))
+
+	for _, group := range inv.SecurityGroups {
+		for direction, rules := range map[string][]Ec2SecGroupRule{DirectionIngress: group.IngressRules, DirectionEgress: group.EgressRules} {
+			for _, rule := range rules {
+				for _, groupId := range rule.SourceGroupIds {
+					if groupId != group.Id && !slices.Contains(referencedBy[groupId], group.Id) {
+						referencedBy[groupId] = append(referencedBy[groupId], group.Id)
+					}
+
+					if known[groupId] {
+						continue
+					}
+
+					issues = append(issues, SecurityGroupIssue{
+						GroupId:   group.Id,
+						GroupName: group.Name,
+						VPC:       group.VPC,
+						Issue:     IssueStaleReference,
+						Detail: fmt.Sprintf("%s rule %s %s references %s, which is deleted or in another account or region",
+							direction, rule.IpProtocol, rulePorts(rule), groupId),
+					})
+				}
+			}
+		}
+	}
+
+	for _, group := range inv.SecurityGroups {
+		switch {
+		case group.IsDefault() && group.HasRules():
+			issues = append(issues, SecurityGroupIssue{
+				GroupId:   group.Id,
+				GroupName: group.Name,
+				VPC:       group.VPC,
+				Issue:     IssueDefaultGroupHasRules,
+				Detail: fmt.Sprintf("%d ingress and %d egress rules, resources left without a group get them",
+					len(group.IngressRules), len(group.EgressRules)),
+			})
+		case !group.IsDefault() && !used[group.Id]:
+			detail := "not attached to any resource"
+			if referencing := referencedBy[group.Id]; len(referencing) > 0 {
+				slices.Sort(referencing)
+				detail += ", referenced by rules of " + strings.Join(referencing, ", ")
+			}
+
+			issues = append(issues, SecurityGroupIssue{
+				GroupId:   group.Id,
+				GroupName: group.Name,
+				VPC:       group.VPC,
+				Issue:     IssueUnusedSecurityGroup,
+				Detail:    detail,
+			})
+		}
+	}
+
+	slices.SortStableFunc(issues, func(a, b SecurityGroupIssue) int {
+		if a.GroupId != b.GroupId {
+			return strings.Compare(a.GroupId, b.GroupId)
+		}
+
+		if a.Issue != b.Issue {
+			return strings.Compare(a.Issue, b.Issue)
+		}
+
+		return strings.Compare(a.Detail, b.Detail)
+	})
+
+	return issues
+}
+
+func usedSecurityGroups(inv Inventory) map[string]bool {
+	members := securityGroupMembers(inv)
+	used := make(map[string]bool, len(members))
+	for groupId := range members {
+		used[groupId] = true
+	}
+
+	return used
+}
+
+// securityGroupMembers maps every security group to the assets using it
+func securityGroupMembers(inv Inventory) map[string][]AssetRef {
+	members := make(map[string][]AssetRef, len(inv.SecurityGroups))
+	add := func(assetType, assetId string, groupIds []string) {
+		for _, groupId := range groupIds {
+			members[groupId] = append(members[groupId], AssetRef{AssetType: assetType, AssetId: assetId})
+		}
+	}
+
+	for _, inst := range inv.Instances {
+		add(AssetEc2Instance, inst.Id, inst.SecurityGroupIds)
+	}
+
+	for _, eni := range inv.NetworkInterfaces {
+		add(AssetNetworkInterface, eni.Id, eni.SecurityGroupIds)
+	}
+
+	for _, lb := range inv.LoadBalancers {
+		add(AssetLoadBalancer, lb.Arn, lb.SecurityGroupIds)
+	}
+
+	for _, template := range inv.LaunchTemplates {
+		add(AssetLaunchTemplate, template.Id, template.SecurityGroupIds)
+	}
+
+	return members
+}
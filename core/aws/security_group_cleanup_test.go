@@ -0,0 +1,33 @@
+package aws
+
+import "testing"
+
+func TestFindSecurityGroupIssues(t *testing.T) {
+	inv := Inventory{
+		Instances:         []Ec2Instance{{Id: "i-1", SecurityGroupIds: []string{"sg-web"}}},
+		NetworkInterfaces: []NetworkInterface{{Id: "eni-db", SecurityGroupIds: []string{"sg-db"}}},
+		SecurityGroups: []SecurityGroup{
+			{Id: "sg-default", Name: defaultSecurityGroupName, EgressRules: []Ec2SecGroupRule{{IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}}},
+			{Id: "sg-web", Name: "web"},
+			{Id: "sg-db", Name: "db", IngressRules: []Ec2SecGroupRule{{FromPort: 5432, ToPort: 5432, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web", "sg-deleted"}}}},
+			{Id: "sg-old", Name: "old", IngressRules: []Ec2SecGroupRule{{FromPort: 22, ToPort: 22, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web"}}}},
+		},
+	}
+
+	expected := map[string]string{
+		"sg-db":      IssueStaleReference,
+		"sg-default": IssueDefaultGroupHasRules,
+		"sg-old":     IssueUnusedSecurityGroup,
+	}
+
+	issues := FindSecurityGroupIssues(inv)
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %+v", len(expected), issues)
+	}
+
+	for _, issue := range issues {
+		if expected[issue.GroupId] != issue.Issue {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+}
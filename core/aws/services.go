@@ -0,0 +1,258 @@
+package aws
+
+import (
+	"asset-relations/support/config"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RiskyService is a service that shouldn't be reachable from the internet, identified by the
+// ports it listens on
+type RiskyService struct {
+	Name     string
+	Protocol string
+	Ports    []int32
+	Severity string
+}
+
+// FindingType is raised on instances exposing the service, e.g. "ssh-open-to-internet"
+func (s RiskyService) FindingType() string {
+	return s.Name + "-open-to-internet"
+}
+
+// UntrustedFindingType is raised on instances exposing the service to public ranges outside
+// of the trusted networks, e.g. "ssh-open-to-untrusted"
+func (s RiskyService) UntrustedFindingType() string {
+	return s.Name + "-open-to-untrusted"
+}
+
+var defaultRiskyServices = []RiskyService{
+	{Name: "ssh", Protocol: protocolTcp, Ports: []int32{SSHPort}, Severity: SeverityHigh},
+	{Name: "rdp", Protocol: protocolTcp, Ports: []int32{RDPPort}, Severity: SeverityHigh},
+	{Name: "telnet", Protocol: protocolTcp, Ports: []int32{23}, Severity: SeverityCritical},
+	{Name: "ftp", Protocol: protocolTcp, Ports: []int32{21}, Severity: SeverityMedium},
+	{Name: "smb", Protocol: protocolTcp, Ports: []int32{445}, Severity: SeverityCritical},
+	{Name: "mssql", Protocol: protocolTcp, Ports: []int32{1433}, Severity: SeverityHigh},
+	{Name: "oracle", Protocol: protocolTcp, Ports: []int32{1521}, Severity: SeverityHigh},
+	{Name: "mysql", Protocol: protocolTcp, Ports: []int32{3306}, Severity: SeverityHigh},
+	{Name: "postgres", Protocol: protocolTcp, Ports: []int32{5432}, Severity: SeverityHigh},
+	{Name: "redis", Protocol: protocolTcp, Ports: []int32{6379}, Severity: SeverityCritical},
+	{Name: "mongodb", Protocol: protocolTcp, Ports: []int32{27017}, Severity: SeverityCritical},
+	{Name: "elasticsearch", Protocol: protocolTcp, Ports: []int32{9200, 9300}, Severity: SeverityCritical},
+	{Name: "memcached", Protocol: protocolTcp, Ports: []int32{11211}, Severity: SeverityHigh},
+	{Name: "docker", Protocol: protocolTcp, Ports: []int32{2375, 2376}, Severity: SeverityCritical},
+	{Name: "kubernetes-api", Protocol: protocolTcp, Ports: []int32{6443}, Severity: SeverityHigh},
+	{Name: "kubelet", Protocol: protocolTcp, Ports: []int32{10250}, Severity: SeverityCritical},
+	{Name: "etcd", Protocol: protocolTcp, Ports: []int32{2379, 2380}, Severity: SeverityCritical},
+	{Name: "vnc", Protocol: protocolTcp, Ports: []int32{5900}, Severity: SeverityHigh},
+}
+
+type ServiceCatalog []RiskyService
+
+// NewServiceCatalog builds the catalog from the configured services, or returns the
+// built-in one when none are configured. SSH is always in the catalog, as the SSH endpoints
+// rely on it
+func NewServiceCatalog(services []config.ServiceConfig) (ServiceCatalog, error) {
+	if len(services) == 0 {
+		return slices.Clone(defaultRiskyServices), nil
+	}
+
+	catalog := make(ServiceCatalog, 0, len(services))
+	for _, service := range services {
+		riskyService := RiskyService{
+			Name:     strings.ToLower(service.Name),
+			Protocol: strings.ToLower(service.Protocol),
+			Ports:    service.Ports,
+			Severity: service.Severity,
+		}
+
+		if err := riskyService.validate(); err != nil {
+			return nil, fmt.Errorf("invalid service %q: %w", service.Name, err)
+		}
+
+		if _, duplicated := catalog.Lookup(riskyService.Name); duplicated {
+			return nil, fmt.Errorf("duplicated service %q", service.Name)
+		}
+
+		catalog = append(catalog, riskyService)
+	}
+
+	if _, hasSSH := catalog.Lookup(defaultRiskyServices[0].Name); !hasSSH {
+		catalog = append(catalog, defaultRiskyServices[0])
+	}
+
+	return catalog, nil
+}
+
+func (s RiskyService) validate() error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if s.Protocol != protocolTcp && s.Protocol != protocolUdp {
+		return fmt.Errorf("protocol must be %s or %s", protocolTcp, protocolUdp)
+	}
+
+	if len(s.Ports) == 0 {
+		return errors.New("at least one port is required")
+	}
+
+	for _, port := range s.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+
+	if !slices.Contains(severities, s.Severity) {
+		return fmt.Errorf("severity must be one of %s", strings.Join(severities, ", "))
+	}
+
+	return nil
+}
+
+func (c ServiceCatalog) Lookup(name string) (RiskyService, bool) {
+	for _, service := range c {
+		if service.Name == name {
+			return service, true
+		}
+	}
+
+	return RiskyService{}, false
+}
+
+func (c ServiceCatalog) Names() []string {
+	names := make([]string, 0, len(c))
+	for _, service := range c {
+		names = append(names, service.Name)
+	}
+
+	return names
+}
+
+// ServiceExposure is a risky service an instance accepts connections to. It's open to the
+// internet when the instance has a public address and a rule allows any address
+type ServiceExposure struct {
+	InstanceId     string
+	Service        string
+	Protocol       string
+	Severity       string
+	Ports          []int32
+	IpRanges       []string
+	Sources        []SourceClass
+	SourceGroupIds []string
+	OpenToInternet bool
+	PublicAddress  bool
+}
+
+func (e ServiceExposure) SourceClasses() []string {
+	classes := make([]string, 0, len(e.Sources))
+	for _, source := range e.Sources {
+		if !slices.Contains(classes, source.Class) {
+			classes = append(classes, source.Class)
+		}
+	}
+
+	slices.Sort(classes)
+
+	return classes
+}
+
+// UntrustedRanges are the public ranges allowed that aren't in any configured network, the
+// whole internet excluded
+func (e ServiceExposure) UntrustedRanges() []string {
+	ranges := make([]string, 0, len(e.Sources))
+	for _, source := range e.Sources {
+		if source.Class == ClassBroadUntrusted {
+			ranges = append(ranges, source.Range)
+		}
+	}
+
+	return ranges
+}
+
+// TrustedNetworks are the names of the configured networks allowed
+func (e ServiceExposure) TrustedNetworks() []string {
+	networks := make([]string, 0, len(e.Sources))
+	for _, source := range e.Sources {
+		if source.Network != "" && !slices.Contains(networks, source.Network) {
+			networks = append(networks, source.Network)
+		}
+	}
+
+	slices.Sort(networks)
+
+	return networks
+}
+
+// computeServiceExposures merges every ingress rule allowing one of the service ports, as
+// instances often get the same port from several security groups
+func computeServiceExposures(inv Inventory, catalog ServiceCatalog, networks NetworkClassifier) []ServiceExposure {
+	exposures := make([]ServiceExposure, 0, len(inv.Instances))
+	for _, inst := range inv.Instances {
+		for _, service := range catalog {
+			exposure, exposed := serviceExposure(inst, service)
+			if exposed {
+				exposure.Sources = networks.ClassifyAll(exposure.IpRanges)
+				exposures = append(exposures, exposure)
+			}
+		}
+	}
+
+	return exposures
+}
+
+func serviceExposure(inst Ec2Instance, service RiskyService) (ServiceExposure, bool) {
+	exposure := ServiceExposure{
+		InstanceId: inst.Id,
+		Service:    service.Name,
+		Protocol:   service.Protocol,
+		Severity:   service.Severity,
+	}
+
+	for _, rule := range inst.IngressSecRules {
+		if !protocolMatches(rule.IpProtocol, service.Protocol) {
+			continue
+		}
+
+		covered := false
+		for _, port := range service.Ports {
+			if rule.coversPort(port) {
+				covered = true
+				if !slices.Contains(exposure.Ports, port) {
+					exposure.Ports = append(exposure.Ports, port)
+				}
+			}
+		}
+
+		if !covered {
+			continue
+		}
+
+		for _, ipRange := range rule.IpRanges {
+			if !slices.Contains(exposure.IpRanges, ipRange) {
+				exposure.IpRanges = append(exposure.IpRanges, ipRange)
+			}
+		}
+
+		for _, groupId := range rule.SourceGroupIds {
+			if !slices.Contains(exposure.SourceGroupIds, groupId) {
+				exposure.SourceGroupIds = append(exposure.SourceGroupIds, groupId)
+			}
+		}
+	}
+
+	if len(exposure.Ports) == 0 {
+		return exposure, false
+	}
+
+	slices.Sort(exposure.Ports)
+	slices.Sort(exposure.IpRanges)
+	slices.Sort(exposure.SourceGroupIds)
+	exposure.PublicAddress = inst.IsOpenToInternet()
+	exposure.OpenToInternet = exposure.PublicAddress &&
+		(slices.Contains(exposure.IpRanges, anyIPv4) || slices.Contains(exposure.IpRanges, anyIPv6))
+
+	return exposure, true
+}
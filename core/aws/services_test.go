@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"asset-relations/support/config"
+	"asset-relations/support/ptr"
+	"slices"
+	"testing"
+)
+
+func TestNewServiceCatalog(t *testing.T) {
+	catalog, err := NewServiceCatalog(nil)
+	if err != nil || len(catalog) != len(defaultRiskyServices) {
+		t.Fatalf("expected the built-in catalog, got %v and %v", catalog, err)
+	}
+
+	catalog, err = NewServiceCatalog([]config.ServiceConfig{
+		{Name: "Grafana", Protocol: "TCP", Ports: []int32{3000}, Severity: SeverityMedium},
+	})
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if !slices.Equal(catalog.Names(), []string{"grafana", "ssh"}) {
+		t.Errorf("expected grafana lowercased and ssh kept, got %v", catalog.Names())
+	}
+
+	invalid := map[string][]config.ServiceConfig{
+		"no name":    {{Protocol: "tcp", Ports: []int32{3000}, Severity: SeverityMedium}},
+		"protocol":   {{Name: "grafana", Protocol: "icmp", Ports: []int32{3000}, Severity: SeverityMedium}},
+		"no port":    {{Name: "grafana", Protocol: "tcp", Severity: SeverityMedium}},
+		"port zero":  {{Name: "grafana", Protocol: "tcp", Ports: []int32{0}, Severity: SeverityMedium}},
+		"port above": {{Name: "grafana", Protocol: "tcp", Ports: []int32{65536}, Severity: SeverityMedium}},
+		"severity":   {{Name: "grafana", Protocol: "tcp", Ports: []int32{3000}, Severity: "urgent"}},
+		"duplicated": {
+			{Name: "grafana", Protocol: "tcp", Ports: []int32{3000}, Severity: SeverityMedium},
+			{Name: "Grafana", Protocol: "tcp", Ports: []int32{3001}, Severity: SeverityMedium},
+		},
+	}
+
+	for name, services := range invalid {
+		if _, err := NewServiceCatalog(services); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestServiceExposureMergesRules(t *testing.T) {
+	elasticsearch, _ := ServiceCatalog(defaultRiskyServices).Lookup("elasticsearch")
+
+	inst := Ec2Instance{Id: "i-1", PublicIP: ptr.Ref("3.3.3.3"), IngressSecRules: []Ec2SecGroupRule{
+		{FromPort: 9200, ToPort: 9200, IpProtocol: "tcp", IpRanges: []string{"10.0.0.0/8"}},
+		{FromPort: 9000, ToPort: 9400, IpProtocol: allProtocols, IpRanges: []string{anyIPv4, "10.0.0.0/8"}, SourceGroupIds: []string{"sg-2"}},
+		{FromPort: 9300, ToPort: 9300, IpProtocol: "udp", IpRanges: []string{"192.0.2.0/24"}},
+		{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"198.51.100.0/24"}},
+	}}
+
+	exposure, exposed := serviceExposure(inst, elasticsearch)
+	if !exposed {
+		t.Fatal("expected elasticsearch to be exposed")
+	}
+
+	if !slices.Equal(exposure.Ports, []int32{9200, 9300}) || !slices.Equal(exposure.IpRanges, []string{anyIPv4, "10.0.0.0/8"}) ||
+		!slices.Equal(exposure.SourceGroupIds, []string{"sg-2"}) {
+		t.Errorf("expected the rules allowing tcp on the ports to be merged, got %+v", exposure)
+	}
+
+	if !exposure.OpenToInternet || !exposure.PublicAddress {
+		t.Errorf("expected elasticsearch to be open to the internet, got %+v", exposure)
+	}
+
+	inst.PublicIP = nil
+	if exposure, _ = serviceExposure(inst, elasticsearch); exposure.OpenToInternet {
+		t.Errorf("expected an instance without public address not to be open to the internet, got %+v", exposure)
+	}
+
+	if _, exposed = serviceExposure(Ec2Instance{Id: "i-2", IngressSecRules: inst.IngressSecRules[2:]}, elasticsearch); exposed {
+		t.Error("expected udp and other ports not to expose elasticsearch")
+	}
+}
@@ -0,0 +1,391 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Changes a simulation can apply to the inventory
+const (
+	ChangeAddIngressRule      = "add-ingress-rule"
+	ChangeRemoveIngressRule   = "remove-ingress-rule"
+	ChangeAddEgressRule       = "add-egress-rule"
+	ChangeRemoveEgressRule    = "remove-egress-rule"
+	ChangeAttachGroup         = "attach-group"
+	ChangeDetachGroup         = "detach-group"
+	ChangeAddRoute            = "add-route"
+	ChangeRemoveRoute         = "remove-route"
+	ChangeAssociateRouteTable = "associate-route-table"
+)
+
+// SimulationChange is a proposed change. Rule changes need GroupId and Rule, group changes
+// InstanceId and GroupId, route changes RouteTableId with the Destination, and the Target when
+// adding, and route table associations SubnetId and RouteTableId
+type SimulationChange struct {
+	Action       string
+	GroupId      string
+	InstanceId   string
+	Rule         Ec2SecGroupRule
+	RouteTableId string
+	SubnetId     string
+	Destination  string
+	Target       string
+}
+
+// ExposureChange is an instance whose exposure status the changes modify
+type ExposureChange struct {
+	InstanceId string
+	Before     string
+	After      string
+}
+
+// SimulationDiff is what the changes would do: findings raised and resolved, the ports instance
+// pairs can connect on that they couldn't before or the other way around, and exposure changes
+type SimulationDiff struct {
+	NewFindings       []Finding
+	ResolvedFindings  []Finding
+	NewlyReachable    []InstanceReach
+	NoLongerReachable []InstanceReach
+	ExposureChanges   []ExposureChange
+}
+
+// Simulate applies the changes to a copy of the inventory, runs the exposure, findings and
+// reachability analyses on both and returns the difference. Findings of rules aren't part of
+// it, as they match over the stored graph
+func Simulate(inv Inventory, changes []SimulationChange, catalog ServiceCatalog, networks NetworkClassifier) (SimulationDiff, error) {
+	changed, err := applyChanges(inv, changes)
+	if err != nil {
+		return SimulationDiff{}, err
+	}
+
+	return diffInventories(inv, changed, catalog, networks), nil
+}
+
+// diffInventories tells what changes from an inventory to the other
+func diffInventories(inv, changed Inventory, catalog ServiceCatalog, networks NetworkClassifier) SimulationDiff {
+	beforeExposures, beforeFindings := analyzeExposures(inv, catalog, networks)
+	afterExposures, afterFindings := analyzeExposures(changed, catalog, networks)
+	beforeReaches, afterReaches := computeInstanceReaches(inv), computeInstanceReaches(changed)
+
+	diff := SimulationDiff{
+		NewFindings:       findingsMissingFrom(afterFindings, beforeFindings),
+		ResolvedFindings:  findingsMissingFrom(beforeFindings, afterFindings),
+		NewlyReachable:    reachesMissingFrom(afterReaches, beforeReaches),
+		NoLongerReachable: reachesMissingFrom(beforeReaches, afterReaches),
+	}
+
+	before := make(map[string]string, len(beforeExposures))
+	for _, exposure := range beforeExposures {
+		before[exposure.InstanceId] = exposure.Status
+	}
+
+	for _, exposure := range afterExposures {
+		if before[exposure.InstanceId] != exposure.Status {
+			diff.ExposureChanges = append(diff.ExposureChanges, ExposureChange{
+				InstanceId: exposure.InstanceId,
+				Before:     before[exposure.InstanceId],
+				After:      exposure.Status,
+			})
+		}
+	}
+
+	return diff
+}
+
+func analyzeExposures(inv Inventory, catalog ServiceCatalog, networks NetworkClassifier) ([]InstanceExposure, []Finding) {
+	resolver := newDnsResolver(inv, indexIpAddresses(inv))
+	exposures := computeInstanceExposures(inv, buildFrontDoorGraph(inv, resolver))
+	findings := buildFindings(inv, exposures, computeServiceExposures(inv, catalog, networks), catalog)
+
+	return exposures, findings
+}
+
+func findingsMissingFrom(findings, others []Finding) []Finding {
+	missing := make([]Finding, 0, len(findings))
+	for _, finding := range findings {
+		if !slices.ContainsFunc(others, func(other Finding) bool { return other.Id == finding.Id }) {
+			missing = append(missing, finding)
+		}
+	}
+
+	return missing
+}
+
+// reachesMissingFrom returns the ports of the reaches the others don't have, so pairs that
+// can connect on more ports show up with the ports they gained, not only the new pairs
+func reachesMissingFrom(reaches, others []InstanceReach) []InstanceReach {
+	known := make(map[[3]string][]PortRange, len(others))
+	for _, reach := range others {
+		key := [3]string{reach.From, reach.To, reach.Protocol}
+		known[key] = append(known[key], reach.PortRanges...)
+	}
+
+	missing := make([]InstanceReach, 0, len(reaches))
+	for _, reach := range reaches {
+		ports := mergePortRanges(reach.PortRanges)
+		for _, portRange := range known[[3]string{reach.From, reach.To, reach.Protocol}] {
+			ports = subtractPortRange(ports, portRange)
+		}
+
+		if len(ports) > 0 {
+			reach.PortRanges = ports
+			missing = append(missing, reach)
+		}
+	}
+
+	return missing
+}
+
+// applyChanges returns a copy of the inventory with the changes applied in order
+func applyChanges(inv Inventory, changes []SimulationChange) (Inventory, error) {
+	changed, err := cloneInventory(inv)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	for idx, change := range changes {
+		if err := changed.apply(change); err != nil {
+			return Inventory{}, fmt.Errorf("change %d (%s): %s", idx+1, change.Action, err.Error())
+		}
+	}
+
+	return changed, nil
+}
+
+// cloneInventory copies the inventory deeply, the same way it's kept in the store
+func cloneInventory(inv Inventory) (Inventory, error) {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	var clone Inventory
+	err = json.Unmarshal(data, &clone)
+
+	return clone, err
+}
+
+func (inv *Inventory) apply(change SimulationChange) error {
+	switch change.Action {
+	case ChangeAddIngressRule, ChangeAddEgressRule:
+		return inv.changeGroupRules(change.GroupId, change.Action == ChangeAddEgressRule, func(rules []Ec2SecGroupRule) ([]Ec2SecGroupRule, error) {
+			rule := change.Rule
+			rule.GroupId = change.GroupId
+			return append(rules, rule), nil
+		})
+	case ChangeRemoveIngressRule, ChangeRemoveEgressRule:
+		return inv.changeGroupRules(change.GroupId, change.Action == ChangeRemoveEgressRule, func(rules []Ec2SecGroupRule) ([]Ec2SecGroupRule, error) {
+			return revokeRule(rules, change.Rule)
+		})
+	case ChangeAttachGroup:
+		return inv.attachGroup(change.InstanceId, change.GroupId)
+	case ChangeDetachGroup:
+		return inv.detachGroup(change.InstanceId, change.GroupId)
+	case ChangeAddRoute, ChangeRemoveRoute:
+		return inv.changeRoute(change)
+	case ChangeAssociateRouteTable:
+		return inv.associateRouteTable(change.SubnetId, change.RouteTableId)
+	default:
+		return fmt.Errorf("unknown action, expected one of %s", strings.Join([]string{
+			ChangeAddIngressRule, ChangeRemoveIngressRule, ChangeAddEgressRule, ChangeRemoveEgressRule, ChangeAttachGroup,
+			ChangeDetachGroup, ChangeAddRoute, ChangeRemoveRoute, ChangeAssociateRouteTable,
+		}, ", "))
+	}
+}
+
+// changeGroupRules changes the rules of a group, and of every instance in it, as instances
+// carry the rules of their groups
+func (inv *Inventory) changeGroupRules(groupId string, egress bool, change func([]Ec2SecGroupRule) ([]Ec2SecGroupRule, error)) error {
+	found := false
+	for idx, group := range inv.SecurityGroups {
+		if group.Id != groupId {
+			continue
+		}
+
+		found = true
+		rules, err := change(selectRules(group.IngressRules, group.EgressRules, egress))
+		if err != nil {
+			return err
+		}
+
+		if egress {
+			inv.SecurityGroups[idx].EgressRules = rules
+		} else {
+			inv.SecurityGroups[idx].IngressRules = rules
+		}
+	}
+
+	for idx, inst := range inv.Instances {
+		if !slices.Contains(inst.SecurityGroupIds, groupId) {
+			continue
+		}
+
+		found = true
+		all := selectRules(inst.IngressSecRules, inst.EgressSecRules, egress)
+		others := slices.DeleteFunc(slices.Clone(all), func(rule Ec2SecGroupRule) bool { return rule.GroupId == groupId })
+		groupRules := slices.DeleteFunc(slices.Clone(all), func(rule Ec2SecGroupRule) bool { return rule.GroupId != groupId })
+
+		groupRules, err := change(groupRules)
+		if err != nil {
+			return err
+		}
+
+		if egress {
+			inv.Instances[idx].EgressSecRules = append(others, groupRules...)
+		} else {
+			inv.Instances[idx].IngressSecRules = append(others, groupRules...)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("unknown security group %s", groupId)
+	}
+
+	return nil
+}
+
+func selectRules(ingress, egress []Ec2SecGroupRule, isEgress bool) []Ec2SecGroupRule {
+	if isEgress {
+		return egress
+	}
+
+	return ingress
+}
+
+// revokeRule removes the ranges and groups of the revoked rule from the rule with the same
+// protocol and ports, like revoking a security group rule does, and drops it once empty
+func revokeRule(rules []Ec2SecGroupRule, revoked Ec2SecGroupRule) ([]Ec2SecGroupRule, error) {
+	for idx, rule := range rules {
+		if normalizeProtocol(rule.IpProtocol) != normalizeProtocol(revoked.IpProtocol) || rule.FromPort != revoked.FromPort || rule.ToPort != revoked.ToPort {
+			continue
+		}
+
+		rule.IpRanges = slices.DeleteFunc(slices.Clone(rule.IpRanges), func(ipRange string) bool {
+			return slices.Contains(revoked.IpRanges, ipRange)
+		})
+		rule.SourceGroupIds = slices.DeleteFunc(slices.Clone(rule.SourceGroupIds), func(groupId string) bool {
+			return slices.Contains(revoked.SourceGroupIds, groupId)
+		})
+
+		if len(rule.IpRanges) == 0 && len(rule.SourceGroupIds) == 0 && len(rule.PrefixListIds) == 0 {
+			return slices.Delete(slices.Clone(rules), idx, idx+1), nil
+		}
+
+		rules = slices.Clone(rules)
+		rules[idx] = rule
+		return rules, nil
+	}
+
+	return nil, fmt.Errorf("no %s %d-%d rule to remove", revoked.IpProtocol, revoked.FromPort, revoked.ToPort)
+}
+
+func (inv *Inventory) instanceIndex(instanceId string) (int, error) {
+	idx := slices.IndexFunc(inv.Instances, func(inst Ec2Instance) bool { return inst.Id == instanceId })
+	if idx < 0 {
+		return idx, fmt.Errorf("unknown instance %s", instanceId)
+	}
+
+	return idx, nil
+}
+
+// attachGroup gives the instance the rules of the group, from the fetched groups or from
+// instances already in it
+func (inv *Inventory) attachGroup(instanceId, groupId string) error {
+	idx, err := inv.instanceIndex(instanceId)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(inv.Instances[idx].SecurityGroupIds, groupId) {
+		return fmt.Errorf("%s is already in %s", instanceId, groupId)
+	}
+
+	groups := securityGroupsOf(*inv)
+	groupIdx := slices.IndexFunc(groups, func(group instanceSecurityGroup) bool { return group.Id == groupId })
+	if groupIdx < 0 {
+		return fmt.Errorf("unknown security group %s", groupId)
+	}
+
+	inst := &inv.Instances[idx]
+	inst.SecurityGroupIds = append(inst.SecurityGroupIds, groupId)
+	inst.IngressSecRules = append(inst.IngressSecRules, groups[groupIdx].Ingress...)
+	inst.EgressSecRules = append(inst.EgressSecRules, groups[groupIdx].Egress...)
+
+	return nil
+}
+
+func (inv *Inventory) detachGroup(instanceId, groupId string) error {
+	idx, err := inv.instanceIndex(instanceId)
+	if err != nil {
+		return err
+	}
+
+	inst := &inv.Instances[idx]
+	if !slices.Contains(inst.SecurityGroupIds, groupId) {
+		return fmt.Errorf("%s is not in %s", instanceId, groupId)
+	}
+
+	inGroup := func(rule Ec2SecGroupRule) bool { return rule.GroupId == groupId }
+	inst.SecurityGroupIds = slices.DeleteFunc(inst.SecurityGroupIds, func(id string) bool { return id == groupId })
+	inst.IngressSecRules = slices.DeleteFunc(inst.IngressSecRules, inGroup)
+	inst.EgressSecRules = slices.DeleteFunc(inst.EgressSecRules, inGroup)
+
+	return nil
+}
+
+func (inv *Inventory) routeTableIndex(routeTableId string) (int, error) {
+	idx := slices.IndexFunc(inv.RouteTables, func(table RouteTable) bool { return table.Id == routeTableId })
+	if idx < 0 {
+		return idx, fmt.Errorf("unknown route table %s", routeTableId)
+	}
+
+	return idx, nil
+}
+
+// changeRoute adds or replaces the route to the destination, or removes it
+func (inv *Inventory) changeRoute(change SimulationChange) error {
+	idx, err := inv.routeTableIndex(change.RouteTableId)
+	if err != nil {
+		return err
+	}
+
+	table := &inv.RouteTables[idx]
+	existing := slices.IndexFunc(table.Routes, func(route Route) bool { return route.Destination == change.Destination })
+
+	switch {
+	case change.Action == ChangeRemoveRoute && existing < 0:
+		return fmt.Errorf("no route to %s in %s", change.Destination, change.RouteTableId)
+	case change.Action == ChangeRemoveRoute:
+		table.Routes = slices.Delete(table.Routes, existing, existing+1)
+	case change.Target == "":
+		return fmt.Errorf("a target is required to add a route")
+	case existing >= 0:
+		table.Routes[existing] = Route{Destination: change.Destination, Target: change.Target}
+	default:
+		table.Routes = append(table.Routes, Route{Destination: change.Destination, Target: change.Target})
+	}
+
+	return nil
+}
+
+// associateRouteTable moves the subnet to the route table, a subnet has a single one
+func (inv *Inventory) associateRouteTable(subnetId, routeTableId string) error {
+	idx, err := inv.routeTableIndex(routeTableId)
+	if err != nil {
+		return err
+	}
+
+	if subnetId == "" {
+		return fmt.Errorf("a subnet is required")
+	}
+
+	for tableIdx := range inv.RouteTables {
+		inv.RouteTables[tableIdx].SubnetIds = slices.DeleteFunc(inv.RouteTables[tableIdx].SubnetIds, func(id string) bool { return id == subnetId })
+	}
+
+	inv.RouteTables[idx].SubnetIds = append(inv.RouteTables[idx].SubnetIds, subnetId)
+
+	return nil
+}
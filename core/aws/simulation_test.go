@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	inv := reachabilityInventory()
+	inv.Instances[0].SecurityGroupIds = []string{"sg-web"}
+	inv.Instances[0].IngressSecRules[0].GroupId = "sg-web"
+	inv.Instances[1].SecurityGroupIds = []string{"sg-app"}
+	inv.Instances[1].IngressSecRules = []Ec2SecGroupRule{{GroupId: "sg-app", FromPort: 22, ToPort: 22, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web"}}}
+
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+
+	diff, err := Simulate(inv, []SimulationChange{
+		{Action: ChangeRemoveIngressRule, GroupId: "sg-web", Rule: Ec2SecGroupRule{IpProtocol: "tcp", FromPort: 22, ToPort: 22, IpRanges: []string{anyIPv4}}},
+		{Action: ChangeAddEgressRule, GroupId: "sg-web", Rule: Ec2SecGroupRule{IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}},
+	}, catalog, classifier)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(diff.NewFindings) != 0 || len(diff.ResolvedFindings) != 1 || diff.ResolvedFindings[0].Asset.AssetId != "i-public" {
+		t.Errorf("expected the SSH finding of i-public to be resolved, got new %+v and resolved %+v", diff.NewFindings, diff.ResolvedFindings)
+	}
+
+	if len(diff.NewlyReachable) == 0 || diff.NewlyReachable[0].From != "i-public" || diff.NewlyReachable[0].To != "i-private" || diff.NewlyReachable[0].Status != ReachabilityReachable {
+		t.Errorf("expected i-public to reach i-private, got %+v", diff.NewlyReachable)
+	}
+
+	expected := []ExposureChange{{InstanceId: "i-public", Before: ExposureOpenToInternet, After: ExposurePublic}}
+	if !slices.Equal(diff.ExposureChanges, expected) {
+		t.Errorf("expected exposure changes %+v, got %+v", expected, diff.ExposureChanges)
+	}
+
+	if len(inv.Instances[0].IngressSecRules) != 1 || len(inv.Instances[0].EgressSecRules) != 0 {
+		t.Errorf("expected the inventory to be left untouched, got %+v", inv.Instances[0])
+	}
+
+	_, err = Simulate(inv, []SimulationChange{
+		{Action: ChangeRemoveIngressRule, GroupId: "sg-web", Rule: Ec2SecGroupRule{IpProtocol: "tcp", FromPort: 3389, ToPort: 3389, IpRanges: []string{anyIPv4}}},
+	}, catalog, classifier)
+	if err == nil {
+		t.Error("expected removing a rule that doesn't exist to fail")
+	}
+}
+
+func TestSimulateWidenedReach(t *testing.T) {
+	inv := reachabilityInventory()
+	inv.Instances[0].SecurityGroupIds = []string{"sg-web"}
+	inv.Instances[0].EgressSecRules = []Ec2SecGroupRule{{GroupId: "sg-web", IpProtocol: allProtocols, IpRanges: []string{anyIPv4}}}
+	inv.Instances[1].SecurityGroupIds = []string{"sg-app"}
+	inv.Instances[1].IngressSecRules = []Ec2SecGroupRule{{GroupId: "sg-app", FromPort: 443, ToPort: 443, IpProtocol: "tcp", SourceGroupIds: []string{"sg-web"}}}
+
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+
+	diff, err := Simulate(inv, []SimulationChange{
+		{Action: ChangeAddIngressRule, GroupId: "sg-app", Rule: Ec2SecGroupRule{IpProtocol: "tcp", FromPort: 22, ToPort: 22, SourceGroupIds: []string{"sg-web"}}},
+	}, catalog, classifier)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(diff.NewlyReachable) != 1 || diff.NewlyReachable[0].To != "i-private" || !slices.Equal(diff.NewlyReachable[0].Ports(), []string{"22"}) {
+		t.Errorf("expected i-public to reach i-private on 22 on top of 443, got %+v", diff.NewlyReachable)
+	}
+
+	diff, err = Simulate(inv, []SimulationChange{
+		{Action: ChangeRemoveIngressRule, GroupId: "sg-app", Rule: Ec2SecGroupRule{IpProtocol: "tcp", FromPort: 443, ToPort: 443, SourceGroupIds: []string{"sg-web"}}},
+		{Action: ChangeAddIngressRule, GroupId: "sg-app", Rule: Ec2SecGroupRule{IpProtocol: "tcp", FromPort: 0, ToPort: 65535, SourceGroupIds: []string{"sg-web"}}},
+	}, catalog, classifier)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(diff.NewlyReachable) != 1 || !slices.Equal(diff.NewlyReachable[0].Ports(), []string{"0-442", "444-65535"}) || len(diff.NoLongerReachable) != 0 {
+		t.Errorf("expected the reach of i-public to i-private to be widened to every port, got %+v and %+v", diff.NewlyReachable, diff.NoLongerReachable)
+	}
+}
@@ -0,0 +1,47 @@
+package aws
+
+import (
+	"context"
+	"slices"
+)
+
+// InventorySource loads an inventory from somewhere else than the AWS APIs, like files
+// exported from an account, so it can be analyzed without access to it
+type InventorySource interface {
+	Name() string
+	Load(ctx context.Context) (Inventory, error)
+}
+
+// BuildFrom analyzes and stores the inventory of the source, like Build does with the one
+// fetched from AWS
+func (r *RelationBuilder) BuildFrom(ctx context.Context, source InventorySource) error {
+	r.logger.Info("Building Relationship from " + source.Name())
+
+	inventory, err := source.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.analyzer.buildRelationsAndSave(ctx, inventory)
+}
+
+// markMissingData records the network data infrastructure as code doesn't hold, as it's often
+// managed elsewhere or left to AWS defaults, so its absence isn't taken as proof of anything
+func markMissingData(inv Inventory) Inventory {
+	missing := map[string]bool{
+		DataVpcs:             len(inv.Vpcs) == 0,
+		DataSubnets:          len(inv.Subnets) == 0,
+		DataRouteTables:      len(inv.RouteTables) == 0,
+		DataNetworkAcls:      len(inv.NetworkAcls) == 0,
+		DataInternetGateways: len(inv.InternetGateways) == 0,
+		DataInstanceProfiles: len(inv.InstanceProfiles) == 0 && len(usedInstanceProfiles(inv)) > 0,
+	}
+
+	for _, data := range []string{DataVpcs, DataSubnets, DataRouteTables, DataNetworkAcls, DataInternetGateways, DataInstanceProfiles} {
+		if missing[data] && !slices.Contains(inv.MissingData, data) {
+			inv.MissingData = append(inv.MissingData, data)
+		}
+	}
+
+	return inv
+}
@@ -0,0 +1,329 @@
+package aws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const terraformStateJson = `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "aws_vpc", "name": "main", "instances": [
+      {"attributes": {"id": "vpc-1", "cidr_block": "10.0.0.0/16"}}
+    ]},
+    {"mode": "managed", "type": "aws_subnet", "name": "public", "instances": [
+      {"attributes": {"id": "subnet-1", "vpc_id": "vpc-1", "cidr_block": "10.0.0.0/24", "map_public_ip_on_launch": true}}
+    ]},
+    {"mode": "managed", "type": "aws_internet_gateway", "name": "main", "instances": [
+      {"attributes": {"id": "igw-1", "vpc_id": "vpc-1"}}
+    ]},
+    {"mode": "managed", "type": "aws_route_table", "name": "public", "instances": [
+      {"attributes": {"id": "rtb-1", "vpc_id": "vpc-1", "route": [{"cidr_block": "0.0.0.0/0", "gateway_id": "igw-1"}]}}
+    ]},
+    {"mode": "managed", "type": "aws_route_table_association", "name": "public", "instances": [
+      {"attributes": {"subnet_id": "subnet-1", "route_table_id": "rtb-1"}}
+    ]},
+    {"mode": "managed", "type": "aws_default_network_acl", "name": "main", "instances": [
+      {"attributes": {"id": "acl-1", "subnet_ids": ["subnet-1"],
+        "ingress": [{"rule_no": 100, "action": "allow", "protocol": "-1", "cidr_block": "0.0.0.0/0", "from_port": 0, "to_port": 0}],
+        "egress": [{"rule_no": 100, "action": "allow", "protocol": "-1", "cidr_block": "0.0.0.0/0", "from_port": 0, "to_port": 0}]}}
+    ]},
+    {"mode": "managed", "type": "aws_security_group", "name": "ssh", "instances": [
+      {"attributes": {"id": "sg-1", "vpc_id": "vpc-1", "name": "ssh",
+        "ingress": [{"from_port": 22, "to_port": 22, "protocol": "tcp", "cidr_blocks": ["0.0.0.0/0"], "self": false}], "egress": []}}
+    ]},
+    {"mode": "managed", "type": "aws_security_group_rule", "name": "ssh", "instances": [
+      {"attributes": {"type": "ingress", "security_group_id": "sg-1", "from_port": 22, "to_port": 22, "protocol": "tcp", "cidr_blocks": ["0.0.0.0/0"]}}
+    ]},
+    {"mode": "managed", "type": "aws_instance", "name": "bastion", "instances": [
+      {"index_key": 0, "attributes": {"id": "i-1", "arn": "arn:aws:ec2:eu-west-1:123456789012:instance/i-1", "ami": "ami-1",
+        "subnet_id": "subnet-1", "private_ip": "10.0.0.10", "public_ip": "3.3.3.3", "vpc_security_group_ids": ["sg-1"],
+        "metadata_options": [{"http_tokens": "optional"}]}}
+    ]}
+  ]
+}`
+
+const cloudFormationTemplate = `
+Parameters:
+  SshCidr:
+    Type: String
+    Default: 10.0.0.0/8
+Resources:
+  Vpc:
+    Type: AWS::EC2::VPC
+    Properties:
+      CidrBlock: 10.0.0.0/16
+  Subnet:
+    Type: AWS::EC2::Subnet
+    Properties:
+      VpcId: !Ref Vpc
+      CidrBlock: !Select [0, !Cidr [!GetAtt Vpc.CidrBlock, 1, 8]]
+  SshGroup:
+    Type: AWS::EC2::SecurityGroup
+    Properties:
+      GroupDescription: !Sub "SSH from ${SshCidr}"
+      VpcId: !Ref Vpc
+      SecurityGroupIngress:
+        - IpProtocol: tcp
+          FromPort: 22
+          ToPort: 22
+          CidrIp: !Ref SshCidr
+  Bastion:
+    Type: AWS::EC2::Instance
+    Properties:
+      ImageId: ami-1
+      NetworkInterfaces:
+        - DeviceIndex: "0"
+          SubnetId: !Ref Subnet
+          AssociatePublicIpAddress: true
+          GroupSet: [!GetAtt SshGroup.GroupId]
+`
+
+func TestTerraformStateSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	if err := os.WriteFile(path, []byte(terraformStateJson), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := NewTerraformStateSource(path).Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if inv.AccountId != "123456789012" || inv.Region != "eu-west-1" || len(inv.MissingData) != 0 {
+		t.Errorf("expected the account and region of the ARNs and no missing data, got %s %s %v", inv.AccountId, inv.Region, inv.MissingData)
+	}
+
+	if len(inv.Instances) != 1 || inv.Instances[0].VPC != "vpc-1" || len(inv.Instances[0].IngressSecRules) != 1 || !inv.Instances[0].UsesIMDSv1() {
+		t.Fatalf("expected i-1 in vpc-1 with the SSH rule once, got %+v", inv.Instances)
+	}
+
+	if !slices.Contains(inv.RouteTables[0].Routes, Route{Destination: "10.0.0.0/16", Target: routeTargetLocal}) {
+		t.Errorf("expected the local route of the VPC, got %+v", inv.RouteTables[0].Routes)
+	}
+
+	exposures := computeInstanceExposures(inv, frontDoorGraph{})
+	if exposures[0].Status != ExposureOpenToInternet || exposures[0].Confidence != ConfidenceConfirmed {
+		t.Errorf("expected i-1 to be confirmed open to the internet, got %+v", exposures[0])
+	}
+}
+
+func TestCloudFormationSource(t *testing.T) {
+	dir := t.TempDir()
+	template, parameters := filepath.Join(dir, "template.yml"), filepath.Join(dir, "parameters.json")
+	if err := os.WriteFile(template, []byte(cloudFormationTemplate), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(parameters, []byte(`[{"ParameterKey": "SshCidr", "ParameterValue": "0.0.0.0/0"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := NewCloudFormationSource(template, parameters).Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(inv.Instances) != 1 {
+		t.Fatalf("expected the bastion, got %+v", inv.Instances)
+	}
+
+	bastion := inv.Instances[0]
+	if bastion.Id != "i-{Bastion}" || bastion.VPC != "vpc-{Vpc}" || !bastion.IsOpenToInternet() {
+		t.Errorf("expected the bastion in the VPC with a public address, got %+v", bastion)
+	}
+
+	if len(bastion.IngressSecRules) != 1 || !slices.Equal(bastion.IngressSecRules[0].IpRanges, []string{anyIPv4}) {
+		t.Errorf("expected SSH from the parameter range, got %+v", bastion.IngressSecRules)
+	}
+
+	if len(bastion.EgressSecRules) != 1 || bastion.EgressSecRules[0].IpProtocol != allProtocols {
+		t.Errorf("expected the default egress rule, got %+v", bastion.EgressSecRules)
+	}
+
+	if inv.SecurityGroups[0].Description != "SSH from 0.0.0.0/0" {
+		t.Errorf("expected the description to be substituted, got %s", inv.SecurityGroups[0].Description)
+	}
+
+	if !slices.Contains(inv.MissingData, DataNetworkAcls) {
+		t.Errorf("expected network ACLs to be missing, got %v", inv.MissingData)
+	}
+
+	invalid := map[string]string{
+		"negative select": "Resources:\n  Vpc:\n    Type: AWS::EC2::VPC\n    Properties:\n      CidrBlock: !Select [-1, [10.0.0.0/16, 10.1.0.0/16]]\n",
+		"condition cycle": "Conditions:\n  A: !Condition B\n  B: !Not [!Condition A]\nResources: {}\n",
+	}
+	for name, content := range invalid {
+		if err := os.WriteFile(template, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewCloudFormationSource(template, "").Load(context.Background()); err == nil {
+			t.Errorf("expected an error for the %s", name)
+		}
+	}
+
+	detached := "Conditions:\n  Attached: !Equals [a, b]\nResources:\n" +
+		"  Vpc:\n    Type: AWS::EC2::VPC\n    Properties:\n      CidrBlock: 10.0.0.0/16\n" +
+		"  Gateway:\n    Type: AWS::EC2::InternetGateway\n" +
+		"  Attachment:\n    Type: AWS::EC2::VPCGatewayAttachment\n    Condition: Attached\n" +
+		"    Properties:\n      VpcId: !Ref Vpc\n      InternetGatewayId: !Ref Gateway\n" +
+		"  OptionalAttachment:\n    Type: AWS::EC2::VPCGatewayAttachment\n" +
+		"    Properties: !If [Attached, {VpcId: !Ref Vpc, InternetGatewayId: !Ref Gateway}, !Ref AWS::NoValue]\n"
+	if err := os.WriteFile(template, []byte(detached), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err = NewCloudFormationSource(template, "").Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(inv.InternetGateways) != 1 || slices.Contains(inv.InternetGateways[0].VPCs, "vpc-{Vpc}") {
+		t.Errorf("expected the gateway to stay detached, got %+v", inv.InternetGateways)
+	}
+}
+
+var awsCliDumps = map[string]string{
+	"identity.json": `{"UserId": "AIDA", "Account": "123456789012", "Arn": "arn:aws:iam::123456789012:user/auditor"}`,
+	"instances.json": `{"Reservations": [{"Instances": [{"InstanceId": "i-1", "ImageId": "ami-1", "VpcId": "vpc-1",
+		"SubnetId": "subnet-1", "PrivateIpAddress": "10.0.0.10", "PublicIpAddress": "3.3.3.3",
+		"LaunchTime": "2024-01-01T10:00:00+00:00", "MetadataOptions": {"HttpTokens": "required"},
+		"SecurityGroups": [{"GroupId": "sg-1", "GroupName": "ssh"}], "Tags": [{"Key": "Name", "Value": "bastion"}]}]}]}`,
+	"security-groups.json": `{"SecurityGroups": [{"GroupId": "sg-1", "GroupName": "ssh", "VpcId": "vpc-1",
+		"IpPermissions": [{"IpProtocol": "tcp", "FromPort": 22, "ToPort": 22, "IpRanges": [{"CidrIp": "0.0.0.0/0"}]}],
+		"IpPermissionsEgress": [{"IpProtocol": "-1", "IpRanges": [{"CidrIp": "0.0.0.0/0"}]}]}]}`,
+	"vpcs.json":    `{"Vpcs": [{"VpcId": "vpc-1", "CidrBlock": "10.0.0.0/16", "IsDefault": false}]}`,
+	"subnets.json": `{"Subnets": [{"SubnetId": "subnet-1", "VpcId": "vpc-1", "CidrBlock": "10.0.0.0/24", "AvailabilityZone": "eu-west-1a"}]}`,
+	"route-tables.json": `{"RouteTables": [{"RouteTableId": "rtb-1", "VpcId": "vpc-1", "Associations": [{"Main": true}],
+		"Routes": [{"DestinationCidrBlock": "10.0.0.0/16", "GatewayId": "local"}, {"DestinationCidrBlock": "0.0.0.0/0", "GatewayId": "igw-1"}]}]}`,
+	"network-acls.json": `{"NetworkAcls": [{"NetworkAclId": "acl-1", "VpcId": "vpc-1", "IsDefault": true,
+		"Associations": [{"SubnetId": "subnet-1"}], "Entries": [
+		{"RuleNumber": 100, "Protocol": "-1", "RuleAction": "allow", "Egress": false, "CidrBlock": "0.0.0.0/0"},
+		{"RuleNumber": 100, "Protocol": "-1", "RuleAction": "allow", "Egress": true, "CidrBlock": "0.0.0.0/0"}]}]}`,
+	"internet-gateways.json":        `{"InternetGateways": [{"InternetGatewayId": "igw-1", "Attachments": [{"VpcId": "vpc-1", "State": "available"}]}]}`,
+	"ami-1-launch-permissions.json": `{"ImageId": "ami-1", "LaunchPermissions": [{"Group": "all"}]}`,
+	"notes.json":                    `{"Comment": "collected by the customer"}`,
+}
+
+func TestAwsCliDumpSource(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range awsCliDumps {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inv, err := NewAwsCliDumpSource(dir, slog.Default()).Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if inv.AccountId != "123456789012" || inv.Region != "eu-west-1" || len(inv.MissingData) != 0 {
+		t.Errorf("expected the account of the identity, the region of the subnets and no missing data, got %s %s %v", inv.AccountId, inv.Region, inv.MissingData)
+	}
+
+	if len(inv.Instances) != 1 || len(inv.Instances[0].IngressSecRules) != 1 || len(inv.Instances[0].EgressSecRules) != 1 {
+		t.Fatalf("expected i-1 with the rules of sg-1, got %+v", inv.Instances)
+	}
+
+	if len(inv.Amis) != 1 || inv.Amis[0].Provenance != AmiOwnedByAccount || !inv.Amis[0].IsSharedPublicly() {
+		t.Errorf("expected ami-1 to be ours and public, got %+v", inv.Amis)
+	}
+
+	exposures := computeInstanceExposures(inv, frontDoorGraph{})
+	if exposures[0].Status != ExposureOpenToInternet || exposures[0].Confidence != ConfidenceConfirmed {
+		t.Errorf("expected i-1 to be confirmed open to the internet, got %+v", exposures[0])
+	}
+}
+
+const awsConfigSnapshot = `{"fileVersion": "1.0", "configSnapshotId": "1", "configurationItems": [
+  {"resourceType": "AWS::EC2::VPC", "resourceId": "vpc-1", "awsAccountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-05-01T00:00:00.000Z", "configurationItemStatus": "ResourceDiscovered",
+    "configuration": {"vpcId": "vpc-1", "cidrBlock": "10.0.0.0/16", "isDefault": false}},
+  {"resourceType": "AWS::EC2::SecurityGroup", "resourceId": "sg-1", "awsAccountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-05-01T00:00:00.000Z", "configurationItemStatus": "ResourceDiscovered",
+    "configuration": {"groupId": "sg-1", "groupName": "ssh", "vpcId": "vpc-1", "ipPermissions": [{"ipProtocol": "tcp",
+      "fromPort": 22, "toPort": 22, "ipRanges": ["0.0.0.0/0"], "ipv4Ranges": [{"cidrIp": "0.0.0.0/0"}], "userIdGroupPairs": []}],
+      "ipPermissionsEgress": [{"ipProtocol": "-1", "ipRanges": ["0.0.0.0/0"], "ipv4Ranges": [{"cidrIp": "0.0.0.0/0"}]}]}},
+  {"resourceType": "AWS::EC2::Instance", "resourceId": "i-1", "awsAccountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-05-01T00:00:00.000Z", "configurationItemStatus": "ResourceDiscovered",
+    "configuration": {"instanceId": "i-1", "vpcId": "vpc-1", "subnetId": "subnet-1", "privateIpAddress": "10.0.0.10",
+      "launchTime": "2024-04-01T00:00:00.000Z", "securityGroups": [{"groupId": "sg-1", "groupName": "ssh"}], "tags": [{"key": "Name", "value": "bastion"}]}},
+  {"resourceType": "AWS::EC2::Instance", "resourceId": "i-2", "awsAccountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-05-01T00:00:00.000Z", "configurationItemStatus": "ResourceDiscovered",
+    "configuration": {"instanceId": "i-2", "vpcId": "vpc-1", "securityGroups": [{"groupId": "sg-1"}]}},
+  {"resourceType": "AWS::S3::Bucket", "resourceId": "logs", "awsAccountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-05-01T00:00:00.000Z", "configurationItemStatus": "ResourceDiscovered",
+    "configuration": {"name": "logs"}}
+]}`
+
+const awsConfigHistory = `{"configurationItems": [
+  {"resourceType": "AWS::EC2::SecurityGroup", "resourceId": "sg-1", "accountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-06-01T00:00:00+00:00", "configurationItemStatus": "OK",
+    "configuration": "{\"groupId\": \"sg-1\", \"groupName\": \"ssh\", \"vpcId\": \"vpc-1\", \"ipPermissions\": [{\"ipProtocol\": \"tcp\", \"fromPort\": 22, \"toPort\": 22, \"ipRanges\": [\"10.0.0.0/8\"]}]}"},
+  {"resourceType": "AWS::EC2::Instance", "resourceId": "i-2", "accountId": "123456789012", "awsRegion": "eu-west-1",
+    "configurationItemCaptureTime": "2024-06-01T00:00:00+00:00", "configurationItemStatus": "ResourceDeleted"}
+]}`
+
+func TestAwsConfigSource(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "AWSLogs", "123456789012", "Config", "eu-west-1")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot bytes.Buffer
+	gzipWriter := gzip.NewWriter(&snapshot)
+	if _, err := gzipWriter.Write([]byte(awsConfigSnapshot)); err != nil || gzipWriter.Close() != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "ConfigSnapshot.json.gz"), snapshot.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "ConfigHistory.json"), []byte(awsConfigHistory), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := NewAwsConfigSource(dir, time.Time{}, slog.Default()).Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if latest.AccountId != "123456789012" || latest.Region != "eu-west-1" {
+		t.Errorf("expected the account and region of the items, got %s %s", latest.AccountId, latest.Region)
+	}
+
+	if len(latest.Instances) != 1 || latest.Instances[0].Tags["Name"] != "bastion" {
+		t.Fatalf("expected i-2 to be deleted, got %+v", latest.Instances)
+	}
+
+	if rules := latest.Instances[0].IngressSecRules; len(rules) != 1 || !slices.Equal(rules[0].IpRanges, []string{"10.0.0.0/8"}) {
+		t.Errorf("expected SSH from 10.0.0.0/8 in the latest state, got %+v", rules)
+	}
+
+	past, err := NewAwsConfigSource(dir, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), slog.Default()).Load(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(past.Instances) != 2 {
+		t.Fatalf("expected i-1 and i-2 before i-2 was deleted, got %+v", past.Instances)
+	}
+
+	if rules := past.Instances[0].IngressSecRules; len(rules) != 1 || !slices.Equal(rules[0].IpRanges, []string{anyIPv4}) {
+		t.Errorf("expected SSH from anywhere before the group was narrowed, got %+v", rules)
+	}
+
+	if len(past.Instances[0].EgressSecRules) != 1 {
+		t.Errorf("expected the egress rule of the snapshot, got %+v", past.Instances[0].EgressSecRules)
+	}
+}
@@ -0,0 +1,144 @@
+package aws
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Suppression is an accepted risk, e.g. a bastion host that must be open to the internet. It
+// hides the findings matching every criteria it sets, until it expires
+type Suppression struct {
+	Id string
+	// AssetId matches the asset of the finding: an instance, an Auto Scaling group, an AMI...
+	AssetId string
+	// Tag is key=value, and matches when every instance of the finding has it
+	Tag string
+	// SecurityGroupId matches when every instance of the finding is in the group
+	SecurityGroupId string
+	// Rule is the finding type, e.g. ssh-open-to-internet, the id of a rule of rules.yml, or
+	// what reports not raised as findings list, see the Report constants
+	Rule          string
+	Owner         string
+	Justification string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+// Validate makes sure the suppression says who accepted the risk, why and until when, and
+// matches something narrower than every finding
+func (s Suppression) Validate(now time.Time) error {
+	switch {
+	case s.AssetId == "" && s.Tag == "" && s.SecurityGroupId == "" && s.Rule == "":
+		return errors.New("a suppression needs at least one of assetId, tag, securityGroupId or rule")
+	case s.Tag != "" && !strings.Contains(s.Tag, "="):
+		return errors.New("tag must be key=value")
+	case s.Owner == "":
+		return errors.New("owner is required")
+	case s.Justification == "":
+		return errors.New("justification is required")
+	case !s.ExpiresAt.After(now):
+		return errors.New("expiresAt must be in the future")
+	}
+
+	return nil
+}
+
+func (s Suppression) Active(now time.Time) bool {
+	return s.ExpiresAt.After(now)
+}
+
+// Rules of the items of reports that aren't raised as findings. Instances listed by exposure
+// take the exposure, e.g. open-to-internet, and security group cleanup issues the issue, e.g.
+// stale-reference
+const (
+	ReportDanglingDns    = "dangling-dns"
+	ReportRiskyAmi       = "risky-ami"
+	ReportPermissiveRule = "permissive-rule"
+	ReportRedundantRule  = "redundant-rule"
+)
+
+// SuppressionSubject is what suppressions are matched against: a finding, or an item of an
+// endpoint listing them, with the finding type it would be raised as
+type SuppressionSubject struct {
+	Rule        string
+	AssetId     string
+	InstanceIds []string
+}
+
+// Suppressions matches subjects against the active suppressions. Tags, security groups and
+// Auto Scaling groups are looked up on the instances of the inventory
+type Suppressions struct {
+	active    []Suppression
+	instances map[string]Ec2Instance
+	groups    map[string]string
+}
+
+func NewSuppressions(suppressions []Suppression, inv Inventory, now time.Time) Suppressions {
+	s := Suppressions{
+		active:    make([]Suppression, 0, len(suppressions)),
+		instances: make(map[string]Ec2Instance, len(inv.Instances)),
+		groups:    instanceGroups(inv),
+	}
+
+	for _, suppression := range suppressions {
+		if suppression.Active(now) {
+			s.active = append(s.active, suppression)
+		}
+	}
+
+	for _, inst := range inv.Instances {
+		s.instances[inst.Id] = inst
+	}
+
+	return s
+}
+
+// Match returns the first active suppression matching the subject
+func (s Suppressions) Match(subject SuppressionSubject) (Suppression, bool) {
+	for _, suppression := range s.active {
+		if s.matches(suppression, subject) {
+			return suppression, true
+		}
+	}
+
+	return Suppression{}, false
+}
+
+func (s Suppressions) matches(suppression Suppression, subject SuppressionSubject) bool {
+	if suppression.Rule != "" && suppression.Rule != subject.Rule {
+		return false
+	}
+
+	// Suppressing an Auto Scaling group covers its instances, as they are replaced
+	if suppression.AssetId != "" && suppression.AssetId != subject.AssetId && suppression.AssetId != s.groups[subject.AssetId] {
+		return false
+	}
+
+	if suppression.Tag == "" && suppression.SecurityGroupId == "" {
+		return true
+	}
+
+	if len(subject.InstanceIds) == 0 {
+		return false
+	}
+
+	key, value, _ := strings.Cut(suppression.Tag, "=")
+	for _, instanceId := range subject.InstanceIds {
+		inst, known := s.instances[instanceId]
+		if !known {
+			return false
+		}
+
+		if tag, tagged := inst.Tags[key]; suppression.Tag != "" && (!tagged || tag != value) {
+			return false
+		}
+
+		if suppression.SecurityGroupId != "" && !slices.Contains(inst.SecurityGroupIds, suppression.SecurityGroupId) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuppressionsMatch(t *testing.T) {
+	now := time.Now()
+	inv := Inventory{Instances: []Ec2Instance{
+		{Id: "i-bastion", SecurityGroupIds: []string{"sg-bastion"}, Tags: map[string]string{"role": "bastion"}},
+		{Id: "i-web", SecurityGroupIds: []string{"sg-web"}, Tags: map[string]string{"role": "web"}},
+		{Id: "i-worker", Tags: map[string]string{autoScalingGroupTag: "asg-workers"}},
+	}}
+
+	suppressions := NewSuppressions([]Suppression{
+		{Id: "tag", Tag: "role=bastion", Rule: "ssh-open-to-internet", ExpiresAt: now.Add(time.Hour)},
+		{Id: "group", SecurityGroupId: "sg-web", ExpiresAt: now.Add(-time.Hour)},
+		{Id: "asset", AssetId: "ami-1", ExpiresAt: now.Add(time.Hour)},
+		{Id: "asg", AssetId: "asg-workers", ExpiresAt: now.Add(time.Hour)},
+	}, inv, now)
+
+	cases := []struct {
+		subject  SuppressionSubject
+		expected string
+	}{
+		{SuppressionSubject{Rule: "ssh-open-to-internet", AssetId: "i-bastion", InstanceIds: []string{"i-bastion"}}, "tag"},
+		{SuppressionSubject{Rule: "rdp-open-to-internet", AssetId: "i-bastion", InstanceIds: []string{"i-bastion"}}, ""},
+		{SuppressionSubject{Rule: "ssh-open-to-internet", AssetId: "asg-1", InstanceIds: []string{"i-bastion", "i-web"}}, ""},
+		{SuppressionSubject{Rule: "ssh-open-to-internet", AssetId: "i-web", InstanceIds: []string{"i-web"}}, ""},
+		{SuppressionSubject{Rule: FindingAmiSharedPublicly, AssetId: "ami-1"}, "asset"},
+		{SuppressionSubject{Rule: "ssh-open-to-internet", AssetId: "i-worker", InstanceIds: []string{"i-worker"}}, "asg"},
+		{SuppressionSubject{Rule: ReportRiskyAmi, AssetId: "i-web", InstanceIds: []string{"i-web"}}, ""},
+	}
+
+	for _, c := range cases {
+		suppression, _ := suppressions.Match(c.subject)
+		if suppression.Id != c.expected {
+			t.Errorf("%+v: expected suppression %q, got %q", c.subject, c.expected, suppression.Id)
+		}
+	}
+}
@@ -0,0 +1,817 @@
+package aws
+
+import (
+	"asset-relations/support/ptr"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// knownAfterApply stands for values Terraform only knows once the resources are created, like
+// the public address of a new instance
+const knownAfterApply = "(known after apply)"
+
+// Terraform resource types mapped to assets
+const (
+	tfInstance              = "aws_instance"
+	tfSecurityGroup         = "aws_security_group"
+	tfDefaultSecurityGroup  = "aws_default_security_group"
+	tfSecurityGroupRule     = "aws_security_group_rule"
+	tfSecurityGroupIngress  = "aws_vpc_security_group_ingress_rule"
+	tfSecurityGroupEgress   = "aws_vpc_security_group_egress_rule"
+	tfVpc                   = "aws_vpc"
+	tfSubnet                = "aws_subnet"
+	tfInternetGateway       = "aws_internet_gateway"
+	tfRouteTable            = "aws_route_table"
+	tfRoute                 = "aws_route"
+	tfRouteTableAssociation = "aws_route_table_association"
+	tfDefaultRouteTable     = "aws_default_route_table"
+	tfMainRouteTable        = "aws_main_route_table_association"
+	tfNetworkAcl            = "aws_network_acl"
+	tfDefaultNetworkAcl     = "aws_default_network_acl"
+	tfNetworkAclRule        = "aws_network_acl_rule"
+	tfNetworkAclAssociation = "aws_network_acl_association"
+	tfEip                   = "aws_eip"
+	tfEipAssociation        = "aws_eip_association"
+)
+
+// Id prefixes of resources, so ids only known after apply still tell what they are
+var tfIdPrefixes = map[string]string{
+	tfInstance:                   "i-",
+	tfSecurityGroup:              "sg-",
+	tfDefaultSecurityGroup:       "sg-",
+	tfVpc:                        "vpc-",
+	tfSubnet:                     "subnet-",
+	tfInternetGateway:            "igw-",
+	tfRouteTable:                 "rtb-",
+	tfDefaultRouteTable:          "rtb-",
+	tfNetworkAcl:                 "acl-",
+	tfDefaultNetworkAcl:          "acl-",
+	"aws_nat_gateway":            "nat-",
+	"aws_vpc_peering_connection": "pcx-",
+	"aws_ec2_transit_gateway":    "tgw-",
+	"aws_vpn_gateway":            "vgw-",
+}
+
+// Attributes of routes holding their target, the first one set is the target
+var tfRouteTargets = []string{
+	"gateway_id", "nat_gateway_id", "transit_gateway_id", "vpc_peering_connection_id", "network_interface_id",
+	"vpc_endpoint_id", "egress_only_gateway_id", "local_gateway_id", "carrier_gateway_id", "core_network_arn",
+}
+
+var tfIndex = regexp.MustCompile(`\[[^]]*]`)
+
+// terraformResource is a resource of a plan or a state with its attribute values
+type terraformResource struct {
+	Address string
+	Type    string
+	Values  map[string]any
+	// References are the addresses of what every attribute refers to, to resolve the values
+	// only known after apply
+	References map[string][]string
+}
+
+// terraformOverlay applies Terraform resources on an inventory
+type terraformOverlay struct {
+	inv Inventory
+	// ids are the ids of resources by address, with and without their index
+	ids map[string]string
+	// groups are the rules of every security group, to give instances the rules of their groups
+	groups  map[string]instanceSecurityGroup
+	changed map[string]bool
+}
+
+// overlayTerraform returns a copy of the inventory with the resources created or updated and
+// the removed ones deleted. Resources of unsupported types are left out
+func overlayTerraform(inv Inventory, upserted, removed []terraformResource) (Inventory, error) {
+	clone, err := cloneInventory(inv)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	o := terraformOverlay{
+		inv:     clone,
+		ids:     make(map[string]string, len(upserted)+len(removed)),
+		groups:  make(map[string]instanceSecurityGroup, len(clone.SecurityGroups)),
+		changed: make(map[string]bool),
+	}
+
+	for _, group := range securityGroupsOf(clone) {
+		o.groups[group.Id] = group
+	}
+
+	for _, res := range slices.Concat(removed, upserted) {
+		id := tfString(res.Values, "id")
+		if id == "" {
+			id = tfIdPrefixes[res.Type] + "{" + res.Address + "}"
+		}
+
+		o.ids[res.Address] = id
+		if _, exists := o.ids[tfConfigAddress(res.Address)]; !exists {
+			o.ids[tfConfigAddress(res.Address)] = id
+		}
+	}
+
+	for _, res := range removed {
+		o.remove(res)
+	}
+
+	// Containers first, so what's inside them finds them
+	order := []string{
+		tfVpc, tfSubnet, tfInternetGateway, tfSecurityGroup, tfDefaultSecurityGroup, tfSecurityGroupRule, tfSecurityGroupIngress,
+		tfSecurityGroupEgress, tfRouteTable, tfDefaultRouteTable, tfRoute, tfRouteTableAssociation, tfMainRouteTable,
+		tfNetworkAcl, tfDefaultNetworkAcl, tfNetworkAclRule, tfNetworkAclAssociation, tfInstance, tfEip, tfEipAssociation,
+	}
+
+	for _, resType := range order {
+		for _, res := range upserted {
+			if res.Type == resType {
+				o.upsert(res)
+			}
+		}
+	}
+
+	o.applyGroupRules()
+
+	return o.inv, nil
+}
+
+// tfConfigAddress is the address of the resource in the configuration, without the index
+// of resources with count or for_each
+func tfConfigAddress(address string) string {
+	return tfIndex.ReplaceAllString(address, "")
+}
+
+// value returns the attribute, or the id of the resource it refers to when it's only known
+// after apply
+func (o *terraformOverlay) value(res terraformResource, attribute string) string {
+	if value := tfString(res.Values, attribute); value != "" {
+		return value
+	}
+
+	if ids := o.resolve(res.References[attribute]); len(ids) > 0 {
+		return ids[0]
+	}
+
+	return ""
+}
+
+func (o *terraformOverlay) values(res terraformResource, attribute string) []string {
+	if values := tfStrings(res.Values, attribute); len(values) > 0 {
+		return values
+	}
+
+	return o.resolve(res.References[attribute])
+}
+
+// resolve finds the resources references point to, e.g. aws_security_group.web.id refers
+// to aws_security_group.web. References to variables or data sources are left out
+func (o *terraformOverlay) resolve(references []string) []string {
+	ids := make([]string, 0, len(references))
+	for _, reference := range references {
+		parts := strings.Split(tfConfigAddress(reference), ".")
+		for end := len(parts); end >= 2; end-- {
+			if id, exists := o.ids[strings.Join(parts[:end], ".")]; exists {
+				if !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+				break
+			}
+		}
+	}
+
+	return ids
+}
+
+func (o *terraformOverlay) remove(res terraformResource) {
+	id := o.ids[res.Address]
+	isId := func(assetId string) bool { return assetId == id }
+
+	switch res.Type {
+	case tfInstance:
+		o.inv.Instances = slices.DeleteFunc(o.inv.Instances, func(inst Ec2Instance) bool { return isId(inst.Id) })
+	case tfSecurityGroup, tfDefaultSecurityGroup:
+		o.inv.SecurityGroups = slices.DeleteFunc(o.inv.SecurityGroups, func(group SecurityGroup) bool { return isId(group.Id) })
+		delete(o.groups, id)
+		for idx := range o.inv.Instances {
+			inst := &o.inv.Instances[idx]
+			if slices.Contains(inst.SecurityGroupIds, id) {
+				inst.SecurityGroupIds = slices.DeleteFunc(inst.SecurityGroupIds, isId)
+				o.changed[inst.Id] = true
+			}
+		}
+	case tfSecurityGroupRule, tfSecurityGroupIngress, tfSecurityGroupEgress:
+		groupId, egress, rule := o.rule(res)
+		group, exists := o.groups[groupId]
+		if !exists {
+			return
+		}
+
+		// The rule may be gone already, e.g. with its group
+		if rules, err := revokeRule(selectRules(group.Ingress, group.Egress, egress), rule); err == nil && egress {
+			group.Egress = rules
+		} else if err == nil {
+			group.Ingress = rules
+		}
+		o.setGroup(group)
+	case tfVpc:
+		o.inv.Vpcs = slices.DeleteFunc(o.inv.Vpcs, func(vpc Vpc) bool { return isId(vpc.Id) })
+	case tfNetworkAcl, tfDefaultNetworkAcl:
+		o.inv.NetworkAcls = slices.DeleteFunc(o.inv.NetworkAcls, func(acl NetworkAcl) bool { return isId(acl.Id) })
+	case tfSubnet:
+		o.inv.Subnets = slices.DeleteFunc(o.inv.Subnets, func(subnet Subnet) bool { return isId(subnet.Id) })
+	case tfInternetGateway:
+		o.inv.InternetGateways = slices.DeleteFunc(o.inv.InternetGateways, func(igw InternetGateway) bool { return isId(igw.Id) })
+	case tfRouteTable, tfDefaultRouteTable:
+		o.inv.RouteTables = slices.DeleteFunc(o.inv.RouteTables, func(table RouteTable) bool { return isId(table.Id) })
+	case tfRoute:
+		if idx, err := o.inv.routeTableIndex(o.value(res, "route_table_id")); err == nil {
+			destination := tfRouteDestination(res.Values)
+			o.inv.RouteTables[idx].Routes = slices.DeleteFunc(o.inv.RouteTables[idx].Routes, func(route Route) bool {
+				return route.Destination == destination
+			})
+		}
+	case tfRouteTableAssociation:
+		subnetId := o.value(res, "subnet_id")
+		for idx := range o.inv.RouteTables {
+			o.inv.RouteTables[idx].SubnetIds = slices.DeleteFunc(o.inv.RouteTables[idx].SubnetIds, func(id string) bool { return id == subnetId })
+		}
+	case tfEip, tfEipAssociation:
+		idx, err := o.inv.instanceIndex(o.value(res, "instance"))
+		if err != nil {
+			idx, err = o.inv.instanceIndex(o.value(res, "instance_id"))
+		}
+
+		if err == nil {
+			o.inv.Instances[idx].PublicIP = nil
+		}
+	}
+}
+
+func (o *terraformOverlay) upsert(res terraformResource) {
+	id := o.ids[res.Address]
+
+	switch res.Type {
+	case tfVpc:
+		vpc := Vpc{Id: id, CidrBlocks: tfStrings(map[string]any{"cidrs": []any{res.Values["cidr_block"], res.Values["ipv6_cidr_block"]}}, "cidrs")}
+		o.inv.Vpcs = upsertById(o.inv.Vpcs, vpc, func(v Vpc) string { return v.Id })
+	case tfSubnet:
+		subnet := Subnet{
+			Id:                  id,
+			VPC:                 o.value(res, "vpc_id"),
+			CidrBlock:           tfString(res.Values, "cidr_block"),
+			AvailabilityZone:    tfString(res.Values, "availability_zone"),
+			MapPublicIpOnLaunch: tfBool(res.Values, "map_public_ip_on_launch"),
+		}
+		o.inv.Subnets = upsertById(o.inv.Subnets, subnet, func(s Subnet) string { return s.Id })
+	case tfInternetGateway:
+		igw := InternetGateway{Id: id, VPCs: []string{o.value(res, "vpc_id")}}
+		o.inv.InternetGateways = upsertById(o.inv.InternetGateways, igw, func(i InternetGateway) string { return i.Id })
+	case tfSecurityGroup, tfDefaultSecurityGroup:
+		o.upsertSecurityGroup(res, id)
+	case tfSecurityGroupRule, tfSecurityGroupIngress, tfSecurityGroupEgress:
+		groupId, egress, rule := o.rule(res)
+		group := o.groups[groupId]
+		group.Id = groupId
+
+		// States list rules defined on their own in their group too
+		switch {
+		case egress && !ruleCovered(group.Egress, rule):
+			group.Egress = append(group.Egress, rule)
+		case !egress && !ruleCovered(group.Ingress, rule):
+			group.Ingress = append(group.Ingress, rule)
+		}
+		o.setGroup(group)
+	case tfRouteTable, tfDefaultRouteTable:
+		o.upsertRouteTable(res, id)
+	case tfMainRouteTable:
+		vpc, routeTableId := o.value(res, "vpc_id"), o.value(res, "route_table_id")
+		for idx := range o.inv.RouteTables {
+			table := &o.inv.RouteTables[idx]
+			if table.VPC == vpc {
+				table.Main = table.Id == routeTableId
+			}
+		}
+	case tfNetworkAcl, tfDefaultNetworkAcl:
+		o.upsertNetworkAcl(res, id)
+	case tfNetworkAclRule:
+		if idx := slices.IndexFunc(o.inv.NetworkAcls, func(acl NetworkAcl) bool { return acl.Id == o.value(res, "network_acl_id") }); idx >= 0 {
+			acl := &o.inv.NetworkAcls[idx]
+			acl.Entries = append(acl.Entries, tfNetworkAclEntry(res.Values, tfBool(res.Values, "egress")))
+		}
+	case tfNetworkAclAssociation:
+		o.associateNetworkAcl(o.value(res, "subnet_id"), o.value(res, "network_acl_id"))
+	case tfRoute:
+		idx, err := o.inv.routeTableIndex(o.value(res, "route_table_id"))
+		if err != nil {
+			return
+		}
+
+		route := Route{Destination: tfRouteDestination(res.Values), Target: o.routeTarget(res, "")}
+		table := &o.inv.RouteTables[idx]
+		table.Routes = upsertById(table.Routes, route, func(r Route) string { return r.Destination })
+	case tfRouteTableAssociation:
+		if subnetId := o.value(res, "subnet_id"); subnetId != "" {
+			_ = o.inv.associateRouteTable(subnetId, o.value(res, "route_table_id"))
+		}
+	case tfInstance:
+		o.upsertInstance(res, id)
+	case tfEip, tfEipAssociation:
+		idx, err := o.inv.instanceIndex(o.value(res, "instance"))
+		if err != nil {
+			idx, err = o.inv.instanceIndex(o.value(res, "instance_id"))
+		}
+
+		if err != nil {
+			return
+		}
+
+		publicIp := tfString(res.Values, "public_ip")
+		if publicIp == "" {
+			publicIp = knownAfterApply
+		}
+		o.inv.Instances[idx].PublicIP = ptr.Ref(publicIp)
+	}
+}
+
+func (o *terraformOverlay) upsertSecurityGroup(res terraformResource, id string) {
+	group := SecurityGroup{
+		Id:          id,
+		Name:        tfString(res.Values, "name"),
+		Description: tfString(res.Values, "description"),
+		VPC:         o.value(res, "vpc_id"),
+		OwnerId:     tfString(res.Values, "owner_id"),
+		Tags:        tfTags(res.Values),
+	}
+
+	if res.Type == tfDefaultSecurityGroup {
+		group.Name = defaultSecurityGroupName
+	}
+
+	for _, block := range tfBlocks(res.Values, "ingress") {
+		group.IngressRules = append(group.IngressRules, o.inlineRule(id, block))
+	}
+
+	for _, block := range tfBlocks(res.Values, "egress") {
+		group.EgressRules = append(group.EgressRules, o.inlineRule(id, block))
+	}
+
+	o.inv.SecurityGroups = upsertById(o.inv.SecurityGroups, group, func(g SecurityGroup) string { return g.Id })
+	o.setGroup(instanceSecurityGroup{Id: id, Ingress: group.IngressRules, Egress: group.EgressRules})
+}
+
+// inlineRule converts the ingress and egress blocks of security groups
+func (o *terraformOverlay) inlineRule(groupId string, block map[string]any) Ec2SecGroupRule {
+	rule := Ec2SecGroupRule{
+		GroupId:        groupId,
+		FromPort:       tfInt32(block, "from_port"),
+		ToPort:         tfInt32(block, "to_port"),
+		IpProtocol:     tfProtocol(tfString(block, "protocol")),
+		IpRanges:       slices.Concat(tfStrings(block, "cidr_blocks"), tfStrings(block, "ipv6_cidr_blocks")),
+		SourceGroupIds: tfStrings(block, "security_groups"),
+		PrefixListIds:  tfStrings(block, "prefix_list_ids"),
+	}
+
+	if tfBool(block, "self") {
+		rule.SourceGroupIds = append(rule.SourceGroupIds, groupId)
+	}
+
+	return rule
+}
+
+// rule converts rules defined on their own, which are either aws_security_group_rule or the
+// newer aws_vpc_security_group_ingress_rule and aws_vpc_security_group_egress_rule
+func (o *terraformOverlay) rule(res terraformResource) (string, bool, Ec2SecGroupRule) {
+	groupId := o.value(res, "security_group_id")
+	rule := Ec2SecGroupRule{
+		GroupId:  groupId,
+		FromPort: tfInt32(res.Values, "from_port"),
+		ToPort:   tfInt32(res.Values, "to_port"),
+	}
+
+	if res.Type == tfSecurityGroupRule {
+		rule.IpProtocol = tfProtocol(tfString(res.Values, "protocol"))
+		rule.IpRanges = slices.Concat(tfStrings(res.Values, "cidr_blocks"), tfStrings(res.Values, "ipv6_cidr_blocks"))
+		rule.PrefixListIds = tfStrings(res.Values, "prefix_list_ids")
+		if source := o.value(res, "source_security_group_id"); source != "" {
+			rule.SourceGroupIds = []string{source}
+		}
+
+		if tfBool(res.Values, "self") {
+			rule.SourceGroupIds = []string{groupId}
+		}
+
+		return groupId, tfString(res.Values, "type") == "egress", rule
+	}
+
+	rule.IpProtocol = tfProtocol(tfString(res.Values, "ip_protocol"))
+	rule.IpRanges = slices.DeleteFunc([]string{tfString(res.Values, "cidr_ipv4"), tfString(res.Values, "cidr_ipv6")}, func(cidr string) bool {
+		return cidr == ""
+	})
+	if prefixList := o.value(res, "prefix_list_id"); prefixList != "" {
+		rule.PrefixListIds = []string{prefixList}
+	}
+
+	if source := o.value(res, "referenced_security_group_id"); source != "" {
+		rule.SourceGroupIds = []string{source}
+	}
+
+	return groupId, res.Type == tfSecurityGroupEgress, rule
+}
+
+// setGroup keeps the rules of the group, the fetched group included, and marks its instances
+// to get them
+func (o *terraformOverlay) setGroup(group instanceSecurityGroup) {
+	o.groups[group.Id] = group
+
+	for idx, securityGroup := range o.inv.SecurityGroups {
+		if securityGroup.Id == group.Id {
+			o.inv.SecurityGroups[idx].IngressRules = group.Ingress
+			o.inv.SecurityGroups[idx].EgressRules = group.Egress
+		}
+	}
+
+	for _, inst := range o.inv.Instances {
+		if slices.Contains(inst.SecurityGroupIds, group.Id) {
+			o.changed[inst.Id] = true
+		}
+	}
+}
+
+func (o *terraformOverlay) upsertRouteTable(res terraformResource, id string) {
+	table := RouteTable{Id: id, VPC: o.value(res, "vpc_id"), Main: res.Type == tfDefaultRouteTable}
+	if idx, err := o.inv.routeTableIndex(id); err == nil {
+		// Associations are resources of their own
+		table.Main, table.SubnetIds = table.Main || o.inv.RouteTables[idx].Main, o.inv.RouteTables[idx].SubnetIds
+	}
+
+	// The default route table only refers to its VPC through its id
+	if table.VPC == "" {
+		table.VPC = o.defaultRouteTableVpc(res)
+	}
+
+	for _, cidr := range o.vpcCidrs(table.VPC) {
+		table.Routes = append(table.Routes, Route{Destination: cidr, Target: routeTargetLocal})
+	}
+
+	for _, block := range tfBlocks(res.Values, "route") {
+		table.Routes = append(table.Routes, Route{Destination: tfRouteDestination(block), Target: o.routeTarget(res, tfRouteTargetOf(block))})
+	}
+
+	o.inv.RouteTables = upsertById(o.inv.RouteTables, table, func(t RouteTable) string { return t.Id })
+}
+
+// vpcCidrs are the ranges of the VPC, as Terraform leaves the local route out of route tables
+func (o *terraformOverlay) vpcCidrs(vpcId string) []string {
+	for _, vpc := range o.inv.Vpcs {
+		if vpc.Id == vpcId {
+			return vpc.CidrBlocks
+		}
+	}
+
+	cidrs := make([]string, 0, 1)
+	for _, table := range o.inv.RouteTables {
+		for _, route := range table.Routes {
+			if table.VPC == vpcId && route.Target == routeTargetLocal && !slices.Contains(cidrs, route.Destination) {
+				cidrs = append(cidrs, route.Destination)
+			}
+		}
+	}
+
+	return cidrs
+}
+
+func (o *terraformOverlay) defaultRouteTableVpc(res terraformResource) string {
+	for _, id := range o.resolve(res.References["default_route_table_id"]) {
+		if strings.HasPrefix(id, tfIdPrefixes[tfVpc]) {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// routeTarget returns the target of the route, resolving the gateways only known after apply
+// from what the resource refers to
+func (o *terraformOverlay) routeTarget(res terraformResource, target string) string {
+	if target != "" {
+		return target
+	}
+
+	if target = tfRouteTargetOf(res.Values); target != "" {
+		return target
+	}
+
+	for _, attribute := range tfRouteTargets {
+		if ids := o.resolve(res.References[attribute]); len(ids) > 0 {
+			return ids[0]
+		}
+	}
+
+	return ""
+}
+
+func (o *terraformOverlay) upsertNetworkAcl(res terraformResource, id string) {
+	acl := NetworkAcl{Id: id, VPC: o.value(res, "vpc_id"), Default: res.Type == tfDefaultNetworkAcl}
+	if idx := slices.IndexFunc(o.inv.NetworkAcls, func(other NetworkAcl) bool { return other.Id == id }); idx >= 0 && acl.VPC == "" {
+		acl.VPC = o.inv.NetworkAcls[idx].VPC
+	}
+
+	for _, block := range tfBlocks(res.Values, "ingress") {
+		acl.Entries = append(acl.Entries, tfNetworkAclEntry(block, false))
+	}
+
+	for _, block := range tfBlocks(res.Values, "egress") {
+		acl.Entries = append(acl.Entries, tfNetworkAclEntry(block, true))
+	}
+
+	// Like AWS, every ACL ends denying what no entry allows
+	acl.Entries = append(acl.Entries,
+		NetworkAclEntry{RuleNumber: 32767, Protocol: allProtocols, Cidr: anyIPv4},
+		NetworkAclEntry{RuleNumber: 32767, Egress: true, Protocol: allProtocols, Cidr: anyIPv4},
+	)
+
+	o.inv.NetworkAcls = upsertById(o.inv.NetworkAcls, acl, func(a NetworkAcl) string { return a.Id })
+	for _, subnetId := range o.values(res, "subnet_ids") {
+		o.associateNetworkAcl(subnetId, id)
+	}
+}
+
+// associateNetworkAcl moves the subnet to the network ACL, a subnet has a single one
+func (o *terraformOverlay) associateNetworkAcl(subnetId, aclId string) {
+	for idx := range o.inv.NetworkAcls {
+		acl := &o.inv.NetworkAcls[idx]
+		acl.SubnetIds = slices.DeleteFunc(acl.SubnetIds, func(id string) bool { return id == subnetId })
+		if acl.Id == aclId {
+			acl.SubnetIds = append(acl.SubnetIds, subnetId)
+		}
+	}
+}
+
+// tfNetworkAclEntry converts the ingress and egress blocks of network ACLs, and the network
+// ACL rules defined on their own
+func tfNetworkAclEntry(values map[string]any, egress bool) NetworkAclEntry {
+	cidr := tfString(values, "cidr_block")
+	if cidr == "" {
+		cidr = tfString(values, "ipv6_cidr_block")
+	}
+
+	ruleNumber := tfInt32(values, "rule_no")
+	if ruleNumber == 0 {
+		ruleNumber = tfInt32(values, "rule_number")
+	}
+
+	action := tfString(values, "action")
+	if action == "" {
+		action = tfString(values, "rule_action")
+	}
+
+	return NetworkAclEntry{
+		RuleNumber: ruleNumber,
+		Egress:     egress,
+		Protocol:   normalizeProtocol(tfProtocol(tfString(values, "protocol"))),
+		FromPort:   tfInt32(values, "from_port"),
+		ToPort:     tfInt32(values, "to_port"),
+		Cidr:       cidr,
+		Allow:      strings.EqualFold(action, "allow"),
+	}
+}
+
+func tfRouteTargetOf(values map[string]any) string {
+	for _, attribute := range tfRouteTargets {
+		if target := tfString(values, attribute); target != "" {
+			return target
+		}
+	}
+
+	return ""
+}
+
+func tfRouteDestination(values map[string]any) string {
+	for _, attribute := range []string{"cidr_block", "destination_cidr_block", "ipv6_cidr_block", "destination_ipv6_cidr_block", "destination_prefix_list_id"} {
+		if destination := tfString(values, attribute); destination != "" {
+			return destination
+		}
+	}
+
+	return ""
+}
+
+func (o *terraformOverlay) upsertInstance(res terraformResource, id string) {
+	inst := Ec2Instance{Id: id}
+	if idx, err := o.inv.instanceIndex(id); err == nil {
+		inst = o.inv.Instances[idx]
+	}
+
+	inst.SubnetId = o.value(res, "subnet_id")
+	inst.ImageId = tfString(res.Values, "ami")
+	inst.PrivateIP = tfString(res.Values, "private_ip")
+	inst.SecurityGroupIds = o.values(res, "vpc_security_group_ids")
+	inst.Tags = tfTags(res.Values)
+
+	for _, subnet := range o.inv.Subnets {
+		if subnet.Id == inst.SubnetId {
+			inst.VPC = subnet.VPC
+		}
+	}
+
+	if keyName := tfString(res.Values, "key_name"); keyName != "" {
+		inst.SSHKeyPairName = ptr.Ref(keyName)
+	}
+
+	if profile := o.value(res, "iam_instance_profile"); profile != "" {
+		inst.IamInstanceProfile = ptr.Ref(o.instanceProfileArn(profile))
+	}
+
+	if options := tfBlocks(res.Values, "metadata_options"); len(options) > 0 {
+		inst.MetadataHttpTokens = tfString(options[0], "http_tokens")
+	}
+
+	publicAddress := tfBool(res.Values, "associate_public_ip_address")
+	if _, isSet := res.Values["associate_public_ip_address"]; !isSet {
+		publicAddress = slices.ContainsFunc(o.inv.Subnets, func(subnet Subnet) bool {
+			return subnet.Id == inst.SubnetId && subnet.MapPublicIpOnLaunch
+		})
+	}
+
+	switch publicIp := tfString(res.Values, "public_ip"); {
+	case publicIp != "":
+		inst.PublicIP = ptr.Ref(publicIp)
+	case publicAddress && ptr.IsEmpty(inst.PublicIP):
+		inst.PublicIP = ptr.Ref(knownAfterApply)
+	case !publicAddress:
+		inst.PublicIP = nil
+	}
+
+	o.inv.Instances = upsertById(o.inv.Instances, inst, func(i Ec2Instance) string { return i.Id })
+	o.changed[id] = true
+}
+
+// instanceProfileArn returns the ARN of the instance profile Terraform refers to by name,
+// from the fetched profiles when known
+func (o *terraformOverlay) instanceProfileArn(name string) string {
+	if strings.HasPrefix(name, "arn:") {
+		return name
+	}
+
+	for _, profile := range o.inv.InstanceProfiles {
+		if instanceProfileName(profile.Arn) == name {
+			return profile.Arn
+		}
+	}
+
+	return fmt.Sprintf("arn:aws:iam::%s:instance-profile/%s", o.inv.AccountId, name)
+}
+
+// applyGroupRules gives changed instances the rules of their groups. Rules of groups nothing
+// is known about are kept as they were
+func (o *terraformOverlay) applyGroupRules() {
+	for idx := range o.inv.Instances {
+		inst := &o.inv.Instances[idx]
+		if !o.changed[inst.Id] {
+			continue
+		}
+
+		ingress := make([]Ec2SecGroupRule, 0, len(inst.IngressSecRules))
+		egress := make([]Ec2SecGroupRule, 0, len(inst.EgressSecRules))
+		for _, groupId := range inst.SecurityGroupIds {
+			group, known := o.groups[groupId]
+			if !known {
+				inGroup := func(rule Ec2SecGroupRule) bool { return rule.GroupId == groupId }
+				ingress = append(ingress, filterRules(inst.IngressSecRules, inGroup)...)
+				egress = append(egress, filterRules(inst.EgressSecRules, inGroup)...)
+				continue
+			}
+
+			ingress = append(ingress, group.Ingress...)
+			egress = append(egress, group.Egress...)
+		}
+
+		inst.IngressSecRules, inst.EgressSecRules = ingress, egress
+	}
+}
+
+// ruleCovered tells whether a rule with the same protocol and ports allows every source of the
+// rule already
+func ruleCovered(rules []Ec2SecGroupRule, rule Ec2SecGroupRule) bool {
+	return slices.ContainsFunc(rules, func(other Ec2SecGroupRule) bool {
+		if normalizeProtocol(other.IpProtocol) != normalizeProtocol(rule.IpProtocol) || other.FromPort != rule.FromPort || other.ToPort != rule.ToPort {
+			return false
+		}
+
+		for _, sources := range [][2][]string{{rule.IpRanges, other.IpRanges}, {rule.SourceGroupIds, other.SourceGroupIds}, {rule.PrefixListIds, other.PrefixListIds}} {
+			for _, source := range sources[0] {
+				if !slices.Contains(sources[1], source) {
+					return false
+				}
+			}
+		}
+
+		return true
+	})
+}
+
+func filterRules(rules []Ec2SecGroupRule, keep func(Ec2SecGroupRule) bool) []Ec2SecGroupRule {
+	filtered := make([]Ec2SecGroupRule, 0, len(rules))
+	for _, rule := range rules {
+		if keep(rule) {
+			filtered = append(filtered, rule)
+		}
+	}
+
+	return filtered
+}
+
+// upsertById replaces the item with the same id, or adds it
+func upsertById[T any](items []T, item T, id func(T) string) []T {
+	if idx := slices.IndexFunc(items, func(other T) bool { return id(other) == id(item) }); idx >= 0 {
+		items[idx] = item
+		return items
+	}
+
+	return append(items, item)
+}
+
+// tfProtocol converts protocols to the values of the EC2 API, where all protocols are -1
+func tfProtocol(protocol string) string {
+	if strings.EqualFold(protocol, "all") {
+		return allProtocols
+	}
+
+	return strings.ToLower(protocol)
+}
+
+func tfString(values map[string]any, attribute string) string {
+	switch value := values[attribute].(type) {
+	case string:
+		return value
+	case float64:
+		return fmt.Sprint(value)
+	default:
+		return ""
+	}
+}
+
+// tfBool and tfInt32 also read strings, which CloudFormation takes for every property
+func tfBool(values map[string]any, attribute string) bool {
+	switch value := values[attribute].(type) {
+	case bool:
+		return value
+	case string:
+		parsed, _ := strconv.ParseBool(value)
+		return parsed
+	default:
+		return false
+	}
+}
+
+func tfInt32(values map[string]any, attribute string) int32 {
+	switch value := values[attribute].(type) {
+	case float64:
+		return int32(value)
+	case string:
+		parsed, _ := strconv.ParseInt(value, 10, 32)
+		return int32(parsed)
+	default:
+		return 0
+	}
+}
+
+func tfStrings(values map[string]any, attribute string) []string {
+	list, _ := values[attribute].([]any)
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if str, isString := item.(string); isString && str != "" {
+			strs = append(strs, str)
+		}
+	}
+
+	return strs
+}
+
+// tfBlocks returns nested blocks, like the ingress rules of security groups
+func tfBlocks(values map[string]any, attribute string) []map[string]any {
+	list, _ := values[attribute].([]any)
+	blocks := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if block, isBlock := item.(map[string]any); isBlock {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
+}
+
+func tfTags(values map[string]any) map[string]string {
+	tags, _ := values["tags"].(map[string]any)
+	converted := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if str, isString := value.(string); isString {
+			converted[key] = str
+		}
+	}
+
+	return converted
+}
@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Actions of resource changes in Terraform plans
+const (
+	tfActionCreate = "create"
+	tfActionUpdate = "update"
+	tfActionDelete = "delete"
+)
+
+// TerraformPlan is a plan as output by terraform show -json
+type TerraformPlan struct {
+	ResourceChanges []terraformResourceChange `json:"resource_changes"`
+	Configuration   struct {
+		RootModule terraformModule `json:"root_module"`
+	} `json:"configuration"`
+}
+
+type terraformResourceChange struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Change  struct {
+		Actions []string       `json:"actions"`
+		Before  map[string]any `json:"before"`
+		After   map[string]any `json:"after"`
+	} `json:"change"`
+}
+
+// terraformModule is the configuration of a module, which tells what the values only known
+// after apply refer to
+type terraformModule struct {
+	Resources []struct {
+		Address     string                     `json:"address"`
+		Expressions map[string]json.RawMessage `json:"expressions"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module terraformModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+type terraformExpression struct {
+	References []string `json:"references"`
+}
+
+func ParseTerraformPlan(data []byte) (TerraformPlan, error) {
+	var plan TerraformPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, fmt.Errorf("invalid plan, expected the output of terraform show -json: %w", err)
+	}
+
+	return plan, nil
+}
+
+// PlanFindings overlays the plan on the inventory and tells the findings it introduces and
+// resolves. Findings of rules aren't part of it, as they match over the stored graph
+func PlanFindings(inv Inventory, plan TerraformPlan, catalog ServiceCatalog, networks NetworkClassifier) (SimulationDiff, error) {
+	upserted, removed := plan.resources()
+
+	planned, err := overlayTerraform(inv, upserted, removed)
+	if err != nil {
+		return SimulationDiff{}, err
+	}
+
+	return diffInventories(inv, planned, catalog, networks), nil
+}
+
+// resources splits the changes of managed resources into what the plan creates or updates and
+// what it deletes. Replaced resources are both
+func (p TerraformPlan) resources() ([]terraformResource, []terraformResource) {
+	references := make(map[string]map[string][]string)
+	p.Configuration.RootModule.collectReferences("", references)
+
+	upserted := make([]terraformResource, 0, len(p.ResourceChanges))
+	removed := make([]terraformResource, 0, len(p.ResourceChanges))
+	for _, change := range p.ResourceChanges {
+		if change.Mode != "managed" {
+			continue
+		}
+
+		actions := change.Change.Actions
+		if slices.Contains(actions, tfActionDelete) {
+			removed = append(removed, terraformResource{Address: change.Address, Type: change.Type, Values: change.Change.Before})
+		}
+
+		if slices.Contains(actions, tfActionCreate) || slices.Contains(actions, tfActionUpdate) {
+			values := change.Change.After
+			if values == nil {
+				values = map[string]any{}
+			}
+
+			// Replaced resources get a new id, keeping the old one keeps the identity of findings
+			if _, known := values["id"]; !known && change.Change.Before != nil {
+				values["id"] = change.Change.Before["id"]
+			}
+
+			upserted = append(upserted, terraformResource{
+				Address:    change.Address,
+				Type:       change.Type,
+				Values:     values,
+				References: references[tfConfigAddress(change.Address)],
+			})
+		}
+	}
+
+	return upserted, removed
+}
+
+// collectReferences indexes the references of every attribute by resource address. Addresses
+// within modules are relative to them, they get the address of the module in front
+func (m terraformModule) collectReferences(prefix string, references map[string]map[string][]string) {
+	for _, resource := range m.Resources {
+		attributes := make(map[string][]string, len(resource.Expressions))
+		for attribute, raw := range resource.Expressions {
+			var expression terraformExpression
+			if err := json.Unmarshal(raw, &expression); err != nil {
+				// Nested blocks are lists of expressions, their references aren't resolved
+				continue
+			}
+
+			for _, reference := range expression.References {
+				attributes[attribute] = append(attributes[attribute], prefix+reference)
+			}
+		}
+
+		references[prefix+resource.Address] = attributes
+	}
+
+	for name, call := range m.ModuleCalls {
+		call.Module.collectReferences(prefix+"module."+name+".", references)
+	}
+}
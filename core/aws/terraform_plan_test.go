@@ -0,0 +1,128 @@
+package aws
+
+import (
+	"testing"
+)
+
+const terraformPlanJson = `{
+  "resource_changes": [
+    {
+      "address": "aws_security_group.web",
+      "mode": "managed",
+      "type": "aws_security_group",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "sg-web", "vpc_id": "vpc-1"},
+        "after": {"id": "sg-web", "vpc_id": "vpc-1", "ingress": [
+          {"from_port": 443, "to_port": 443, "protocol": "tcp", "cidr_blocks": ["0.0.0.0/0"], "self": false}
+        ], "egress": []}
+      }
+    },
+    {
+      "address": "module.bastion.aws_security_group.ssh",
+      "mode": "managed",
+      "type": "aws_security_group",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"vpc_id": "vpc-1", "ingress": [
+          {"from_port": 22, "to_port": 22, "protocol": "tcp", "cidr_blocks": ["0.0.0.0/0"], "self": false}
+        ]}
+      }
+    },
+    {
+      "address": "module.bastion.aws_instance.bastion[0]",
+      "mode": "managed",
+      "type": "aws_instance",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"ami": "ami-1", "subnet_id": "subnet-public", "associate_public_ip_address": true}
+      }
+    }
+  ],
+  "configuration": {
+    "root_module": {
+      "module_calls": {
+        "bastion": {
+          "module": {
+            "resources": [
+              {"address": "aws_instance.bastion", "expressions": {
+                "vpc_security_group_ids": {"references": ["aws_security_group.ssh.id", "aws_security_group.ssh"]}
+              }}
+            ]
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestPlanFindings(t *testing.T) {
+	inv := reachabilityInventory()
+	inv.Subnets = []Subnet{{Id: "subnet-public", VPC: "vpc-1"}}
+	inv.Instances[0].SecurityGroupIds = []string{"sg-web"}
+	inv.Instances[0].IngressSecRules[0].GroupId = "sg-web"
+
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+
+	plan, err := ParseTerraformPlan([]byte(terraformPlanJson))
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	diff, err := PlanFindings(inv, plan, catalog, classifier)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	bastion := "i-{module.bastion.aws_instance.bastion[0]}"
+	if len(diff.NewFindings) != 1 || diff.NewFindings[0].Asset.AssetId != bastion || diff.NewFindings[0].Severity != SeverityHigh {
+		t.Errorf("expected a high severity finding for the new bastion, got %+v", diff.NewFindings)
+	}
+
+	if len(diff.ResolvedFindings) != 1 || diff.ResolvedFindings[0].Asset.AssetId != "i-public" {
+		t.Errorf("expected the SSH finding of i-public to be resolved, got %+v", diff.ResolvedFindings)
+	}
+}
+
+const terraformDetachPlanJson = `{
+  "resource_changes": [
+    {
+      "address": "aws_eip_association.public",
+      "mode": "managed",
+      "type": "aws_eip_association",
+      "change": {
+        "actions": ["delete"],
+        "before": {"id": "eipassoc-1", "instance_id": "i-public", "allocation_id": "eipalloc-1"},
+        "after": null
+      }
+    },
+    {
+      "address": "aws_eip.spare",
+      "mode": "managed",
+      "type": "aws_eip",
+      "change": {"actions": ["update"], "before": {"id": "eipalloc-2"}, "after": null}
+    }
+  ]
+}`
+
+func TestPlanFindingsDetachedAddress(t *testing.T) {
+	catalog, _ := NewServiceCatalog(nil)
+	classifier, _ := NewNetworkClassifier(nil)
+
+	plan, err := ParseTerraformPlan([]byte(terraformDetachPlanJson))
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	diff, err := PlanFindings(reachabilityInventory(), plan, catalog, classifier)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(diff.NewFindings) != 0 || len(diff.ResolvedFindings) != 1 || diff.ResolvedFindings[0].Asset.AssetId != "i-public" {
+		t.Errorf("expected the SSH finding of i-public to be resolved, got %+v", diff)
+	}
+}
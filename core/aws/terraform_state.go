@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TerraformStateSource loads the inventory from a Terraform state, either the state file
+// itself or the output of terraform show -json
+type TerraformStateSource struct {
+	path string
+}
+
+func NewTerraformStateSource(path string) TerraformStateSource {
+	return TerraformStateSource{path: path}
+}
+
+func (t TerraformStateSource) Name() string {
+	return "Terraform state " + t.path
+}
+
+// terraformState holds both formats: the state file lists resources with their instances, the
+// JSON output nests modules under values
+type terraformState struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any            `json:"index_key"`
+			Attributes map[string]any `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+	Values *struct {
+		RootModule terraformStateModule `json:"root_module"`
+	} `json:"values"`
+}
+
+type terraformStateModule struct {
+	Resources []struct {
+		Address string         `json:"address"`
+		Mode    string         `json:"mode"`
+		Type    string         `json:"type"`
+		Values  map[string]any `json:"values"`
+	} `json:"resources"`
+	ChildModules []terraformStateModule `json:"child_modules"`
+}
+
+func (t TerraformStateSource) Load(_ context.Context) (Inventory, error) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	var state terraformState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return Inventory{}, fmt.Errorf("invalid Terraform state: %w", err)
+	}
+
+	resources := state.resources()
+	inv, err := overlayTerraform(Inventory{}, resources, nil)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	inv.AccountId, inv.Region = tfAccountAndRegion(resources)
+
+	return markMissingData(inv), nil
+}
+
+func (s terraformState) resources() []terraformResource {
+	if s.Values != nil {
+		return s.Values.RootModule.resources()
+	}
+
+	resources := make([]terraformResource, 0, len(s.Resources))
+	for _, resource := range s.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+
+		address := resource.Type + "." + resource.Name
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+
+		for _, instance := range resource.Instances {
+			indexed := address
+			switch key := instance.IndexKey.(type) {
+			case float64:
+				indexed += fmt.Sprintf("[%d]", int(key))
+			case string:
+				indexed += fmt.Sprintf("[%q]", key)
+			}
+
+			resources = append(resources, terraformResource{Address: indexed, Type: resource.Type, Values: instance.Attributes})
+		}
+	}
+
+	return resources
+}
+
+func (m terraformStateModule) resources() []terraformResource {
+	resources := make([]terraformResource, 0, len(m.Resources))
+	for _, resource := range m.Resources {
+		if resource.Mode == "managed" {
+			resources = append(resources, terraformResource{Address: resource.Address, Type: resource.Type, Values: resource.Values})
+		}
+	}
+
+	for _, child := range m.ChildModules {
+		resources = append(resources, child.resources()...)
+	}
+
+	return resources
+}
+
+// tfAccountAndRegion finds the account and region of the state in the ARNs of its resources,
+// e.g. arn:aws:ec2:eu-west-1:123456789012:instance/i-1
+func tfAccountAndRegion(resources []terraformResource) (string, string) {
+	for _, resource := range resources {
+		parts := strings.Split(tfString(resource.Values, "arn"), ":")
+		if len(parts) > 5 && parts[2] == "ec2" && parts[3] != "" && parts[4] != "" {
+			return parts[4], parts[3]
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"asset-relations/support/config"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Classes of the sources allowed by a security group rule, from the most to the least trusted
+const (
+	ClassTrusted        = "trusted"
+	ClassInternal       = "internal"
+	ClassKnownPartner   = "known-partner"
+	ClassBroadUntrusted = "broad-untrusted"
+	ClassInternet       = "internet"
+)
+
+// Kinds of the configured networks. Office and VPN ranges are trusted, partner ranges are
+// known but not ours
+const (
+	NetworkKindTrusted = "trusted"
+	NetworkKindPartner = "partner"
+)
+
+// Private, shared address space and unique local ranges can only come from inside our networks
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+type TrustedNetwork struct {
+	Name     string
+	Kind     string
+	Prefixes []netip.Prefix
+}
+
+// SourceClass is the classification of a range allowed by a rule. Network is the name of the
+// configured network containing it, if any
+type SourceClass struct {
+	Range   string
+	Class   string
+	Network string
+}
+
+// NetworkClassifier classifies rule sources against the configured trusted networks
+type NetworkClassifier struct {
+	networks []TrustedNetwork
+}
+
+func NewNetworkClassifier(networks []config.TrustedNetworkConfig) (NetworkClassifier, error) {
+	classifier := NetworkClassifier{networks: make([]TrustedNetwork, 0, len(networks))}
+	for _, network := range networks {
+		trusted := TrustedNetwork{Name: network.Name, Kind: strings.ToLower(network.Kind)}
+		if trusted.Name == "" {
+			return classifier, errors.New("trusted network without a name")
+		}
+
+		if trusted.Kind != NetworkKindTrusted && trusted.Kind != NetworkKindPartner {
+			return classifier, fmt.Errorf("invalid trusted network %q: kind must be %s or %s", network.Name, NetworkKindTrusted, NetworkKindPartner)
+		}
+
+		for _, cidr := range network.Cidrs {
+			prefix, valid := parsePrefix(cidr)
+			if !valid {
+				return classifier, fmt.Errorf("invalid trusted network %q: invalid CIDR %s", network.Name, cidr)
+			}
+
+			trusted.Prefixes = append(trusted.Prefixes, prefix)
+		}
+
+		classifier.networks = append(classifier.networks, trusted)
+	}
+
+	return classifier, nil
+}
+
+// Classify tells how much a rule source is trusted. A source only belongs to a network when
+// all its addresses are in it, so 10.0.0.0/8 isn't trusted because the VPN is 10.8.0.0/16.
+// Sources that can't be parsed, like prefix lists, are untrusted
+func (c NetworkClassifier) Classify(ipRange string) SourceClass {
+	source := SourceClass{Range: ipRange, Class: ClassBroadUntrusted}
+	if ipRange == anyIPv4 || ipRange == anyIPv6 {
+		source.Class = ClassInternet
+		return source
+	}
+
+	prefix, valid := parsePrefix(ipRange)
+	if !valid {
+		return source
+	}
+
+	for _, kind := range []string{NetworkKindTrusted, NetworkKindPartner} {
+		for _, network := range c.networks {
+			if network.Kind == kind && slices.ContainsFunc(network.Prefixes, func(p netip.Prefix) bool { return prefixContains(p, prefix) }) {
+				source.Network = network.Name
+				source.Class = ClassTrusted
+				if kind == NetworkKindPartner {
+					source.Class = ClassKnownPartner
+				}
+
+				return source
+			}
+		}
+	}
+
+	if slices.ContainsFunc(internalPrefixes, func(p netip.Prefix) bool { return prefixContains(p, prefix) }) {
+		source.Class = ClassInternal
+	}
+
+	return source
+}
+
+func (c NetworkClassifier) ClassifyAll(ipRanges []string) []SourceClass {
+	sources := make([]SourceClass, 0, len(ipRanges))
+	for _, ipRange := range ipRanges {
+		sources = append(sources, c.Classify(ipRange))
+	}
+
+	return sources
+}
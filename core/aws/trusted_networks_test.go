@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"asset-relations/support/config"
+	"asset-relations/support/ptr"
+	"testing"
+)
+
+func TestClassifySources(t *testing.T) {
+	classifier, err := NewNetworkClassifier([]config.TrustedNetworkConfig{
+		{Name: "vpn", Kind: "trusted", Cidrs: []string{"198.51.100.0/24"}},
+		{Name: "acme", Kind: "partner", Cidrs: []string{"192.0.2.0/28"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]SourceClass{
+		"0.0.0.0/0":        {Range: "0.0.0.0/0", Class: ClassInternet},
+		"198.51.100.10/32": {Range: "198.51.100.10/32", Class: ClassTrusted, Network: "vpn"},
+		"198.51.0.0/16":    {Range: "198.51.0.0/16", Class: ClassBroadUntrusted},
+		"192.0.2.1":        {Range: "192.0.2.1", Class: ClassKnownPartner, Network: "acme"},
+		"10.1.0.0/16":      {Range: "10.1.0.0/16", Class: ClassInternal},
+		"52.0.0.0/8":       {Range: "52.0.0.0/8", Class: ClassBroadUntrusted},
+		"pl-12345678":      {Range: "pl-12345678", Class: ClassBroadUntrusted},
+	}
+
+	for ipRange, expected := range cases {
+		if got := classifier.Classify(ipRange); got != expected {
+			t.Errorf("%s: expected %+v, got %+v", ipRange, expected, got)
+		}
+	}
+}
+
+func TestServiceExposureUntrustedRanges(t *testing.T) {
+	classifier, _ := NewNetworkClassifier([]config.TrustedNetworkConfig{
+		{Name: "vpn", Kind: "trusted", Cidrs: []string{"198.51.100.0/24"}},
+	})
+	catalog, _ := NewServiceCatalog(nil)
+
+	inv := Inventory{Instances: []Ec2Instance{{Id: "i-1", PublicIP: ptr.Ref("3.3.3.3"), IngressSecRules: []Ec2SecGroupRule{
+		{FromPort: 22, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"198.51.100.0/24"}},
+		{FromPort: 20, ToPort: 22, IpProtocol: "tcp", IpRanges: []string{"52.0.0.0/8"}},
+	}}}}
+
+	exposures := computeServiceExposures(inv, catalog, classifier)
+	if len(exposures) != 2 {
+		t.Fatalf("expected ssh and ftp exposures, got %+v", exposures)
+	}
+
+	ssh := exposures[0]
+	if ssh.Service != "ssh" || ssh.OpenToInternet || len(ssh.UntrustedRanges()) != 1 || ssh.UntrustedRanges()[0] != "52.0.0.0/8" {
+		t.Errorf("unexpected ssh exposure %+v", ssh)
+	}
+
+	for _, finding := range buildFindings(inv, nil, exposures, catalog) {
+		if finding.Type == "ssh-open-to-untrusted" && finding.Severity != SeverityMedium {
+			t.Errorf("expected untrusted findings one level less severe, got %+v", finding)
+		}
+	}
+}
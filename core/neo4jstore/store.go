@@ -34,13 +34,22 @@ func NewNeo4jDataStore(ctx context.Context, logger *slog.Logger, cfg config.Neo4
 	return store, nil
 }
 
-const initQuery = `
-	CREATE INDEX ec2Id IF NOT EXISTS FOR (n:Ec2Instance) ON (n.id)
-`
+var initQueries = []string{
+	`CREATE INDEX ec2Id IF NOT EXISTS FOR (n:Ec2Instance) ON (n.id)`,
+	`CREATE INDEX ipAddress IF NOT EXISTS FOR (n:IpAddress) ON (n.address)`,
+	`CREATE INDEX dnsRecordId IF NOT EXISTS FOR (n:DnsRecord) ON (n.id)`,
+	`CREATE INDEX findingId IF NOT EXISTS FOR (n:Finding) ON (n.id)`,
+}
 
 func (n *Neo4jDataStore) initDB(ctx context.Context) error {
 	n.logger.Info("Initializing DB")
-	return n.write(ctx, initQuery, nil)
+	for _, query := range initQueries {
+		if err := n.write(ctx, query, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Use MERGE as create or update statement
@@ -55,33 +64,31 @@ const mergeInstanceQuery = `
 		VPCId: 				$_POS_.VPCId,
 		openIngressPorts: 	$_POS_.openIngressPorts,
         openEgressPorts:	$_POS_.openEgressPorts,
+		imageId: 			$_POS_.imageId,
 		version: COALESCE(n_POS_.version, 0) + 1
 	}
 `
 
 func (n *Neo4jDataStore) StoreInstances(ctx context.Context, instances []aws.Ec2Instance) error {
 	n.logger.Info("Storing ec2 instances")
-	b := strings.Builder{}
-	params := make(map[string]any, len(instances))
+	nodes := make([]map[string]any, 0, len(instances))
 
-	for idx, inst := range instances {
-		pos := fmt.Sprintf("v%d", idx)
-		params[pos] = map[string]any{
+	for _, inst := range instances {
+		nodes = append(nodes, map[string]any{
 			"id":               inst.Id,
 			"isOpenToInternet": inst.IsOpenToInternet(),
-			"hasSSHPortOpen":   inst.HasSSHPortOpen(),
-			"SSHOpenToIps":     inst.GetSSHOpenToIpRanges(),
-			"hasRDPPortOpen":   inst.HasRDPPortOpen(),
-			"RDPOpenToIps":     inst.GetRDPOpenToIpRanges(),
+			"hasSSHPortOpen":   inst.HasPortOpen(aws.SSHPort),
+			"SSHOpenToIps":     inst.GetPortOpenToIpRanges(aws.SSHPort),
+			"hasRDPPortOpen":   inst.HasPortOpen(aws.RDPPort),
+			"RDPOpenToIps":     inst.GetPortOpenToIpRanges(aws.RDPPort),
 			"VPCId":            inst.VPC,
 			"openIngressPorts": inst.GetOpenIngressPorts(),
 			"openEgressPorts":  inst.GetOpenEgressPorts(),
-		}
-
-		b.WriteString(strings.ReplaceAll(mergeInstanceQuery, "_POS_", pos))
+			"imageId":          inst.ImageId,
+		})
 	}
 
-	return n.write(ctx, b.String(), params)
+	return n.replaceNodes(ctx, "Ec2Instance", mergeInstanceQuery, nodes)
 }
 
 // Use MERGE as create or update statement
@@ -142,12 +149,10 @@ func buildVPCArg(fromInst, toInst aws.Ec2Instance, vpcIdAcc, fromIdx, toIdx int)
 }
 
 const matchInstancesOpenToTheInternetQuery = `
-	MATCH(n:Ec2Instance) 
-	WHERE 
-		n.isOpenToInternet = true 
-		AND n.hasSSHPortOpen = true  
-		AND n.SSHOpenToIps = '0.0.0.0/0' 
-	RETURN(n)
+	MATCH(n:Ec2Instance)-[r:EXPOSES]->(:Service {name: 'ssh'})
+	WHERE r.openToInternet = true
+	RETURN n, r
+	ORDER BY n.id
 `
 
 func (n *Neo4jDataStore) GetInstancesWithOpenSSH(ctx context.Context) ([]map[string]any, error) {
@@ -156,16 +161,20 @@ func (n *Neo4jDataStore) GetInstancesWithOpenSSH(ctx context.Context) ([]map[str
 		return nil, err
 	}
 
-	return extractPropsFromNodes(records, "n"), nil
+	return extractPropsWithRelationship(records, "n", "r", "exposure"), nil
 }
 
+// Partially open instances have a public address and allow SSH from public ranges we don't
+// trust or from partners, but not from the whole internet. Sources only in the office, VPN or
+// private ranges aren't reported
 const matchInstancesPartiallyOpenToTheInternetQuery = `
-	MATCH(n:Ec2Instance) 
-	WHERE 
-		n.isOpenToInternet = true 
-		AND n.hasSSHPortOpen = true  
-		AND n.SSHOpenToIps <> '0.0.0.0/0' 
-	RETURN(n)
+	MATCH(n:Ec2Instance)-[r:EXPOSES]->(:Service {name: 'ssh'})
+	WHERE
+		r.publicAddress = true
+		AND r.openToInternet = false
+		AND ANY(class IN r.sourceClasses WHERE class IN ['broad-untrusted', 'known-partner'])
+	RETURN n, r
+	ORDER BY n.id
 `
 
 func (n *Neo4jDataStore) GetInstancesWithPartiallyOpenSSH(ctx context.Context) ([]map[string]any, error) {
@@ -174,6 +183,41 @@ func (n *Neo4jDataStore) GetInstancesWithPartiallyOpenSSH(ctx context.Context) (
 		return nil, err
 	}
 
+	return extractPropsWithRelationship(records, "n", "r", "exposure"), nil
+}
+
+const matchInstanceQuery = `
+	MATCH(n:Ec2Instance {id: $id})
+	RETURN(n)
+`
+
+// GetInstance returns the stored instance, or nil if it's unknown
+func (n *Neo4jDataStore) GetInstance(ctx context.Context, id string) (map[string]any, error) {
+	records, err := n.read(ctx, matchInstanceQuery, map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	instances := extractPropsFromNodes(records, "n")
+	if len(instances) == 0 {
+		return nil, nil
+	}
+
+	return instances[0], nil
+}
+
+const matchInstancesByExposureQuery = `
+	MATCH(n:Ec2Instance)
+	WHERE n.exposure = $exposure
+	RETURN(n)
+`
+
+func (n *Neo4jDataStore) GetInstancesByExposure(ctx context.Context, exposure string) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchInstancesByExposureQuery, map[string]any{"exposure": exposure})
+	if err != nil {
+		return nil, err
+	}
+
 	return extractPropsFromNodes(records, "n"), nil
 }
 
@@ -193,6 +237,27 @@ func (n *Neo4jDataStore) GetInstancesInVPC(ctx context.Context, id string) ([]ma
 	return extractPropsFromNodes(records, "o"), nil
 }
 
+// extractPropsWithRelationship returns the props of every node with the props of the
+// relationship it was matched through under relationshipProp
+func extractPropsWithRelationship(records []*neo4j.Record, nodeKey, relationshipKey, relationshipProp string) []map[string]any {
+	nodes := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		value, _ := record.Get(nodeKey)
+		relationshipValue, _ := record.Get(relationshipKey)
+		node, isNode := value.(dbtype.Node)
+		relationship, isRelationship := relationshipValue.(dbtype.Relationship)
+		if !isNode || !isRelationship {
+			continue
+		}
+
+		props := node.Props
+		props[relationshipProp] = relationship.Props
+		nodes = append(nodes, props)
+	}
+
+	return nodes
+}
+
 func extractPropsFromNodes(records []*neo4j.Record, variableName string) []map[string]any {
 	response := make([]map[string]any, 0, len(records))
 	for _, record := range records {
@@ -201,7 +266,13 @@ func extractPropsFromNodes(records []*neo4j.Record, variableName string) []map[s
 			continue
 		}
 
-		response = append(response, props.(dbtype.Node).Props)
+		// Values of OPTIONAL MATCH might not be a node
+		node, isNode := props.(dbtype.Node)
+		if !isNode {
+			continue
+		}
+
+		response = append(response, node.Props)
 	}
 
 	return response
@@ -211,6 +282,58 @@ func (n *Neo4jDataStore) Close(ctx context.Context) error {
 	return n.driver.Close(ctx)
 }
 
+// replaceLabel sets the node label of queries where it's only known at runtime, as labels
+// can't be query parameters
+func replaceLabel(query, label string) string {
+	return strings.ReplaceAll(query, "_LABEL_", label)
+}
+
+// Assets gone since the last fetch are deleted with their relationships. The label and the
+// key property are replaced before running the query
+const pruneNodesQuery = `
+	MATCH (n:_LABEL_) WHERE NOT n._KEY_ IN $keys
+	DETACH DELETE n
+`
+
+// replaceNodes merges the nodes, keyed by id, and deletes the nodes of the label that aren't
+// among them anymore, so nothing is raised on assets that are gone
+func (n *Neo4jDataStore) replaceNodes(ctx context.Context, label, mergeQuery string, nodes []map[string]any) error {
+	if err := n.pruneNodes(ctx, label, "id", nodes); err != nil {
+		return err
+	}
+
+	return n.mergeNodes(ctx, mergeQuery, nodes)
+}
+
+func (n *Neo4jDataStore) pruneNodes(ctx context.Context, label, key string, nodes []map[string]any) error {
+	keys := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node[key])
+	}
+
+	query := strings.NewReplacer("_LABEL_", label, "_KEY_", key).Replace(pruneNodesQuery)
+	return n.write(ctx, query, map[string]any{"keys": keys})
+}
+
+// mergeNodes writes all nodes in a single query, by repeating mergeQuery for each of them.
+// mergeQuery must refer to the node and its parameter through the _POS_ placeholder
+func (n *Neo4jDataStore) mergeNodes(ctx context.Context, mergeQuery string, nodes []map[string]any) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	b := strings.Builder{}
+	params := make(map[string]any, len(nodes))
+
+	for idx, node := range nodes {
+		pos := fmt.Sprintf("v%d", idx)
+		params[pos] = node
+		b.WriteString(strings.ReplaceAll(mergeQuery, "_POS_", pos))
+	}
+
+	return n.write(ctx, b.String(), params)
+}
+
 func (n *Neo4jDataStore) write(ctx context.Context, writeQuery string, params map[string]any) error {
 	return n.writeMultiple(ctx, map[string]map[string]any{
 		writeQuery: params,
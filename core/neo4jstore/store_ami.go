@@ -0,0 +1,90 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"asset-relations/support/ptr"
+	"context"
+	"time"
+)
+
+const mergeAmiQuery = `
+	MERGE(n_POS_:Ami {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		name: 				$_POS_.name,
+		ownerId: 			$_POS_.ownerId,
+		ownerAlias: 		$_POS_.ownerAlias,
+		provenance: 		$_POS_.provenance,
+		public: 			$_POS_.public,
+		sharedPublicly: 	$_POS_.sharedPublicly,
+		sharedWithAccounts: $_POS_.sharedWithAccounts,
+		state: 				$_POS_.state,
+		creationDate: 		$_POS_.creationDate,
+		deprecationTime: 	$_POS_.deprecationTime,
+		deprecated: 		$_POS_.deprecated,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+// Instances and launch templates keep the image id, so they are linked in a single pass
+const mergeAmiRelationsQuery = `
+	MATCH ()-[r:RUNS_IMAGE]->(:Ami)
+	DELETE r
+	WITH count(*) AS ignored
+	MATCH (n) WHERE (n:Ec2Instance OR n:LaunchTemplate) AND n.imageId IS NOT NULL
+	MATCH (ami:Ami {id: n.imageId})
+	MERGE (n)-[:RUNS_IMAGE]->(ami)
+`
+
+func (n *Neo4jDataStore) StoreAmis(ctx context.Context, amis []aws.Ami) error {
+	n.logger.Info("Storing AMIs")
+	now := time.Now()
+	nodes := make([]map[string]any, 0, len(amis))
+
+	for _, ami := range amis {
+		nodes = append(nodes, map[string]any{
+			"id":                 ami.Id,
+			"name":               ami.Name,
+			"ownerId":            ami.OwnerId,
+			"ownerAlias":         ptr.Deref(ami.OwnerAlias),
+			"provenance":         ami.Provenance,
+			"public":             ami.Public,
+			"sharedPublicly":     ami.IsSharedPublicly(),
+			"sharedWithAccounts": ami.SharedWithAccounts(),
+			"state":              ami.State,
+			"creationDate":       ami.CreationDate,
+			"deprecationTime":    ami.DeprecationTime,
+			"deprecated":         ami.IsDeprecated(now),
+		})
+	}
+
+	if err := n.replaceNodes(ctx, "Ami", mergeAmiQuery, nodes); err != nil {
+		return err
+	}
+
+	return n.write(ctx, mergeAmiRelationsQuery, nil)
+}
+
+const matchInstancesWithRiskyAmisQuery = `
+	MATCH (n:Ec2Instance)-[:RUNS_IMAGE]->(ami:Ami)
+	WHERE ami.sharedPublicly OR ami.deprecated OR ami.provenance IN ['third-party', 'unavailable']
+	RETURN n, ami
+	ORDER BY n.id
+`
+
+// GetInstancesWithRiskyAmis returns instances running our images shared publicly, deprecated,
+// third-party or unavailable images, with the image under "ami". Amazon and Marketplace
+// images are public by design, so they are not risky on their own
+func (n *Neo4jDataStore) GetInstancesWithRiskyAmis(ctx context.Context) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchInstancesWithRiskyAmisQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := extractPropsFromNodes(records, "n")
+	amis := extractPropsFromNodes(records, "ami")
+	for idx := range instances {
+		instances[idx]["ami"] = amis[idx]
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,136 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeLaunchTemplateQuery = `
+	MERGE(n_POS_:LaunchTemplate {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		name: 				$_POS_.name,
+		defaultVersion: 	$_POS_.defaultVersion,
+		imageId: 			$_POS_.imageId,
+		instanceType: 		$_POS_.instanceType,
+		keyName: 			$_POS_.keyName,
+		securityGroupIds: 	$_POS_.securityGroupIds,
+		iamInstanceProfile: $_POS_.iamInstanceProfile,
+		associatePublicIp: 	$_POS_.associatePublicIp,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreLaunchTemplates(ctx context.Context, templates []aws.LaunchTemplate) error {
+	n.logger.Info("Storing launch templates")
+	nodes := make([]map[string]any, 0, len(templates))
+
+	for _, template := range templates {
+		nodes = append(nodes, map[string]any{
+			"id":                 template.Id,
+			"name":               template.Name,
+			"defaultVersion":     template.DefaultVersion,
+			"imageId":            template.ImageId,
+			"instanceType":       template.InstanceType,
+			"keyName":            template.KeyName,
+			"securityGroupIds":   template.SecurityGroupIds,
+			"iamInstanceProfile": template.IamInstanceProfile,
+			"associatePublicIp":  template.AssociatePublicIp,
+		})
+	}
+
+	return n.replaceNodes(ctx, "LaunchTemplate", mergeLaunchTemplateQuery, nodes)
+}
+
+const mergeAutoScalingGroupQuery = `
+	MERGE(n_POS_:AutoScalingGroup {id: $_POS_.id}) SET n_POS_ = {
+		id: 					$_POS_.id,
+		arn: 					$_POS_.arn,
+		launchTemplateId: 		$_POS_.launchTemplateId,
+		launchTemplateVersion: 	$_POS_.launchTemplateVersion,
+		launchConfigurationName: $_POS_.launchConfigurationName,
+		targetGroupArns: 		$_POS_.targetGroupArns,
+		subnetIds: 				$_POS_.subnetIds,
+		minSize: 				$_POS_.minSize,
+		maxSize: 				$_POS_.maxSize,
+		desiredCapacity: 		$_POS_.desiredCapacity,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const deleteAutoScalingGroupRelationsQuery = `
+	MATCH (:AutoScalingGroup)-[r:LAUNCHES_FROM]->()
+	DELETE r
+	WITH count(*) AS ignored
+	MATCH ()-[m:MEMBER_OF]->(:AutoScalingGroup)
+	DELETE m
+`
+
+const mergeGroupMemberRelationQuery = `
+	MATCH (inst_POS_:Ec2Instance {id: $instanceId}), (group_POS_:AutoScalingGroup {id: $groupId})
+	MERGE (inst_POS_)-[r_POS_:MEMBER_OF]->(group_POS_) WITH r_POS_
+	FINISH
+`
+
+const mergeGroupTemplateRelationQuery = `
+	MATCH (group_POS_:AutoScalingGroup {id: $groupId}), (template_POS_:LaunchTemplate {id: $templateId})
+	MERGE (group_POS_)-[r_POS_:LAUNCHES_FROM]->(template_POS_) WITH r_POS_
+	FINISH
+`
+
+// StoreAutoScalingGroups uses the group name as id, unlike the ARN it survives the group
+// being recreated
+func (n *Neo4jDataStore) StoreAutoScalingGroups(ctx context.Context, groups []aws.AutoScalingGroup) error {
+	n.logger.Info("Storing Auto Scaling groups")
+	nodes := make([]map[string]any, 0, len(groups))
+	queryParams := make(map[string]map[string]any, len(groups)*5)
+
+	for groupIdx, group := range groups {
+		nodes = append(nodes, map[string]any{
+			"id":                      group.Name,
+			"arn":                     group.Arn,
+			"launchTemplateId":        group.LaunchTemplateId,
+			"launchTemplateVersion":   group.LaunchTemplateVersion,
+			"launchConfigurationName": group.LaunchConfigurationName,
+			"targetGroupArns":         group.TargetGroupArns,
+			"subnetIds":               group.SubnetIds,
+			"minSize":                 group.MinSize,
+			"maxSize":                 group.MaxSize,
+			"desiredCapacity":         group.DesiredCapacity,
+		})
+
+		if group.LaunchTemplateId != nil {
+			query := strings.ReplaceAll(mergeGroupTemplateRelationQuery, "_POS_", fmt.Sprintf("v%d", groupIdx))
+			queryParams[query] = map[string]any{
+				"groupId":    group.Name,
+				"templateId": *group.LaunchTemplateId,
+			}
+		}
+
+		for instanceIdx, instanceId := range group.InstanceIds {
+			query := strings.ReplaceAll(mergeGroupMemberRelationQuery, "_POS_", fmt.Sprintf("v%d_%d", groupIdx, instanceIdx))
+			queryParams[query] = map[string]any{
+				"groupId":    group.Name,
+				"instanceId": instanceId,
+			}
+		}
+	}
+
+	if err := n.replaceNodes(ctx, "AutoScalingGroup", mergeAutoScalingGroupQuery, nodes); err != nil {
+		return err
+	}
+
+	// Instances come and go, so memberships are rebuilt on every fetch
+	if err := n.write(ctx, deleteAutoScalingGroupRelationsQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
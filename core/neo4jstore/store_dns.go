@@ -0,0 +1,98 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeDnsRecordQuery = `
+	MERGE(n_POS_:DnsRecord {id: $_POS_.id}) SET n_POS_ = {
+		id: 			$_POS_.id,
+		zoneId: 		$_POS_.zoneId,
+		zoneName: 		$_POS_.zoneName,
+		privateZone: 	$_POS_.privateZone,
+		name: 			$_POS_.name,
+		type: 			$_POS_.type,
+		values: 		$_POS_.values,
+		aliasTarget: 	$_POS_.aliasTarget,
+		status: 		$_POS_.status,
+		danglingValues: $_POS_.danglingValues,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const deleteDnsRecordTargetsQuery = `
+	MATCH (:DnsRecord)-[r:RESOLVES_TO]->()
+	DELETE r
+`
+
+const mergeDnsTargetRelationQuery = `
+	MATCH (record_POS_:DnsRecord {id: $recordId}), (target_POS_:_LABEL_ {id: $targetId})
+	MERGE (record_POS_)-[r_POS_:RESOLVES_TO]->(target_POS_) WITH r_POS_
+	FINISH
+`
+
+func (n *Neo4jDataStore) StoreDnsRecords(ctx context.Context, resolutions []aws.DnsResolution) error {
+	n.logger.Info("Storing DNS records")
+	nodes := make([]map[string]any, 0, len(resolutions))
+	queryParams := make(map[string]map[string]any, len(resolutions))
+
+	for recordIdx, resolution := range resolutions {
+		record := resolution.Record
+		nodes = append(nodes, map[string]any{
+			"id":             record.Id(),
+			"zoneId":         record.ZoneId,
+			"zoneName":       record.ZoneName,
+			"privateZone":    record.PrivateZone,
+			"name":           record.Name,
+			"type":           record.Type,
+			"values":         record.Values,
+			"aliasTarget":    record.AliasTarget,
+			"status":         resolution.Status,
+			"danglingValues": resolution.DanglingValues,
+		})
+
+		for targetIdx, target := range resolution.Targets {
+			pos := fmt.Sprintf("v%d_%d", recordIdx, targetIdx)
+			query := strings.ReplaceAll(replaceLabel(mergeDnsTargetRelationQuery, target.AssetType), "_POS_", pos)
+			queryParams[query] = map[string]any{
+				"recordId": record.Id(),
+				"targetId": target.AssetId,
+			}
+		}
+	}
+
+	if err := n.replaceNodes(ctx, "DnsRecord", mergeDnsRecordQuery, nodes); err != nil {
+		return err
+	}
+
+	// Records change targets over time, so the relationships are rebuilt on every fetch
+	if err := n.write(ctx, deleteDnsRecordTargetsQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
+
+const matchDanglingDnsRecordsQuery = `
+	MATCH(n:DnsRecord)
+	WHERE n.status = 'dangling'
+	RETURN(n)
+`
+
+func (n *Neo4jDataStore) GetDanglingDnsRecords(ctx context.Context) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchDanglingDnsRecordsQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractPropsFromNodes(records, "n"), nil
+}
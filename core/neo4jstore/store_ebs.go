@@ -0,0 +1,132 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+)
+
+const mergeEbsVolumeQuery = `
+	MERGE(n_POS_:EbsVolume {id: $_POS_.id}) SET n_POS_ = {
+		id: 			$_POS_.id,
+		encrypted: 		$_POS_.encrypted,
+		kmsKeyId: 		$_POS_.kmsKeyId,
+		size: 			$_POS_.size,
+		state: 			$_POS_.state,
+		snapshotId: 	$_POS_.snapshotId,
+		instanceIds: 	$_POS_.instanceIds,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const mergeEbsVolumeRelationsQuery = `
+	MATCH (:EbsVolume)-[r:ATTACHED_TO]->(:Ec2Instance)
+	DELETE r
+	WITH count(*) AS ignored
+	MATCH (volume:EbsVolume) UNWIND volume.instanceIds AS instanceId
+	MATCH (inst:Ec2Instance {id: instanceId})
+	MERGE (volume)-[:ATTACHED_TO]->(inst)
+`
+
+func (n *Neo4jDataStore) StoreEbsVolumes(ctx context.Context, volumes []aws.EbsVolume) error {
+	n.logger.Info("Storing EBS volumes")
+	nodes := make([]map[string]any, 0, len(volumes))
+
+	for _, volume := range volumes {
+		nodes = append(nodes, map[string]any{
+			"id":          volume.Id,
+			"encrypted":   volume.Encrypted,
+			"kmsKeyId":    volume.KmsKeyId,
+			"size":        volume.Size,
+			"state":       volume.State,
+			"snapshotId":  volume.SnapshotId,
+			"instanceIds": volume.InstanceIds,
+		})
+	}
+
+	if err := n.replaceNodes(ctx, "EbsVolume", mergeEbsVolumeQuery, nodes); err != nil {
+		return err
+	}
+
+	return n.write(ctx, mergeEbsVolumeRelationsQuery, nil)
+}
+
+const mergeEbsSnapshotQuery = `
+	MERGE(n_POS_:EbsSnapshot {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		volumeId: 			$_POS_.volumeId,
+		description: 		$_POS_.description,
+		encrypted: 			$_POS_.encrypted,
+		kmsKeyId: 			$_POS_.kmsKeyId,
+		size: 				$_POS_.size,
+		startTime: 			$_POS_.startTime,
+		public: 			$_POS_.public,
+		sharedWithAccounts: $_POS_.sharedWithAccounts,
+		sourceInstanceId: 	$_POS_.sourceInstanceId,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const mergeEbsSnapshotRelationsQuery = `
+	MATCH (:EbsSnapshot)-[r:SNAPSHOT_OF|CREATED_FROM]->()
+	DELETE r
+	WITH count(*) AS ignored
+	MATCH (snapshot:EbsSnapshot)
+	OPTIONAL MATCH (volume:EbsVolume {id: snapshot.volumeId})
+	OPTIONAL MATCH (inst:Ec2Instance {id: snapshot.sourceInstanceId})
+	FOREACH (v IN CASE WHEN volume IS NULL THEN [] ELSE [volume] END | MERGE (snapshot)-[:SNAPSHOT_OF]->(v))
+	FOREACH (i IN CASE WHEN inst IS NULL THEN [] ELSE [inst] END | MERGE (snapshot)-[:CREATED_FROM]->(i))
+`
+
+// StoreEbsSnapshots links snapshots to their volume and to the instance their data comes from
+func (n *Neo4jDataStore) StoreEbsSnapshots(ctx context.Context, snapshots []aws.EbsSnapshot, sources map[string]string) error {
+	n.logger.Info("Storing EBS snapshots")
+	nodes := make([]map[string]any, 0, len(snapshots))
+
+	for _, snapshot := range snapshots {
+		var sourceInstanceId *string
+		if instanceId, found := sources[snapshot.Id]; found {
+			sourceInstanceId = &instanceId
+		}
+
+		nodes = append(nodes, map[string]any{
+			"id":                 snapshot.Id,
+			"volumeId":           snapshot.VolumeId,
+			"description":        snapshot.Description,
+			"encrypted":          snapshot.Encrypted,
+			"kmsKeyId":           snapshot.KmsKeyId,
+			"size":               snapshot.Size,
+			"startTime":          snapshot.StartTime,
+			"public":             snapshot.IsPublic(),
+			"sharedWithAccounts": snapshot.SharedWithAccounts(),
+			"sourceInstanceId":   sourceInstanceId,
+		})
+	}
+
+	if err := n.replaceNodes(ctx, "EbsSnapshot", mergeEbsSnapshotQuery, nodes); err != nil {
+		return err
+	}
+
+	return n.write(ctx, mergeEbsSnapshotRelationsQuery, nil)
+}
+
+var matchSnapshotFindingsQuery = `
+	MATCH (f:Finding {status: 'open'})-[:AFFECTS]->(s:EbsSnapshot)
+	RETURN f, s
+	ORDER BY ` + severityRank("f") + `, f.id
+`
+
+// GetSnapshotFindings returns the open findings on snapshots, with the snapshot under "snapshot"
+func (n *Neo4jDataStore) GetSnapshotFindings(ctx context.Context) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchSnapshotFindingsQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := extractPropsFromNodes(records, "f")
+	snapshots := extractPropsFromNodes(records, "s")
+	for idx := range findings {
+		findings[idx]["snapshot"] = snapshots[idx]
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,120 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	findingStatusOpen     = "open"
+	findingStatusResolved = "resolved"
+)
+
+// Findings keep their first sighting across fetches, so they can be tracked over time
+const mergeFindingQuery = `
+	MERGE(n_POS_:Finding {id: $_POS_.id})
+	ON CREATE SET n_POS_.firstSeen = datetime()
+	SET n_POS_ += {
+		id: 		$_POS_.id,
+		type: 		$_POS_.type,
+		severity: 	$_POS_.severity,
+		assetType: 	$_POS_.assetType,
+		assetId: 	$_POS_.assetId,
+		title: 		$_POS_.title,
+		instances: 	$_POS_.instances,
+		remediation: $_POS_.remediation,
+		evidence: 	$_POS_.evidence,
+		confidence: $_POS_.confidence,
+		status: 	$_POS_.status,
+		run: 		$_POS_.run,
+		lastSeen: 	datetime()
+	}
+`
+
+// Findings not raised by the latest run are not there anymore
+const resolveFindingsQuery = `
+	MATCH (f:Finding)
+	WHERE f.run <> $run AND f.status = 'open'
+	SET f.status = 'resolved', f.resolvedAt = datetime()
+`
+
+const mergeFindingAssetRelationQuery = `
+	MATCH (finding_POS_:Finding {id: $findingId}), (asset_POS_:_LABEL_ {id: $assetId})
+	MERGE (finding_POS_)-[r_POS_:AFFECTS]->(asset_POS_) WITH r_POS_
+	FINISH
+`
+
+func (n *Neo4jDataStore) StoreFindings(ctx context.Context, findings []aws.Finding) error {
+	n.logger.Info("Storing findings")
+	run := fmt.Sprintf("%d", time.Now().UnixNano())
+	nodes := make([]map[string]any, 0, len(findings))
+	queryParams := make(map[string]map[string]any, len(findings))
+
+	for idx, finding := range findings {
+		nodes = append(nodes, map[string]any{
+			"id":          finding.Id,
+			"type":        finding.Type,
+			"severity":    finding.Severity,
+			"assetType":   finding.Asset.AssetType,
+			"assetId":     finding.Asset.AssetId,
+			"title":       finding.Title,
+			"instances":   finding.Instances,
+			"remediation": finding.Remediation,
+			"evidence":    finding.EvidenceDetails(),
+			"confidence":  finding.Confidence,
+			"status":      findingStatusOpen,
+			"run":         run,
+		})
+
+		query := strings.ReplaceAll(replaceLabel(mergeFindingAssetRelationQuery, finding.Asset.AssetType), "_POS_", fmt.Sprintf("v%d", idx))
+		queryParams[query] = map[string]any{
+			"findingId": finding.Id,
+			"assetId":   finding.Asset.AssetId,
+		}
+	}
+
+	if err := n.mergeNodes(ctx, mergeFindingQuery, nodes); err != nil {
+		return err
+	}
+
+	if err := n.write(ctx, resolveFindingsQuery, map[string]any{"run": run}); err != nil {
+		return err
+	}
+
+	return n.writeMultiple(ctx, queryParams)
+}
+
+var matchFindingsQuery = `
+	MATCH(n:Finding)
+	WHERE $status = 'all' OR n.status = $status
+	RETURN(n)
+	ORDER BY ` + severityRank("n") + `, n.id
+`
+
+// severityRank ranks the severity of the finding from the most severe, severities as text would
+// sort alphabetically
+func severityRank(finding string) string {
+	severities := aws.Severities()
+
+	var rank strings.Builder
+	rank.WriteString("CASE " + finding + ".severity")
+	for idx, severity := range severities {
+		fmt.Fprintf(&rank, " WHEN '%s' THEN %d", severity, idx)
+	}
+	fmt.Fprintf(&rank, " ELSE %d END", len(severities))
+
+	return rank.String()
+}
+
+// GetFindings returns the findings in the given status, "all" returns every finding
+func (n *Neo4jDataStore) GetFindings(ctx context.Context, status string) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchFindingsQuery, map[string]any{"status": status})
+	if err != nil {
+		return nil, err
+	}
+
+	return extractPropsFromNodes(records, "n"), nil
+}
@@ -0,0 +1,157 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/dbtype"
+	"strings"
+	"time"
+)
+
+const deleteObservedTrafficQuery = `
+	MATCH ()-[r:OBSERVED_TRAFFIC]->()
+	WHERE r.day IN $days
+	DELETE r
+`
+
+const deleteUnusedCidrsQuery = `
+	MATCH (n:Cidr)
+	WHERE NOT (n)--()
+	DELETE n
+`
+
+const mergeCidrQuery = `
+	MERGE(n_POS_:Cidr {id: $_POS_.id}) SET n_POS_ = {
+		id: 		$_POS_.id,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+// Both labels are replaced before running the query
+const mergeObservedTrafficQuery = `
+	MATCH (from_POS_:_FROM_ {id: $fromId}), (to_POS_:_TO_ {id: $toId})
+	MERGE (from_POS_)-[r_POS_:OBSERVED_TRAFFIC {protocol: $protocol, port: $port, action: $action, day: $day}]->(to_POS_)
+	SET r_POS_.bytes = $bytes, r_POS_.packets = $packets, r_POS_.flows = $flows,
+		r_POS_.firstSeen = $firstSeen, r_POS_.lastSeen = $lastSeen
+	WITH r_POS_
+	FINISH
+`
+
+// StoreObservedTraffic stores OBSERVED_TRAFFIC relationships, one per client, server, protocol,
+// port, action and day. The traffic of the days in traffic replaces the one stored for them,
+// earlier days are kept so windows can reach back past the last load
+func (n *Neo4jDataStore) StoreObservedTraffic(ctx context.Context, traffic []aws.ObservedTraffic) error {
+	n.logger.Info("Storing observed traffic")
+	cidrs := make([]map[string]any, 0)
+	seen := make(map[string]bool)
+	seenDays := make(map[time.Time]bool)
+	days := make([]any, 0)
+	queryParams := make(map[string]map[string]any, len(traffic))
+
+	for idx, observed := range traffic {
+		if !seenDays[observed.Day] {
+			seenDays[observed.Day] = true
+			days = append(days, observed.Day)
+		}
+
+		for _, endpoint := range []aws.AssetRef{observed.From, observed.To} {
+			if endpoint.AssetType == aws.AssetCidr && !seen[endpoint.AssetId] {
+				seen[endpoint.AssetId] = true
+				cidrs = append(cidrs, map[string]any{"id": endpoint.AssetId})
+			}
+		}
+
+		query := strings.NewReplacer(
+			"_FROM_", observed.From.AssetType,
+			"_TO_", observed.To.AssetType,
+			"_POS_", fmt.Sprintf("v%d", idx),
+		).Replace(mergeObservedTrafficQuery)
+
+		queryParams[query] = map[string]any{
+			"fromId":    observed.From.AssetId,
+			"toId":      observed.To.AssetId,
+			"protocol":  observed.Protocol,
+			"port":      observed.Port,
+			"action":    observed.Action,
+			"day":       observed.Day,
+			"bytes":     observed.Bytes,
+			"packets":   observed.Packets,
+			"flows":     observed.Flows,
+			"firstSeen": observed.FirstSeen,
+			"lastSeen":  observed.LastSeen,
+		}
+	}
+
+	if err := n.write(ctx, deleteObservedTrafficQuery, map[string]any{"days": days}); err != nil {
+		return err
+	}
+
+	if err := n.mergeNodes(ctx, mergeCidrQuery, cidrs); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	if err := n.write(ctx, deleteUnusedCidrsQuery, nil); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
+
+const matchObservedTrafficQuery = `
+	MATCH (from)-[r:OBSERVED_TRAFFIC]->(to)
+	RETURN labels(from)[0] AS fromType, from.id AS fromId, labels(to)[0] AS toType, to.id AS toId, r
+	ORDER BY fromType, fromId, toType, toId, r.protocol, r.port, r.action, r.day
+`
+
+// GetObservedTraffic returns every OBSERVED_TRAFFIC relationship
+func (n *Neo4jDataStore) GetObservedTraffic(ctx context.Context) ([]aws.ObservedTraffic, error) {
+	records, err := n.read(ctx, matchObservedTrafficQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	traffic := make([]aws.ObservedTraffic, 0, len(records))
+	for _, record := range records {
+		value, _ := record.Get("r")
+		relationship, isRelationship := value.(dbtype.Relationship)
+		if !isRelationship {
+			continue
+		}
+
+		props := relationship.Props
+		traffic = append(traffic, aws.ObservedTraffic{
+			From:      aws.AssetRef{AssetType: recordString(record, "fromType"), AssetId: recordString(record, "fromId")},
+			To:        aws.AssetRef{AssetType: recordString(record, "toType"), AssetId: recordString(record, "toId")},
+			Protocol:  propString(props, "protocol"),
+			Port:      int32(propInt(props, "port")),
+			Action:    propString(props, "action"),
+			Day:       propTime(props, "day"),
+			Bytes:     propInt(props, "bytes"),
+			Packets:   propInt(props, "packets"),
+			Flows:     propInt(props, "flows"),
+			FirstSeen: propTime(props, "firstSeen"),
+			LastSeen:  propTime(props, "lastSeen"),
+		})
+	}
+
+	return traffic, nil
+}
+
+func propInt(props map[string]any, key string) int64 {
+	value, _ := props[key].(int64)
+	return value
+}
+
+func recordString(record *neo4j.Record, key string) string {
+	value, _ := record.Get(key)
+	text, _ := value.(string)
+	return text
+}
@@ -0,0 +1,154 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeCloudFrontDistributionQuery = `
+	MERGE(n_POS_:CloudFrontDistribution {id: $_POS_.id}) SET n_POS_ = {
+		id: 		$_POS_.id,
+		arn: 		$_POS_.arn,
+		domainName: $_POS_.domainName,
+		aliases: 	$_POS_.aliases,
+		enabled: 	$_POS_.enabled,
+		webACLId: 	$_POS_.webACLId,
+		hasWAF: 	$_POS_.hasWAF,
+		origins: 	$_POS_.origins,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreCloudFrontDistributions(ctx context.Context, distributions []aws.CloudFrontDistribution) error {
+	n.logger.Info("Storing CloudFront distributions")
+	nodes := make([]map[string]any, 0, len(distributions))
+
+	for _, distribution := range distributions {
+		nodes = append(nodes, map[string]any{
+			"id":         distribution.Id,
+			"arn":        distribution.Arn,
+			"domainName": distribution.DomainName,
+			"aliases":    distribution.Aliases,
+			"enabled":    distribution.Enabled,
+			"webACLId":   distribution.WebACLId,
+			"hasWAF":     distribution.HasWAF(),
+			"origins":    originsToStrings(distribution.Origins),
+		})
+	}
+
+	return n.replaceNodes(ctx, "CloudFrontDistribution", mergeCloudFrontDistributionQuery, nodes)
+}
+
+const mergeApiGatewayStageQuery = `
+	MERGE(n_POS_:ApiGatewayStage {id: $_POS_.id}) SET n_POS_ = {
+		id: 			$_POS_.id,
+		apiId: 			$_POS_.apiId,
+		apiName: 		$_POS_.apiName,
+		apiType: 		$_POS_.apiType,
+		stageName: 		$_POS_.stageName,
+		private: 		$_POS_.private,
+		webACLArn: 		$_POS_.webACLArn,
+		hasWAF: 		$_POS_.hasWAF,
+		integrations: 	$_POS_.integrations,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreApiGatewayStages(ctx context.Context, stages []aws.ApiGatewayStage) error {
+	n.logger.Info("Storing API Gateway stages")
+	nodes := make([]map[string]any, 0, len(stages))
+
+	for _, stage := range stages {
+		nodes = append(nodes, map[string]any{
+			"id":           stage.Id(),
+			"apiId":        stage.ApiId,
+			"apiName":      stage.ApiName,
+			"apiType":      stage.ApiType,
+			"stageName":    stage.StageName,
+			"private":      stage.Private,
+			"webACLArn":    stage.WebACLArn,
+			"hasWAF":       stage.HasWAF(),
+			"integrations": originsToStrings(stage.Integrations),
+		})
+	}
+
+	return n.replaceNodes(ctx, "ApiGatewayStage", mergeApiGatewayStageQuery, nodes)
+}
+
+func originsToStrings(origins []aws.FrontDoorOrigin) []string {
+	values := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		values = append(values, origin.Type+":"+origin.Target)
+	}
+
+	return values
+}
+
+const deleteFrontDoorRoutesQuery = `
+	MATCH ()-[r:ROUTES_TO]->()
+	DELETE r
+`
+
+// Both labels are replaced before running the query
+const mergeFrontDoorRouteQuery = `
+	MATCH (from_POS_:_FROM_ {id: $fromId}), (to_POS_:_TO_ {id: $toId})
+	MERGE (from_POS_)-[r_POS_:ROUTES_TO]->(to_POS_) WITH r_POS_
+	FINISH
+`
+
+func (n *Neo4jDataStore) StoreFrontDoorRoutes(ctx context.Context, routes []aws.FrontDoorRoute) error {
+	n.logger.Info("Storing front door routes")
+	queryParams := make(map[string]map[string]any, len(routes))
+
+	for idx, route := range routes {
+		query := strings.NewReplacer(
+			"_FROM_", route.From.AssetType,
+			"_TO_", route.To.AssetType,
+			"_POS_", fmt.Sprintf("v%d", idx),
+		).Replace(mergeFrontDoorRouteQuery)
+
+		queryParams[query] = map[string]any{
+			"fromId": route.From.AssetId,
+			"toId":   route.To.AssetId,
+		}
+	}
+
+	// Routes change with origins and targets, so they are rebuilt on every fetch
+	if err := n.write(ctx, deleteFrontDoorRoutesQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
+
+const setInstanceExposureQuery = `
+	MATCH (n_POS_:Ec2Instance {id: $id})
+	SET n_POS_.exposure = $exposure, n_POS_.frontDoorPaths = $frontDoorPaths,
+		n_POS_.exposureConfidence = $exposureConfidence, n_POS_.exposureEvidence = $exposureEvidence
+`
+
+func (n *Neo4jDataStore) StoreInstanceExposures(ctx context.Context, exposures []aws.InstanceExposure) error {
+	n.logger.Info("Storing instance exposures")
+	queryParams := make(map[string]map[string]any, len(exposures))
+
+	for idx, exposure := range exposures {
+		query := strings.ReplaceAll(setInstanceExposureQuery, "_POS_", fmt.Sprintf("v%d", idx))
+		queryParams[query] = map[string]any{
+			"id":                 exposure.InstanceId,
+			"exposure":           exposure.Status,
+			"frontDoorPaths":     exposure.FrontDoorPaths(),
+			"exposureConfidence": exposure.Confidence,
+			"exposureEvidence":   exposure.EvidenceDetails(),
+		}
+	}
+
+	return n.writeMultiple(ctx, queryParams)
+}
@@ -0,0 +1,64 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// The whole inventory is kept as a single JSON document, analyses that depend on the
+// request, like reachability, run on it instead of querying the graph
+const mergeInventoryQuery = `
+	MERGE(n:Inventory {id: $id}) SET n = {
+		id: 		$id,
+		accountId: 	$accountId,
+		region: 	$region,
+		data: 		$data,
+		fetchedAt: 	datetime()
+	}
+`
+
+func (n *Neo4jDataStore) StoreInventory(ctx context.Context, inventory aws.Inventory) error {
+	n.logger.Info("Storing inventory")
+
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return fmt.Errorf("can't convert inventory to json: %s", err.Error())
+	}
+
+	return n.write(ctx, mergeInventoryQuery, map[string]any{
+		"id":        inventory.AccountId + "/" + inventory.Region,
+		"accountId": inventory.AccountId,
+		"region":    inventory.Region,
+		"data":      string(data),
+	})
+}
+
+const matchLatestInventoryQuery = `
+	MATCH(n:Inventory)
+	RETURN n.data AS data
+	ORDER BY n.fetchedAt DESC
+	LIMIT 1
+`
+
+// GetInventory returns the last fetched inventory, false if nothing was fetched yet
+func (n *Neo4jDataStore) GetInventory(ctx context.Context) (aws.Inventory, bool, error) {
+	records, err := n.read(ctx, matchLatestInventoryQuery, nil)
+	if err != nil || len(records) == 0 {
+		return aws.Inventory{}, false, err
+	}
+
+	data, _ := records[0].Get("data")
+	raw, isString := data.(string)
+	if !isString {
+		return aws.Inventory{}, false, nil
+	}
+
+	var inventory aws.Inventory
+	if err := json.Unmarshal([]byte(raw), &inventory); err != nil {
+		return aws.Inventory{}, false, fmt.Errorf("can't read stored inventory: %s", err.Error())
+	}
+
+	return inventory, true, nil
+}
@@ -0,0 +1,253 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeElasticIpQuery = `
+	MERGE(n_POS_:ElasticIp {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		publicIp: 			$_POS_.publicIp,
+		privateIp: 			$_POS_.privateIp,
+		isAssociated: 		$_POS_.isAssociated,
+		instanceId: 		$_POS_.instanceId,
+		networkInterfaceId: $_POS_.networkInterfaceId,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreElasticIps(ctx context.Context, addresses []aws.ElasticIp) error {
+	n.logger.Info("Storing elastic ips")
+	nodes := make([]map[string]any, 0, len(addresses))
+
+	for _, eip := range addresses {
+		nodes = append(nodes, map[string]any{
+			"id":                 eip.AllocationId,
+			"publicIp":           eip.PublicIP,
+			"privateIp":          eip.PrivateIP,
+			"isAssociated":       eip.IsAssociated(),
+			"instanceId":         eip.InstanceId,
+			"networkInterfaceId": eip.NetworkInterfaceId,
+		})
+	}
+
+	return n.replaceNodes(ctx, "ElasticIp", mergeElasticIpQuery, nodes)
+}
+
+const mergeNetworkInterfaceQuery = `
+	MERGE(n_POS_:NetworkInterface {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		type: 				$_POS_.type,
+		description: 		$_POS_.description,
+		VPCId: 				$_POS_.VPCId,
+		subnetId: 			$_POS_.subnetId,
+		instanceId: 		$_POS_.instanceId,
+		securityGroupIds: 	$_POS_.securityGroupIds,
+		privateIps: 		$_POS_.privateIps,
+		publicIps: 			$_POS_.publicIps,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreNetworkInterfaces(ctx context.Context, interfaces []aws.NetworkInterface) error {
+	n.logger.Info("Storing network interfaces")
+	nodes := make([]map[string]any, 0, len(interfaces))
+
+	for _, eni := range interfaces {
+		privateIps := make([]string, 0, len(eni.Addresses))
+		publicIps := make([]string, 0, len(eni.Addresses))
+		for _, addr := range eni.Addresses {
+			privateIps = append(privateIps, addr.PrivateIP)
+			if addr.PublicIP != nil {
+				publicIps = append(publicIps, *addr.PublicIP)
+			}
+		}
+
+		nodes = append(nodes, map[string]any{
+			"id":               eni.Id,
+			"type":             eni.Type,
+			"description":      eni.Description,
+			"VPCId":            eni.VPC,
+			"subnetId":         eni.SubnetId,
+			"instanceId":       eni.InstanceId,
+			"securityGroupIds": eni.SecurityGroupIds,
+			"privateIps":       privateIps,
+			"publicIps":        publicIps,
+		})
+	}
+
+	return n.replaceNodes(ctx, "NetworkInterface", mergeNetworkInterfaceQuery, nodes)
+}
+
+const mergeNatGatewayQuery = `
+	MERGE(n_POS_:NatGateway {id: $_POS_.id}) SET n_POS_ = {
+		id: 		$_POS_.id,
+		VPCId: 		$_POS_.VPCId,
+		subnetId: 	$_POS_.subnetId,
+		privateIps: $_POS_.privateIps,
+		publicIps: 	$_POS_.publicIps,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreNatGateways(ctx context.Context, gateways []aws.NatGateway) error {
+	n.logger.Info("Storing NAT gateways")
+	nodes := make([]map[string]any, 0, len(gateways))
+
+	for _, nat := range gateways {
+		privateIps := make([]string, 0, len(nat.Addresses))
+		publicIps := make([]string, 0, len(nat.Addresses))
+		for _, addr := range nat.Addresses {
+			if addr.PrivateIP != nil {
+				privateIps = append(privateIps, *addr.PrivateIP)
+			}
+			if addr.PublicIP != nil {
+				publicIps = append(publicIps, *addr.PublicIP)
+			}
+		}
+
+		nodes = append(nodes, map[string]any{
+			"id":         nat.Id,
+			"VPCId":      nat.VPC,
+			"subnetId":   nat.SubnetId,
+			"privateIps": privateIps,
+			"publicIps":  publicIps,
+		})
+	}
+
+	return n.replaceNodes(ctx, "NatGateway", mergeNatGatewayQuery, nodes)
+}
+
+const mergeLoadBalancerQuery = `
+	MERGE(n_POS_:LoadBalancer {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		name: 				$_POS_.name,
+		type: 				$_POS_.type,
+		scheme: 			$_POS_.scheme,
+		isInternetFacing: 	$_POS_.isInternetFacing,
+		DNSName: 			$_POS_.DNSName,
+		VPCId: 				$_POS_.VPCId,
+		securityGroupIds: 	$_POS_.securityGroupIds,
+		webACLArn: 			$_POS_.webACLArn,
+		hasWAF: 			$_POS_.hasWAF,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+func (n *Neo4jDataStore) StoreLoadBalancers(ctx context.Context, balancers []aws.LoadBalancer) error {
+	n.logger.Info("Storing load balancers")
+	nodes := make([]map[string]any, 0, len(balancers))
+
+	for _, lb := range balancers {
+		nodes = append(nodes, map[string]any{
+			"id":               lb.Arn,
+			"name":             lb.Name,
+			"type":             lb.Type,
+			"scheme":           lb.Scheme,
+			"isInternetFacing": lb.IsInternetFacing(),
+			"DNSName":          lb.DNSName,
+			"VPCId":            lb.VPC,
+			"securityGroupIds": lb.SecurityGroupIds,
+			"webACLArn":        lb.WebACLArn,
+			"hasWAF":           lb.HasWAF(),
+		})
+	}
+
+	return n.replaceNodes(ctx, "LoadBalancer", mergeLoadBalancerQuery, nodes)
+}
+
+const mergeIpAddressQuery = `
+	MERGE(n_POS_:IpAddress {address: $_POS_.address}) SET n_POS_ = {
+		address: 			$_POS_.address,
+		isPublic: 			$_POS_.isPublic,
+		ownerType: 			$_POS_.ownerType,
+		ownerId: 			$_POS_.ownerId,
+		networkInterfaceId: $_POS_.networkInterfaceId,
+		allocationId: 		$_POS_.allocationId,
+		account: 			$_POS_.account,
+		region: 			$_POS_.region,
+		VPCId: 				$_POS_.VPCId,
+		exposure: 			$_POS_.exposure,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+// The owner label can't be a query parameter, it's replaced before running the query.
+// Previous owners are detached first, as addresses move between assets over time
+const mergeIpOwnerRelationQuery = `
+	MATCH (ip_POS_:IpAddress {address: $address})
+	OPTIONAL MATCH (ip_POS_)-[old_POS_:ASSIGNED_TO]->()
+	DELETE old_POS_
+	WITH DISTINCT ip_POS_
+	MATCH (owner_POS_:_LABEL_ {id: $ownerId})
+	MERGE (ip_POS_)-[:ASSIGNED_TO]->(owner_POS_)
+`
+
+func (n *Neo4jDataStore) StoreIpAddresses(ctx context.Context, addresses []aws.IpAddress) error {
+	n.logger.Info("Storing ip addresses")
+	nodes := make([]map[string]any, 0, len(addresses))
+	queryParams := make(map[string]map[string]any, len(addresses))
+
+	for idx, addr := range addresses {
+		nodes = append(nodes, map[string]any{
+			"address":            addr.Address,
+			"isPublic":           addr.Public,
+			"ownerType":          addr.OwnerType,
+			"ownerId":            addr.OwnerId,
+			"networkInterfaceId": addr.NetworkInterfaceId,
+			"allocationId":       addr.AllocationId,
+			"account":            addr.Account,
+			"region":             addr.Region,
+			"VPCId":              addr.VPC,
+			"exposure":           addr.Exposure,
+		})
+
+		pos := fmt.Sprintf("v%d", idx)
+		query := strings.ReplaceAll(replaceLabel(mergeIpOwnerRelationQuery, addr.OwnerType), "_POS_", pos)
+		queryParams[query] = map[string]any{
+			"address": addr.Address,
+			"ownerId": addr.OwnerId,
+		}
+	}
+
+	// Addresses released since the last fetch are deleted, so they aren't reported as ours
+	if err := n.pruneNodes(ctx, "IpAddress", "address", nodes); err != nil {
+		return err
+	}
+
+	if err := n.mergeNodes(ctx, mergeIpAddressQuery, nodes); err != nil {
+		return err
+	}
+
+	return n.writeMultiple(ctx, queryParams)
+}
+
+const matchIpAddressQuery = `
+	MATCH (ip:IpAddress {address: $address})
+	OPTIONAL MATCH (ip)-[:ASSIGNED_TO]->(owner)
+	RETURN ip, owner
+`
+
+// GetIpAddress returns the indexed address with its owner under "owner", or nil if the
+// address is unknown
+func (n *Neo4jDataStore) GetIpAddress(ctx context.Context, address string) (map[string]any, error) {
+	records, err := n.read(ctx, matchIpAddressQuery, map[string]any{"address": address})
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := extractPropsFromNodes(records, "ip")
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	ip := addresses[0]
+	if owners := extractPropsFromNodes(records, "owner"); len(owners) > 0 {
+		ip["owner"] = owners[0]
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,82 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const deleteReachRelationsQuery = `
+	MATCH (:Ec2Instance)-[r:CAN_REACH]->(:Ec2Instance)
+	DELETE r
+`
+
+const mergeReachRelationQuery = `
+	MATCH (from_POS_:Ec2Instance {id: $fromId}), (to_POS_:Ec2Instance {id: $toId})
+	MERGE (from_POS_)-[r_POS_:CAN_REACH {protocol: $protocol}]->(to_POS_)
+	SET r_POS_.ports = $ports, r_POS_.status = $status
+	WITH r_POS_
+	FINISH
+`
+
+// StoreInstanceReaches replaces every CAN_REACH relationship, one per pair and protocol
+func (n *Neo4jDataStore) StoreInstanceReaches(ctx context.Context, reaches []aws.InstanceReach) error {
+	n.logger.Info("Storing instance reaches")
+	queryParams := make(map[string]map[string]any, len(reaches))
+
+	for idx, reach := range reaches {
+		query := strings.ReplaceAll(mergeReachRelationQuery, "_POS_", fmt.Sprintf("v%d", idx))
+		queryParams[query] = map[string]any{
+			"fromId":   reach.From,
+			"toId":     reach.To,
+			"protocol": reach.Protocol,
+			"ports":    reach.Ports(),
+			"status":   reach.Status,
+		}
+	}
+
+	if err := n.write(ctx, deleteReachRelationsQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
+
+const matchReachableQuery = `
+	MATCH (n:Ec2Instance {id: $id})-[r:CAN_REACH]->(o:Ec2Instance)
+	RETURN o, r
+	ORDER BY o.id, r.protocol
+`
+
+const matchReachableFromQuery = `
+	MATCH (o:Ec2Instance)-[r:CAN_REACH]->(n:Ec2Instance {id: $id})
+	RETURN o, r
+	ORDER BY o.id, r.protocol
+`
+
+// GetReachableInstances returns the instances the given one can connect to, with the
+// protocol, ports and status of the reach under "reach"
+func (n *Neo4jDataStore) GetReachableInstances(ctx context.Context, id string) ([]map[string]any, error) {
+	return n.getReaches(ctx, matchReachableQuery, id)
+}
+
+// GetInstancesReachingFrom returns the instances that can connect to the given one
+func (n *Neo4jDataStore) GetInstancesReachingFrom(ctx context.Context, id string) ([]map[string]any, error) {
+	return n.getReaches(ctx, matchReachableFromQuery, id)
+}
+
+func (n *Neo4jDataStore) getReaches(ctx context.Context, query, id string) ([]map[string]any, error) {
+	records, err := n.read(ctx, query, map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	return extractPropsWithRelationship(records, "o", "r", "reach"), nil
+}
@@ -0,0 +1,56 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const setInstanceRiskQuery = `
+	MATCH (n_POS_:Ec2Instance {id: $id})
+	SET n_POS_.riskScore = $riskScore, n_POS_.riskBreakdown = $riskBreakdown
+`
+
+func (n *Neo4jDataStore) StoreRiskScores(ctx context.Context, scores []aws.RiskScore) error {
+	n.logger.Info("Storing risk scores")
+	queryParams := make(map[string]map[string]any, len(scores))
+
+	for idx, score := range scores {
+		query := strings.ReplaceAll(setInstanceRiskQuery, "_POS_", fmt.Sprintf("v%d", idx))
+		queryParams[query] = map[string]any{
+			"id":            score.InstanceId,
+			"riskScore":     score.Score,
+			"riskBreakdown": score.Breakdown(),
+		}
+	}
+
+	return n.writeMultiple(ctx, queryParams)
+}
+
+const matchInstancesQuery = `
+	MATCH(n:Ec2Instance)
+	RETURN(n)
+	ORDER BY n.id
+`
+
+const matchInstancesByRiskQuery = `
+	MATCH(n:Ec2Instance)
+	RETURN(n)
+	ORDER BY COALESCE(n.riskScore, 0) DESC, n.id
+`
+
+// GetInstances returns every instance, the riskiest first when sortByRisk
+func (n *Neo4jDataStore) GetInstances(ctx context.Context, sortByRisk bool) ([]map[string]any, error) {
+	query := matchInstancesQuery
+	if sortByRisk {
+		query = matchInstancesByRiskQuery
+	}
+
+	records, err := n.read(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractPropsFromNodes(records, "n"), nil
+}
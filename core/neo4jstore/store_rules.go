@@ -0,0 +1,105 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/rules"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MatchRule returns the ids of the assets matching the rule
+func (n *Neo4jDataStore) MatchRule(ctx context.Context, rule rules.Rule) ([]string, error) {
+	query, params := ruleQuery(rule)
+
+	records, err := n.read(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", rule.Id, err)
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		if id, isString := record.Values[0].(string); isString {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// ruleQuery turns the rule into a query, values are always passed as parameters. Labels,
+// relationships and properties are validated identifiers when rules are loaded
+func ruleQuery(rule rules.Rule) (string, map[string]any) {
+	params := make(map[string]any, len(rule.Match.Where))
+	predicates := conditionsPredicates("n", rule.Match.Where, params)
+
+	for idx, related := range rule.Match.Related {
+		node := fmt.Sprintf("m%d", idx)
+
+		label := ""
+		if related.Asset != "" {
+			label = ":`" + related.Asset + "`"
+		}
+
+		pattern := fmt.Sprintf("(n)-[:`%s`]->(%s%s)", related.Relationship, node, label)
+		switch related.Direction {
+		case rules.DirectionIn:
+			pattern = fmt.Sprintf("(n)<-[:`%s`]-(%s%s)", related.Relationship, node, label)
+		case rules.DirectionBoth:
+			pattern = fmt.Sprintf("(n)-[:`%s`]-(%s%s)", related.Relationship, node, label)
+		}
+
+		subquery := "EXISTS { MATCH " + pattern
+		if relatedPredicates := conditionsPredicates(node, related.Where, params); len(relatedPredicates) > 0 {
+			subquery += " WHERE " + strings.Join(relatedPredicates, " AND ")
+		}
+		subquery += " }"
+
+		if !related.MustExist() {
+			subquery = "NOT " + subquery
+		}
+
+		predicates = append(predicates, subquery)
+	}
+
+	query := fmt.Sprintf("MATCH (n:`%s`)", rule.Match.Asset)
+	if len(predicates) > 0 {
+		query += " WHERE " + strings.Join(predicates, " AND ")
+	}
+
+	return query + " RETURN n.id AS id ORDER BY id", params
+}
+
+func conditionsPredicates(node string, conditions []rules.Condition, params map[string]any) []string {
+	predicates := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		param := fmt.Sprintf("p%d", len(params))
+		property := fmt.Sprintf("%s.`%s`", node, condition.Property)
+
+		switch {
+		case condition.Equals != nil:
+			params[param] = condition.Equals
+			predicates = append(predicates, fmt.Sprintf("%s = $%s", property, param))
+		case condition.NotEquals != nil:
+			params[param] = condition.NotEquals
+			predicates = append(predicates, fmt.Sprintf("(%s IS NULL OR %s <> $%s)", property, property, param))
+		case condition.In != nil:
+			params[param] = condition.In
+			predicates = append(predicates, fmt.Sprintf("%s IN $%s", property, param))
+		case condition.Contains != nil:
+			params[param] = condition.Contains
+			predicates = append(predicates, fmt.Sprintf("$%s IN %s", param, property))
+		case condition.Exists != nil && *condition.Exists:
+			predicates = append(predicates, property+" IS NOT NULL")
+		case condition.Exists != nil:
+			predicates = append(predicates, property+" IS NULL")
+		case condition.GreaterThan != nil:
+			params[param] = *condition.GreaterThan
+			predicates = append(predicates, fmt.Sprintf("%s > $%s", property, param))
+		case condition.LessThan != nil:
+			params[param] = *condition.LessThan
+			predicates = append(predicates, fmt.Sprintf("%s < $%s", property, param))
+		}
+	}
+
+	return predicates
+}
@@ -0,0 +1,83 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeSecurityGroupQuery = `
+	MERGE(n_POS_:SecurityGroup {id: $_POS_.id}) SET n_POS_ = {
+		id: 				$_POS_.id,
+		name: 				$_POS_.name,
+		description: 		$_POS_.description,
+		VPCId: 				$_POS_.VPCId,
+		ownerId: 			$_POS_.ownerId,
+		isDefault: 			$_POS_.isDefault,
+		ingressRuleCount: 	$_POS_.ingressRuleCount,
+		egressRuleCount: 	$_POS_.egressRuleCount,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const deleteSecurityGroupRelationsQuery = `
+	MATCH ()-[r:IN_SECURITY_GROUP]->(:SecurityGroup)
+	DELETE r
+`
+
+// The member label can't be a query parameter, it's replaced before running the query
+const mergeSecurityGroupRelationQuery = `
+	MATCH (member_POS_:_LABEL_ {id: $memberId}), (group_POS_:SecurityGroup {id: $groupId})
+	MERGE (member_POS_)-[r_POS_:IN_SECURITY_GROUP]->(group_POS_)
+	WITH r_POS_
+	FINISH
+`
+
+// StoreSecurityGroups stores every group of the region and replaces the IN_SECURITY_GROUP
+// relationships of the assets using them
+func (n *Neo4jDataStore) StoreSecurityGroups(ctx context.Context, groups []aws.SecurityGroup, members map[string][]aws.AssetRef) error {
+	n.logger.Info("Storing security groups")
+	nodes := make([]map[string]any, 0, len(groups))
+
+	for _, group := range groups {
+		nodes = append(nodes, map[string]any{
+			"id":               group.Id,
+			"name":             group.Name,
+			"description":      group.Description,
+			"VPCId":            group.VPC,
+			"ownerId":          group.OwnerId,
+			"isDefault":        group.IsDefault(),
+			"ingressRuleCount": len(group.IngressRules),
+			"egressRuleCount":  len(group.EgressRules),
+		})
+	}
+
+	if err := n.replaceNodes(ctx, "SecurityGroup", mergeSecurityGroupQuery, nodes); err != nil {
+		return err
+	}
+
+	queryParams := make(map[string]map[string]any, len(members))
+	for groupId, groupMembers := range members {
+		for _, member := range groupMembers {
+			pos := fmt.Sprintf("v%d", len(queryParams))
+			query := strings.ReplaceAll(replaceLabel(mergeSecurityGroupRelationQuery, member.AssetType), "_POS_", pos)
+			queryParams[query] = map[string]any{
+				"memberId": member.AssetId,
+				"groupId":  groupId,
+			}
+		}
+	}
+
+	if err := n.write(ctx, deleteSecurityGroupRelationsQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const mergeServiceQuery = `
+	MERGE(n_POS_:Service {name: $_POS_.name}) SET n_POS_ = {
+		name: 		$_POS_.name,
+		protocol: 	$_POS_.protocol,
+		severity: 	$_POS_.severity,
+		version: COALESCE(n_POS_.version, 0) + 1
+	}
+`
+
+const deleteServiceExposureRelationsQuery = `
+	MATCH (:Ec2Instance)-[r:EXPOSES]->(:Service)
+	DELETE r
+`
+
+// Instances are merged again on every fetch, so the list of services exposed to the internet
+// starts empty
+const mergeServiceExposureRelationQuery = `
+	MATCH (inst_POS_:Ec2Instance {id: $instanceId}), (service_POS_:Service {name: $service})
+	MERGE (inst_POS_)-[r_POS_:EXPOSES]->(service_POS_)
+	SET r_POS_.ports = $ports, r_POS_.ipRanges = $ipRanges, r_POS_.sourceGroupIds = $sourceGroupIds,
+		r_POS_.openToInternet = $openToInternet, r_POS_.publicAddress = $publicAddress,
+		r_POS_.sourceClasses = $sourceClasses, r_POS_.untrustedRanges = $untrustedRanges,
+		r_POS_.trustedNetworks = $trustedNetworks
+	SET inst_POS_.exposedServices = CASE WHEN $openToInternet
+		THEN COALESCE(inst_POS_.exposedServices, []) + $service
+		ELSE COALESCE(inst_POS_.exposedServices, []) END
+	WITH r_POS_
+	FINISH
+`
+
+// StoreServiceExposures replaces every EXPOSES relationship between instances and the risky
+// services they accept connections to
+func (n *Neo4jDataStore) StoreServiceExposures(ctx context.Context, exposures []aws.ServiceExposure) error {
+	n.logger.Info("Storing service exposures")
+	services := make(map[string]map[string]any, len(exposures))
+	queryParams := make(map[string]map[string]any, len(exposures))
+
+	for idx, exposure := range exposures {
+		services[exposure.Service] = map[string]any{
+			"name":     exposure.Service,
+			"protocol": exposure.Protocol,
+			"severity": exposure.Severity,
+		}
+
+		query := strings.ReplaceAll(mergeServiceExposureRelationQuery, "_POS_", fmt.Sprintf("v%d", idx))
+		queryParams[query] = map[string]any{
+			"instanceId":      exposure.InstanceId,
+			"service":         exposure.Service,
+			"ports":           exposure.Ports,
+			"ipRanges":        exposure.IpRanges,
+			"sourceGroupIds":  exposure.SourceGroupIds,
+			"openToInternet":  exposure.OpenToInternet,
+			"publicAddress":   exposure.PublicAddress,
+			"sourceClasses":   exposure.SourceClasses(),
+			"untrustedRanges": exposure.UntrustedRanges(),
+			"trustedNetworks": exposure.TrustedNetworks(),
+		}
+	}
+
+	nodes := make([]map[string]any, 0, len(services))
+	for _, service := range services {
+		nodes = append(nodes, service)
+	}
+
+	if err := n.mergeNodes(ctx, mergeServiceQuery, nodes); err != nil {
+		return err
+	}
+
+	if err := n.write(ctx, deleteServiceExposureRelationsQuery, nil); err != nil {
+		return err
+	}
+
+	if err := n.writeMultiple(ctx, queryParams); err != nil {
+		return err
+	}
+
+	n.logger.Info(fmt.Sprintf("Created %d relationships", len(queryParams)))
+
+	return nil
+}
+
+const matchInstancesExposingServiceQuery = `
+	MATCH (n:Ec2Instance)-[r:EXPOSES {openToInternet: true}]->(:Service {name: $service})
+	RETURN n, r
+	ORDER BY n.id
+`
+
+// GetInstancesExposingService returns the instances exposing the service to the internet,
+// with the ports and sources allowed under "exposure"
+func (n *Neo4jDataStore) GetInstancesExposingService(ctx context.Context, service string) ([]map[string]any, error) {
+	records, err := n.read(ctx, matchInstancesExposingServiceQuery, map[string]any{"service": service})
+	if err != nil {
+		return nil, err
+	}
+
+	return extractPropsWithRelationship(records, "n", "r", "exposure"), nil
+}
@@ -0,0 +1,99 @@
+package neo4jstore
+
+import (
+	"asset-relations/core/aws"
+	"context"
+	"time"
+)
+
+const mergeSuppressionQuery = `
+	MERGE(n:Suppression {id: $id}) SET n = {
+		id: 				$id,
+		assetId: 			$assetId,
+		tag: 				$tag,
+		securityGroupId: 	$securityGroupId,
+		rule: 				$rule,
+		owner: 				$owner,
+		justification: 		$justification,
+		expiresAt: 			$expiresAt,
+		createdAt: 			$createdAt
+	}
+`
+
+func (n *Neo4jDataStore) StoreSuppression(ctx context.Context, suppression aws.Suppression) error {
+	n.logger.Info("Storing suppression " + suppression.Id)
+
+	return n.write(ctx, mergeSuppressionQuery, map[string]any{
+		"id":              suppression.Id,
+		"assetId":         suppression.AssetId,
+		"tag":             suppression.Tag,
+		"securityGroupId": suppression.SecurityGroupId,
+		"rule":            suppression.Rule,
+		"owner":           suppression.Owner,
+		"justification":   suppression.Justification,
+		"expiresAt":       suppression.ExpiresAt,
+		"createdAt":       suppression.CreatedAt,
+	})
+}
+
+const matchSuppressionsQuery = `
+	MATCH(n:Suppression)
+	RETURN(n)
+	ORDER BY n.createdAt, n.id
+`
+
+// GetSuppressions returns every suppression, expired ones included
+func (n *Neo4jDataStore) GetSuppressions(ctx context.Context) ([]aws.Suppression, error) {
+	records, err := n.read(ctx, matchSuppressionsQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := extractPropsFromNodes(records, "n")
+	suppressions := make([]aws.Suppression, 0, len(nodes))
+	for _, props := range nodes {
+		suppressions = append(suppressions, aws.Suppression{
+			Id:              propString(props, "id"),
+			AssetId:         propString(props, "assetId"),
+			Tag:             propString(props, "tag"),
+			SecurityGroupId: propString(props, "securityGroupId"),
+			Rule:            propString(props, "rule"),
+			Owner:           propString(props, "owner"),
+			Justification:   propString(props, "justification"),
+			ExpiresAt:       propTime(props, "expiresAt"),
+			CreatedAt:       propTime(props, "createdAt"),
+		})
+	}
+
+	return suppressions, nil
+}
+
+const matchSuppressionQuery = `
+	MATCH(n:Suppression {id: $id})
+	RETURN(n)
+`
+
+const deleteSuppressionQuery = `
+	MATCH(n:Suppression {id: $id})
+	DELETE n
+`
+
+// DeleteSuppression removes the suppression, false if it doesn't exist
+func (n *Neo4jDataStore) DeleteSuppression(ctx context.Context, id string) (bool, error) {
+	records, err := n.read(ctx, matchSuppressionQuery, map[string]any{"id": id})
+	if err != nil || len(records) == 0 {
+		return false, err
+	}
+
+	return true, n.write(ctx, deleteSuppressionQuery, map[string]any{"id": id})
+}
+
+func propString(props map[string]any, key string) string {
+	value, _ := props[key].(string)
+	return value
+}
+
+func propTime(props map[string]any, key string) time.Time {
+	value, _ := props[key].(time.Time)
+	return value
+}
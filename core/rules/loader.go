@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LoadDir reads every .yml and .yaml file of the directory. A file holds a list of rules. Rule
+// ids are the type of their findings, so they can't be one of the reserved built-in types
+func LoadDir(dir string, constraints Constraints) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rules directory: %w", err)
+	}
+
+	loaded := make([]Rule, 0, len(entries))
+	ids := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (extension != ".yml" && extension != ".yaml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		fileRules, err := loadFile(path, constraints)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range fileRules {
+			if slices.Contains(constraints.ReservedIds, rule.Id) {
+				return nil, fmt.Errorf("rule %q of %s clashes with a built-in finding type", rule.Id, path)
+			}
+
+			if previous, duplicated := ids[rule.Id]; duplicated {
+				return nil, fmt.Errorf("rule %q of %s is already defined in %s", rule.Id, path, previous)
+			}
+
+			ids[rule.Id] = path
+			loaded = append(loaded, rule)
+		}
+	}
+
+	return loaded, nil
+}
+
+func loadFile(path string, constraints Constraints) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	var fileRules []Rule
+	if err := yaml.UnmarshalStrict(data, &fileRules); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", path, err)
+	}
+
+	for _, rule := range fileRules {
+		if err := rule.Validate(constraints); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return fileRules, nil
+}
@@ -0,0 +1,69 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var severities = []string{"critical", "high", "medium", "low", "info"}
+
+func TestLoadDirShippedRules(t *testing.T) {
+	loaded, err := LoadDir(filepath.Join("..", "..", "rules"), Constraints{Severities: severities})
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+
+	if len(loaded) == 0 {
+		t.Error("Expected shipped rules to be loaded")
+	}
+}
+
+func TestLoadDirInvalidRule(t *testing.T) {
+	dir := t.TempDir()
+	rule := `
+- id: bad
+  severity: urgent
+  description: Invalid rule
+  match:
+    asset: "Ec2Instance) DETACH DELETE n //"
+    where:
+      - property: exposure
+        equals: public
+        in: [private]
+`
+	if err := os.WriteFile(filepath.Join(dir, "bad.yml"), []byte(rule), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadDir(dir, Constraints{Severities: severities})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	for _, expected := range []string{"invalid severity", "invalid asset", "exactly one operator"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in error %q", expected, err.Error())
+		}
+	}
+}
+
+func TestLoadDirReservedId(t *testing.T) {
+	dir := t.TempDir()
+	rule := `
+- id: ssh-open-to-internet
+  severity: high
+  description: SSH open to the internet
+  match:
+    asset: Ec2Instance
+`
+	if err := os.WriteFile(filepath.Join(dir, "ssh.yml"), []byte(rule), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadDir(dir, Constraints{Severities: severities, ReservedIds: []string{"ssh-open-to-internet"}})
+	if err == nil || !strings.Contains(err.Error(), "clashes with a built-in finding type") {
+		t.Errorf("Expected the id to clash, got %v", err)
+	}
+}
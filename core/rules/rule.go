@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Labels, relationship types and properties end up in queries, so they must be plain identifiers
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const (
+	DirectionOut  = "out"
+	DirectionIn   = "in"
+	DirectionBoth = "both"
+)
+
+// Constraints are what rules are checked against that belongs to the findings rather than the
+// rules: the severities findings can have and the finding types built-in checks already raise
+type Constraints struct {
+	Severities  []string
+	ReservedIds []string
+}
+
+// Rule is a check written in YAML. Every asset matching it gets a finding
+type Rule struct {
+	Id          string `yaml:"id"`
+	Severity    string `yaml:"severity"`
+	Description string `yaml:"description"`
+	Remediation string `yaml:"remediation"`
+	Match       Match  `yaml:"match"`
+}
+
+// Match selects assets of a type by their properties and their relationships to other assets
+type Match struct {
+	Asset   string      `yaml:"asset"`
+	Where   []Condition `yaml:"where"`
+	Related []Related   `yaml:"related"`
+}
+
+// Related requires a relationship to an asset matching the conditions, or its absence when
+// Exists is false
+type Related struct {
+	Relationship string      `yaml:"relationship"`
+	Direction    string      `yaml:"direction"`
+	Asset        string      `yaml:"asset"`
+	Where        []Condition `yaml:"where"`
+	Exists       *bool       `yaml:"exists"`
+}
+
+// Condition compares a property with exactly one of the operators
+type Condition struct {
+	Property    string   `yaml:"property"`
+	Equals      any      `yaml:"equals"`
+	NotEquals   any      `yaml:"not_equals"`
+	In          []any    `yaml:"in"`
+	Contains    any      `yaml:"contains"`
+	Exists      *bool    `yaml:"exists"`
+	GreaterThan *float64 `yaml:"greater_than"`
+	LessThan    *float64 `yaml:"less_than"`
+}
+
+func (r Related) MustExist() bool {
+	return r.Exists == nil || *r.Exists
+}
+
+func (r Rule) Validate(constraints Constraints) error {
+	var errs []error
+
+	if r.Id == "" {
+		errs = append(errs, errors.New("missing id"))
+	}
+
+	if !slices.Contains(constraints.Severities, r.Severity) {
+		errs = append(errs, fmt.Errorf("invalid severity %q", r.Severity))
+	}
+
+	if r.Description == "" {
+		errs = append(errs, errors.New("missing description"))
+	}
+
+	if !identifierRegex.MatchString(r.Match.Asset) {
+		errs = append(errs, fmt.Errorf("invalid asset %q", r.Match.Asset))
+	}
+
+	errs = append(errs, validateConditions(r.Match.Where)...)
+
+	for _, related := range r.Match.Related {
+		if !identifierRegex.MatchString(related.Relationship) {
+			errs = append(errs, fmt.Errorf("invalid relationship %q", related.Relationship))
+		}
+
+		if related.Asset != "" && !identifierRegex.MatchString(related.Asset) {
+			errs = append(errs, fmt.Errorf("invalid related asset %q", related.Asset))
+		}
+
+		switch related.Direction {
+		case "", DirectionOut, DirectionIn, DirectionBoth:
+		default:
+			errs = append(errs, fmt.Errorf("invalid direction %q", related.Direction))
+		}
+
+		errs = append(errs, validateConditions(related.Where)...)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("rule %q: %w", r.Id, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func validateConditions(conditions []Condition) []error {
+	errs := make([]error, 0, len(conditions))
+	for _, condition := range conditions {
+		if !identifierRegex.MatchString(condition.Property) {
+			errs = append(errs, fmt.Errorf("invalid property %q", condition.Property))
+		}
+
+		if operators := condition.operators(); len(operators) != 1 {
+			errs = append(errs, fmt.Errorf("property %q needs exactly one operator, got [%s]", condition.Property, strings.Join(operators, ", ")))
+		}
+	}
+
+	return errs
+}
+
+func (c Condition) operators() []string {
+	operators := make([]string, 0, 1)
+	if c.Equals != nil {
+		operators = append(operators, "equals")
+	}
+	if c.NotEquals != nil {
+		operators = append(operators, "not_equals")
+	}
+	if c.In != nil {
+		operators = append(operators, "in")
+	}
+	if c.Contains != nil {
+		operators = append(operators, "contains")
+	}
+	if c.Exists != nil {
+		operators = append(operators, "exists")
+	}
+	if c.GreaterThan != nil {
+		operators = append(operators, "greater_than")
+	}
+	if c.LessThan != nil {
+		operators = append(operators, "less_than")
+	}
+
+	return operators
+}
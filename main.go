@@ -1,12 +1,15 @@
 package main
 
 import (
+	"asset-relations/application/cli"
 	"asset-relations/application/controller"
 	"asset-relations/application/http"
 	"asset-relations/core/aws"
 	"asset-relations/core/neo4jstore"
+	"asset-relations/core/rules"
 	"asset-relations/support/config"
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 )
@@ -31,9 +34,66 @@ func main() {
 
 	defer store.Close(ctx)
 
-	builder := aws.NewRelationBuilder(logger, cfg.Aws, store)
-	ec2Controller := controller.NewEc2Controller(logger, store, builder)
-	server := http.NewServer(ec2Controller, logger, cfg.Http)
+	services, err := aws.NewServiceCatalog(cfg.Services)
+	if err != nil {
+		logger.Error("Couldn't load the service catalog: " + err.Error())
+		return
+	}
+
+	var loadedRules []rules.Rule
+	if cfg.Rules.Dir != "" {
+		if loadedRules, err = rules.LoadDir(cfg.Rules.Dir, rules.Constraints{
+			Severities:  aws.Severities(),
+			ReservedIds: aws.ReservedFindingTypes(services),
+		}); err != nil {
+			logger.Error("Couldn't load rules: " + err.Error())
+			return
+		}
+
+		logger.Info(fmt.Sprintf("Loaded %d rules", len(loadedRules)))
+	}
+
+	networks, err := aws.NewNetworkClassifier(cfg.TrustedNetworks)
+	if err != nil {
+		logger.Error("Couldn't load trusted networks: " + err.Error())
+		return
+	}
+
+	weights, err := aws.NewRiskWeights(cfg.RiskWeights)
+	if err != nil {
+		logger.Error("Couldn't load risk weights: " + err.Error())
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "plan" {
+		code := cli.NewPlanCommand(logger, store, services, networks).Run(ctx, os.Args[2:])
+		store.Close(ctx)
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "flows" {
+		code := cli.NewFlowsCommand(logger, store).Run(ctx, os.Args[2:])
+		store.Close(ctx)
+		os.Exit(code)
+	}
+
+	builder := aws.NewRelationBuilder(logger, cfg.Aws, store, loadedRules, services, networks, weights)
+	if len(os.Args) > 1 && os.Args[1] == "load" {
+		code := cli.NewLoadCommand(logger, builder).Run(ctx, os.Args[2:])
+		store.Close(ctx)
+		os.Exit(code)
+	}
+
+	ec2Controller := controller.NewEc2Controller(logger, store, builder, services)
+	ipController := controller.NewIpController(logger, store)
+	dnsController := controller.NewDnsController(logger, store)
+	findingController := controller.NewFindingController(logger, store)
+	reachabilityController := controller.NewReachabilityController(logger, store)
+	attackPathController := controller.NewAttackPathController(logger, store)
+	securityGroupController := controller.NewSecurityGroupController(logger, store, networks)
+	suppressionController := controller.NewSuppressionController(logger, store)
+	simulationController := controller.NewSimulationController(logger, store, services, networks)
+	server := http.NewServer(ec2Controller, ipController, dnsController, findingController, reachabilityController, attackPathController, securityGroupController, suppressionController, simulationController, logger, cfg.Http)
 
 	server.ListenAndServe()
 }
@@ -11,6 +11,12 @@ type Config struct {
 	Aws   AwsConfig   `yaml:"aws"`
 	Neo4j Neo4jConfig `yaml:"neo4j"`
 	Http  HTTPConfig  `yaml:"http"`
+	Rules RulesConfig `yaml:"rules"`
+	// Services replaces the built-in catalog of risky services when it's set
+	Services        []ServiceConfig        `yaml:"services"`
+	TrustedNetworks []TrustedNetworkConfig `yaml:"trusted_networks"`
+	// RiskWeights overrides the weight of risk factors, e.g. imdsv1: 20
+	RiskWeights map[string]float64 `yaml:"risk_weights"`
 }
 
 type AwsConfig struct {
@@ -27,6 +33,27 @@ type HTTPConfig struct {
 	Port string `yaml:"port"`
 }
 
+// RulesConfig points to the directory of YAML rules, no rules are evaluated if it's empty
+type RulesConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// ServiceConfig is a risky service whose exposure is tracked on every instance
+type ServiceConfig struct {
+	Name     string  `yaml:"name"`
+	Protocol string  `yaml:"protocol"`
+	Ports    []int32 `yaml:"ports"`
+	Severity string  `yaml:"severity"`
+}
+
+// TrustedNetworkConfig is a named set of ranges, like the office or the VPN. Kind is trusted
+// for our own networks or partner for networks of other companies we work with
+type TrustedNetworkConfig struct {
+	Name  string   `yaml:"name"`
+	Kind  string   `yaml:"kind"`
+	Cidrs []string `yaml:"cidrs"`
+}
+
 func LoadConfig(logger *slog.Logger) (*Config, error) {
 	logger.Info("Loading config.yml")
 